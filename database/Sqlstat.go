@@ -2,7 +2,6 @@ package database
 
 import (
 	"database/sql"
-	"encoding/base64"
 	"fmt"
 	"log"
 	"time"
@@ -24,6 +23,9 @@ type Category struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// MaxLength is how many characters posts in the category may have, or 0
+	// for the configured default.
+	MaxLength int `json:"max_length,omitempty"`
 }
 
 type Comment struct {
@@ -38,31 +40,62 @@ type Comment struct {
 	Avatar    sql.NullString
 	Likes     int
 	Dislikes  int
+	// Reactions totals each reaction type, and MyReaction is the viewer's
+	// own; both are only filled in by AttachCommentReactions.
+	Reactions  map[string]int
+	MyReaction string
+	// ParentID is the comment this one replies to, or zero. Deleted marks a
+	// "[deleted]" placeholder kept for its replies. Depth and ReplyCount are
+	// only filled in for threads.
+	ParentID   int
+	Deleted    bool
+	Depth      int
+	ReplyCount int
+	// EditedAt is when the comment was last edited, if it has been.
+	EditedAt sql.NullTime
+	// PostTitle and Snippet are only filled in by SearchComments.
+	PostTitle string
+	Snippet   string
 }
 
 type Post struct {
-	PostID      int
-	Image       sql.NullString
-	Title       string
-	Content     string
-	PostAt      time.Time
-	UserUserID  int
-	Username    string
-	FirstName   string
-	LastName    string
-	Avatar      sql.NullString
-	Likes       int
-	Dislikes    int
-	Comments    int
-	Categories  []Category
-	ImageBase64 string
+	PostID     int
+	Title      string
+	Content    string
+	PostAt     time.Time
+	UserUserID int
+	Username   string
+	FirstName  string
+	LastName   string
+	Avatar     sql.NullString
+	Likes      int
+	Dislikes   int
+	Comments   int
+	Categories []Category
+	// Attachments are the post's images and other files, in order.
+	Attachments []Attachment
+	// Reactions and MyReaction are only filled in by AttachPostReactions.
+	Reactions  map[string]int
+	MyReaction string
+	// EditedAt is when the post was last edited, if it has been.
+	EditedAt sql.NullTime
+	// Snippet is the content around the words a search matched, with them
+	// between SnippetOpen and SnippetClose. Only searches fill it in.
+	Snippet string
+	// Bookmarked is only filled in by AttachPostBookmarks.
+	Bookmarked bool
 }
 
 type Notification struct {
 	ID        int
 	UserID    int
+	ActorID   int
+	Type      string
 	PostID    int
+	CommentID int
 	Message   string
+	Others    int
+	IsRead    bool
 	CreatedAt time.Time
 	UserImage string
 	UserName  string
@@ -83,15 +116,8 @@ type UserLog struct {
 	Timestamp time.Time
 }
 
-type UserSession struct {
-	ID     int
-	UserID int
-	Start  time.Time
-	End    time.Time
-}
-
 func GetAllCategories(db *sql.DB) ([]Category, error) {
-	rows, err := db.Query("SELECT idcategories, name FROM categories")
+	rows, err := db.Query("SELECT idcategories, name, IFNULL(max_length, 0) FROM categories")
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +126,7 @@ func GetAllCategories(db *sql.DB) ([]Category, error) {
 	var categories []Category
 	for rows.Next() {
 		var category Category
-		if err := rows.Scan(&category.ID, &category.Name); err != nil {
+		if err := rows.Scan(&category.ID, &category.Name, &category.MaxLength); err != nil {
 			return nil, err
 		}
 		categories = append(categories, category)
@@ -113,7 +139,7 @@ func GetAllCategories(db *sql.DB) ([]Category, error) {
 }
 
 func GetComments(db *sql.DB) ([]Comment, error) {
-	rows, err := db.Query("SELECT commentid, content, comment_at, post_postid, user_userid FROM comment")
+	rows, err := db.Query("SELECT commentid, content, comment_at, post_postid, user_userid FROM comment WHERE deleted_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -157,15 +183,15 @@ func GetUserReaction(db *sql.DB, userid int, filter string) ([]Post, error) {
 }
 func GetUserLikedPosts(db *sql.DB, userID int) ([]Post, error) {
 	rows, err := db.Query(`
-        SELECT post.postid, post.image, post.content, post.title, post.post_at,
+        SELECT post.postid, post.content, post.title, post.post_at,
 		        		user.avatar, user.F_name, user.L_name, user.Username,
-		 (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-               (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-               (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
+		 post.like_count AS Likes,
+               post.dislike_count AS Dislikes,
+               post.comment_count AS Comments
         FROM post
-        JOIN likes ON post.postid = likes.post_postid
+        JOIN reactions ON reactions.target_type = 'post' AND reactions.target_id = post.postid
 			JOIN user ON post.user_userid = user.userid 
-        WHERE likes.user_userid = ?
+        WHERE reactions.user_userid = ? AND reactions.kind = 'like' AND post.deleted_at IS NULL
         ORDER BY post.post_at DESC`, userID)
 	if err != nil {
 		log.Println("Error executing query:", err)
@@ -176,7 +202,7 @@ func GetUserLikedPosts(db *sql.DB, userID int) ([]Post, error) {
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.PostID, &post.Image, &post.Content, &post.Title, &post.PostAt, &post.Avatar, &post.FirstName, &post.LastName, &post.Username, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
+		if err := rows.Scan(&post.PostID, &post.Content, &post.Title, &post.PostAt, &post.Avatar, &post.FirstName, &post.LastName, &post.Username, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
 			log.Println("Error scanning row:", err)
 			return nil, err
 		}
@@ -187,9 +213,6 @@ func GetUserLikedPosts(db *sql.DB, userID int) ([]Post, error) {
 			return nil, err
 		}
 		post.Categories = categories
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
-		}
 		posts = append(posts, post)
 	}
 
@@ -198,20 +221,23 @@ func GetUserLikedPosts(db *sql.DB, userID int) ([]Post, error) {
 		return nil, err
 	}
 
+	if err := attachFiles(db, posts); err != nil {
+		return nil, err
+	}
 	return posts, nil
 }
 
 func GetUserDislikedPosts(db *sql.DB, userID int) ([]Post, error) {
 	rows, err := db.Query(`
- SELECT post.postid, post.image, post.content, post.title, post.post_at,
+ SELECT post.postid, post.content, post.title, post.post_at,
         		user.avatar, user.F_name, user.L_name, user.Username,
-		               (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-               (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-               (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
+		               post.like_count AS Likes,
+               post.dislike_count AS Dislikes,
+               post.comment_count AS Comments
         FROM post
-        JOIN dislikes ON post.postid = dislikes.post_postid
+        JOIN reactions ON reactions.target_type = 'post' AND reactions.target_id = post.postid
 			JOIN user ON post.user_userid = user.userid 
-        WHERE dislikes.user_userid = ?
+        WHERE reactions.user_userid = ? AND reactions.kind = 'dislike' AND post.deleted_at IS NULL
         ORDER BY post.post_at DESC
     `, userID)
 	if err != nil {
@@ -223,7 +249,7 @@ func GetUserDislikedPosts(db *sql.DB, userID int) ([]Post, error) {
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.PostID, &post.Image, &post.Content, &post.Title, &post.PostAt, &post.Avatar, &post.FirstName, &post.LastName, &post.Username, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
+		if err := rows.Scan(&post.PostID, &post.Content, &post.Title, &post.PostAt, &post.Avatar, &post.FirstName, &post.LastName, &post.Username, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
 			log.Println("Error scanning row:", err)
 			return nil, err
 		}
@@ -234,9 +260,6 @@ func GetUserDislikedPosts(db *sql.DB, userID int) ([]Post, error) {
 			return nil, err
 		}
 		post.Categories = categories
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
-		}
 		posts = append(posts, post)
 	}
 
@@ -245,6 +268,9 @@ func GetUserDislikedPosts(db *sql.DB, userID int) ([]Post, error) {
 		return nil, err
 	}
 
+	if err := attachFiles(db, posts); err != nil {
+		return nil, err
+	}
 	return posts, nil
 }
 
@@ -255,14 +281,14 @@ func GetUserCommentedPosts(db *sql.DB, userid int, filter string) ([]Post, error
 	}
 
 	query := fmt.Sprintf(`
-        SELECT DISTINCT post.postid, post.image, post.title, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-		               (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-               (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-               (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
+        SELECT DISTINCT post.postid, post.title, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
+		               post.like_count AS Likes,
+               post.dislike_count AS Dislikes,
+               post.comment_count AS Comments
         FROM post
-        JOIN comment ON post.postid = comment.post_postid
+        JOIN comment ON post.postid = comment.post_postid AND comment.deleted_at IS NULL
         JOIN user ON comment.user_userid = user.userid
-        WHERE user.userid = ?
+        WHERE user.userid = ? AND post.deleted_at IS NULL
         ORDER BY post.post_at %s
     `, order)
 
@@ -276,7 +302,7 @@ func GetUserCommentedPosts(db *sql.DB, userid int, filter string) ([]Post, error
 	var postAt string
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.PostID, &post.Image, &post.Title, &post.Content, &post.Title, &postAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
+		if err := rows.Scan(&post.PostID, &post.Title, &post.Content, &post.Title, &postAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
 			return nil, err
 		}
 
@@ -292,9 +318,6 @@ func GetUserCommentedPosts(db *sql.DB, userid int, filter string) ([]Post, error
 			return nil, err
 		}
 		post.Categories = categories
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
-		}
 		posts = append(posts, post)
 	}
 
@@ -302,61 +325,24 @@ func GetUserCommentedPosts(db *sql.DB, userid int, filter string) ([]Post, error
 		return nil, err
 	}
 
+	if err := attachFiles(db, posts); err != nil {
+		return nil, err
+	}
 	return posts, nil
 }
 
-func GetAllPosts(db *sql.DB) ([]Post, error) {
-	rows, err := db.Query(`
-        SELECT post.postid, post.image, post.title, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-               (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-               (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-               (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
-        FROM post
-        JOIN user ON post.user_userid = user.userid
-        ORDER BY post.post_at DESC
-    `)
+// GetAllPosts returns one page of every post, newest first.
+func GetAllPosts(db *sql.DB, page Page) ([]Post, string, error) {
+	posts, next, err := queryPosts(db, postQuery{}, page)
 	if err != nil {
-		log.Println("Error executing query:", err)
-		return nil, err
+		return nil, "", fmt.Errorf("GetAllPosts: %w", err)
 	}
-	defer rows.Close()
-
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		var postAt string
-		if err := rows.Scan(&post.PostID, &post.Image, &post.Title, &post.Content, &post.Title, &postAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
-			log.Println("Error scanning row:", err)
-			return nil, err
-		}
-
-		post.PostAt, err = time.Parse(time.RFC3339, postAt)
-		if err != nil {
-			log.Println("Error parsing post_at:", err)
-			return nil, err
-		}
-
-		// Fetch categories for the post
-		categories, err := GetCategoriesForPost(db, post.PostID)
-		if err != nil {
-			log.Println("Error fetching categories for post:", err)
-			return nil, err
-		}
-		post.Categories = categories
-
-		posts = append(posts, post)
-	}
-	if err := rows.Err(); err != nil {
-		log.Println("Error in rows:", err)
-		return nil, err
-	}
-
-	return posts, nil
+	return posts, next, nil
 }
 
 func GetCategoriesForPost(db *sql.DB, postID int) ([]Category, error) {
 	rows, err := db.Query(`
-        SELECT c.idcategories, c.name
+        SELECT c.idcategories, c.name, IFNULL(c.max_length, 0)
         FROM categories c
         JOIN post_has_categories phc ON c.idcategories = phc.categories_idcategories
         WHERE phc.post_postid = ?
@@ -369,7 +355,7 @@ func GetCategoriesForPost(db *sql.DB, postID int) ([]Category, error) {
 	var categories []Category
 	for rows.Next() {
 		var category Category
-		if err := rows.Scan(&category.ID, &category.Name); err != nil {
+		if err := rows.Scan(&category.ID, &category.Name, &category.MaxLength); err != nil {
 			return nil, err
 		}
 		categories = append(categories, category)
@@ -381,144 +367,61 @@ func GetCategoriesForPost(db *sql.DB, postID int) ([]Category, error) {
 	return categories, nil
 }
 
-func GetAllUsers(db *sql.DB) ([]User, error) {
-	rows, err := db.Query("SELECT userid, F_name, L_name, Username, Email, Avatar, role_id FROM user")
+// GetAllUsers returns one page of users in the order they signed up.
+func GetAllUsers(db *sql.DB, page Page) ([]User, string, error) {
+	after, _, err := page.cursor()
 	if err != nil {
-		log.Println("Error executing query:", err)
-		return nil, err
+		return nil, "", fmt.Errorf("GetAllUsers: %w", err)
+	}
+
+	size := page.size()
+	rows, err := db.Query("SELECT userid, F_name, L_name, Username, Email, Avatar, role_id FROM user WHERE deleted_at IS NULL AND userid > ? ORDER BY userid LIMIT ?", after.ID, size+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetAllUsers: %v", err)
 	}
 	defer rows.Close()
 
 	var users []User
 	for rows.Next() {
 		var user User
-		var avatar sql.NullString
-		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Username, &user.Email, &avatar, &user.RoleID); err != nil {
-			log.Println("Error scanning row:", err)
-			return nil, err
+		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Username, &user.Email, &user.Avatar, &user.RoleID); err != nil {
+			return nil, "", fmt.Errorf("GetAllUsers: %v", err)
 		}
-		user.Avatar = avatar
 		users = append(users, user)
 	}
-
 	if err := rows.Err(); err != nil {
-		log.Println("Error in rows:", err)
-		return nil, err
+		return nil, "", fmt.Errorf("GetAllUsers: %v", err)
 	}
 
-	return users, nil
-}
-
-func GetFilteredPosts(db *sql.DB, filter string) ([]Post, error) {
-	var rows *sql.Rows
-	var err error
-
-	switch filter {
-	case "following":
-		rows, err = db.Query(`
-            SELECT post.postid, post.image, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-                   (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-                   (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-                   (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
-            FROM post
-            JOIN user ON post.user_userid = user.userid
-            ORDER BY post.post_at DESC
-        `)
-	case "friends":
-		rows, err = db.Query(`
-            SELECT post.postid, post.image, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-                   (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-                   (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-                   (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
-            FROM post
-            JOIN user ON post.user_userid = user.userid
-            ORDER BY post.post_at DESC
-        `)
-	case "top-rated":
-		rows, err = db.Query(`
-            SELECT post.postid, post.image, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-                   (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-                   (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-                   (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
-            FROM post
-            JOIN user ON post.user_userid = user.userid
-            ORDER BY Likes DESC, post.post_at DESC
-        `)
-	case "oldest":
-		rows, err = db.Query(`
-            SELECT post.postid, post.image, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-                   (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-                   (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-                   (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
-            FROM post
-            JOIN user ON post.user_userid = user.userid
-            ORDER BY post.post_at ASC
-        `)
-	default:
-		rows, err = db.Query(`
-            SELECT post.postid, post.image, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-                   (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-                   (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-                   (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
-            FROM post
-            JOIN user ON post.user_userid = user.userid
-			ORDER BY post.post_at DESC
-        `, filter)
+	var next string
+	if len(users) > size {
+		users = users[:size]
+		next = cursor{ID: users[size-1].ID}.encode()
 	}
+	return users, next, nil
+}
 
+// GetFilteredPosts returns one page of every post in the order filter names:
+// "oldest", "top-rated", or newest first for anything else.
+func GetFilteredPosts(db *sql.DB, filter string, page Page) ([]Post, string, error) {
+	posts, next, err := queryPosts(db, postQuery{order: postOrderFor(filter)}, page)
 	if err != nil {
-		log.Println("Error executing query:", err)
-		return nil, err
+		return nil, "", fmt.Errorf("GetFilteredPosts: %w", err)
 	}
-	defer rows.Close()
-
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		var postAt string
-		if err := rows.Scan(&post.PostID, &post.Image, &post.Content, &post.Title, &postAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
-			log.Println("Error scanning row:", err)
-			return nil, err
-		}
-
-		post.PostAt, err = time.Parse(time.RFC3339, postAt)
-		if err != nil {
-			log.Println("Error parsing post_at:", err)
-			return nil, err
-		}
-
-		categories, err := GetCategoriesForPost(db, post.PostID)
-		if err != nil {
-			log.Println("Error fetching categories for post:", err)
-			return nil, err
-		}
-		post.Categories = categories
-
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
-		}
-
-		posts = append(posts, post)
-	}
-	if err := rows.Err(); err != nil {
-		log.Println("Error in rows:", err)
-		return nil, err
-	}
-
-	return posts, nil
+	return posts, next, nil
 }
 
 func GetPostsByMultiCategory(db *sql.DB, categoryName string) ([]Post, error) {
 	rows, err := db.Query(`
-        SELECT post.postid, post.image, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-               (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-               (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-               (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
+        SELECT post.postid, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
+               post.like_count AS Likes,
+               post.dislike_count AS Dislikes,
+               post.comment_count AS Comments
         FROM post
         JOIN user ON post.user_userid = user.userid
         JOIN post_has_categories phc ON post.postid = phc.post_postid
         JOIN categories c ON phc.categories_idcategories = c.idcategories
-   		WHERE c.name = ?
+   		WHERE c.name = ? AND post.deleted_at IS NULL
         ORDER BY post.post_at DESC
     `, categoryName)
 	if err != nil {
@@ -531,7 +434,7 @@ func GetPostsByMultiCategory(db *sql.DB, categoryName string) ([]Post, error) {
 	for rows.Next() {
 		var post Post
 		var postAt string
-		if err := rows.Scan(&post.PostID, &post.Image, &post.Content, &post.Title, &postAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
+		if err := rows.Scan(&post.PostID, &post.Content, &post.Title, &postAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
 			log.Println("Error scanning row:", err)
 			return nil, err
 		}
@@ -548,9 +451,6 @@ func GetPostsByMultiCategory(db *sql.DB, categoryName string) ([]Post, error) {
 			return nil, err
 		}
 		post.Categories = categories
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
-		}
 		posts = append(posts, post)
 	}
 	if err := rows.Err(); err != nil {
@@ -558,104 +458,41 @@ func GetPostsByMultiCategory(db *sql.DB, categoryName string) ([]Post, error) {
 		return nil, err
 	}
 
-	return posts, nil
-}
-
-func GetPostsByCategory(db *sql.DB, categoryName string) ([]Post, error) {
-	rows, err := db.Query(`
-        SELECT post.postid, post.image, post.content, post.title, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-               (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-               (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-               (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
-        FROM post
-        JOIN user ON post.user_userid = user.userid
-        JOIN post_has_categories phc ON post.postid = phc.post_postid
-        JOIN categories c ON phc.categories_idcategories = c.idcategories
-        WHERE c.name = ?
-        ORDER BY post.post_at DESC
-    `, categoryName)
-	if err != nil {
-		log.Println("Error executing query:", err)
+	if err := attachFiles(db, posts); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		var postAt string
-		if err := rows.Scan(&post.PostID, &post.Image, &post.Content, &post.Title, &postAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
-			log.Println("Error scanning row:", err)
-			return nil, err
-		}
-
-		post.PostAt, err = time.Parse(time.RFC3339, postAt)
-		if err != nil {
-			log.Println("Error parsing post_at:", err)
-			return nil, err
-		}
-
-		categories, err := GetCategoriesForPost(db, post.PostID)
-		if err != nil {
-			log.Println("Error fetching categories for post:", err)
-			return nil, err
-		}
-		post.Categories = categories
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
-		}
-		posts = append(posts, post)
-	}
-	if err := rows.Err(); err != nil {
-		log.Println("Error in rows:", err)
-		return nil, err
-	}
-
 	return posts, nil
 }
 
-func GetLastNotifications(db *sql.DB, userID int) ([]Notification, error) {
-	rows, err := db.Query(`
-        SELECT n.notificationid, n.user_userid, n.post_id, n.message, n.created_at, u.Avatar, u.Username
-        FROM notifications n
-        JOIN user u ON n.user_userid = u.userid
-        WHERE n.user_userid = ?
-        ORDER BY n.created_at DESC
-    `, userID)
+func GetPostsByCategory(db *sql.DB, categoryName string, page Page) ([]Post, string, error) {
+	posts, next, err := queryPosts(db, postQuery{
+		where: []string{`post.postid IN (
+            SELECT phc.post_postid FROM post_has_categories phc
+            JOIN categories c ON phc.categories_idcategories = c.idcategories
+            WHERE c.name = ?)`},
+		args: []any{categoryName},
+	}, page)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var notifications []Notification
-	for rows.Next() {
-		var notification Notification
-		var avatar sql.NullString
-
-		err := rows.Scan(&notification.ID, &notification.UserID, &notification.PostID, &notification.Message, &notification.CreatedAt, &avatar, &notification.UserName)
-		if err != nil {
-			return nil, err
-		}
-
-		notification.UserImage = avatar.String
-		notifications = append(notifications, notification)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("GetPostsByCategory: %w", err)
 	}
+	return posts, next, nil
+}
 
-	return notifications, nil
+func GetLastNotifications(db *sql.DB, userID int) ([]Notification, error) {
+	notifications, _, err := queryNotifications(db, userID, nil, 10)
+	return notifications, err
 }
 
-func InsertPost(db *sql.DB, content string, title string, image []byte, userID string) (int, error) {
-	stmt, err := db.Prepare("INSERT INTO post (image, content, title, post_at, user_userid) VALUES (?, ?,?, ?, ?)")
+// InsertPost adds a post along with its attachments, in order. Their files
+// must already be recorded with SaveMedia.
+func InsertPost(db *sql.DB, content string, title string, userID int, attachments []Attachment) (int, error) {
+	tx, err := db.Begin()
 	if err != nil {
 		return 0, err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	res, err := stmt.Exec(image, content, title, time.Now(), userID)
+	res, err := tx.Exec("INSERT INTO post (content, title, post_at, user_userid) VALUES (?, ?, ?, ?)", content, title, time.Now(), userID)
 	if err != nil {
 		return 0, err
 	}
@@ -665,6 +502,12 @@ func InsertPost(db *sql.DB, content string, title string, image []byte, userID s
 		return 0, err
 	}
 
+	if err := insertAttachments(tx, int(lastID), attachments); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
 	return int(lastID), nil
 }
 
@@ -679,75 +522,45 @@ func InsertPostCategory(db *sql.DB, postID int, categoryID int) error {
 	return err
 }
 
-func GetUserPosts(db *sql.DB, userID int, filter string) ([]Post, error) {
-	var x string
+// GetUserPosts returns one page of a user's posts, newest first or with
+// filter "oldest" oldest first.
+func GetUserPosts(db *sql.DB, userID int, filter string, page Page) ([]Post, string, error) {
+	order := newestFirst
 	if filter == "oldest" {
-		x = "post.post_at ASC"
-	} else {
-		x = "post.post_at DESC"
-	}
-
-	rows, err := db.Query(`SELECT 
-		post.postid, post.content, post.title, post.image, post.post_at, post.user_userid, 
-		user.avatar, user.F_name, user.L_name, user.Username,
-				 (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-               (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-               (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments 
-	FROM post 
-	JOIN user ON post.user_userid = user.userid 
-	WHERE post.user_userid = ? ORDER BY `+x, userID)
-	if err != nil {
-		return nil, err
+		order = oldestFirst
 	}
-	defer rows.Close()
-
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		if err := rows.Scan(&post.PostID, &post.Content, &post.Title, &post.Image, &post.PostAt, &post.UserUserID, &post.Avatar, &post.FirstName, &post.LastName, &post.Username, &post.Likes, &post.Dislikes, &post.Comments); err != nil {
-			return nil, err
-		}
-
-		categories, err := GetCategoriesForPost(db, post.PostID)
-		if err != nil {
-			log.Println("Error fetching categories for post:", err)
-			return nil, err
-		}
-		post.Categories = categories
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
-		}
-		posts = append(posts, post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
+	posts, next, err := queryPosts(db, postQuery{
+		where: []string{"post.user_userid = ?"},
+		args:  []any{userID},
+		order: order,
+	}, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetUserPosts: %w", err)
 	}
-
-	return posts, nil
+	return posts, next, nil
 }
 
 func GetFollowersCount(db *sql.DB, userID int) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM followers WHERE user_userid = ?", userID).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM followers JOIN user ON followers.follower_id = user.userid WHERE following_id = ? AND user.deleted_at IS NULL", userID).Scan(&count)
 	return count, err
 }
 
 func GetFollowingCount(db *sql.DB, userID int) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM following WHERE user_userid = ?", userID).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM followers JOIN user ON followers.following_id = user.userid WHERE follower_id = ? AND user.deleted_at IS NULL", userID).Scan(&count)
 	return count, err
 }
 
 func GetFriendsCount(db *sql.DB, userID int) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM friends WHERE user_userid = ?", userID).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM friends JOIN user ON friends.friend_userid = user.userid WHERE user_userid = ? AND user.deleted_at IS NULL", userID).Scan(&count)
 	return count, err
 }
 
 func IsFollowing(db *sql.DB, userID int, profileUserID int) (bool, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM followers WHERE user_userid = ? AND follower_userid = ?", profileUserID, userID).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM followers WHERE following_id = ? AND follower_id = ?", profileUserID, userID).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -756,13 +569,13 @@ func IsFollowing(db *sql.DB, userID int, profileUserID int) (bool, error) {
 
 func GetTotalUsersCount(db *sql.DB) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM user").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM user WHERE deleted_at IS NULL").Scan(&count)
 	return count, err
 }
 
 func GetTotalPostsCount(db *sql.DB) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM post").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM post WHERE deleted_at IS NULL").Scan(&count)
 	return count, err
 }
 
@@ -773,7 +586,9 @@ func GetTotalCategoriesCount(db *sql.DB) (int, error) {
 }
 
 func GetAllReports(db *sql.DB) ([]Report, error) {
-	rows, err := db.Query("SELECT id, post_id, reported_by, report_reason, created_at FROM reports ORDER BY created_at DESC")
+	rows, err := db.Query(`SELECT id, post_id, reported_by, report_reason, created_at FROM reports
+	    WHERE post_id NOT IN (SELECT postid FROM post WHERE deleted_at IS NOT NULL)
+	    ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -795,116 +610,58 @@ func GetAllReports(db *sql.DB) ([]Report, error) {
 	return reports, nil
 }
 
-func GetCommentsForPost(db *sql.DB, postID int) ([]Comment, error) {
-	var comments []Comment
-
-	query := `SELECT comment.commentid, comment.post_postid, comment.user_userid, user.F_name, user.L_name, user.Username, comment.content, comment.comment_at, user.Avatar,
-	 		  (SELECT COUNT(*) FROM comment_dislikes WHERE comment_dislikes.commentid = comment.commentid) AS Dislikes,
-			  (SELECT COUNT(*) FROM comment_likes WHERE comment_likes.commentid = comment.commentid) AS Likes
-              FROM comment
-              JOIN user ON comment.user_userid = user.userid
-			  WHERE comment.post_postid = ?`
-	rows, err := db.Query(query, postID)
-	if err != nil {
-		return nil, fmt.Errorf("GetCommentsForPost: %v", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var comment Comment
-		var commentAt time.Time
-
-		// Scan each row into the Comment struct
-		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.FirstName, &comment.LastName, &comment.Username, &comment.Content, &commentAt, &comment.Avatar, &comment.Dislikes, &comment.Likes); err != nil {
-			return nil, fmt.Errorf("GetCommentsForPost: %v", err)
-		}
-
-		comment.CreatedAt = commentAt
-
-		comments = append(comments, comment)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("GetCommentsForPost: %v", err)
-	}
-
-	return comments, nil
-}
-
-func ToggleLike(db *sql.DB, postID int, userID int) error {
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM likes WHERE post_postid = ? AND user_userid = ?)", postID, userID).Scan(&exists)
+// GetCommentsForPost returns a page of postID's top level comments, oldest
+// first, each followed by its replies as loadThreads lays them out.
+func GetCommentsForPost(db *sql.DB, postID int, page Page) ([]Comment, string, error) {
+	after, more, err := page.cursor()
 	if err != nil {
-		return fmt.Errorf("ToggleLike: %v", err)
+		return nil, "", fmt.Errorf("GetCommentsForPost: %w", err)
 	}
 
-	if exists {
-		_, err = db.Exec("DELETE FROM likes WHERE post_postid = ? AND user_userid = ?", postID, userID)
-	} else {
-		_, err = db.Exec("DELETE FROM dislikes WHERE post_postid = ? AND user_userid = ?", postID, userID)
-		if err != nil {
-			return fmt.Errorf("ToggleLike: %v", err)
-		}
-		_, err = db.Exec("INSERT INTO likes (post_postid, like_at, user_userid) VALUES (?, ?, ?)", postID, time.DateTime, userID)
+	// Deleted comments only matter as placeholders for their replies.
+	where, args := `post_postid = ? AND parent_comment_id IS NULL
+	    AND (deleted_at IS NULL OR EXISTS (SELECT 1 FROM comment AS reply WHERE reply.parent_comment_id = comment.commentid))`, []any{postID}
+	if more {
+		where += " AND (comment_at, commentid) > (?, ?)"
+		args = append(args, after.At, after.ID)
 	}
-	return err
-}
+	size := page.size()
 
-func ToggleDislike(db *sql.DB, postID int, userID int) error {
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM dislikes WHERE post_postid = ? AND user_userid = ?)", postID, userID).Scan(&exists)
+	rows, err := db.Query(`SELECT commentid, CAST(comment_at AS TEXT)
+              FROM comment
+			  WHERE `+where+`
+			  ORDER BY comment_at, commentid
+			  LIMIT ?`, append(args, size+1)...)
 	if err != nil {
-		return fmt.Errorf("ToggleDislike: %v", err)
+		return nil, "", fmt.Errorf("GetCommentsForPost: %v", err)
 	}
+	defer rows.Close()
 
-	if exists {
-		_, err = db.Exec("DELETE FROM dislikes WHERE post_postid = ? AND user_userid = ?", postID, userID)
-	} else {
-		_, err = db.Exec("DELETE FROM likes WHERE post_postid = ? AND user_userid = ?", postID, userID)
-		if err != nil {
-			return fmt.Errorf("ToggleDislike: %v", err)
+	var roots []int
+	var keys []string
+	for rows.Next() {
+		var id int
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			return nil, "", fmt.Errorf("GetCommentsForPost: %v", err)
 		}
-		_, err = db.Exec("INSERT INTO dislikes (post_postid, dislike_at, user_userid) VALUES (?, ?, ?)", postID, time.DateTime, userID)
+		roots = append(roots, id)
+		keys = append(keys, key)
 	}
-	return err
-}
-
-func ToggleCommentLike(db *sql.DB, commentID int, userID int) error {
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM comment_likes WHERE commentid = ? AND userid = ?)", commentID, userID).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("ToggleCommentLike: %v", err)
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("GetCommentsForPost: %v", err)
 	}
 
-	if exists {
-		_, err = db.Exec("DELETE FROM comment_likes WHERE commentid = ? AND userid = ?", commentID, userID)
-	} else {
-		_, err = db.Exec("DELETE FROM comment_dislikes WHERE commentid = ? AND userid = ?", commentID, userID)
-		if err != nil {
-			return fmt.Errorf("ToggleCommentLike: %v", err)
-		}
-		_, err = db.Exec("INSERT INTO comment_likes (commentid, like_at, userid) VALUES (?, ?, ?)", commentID, time.DateTime, userID)
+	var next string
+	if len(roots) > size {
+		roots = roots[:size]
+		next = cursor{At: keys[size-1], ID: roots[size-1]}.encode()
 	}
-	return err
-}
-
-func ToggleCommentDislike(db *sql.DB, commentID int, userID int) error {
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM comment_dislikes WHERE commentid = ? AND userid = ?)", commentID, userID).Scan(&exists)
+	comments, err := loadThreads(db, roots)
 	if err != nil {
-		return fmt.Errorf("ToggleCommentDislike: %v", err)
+		return nil, "", fmt.Errorf("GetCommentsForPost: %v", err)
 	}
-
-	if exists {
-		_, err = db.Exec("DELETE FROM comment_dislikes WHERE commentid = ? AND userid = ?", commentID, userID)
-	} else {
-		_, err = db.Exec("DELETE FROM comment_likes WHERE commentid = ? AND userid = ?", commentID, userID)
-		if err != nil {
-			return fmt.Errorf("ToggleCommentDislike: %v", err)
-		}
-		_, err = db.Exec("INSERT INTO comment_dislikes (commentid, dislike_at, userid) VALUES (?, ?, ?)", commentID, time.DateTime, userID)
-	}
-	return err
+	return comments, next, nil
 }
 
 func GetUserLogs(db *sql.DB, userID int) ([]UserLog, error) {
@@ -925,33 +682,12 @@ func GetUserLogs(db *sql.DB, userID int) ([]UserLog, error) {
 	return logs, nil
 }
 
-func GetUserSessions(db *sql.DB, userID int) ([]UserSession, error) {
-	rows, err := db.Query("SELECT sessionid, userid, start, end FROM sessions WHERE userid = ?", userID)
-	if err != nil {
-		log.Println("Failed to fetch user sessions:", err)
-		return nil, err
-	}
-	defer rows.Close()
-
-	var sessions []UserSession
-	for rows.Next() {
-		var session UserSession
-		err := rows.Scan(&session.ID, &session.UserID, &session.Start, &session.End)
-		if err != nil {
-			log.Println("Failed to scan user session:", err)
-			return nil, err
-		}
-		sessions = append(sessions, session)
-	}
-	return sessions, nil
-}
-
 func GetFollowers(db *sql.DB, userID int) ([]User, error) {
 	rows, err := db.Query(`
         SELECT user.userid, user.F_name, user.L_name, user.Username, user.Avatar
         FROM followers
-        JOIN user ON followers.follower_userid = user.userid
-        WHERE followers.user_userid = ?
+        JOIN user ON followers.follower_id = user.userid
+        WHERE followers.following_id = ? AND user.deleted_at IS NULL
     `, userID)
 	if err != nil {
 		return nil, err
@@ -972,9 +708,9 @@ func GetFollowers(db *sql.DB, userID int) ([]User, error) {
 func GetFollowing(db *sql.DB, userID int) ([]User, error) {
 	rows, err := db.Query(`
         SELECT user.userid, user.F_name, user.L_name, user.Username, user.Avatar
-        FROM following
-        JOIN user ON following.following_userid = user.userid
-        WHERE following.user_userid = ?
+        FROM followers
+        JOIN user ON followers.following_id = user.userid
+        WHERE followers.follower_id = ? AND user.deleted_at IS NULL
     `, userID)
 	if err != nil {
 		return nil, err
@@ -997,7 +733,7 @@ func GetFriends(db *sql.DB, userID int) ([]User, error) {
         SELECT user.userid, user.F_name, user.L_name, user.Username, user.Avatar
         FROM friends
         JOIN user ON friends.friend_userid = user.userid
-        WHERE friends.user_userid = ?
+        WHERE friends.user_userid = ? AND user.deleted_at IS NULL
     `, userID)
 	if err != nil {
 		return nil, err
@@ -1017,19 +753,19 @@ func GetFriends(db *sql.DB, userID int) ([]User, error) {
 
 func GetTotalLikes(db *sql.DB, userID int) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM likes WHERE user_userid = ?", userID).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM reactions WHERE user_userid = ? AND target_type = 'post' AND kind = 'like'", userID).Scan(&count)
 	return count, err
 }
 
 func GetTotalPosts(db *sql.DB, userID int) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userid = ?", userID).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userid = ? AND deleted_at IS NULL", userID).Scan(&count)
 	return count, err
 }
 
 func GetUserByID(db *sql.DB, userID int) (User, error) {
 	var user User
-	err := db.QueryRow("SELECT userid, F_name, L_name, Username, Email, Avatar, role_id FROM user WHERE userid = ?", userID).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Username, &user.Email, &user.Avatar, &user.RoleID)
+	err := db.QueryRow("SELECT userid, F_name, L_name, Username, Email, Avatar, role_id FROM user WHERE userid = ? AND deleted_at IS NULL", userID).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Username, &user.Email, &user.Avatar, &user.RoleID)
 	if err != nil {
 		return user, err
 	}
@@ -1046,121 +782,312 @@ func GetRoleNameByID(db *sql.DB, roleID int) (string, error) {
 	return roleName, nil
 }
 
-func GetFriendsPosts(db *sql.DB, userID int) ([]Post, error) {
-	query := `
-        SELECT DISTINCT p.*, u.Username, u.F_name, u.L_name, u.Avatar,
-            (SELECT COUNT(*) FROM likes WHERE post_postid = p.postid) as likes,
-            (SELECT COUNT(*) FROM dislikes WHERE post_postid = p.postid) as dislikes,
-            (SELECT COUNT(*) FROM comment WHERE post_postid = p.postid) as comments
-        FROM post p
-        JOIN user u ON p.user_userid = u.userid
-        JOIN friends f ON (f.user_userid = ? AND f.friend_userid = p.user_userid)
-            OR (f.friend_userid = ? AND f.user_userid = p.user_userid)
-        ORDER BY p.post_at DESC
-    `
-
-	rows, err := db.Query(query, userID, userID)
+func GetFriendsPosts(db *sql.DB, userID int, page Page) ([]Post, string, error) {
+	posts, next, err := queryPosts(db, postQuery{
+		where: []string{`post.user_userid IN (
+            SELECT friend_userid FROM friends WHERE user_userid = ?
+            UNION SELECT user_userid FROM friends WHERE friend_userid = ?)`},
+		args: []any{userID, userID},
+	}, page)
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("GetFriendsPosts: %w", err)
 	}
-	defer rows.Close()
+	return posts, next, nil
+}
 
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		err := rows.Scan(
-			&post.PostID,
-			&post.Image,
-			&post.Content,
-			&post.Title,
-			&post.PostAt,
-			&post.UserUserID,
-			&post.Username,
-			&post.FirstName,
-			&post.LastName,
-			&post.Avatar,
-			&post.Likes,
-			&post.Dislikes,
-			&post.Comments,
-		)
+func GetFollowingPosts(db *sql.DB, userID int, page Page) ([]Post, string, error) {
+	posts, next, err := queryPosts(db, postQuery{
+		where: []string{"post.user_userid IN (SELECT following_id FROM followers WHERE follower_id = ?)"},
+		args:  []any{userID},
+	}, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetFollowingPosts: %w", err)
+	}
+	return posts, next, nil
+}
+
+func GetUserCredentials(db *sql.DB, email string) (User, error) {
+	var user User
+	err := db.QueryRow("SELECT userid, password, username FROM user WHERE email = ? AND deleted_at IS NULL", email).Scan(&user.ID, &user.Password, &user.Username)
+	return user, err
+}
+
+func UsernameExists(db *sql.DB, username string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE username = ?)", username).Scan(&exists)
+	return exists, err
+}
+
+func EmailExists(db *sql.DB, email string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE email = ?)", email).Scan(&exists)
+	return exists, err
+}
+
+func InsertUser(db *sql.DB, user User, provider string, session UserSession) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("InsertUser: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO user (F_name, L_name, Username, Email, password, role_id, Avatar, provider) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		user.FirstName, user.LastName, user.Username, user.Email, user.Password, user.RoleID, user.Avatar, provider)
+	if err != nil {
+		return 0, fmt.Errorf("InsertUser: %v", err)
+	}
+
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("InsertUser: %v", err)
+	}
+
+	session.UserID = int(lastID)
+	if err = insertSession(tx, session); err != nil {
+		return 0, fmt.Errorf("InsertUser: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("InsertUser: %v", err)
+	}
+	return int(lastID), nil
+}
+
+type OAuthAccount struct {
+	Provider     string
+	ExternalID   string
+	FirstName    string
+	LastName     string
+	Username     string
+	Email        string
+	PasswordHash string
+	Avatar       string
+	RoleID       int
+}
+
+func UpsertOAuthUser(db *sql.DB, account OAuthAccount, session UserSession) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var deleted bool
+	err = tx.QueryRow("SELECT userid, deleted_at IS NOT NULL FROM user WHERE email = ?", account.Email).Scan(&userID, &deleted)
+	if err == nil && deleted {
+		return 0, ErrAccountDeleted
+	}
+	if err == sql.ErrNoRows {
+		firstName := account.FirstName
+		if account.Provider == "Github" {
+			firstName = account.Username
+		}
+		res, err := tx.Exec("INSERT INTO user (F_name, L_name, Username, Email, password, role_id, Avatar, provider) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			firstName, account.LastName, account.Username, account.Email, account.PasswordHash, account.RoleID, account.Avatar, account.Provider)
 		if err != nil {
-			return nil, err
+			return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
 		}
-
-		// Get categories for this post
-		categories, err := GetCategoriesForPost(db, post.PostID)
+		lastID, err := res.LastInsertId()
 		if err != nil {
-			return nil, err
+			return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
 		}
-		post.Categories = categories
+		userID = int(lastID)
+	} else if err != nil {
+		return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
+	} else {
+		_, err = tx.Exec("UPDATE user SET provider = ? WHERE userid = ?", account.Provider, userID)
+		if err != nil {
+			return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
+		}
+	}
 
-		// Convert image to base64 if present
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
+	switch account.Provider {
+	case "Github":
+		var exists bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM github WHERE user_userid = ?)", userID).Scan(&exists)
+		if err != nil {
+			return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
+		}
+		if exists {
+			_, err = tx.Exec("UPDATE github SET gitAvatar = ? WHERE user_userid = ?", account.Avatar, userID)
+		} else {
+			_, err = tx.Exec("INSERT INTO github (gituserid, gitF_name, gitL_name, gitUsername, gitEmail, gitpassword, gitAvatar, user_userid) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				account.ExternalID, "", "", account.Username, account.Email, account.PasswordHash, account.Avatar, userID)
+		}
+	case "Google":
+		var exists bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM google WHERE user_userid = ?)", userID).Scan(&exists)
+		if err != nil {
+			return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
+		}
+		if exists {
+			_, err = tx.Exec("UPDATE google SET googleF_name = ?, googleL_name = ?, googleAvatar = ? WHERE user_userid = ?",
+				account.FirstName, account.LastName, account.Avatar, userID)
+		} else {
+			_, err = tx.Exec("INSERT INTO google (google_api_id, googleF_name, googleL_name, googleUsername, googleEmail, googlepassword, googleAvatar, user_userid) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				account.ExternalID, account.FirstName, account.LastName, account.Username, account.Email, account.PasswordHash, account.Avatar, userID)
 		}
+	default:
+		return 0, fmt.Errorf("UpsertOAuthUser: unknown provider %q", account.Provider)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
+	}
 
-		posts = append(posts, post)
+	session.UserID = userID
+	if err = insertSession(tx, session); err != nil {
+		return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
 	}
 
-	return posts, nil
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("UpsertOAuthUser: %v", err)
+	}
+	return userID, nil
+}
+
+func GetUserPassword(db *sql.DB, userID int) (string, error) {
+	var password string
+	err := db.QueryRow("SELECT password FROM user WHERE userid = ?", userID).Scan(&password)
+	return password, err
+}
+
+func UpdateUserPassword(db *sql.DB, userID int, passwordHash string) error {
+	_, err := db.Exec("UPDATE user SET password = ? WHERE userid = ?", passwordHash, userID)
+	return err
+}
+
+func UpdateUserProfile(db *sql.DB, user User) error {
+	_, err := db.Exec("UPDATE user SET F_name = ?, L_name = ?, Username = ?, Email = ?, Avatar = COALESCE(?, Avatar) WHERE userid = ?",
+		user.FirstName, user.LastName, user.Username, user.Email, user.Avatar, user.ID)
+	return err
+}
+
+func UpdateUserRole(db *sql.DB, userID int, roleID int) error {
+	_, err := db.Exec("UPDATE user SET role_id = ? WHERE userid = ?", roleID, userID)
+	return err
 }
 
-func GetFollowingPosts(db *sql.DB, userID int) ([]Post, error) {
-	query := `
-        SELECT DISTINCT p.postid, p.image, p.content, p.title, p.post_at, p.user_userid,
-            u.Username, u.F_name, u.L_name, u.Avatar,
-            (SELECT COUNT(*) FROM likes WHERE post_postid = p.postid) as likes,
-            (SELECT COUNT(*) FROM dislikes WHERE post_postid = p.postid) as dislikes,
-            (SELECT COUNT(*) FROM comment WHERE post_postid = p.postid) as comments
-        FROM post p
-        JOIN user u ON p.user_userid = u.userid
-        JOIN followers f ON f.user_userid = p.user_userid
-        WHERE f.follower_userid = ?
-        ORDER BY p.post_at DESC
-    `
-
-	rows, err := db.Query(query, userID)
+func GetPostByID(db *sql.DB, postID int) (Post, error) {
+	var post Post
+	err := db.QueryRow(`
+        SELECT post.postid, post.title, post.content, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
+               post.like_count AS Likes,
+               post.dislike_count AS Dislikes,
+               post.comment_count AS Comments,
+               post.edited_at
+        FROM post
+        JOIN user ON post.user_userid = user.userid
+        WHERE post.postid = ? AND post.deleted_at IS NULL
+    `, postID).Scan(&post.PostID, &post.Title, &post.Content, &post.PostAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments, &post.EditedAt)
 	if err != nil {
-		return nil, err
+		return post, err
 	}
-	defer rows.Close()
+	posts := []Post{post}
+	if err := attachFiles(db, posts); err != nil {
+		return post, err
+	}
+	return posts[0], nil
+}
 
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		err := rows.Scan(
-			&post.PostID,
-			&post.Image,
-			&post.Content,
-			&post.Title,
-			&post.PostAt,
-			&post.UserUserID,
-			&post.Username,
-			&post.FirstName,
-			&post.LastName,
-			&post.Avatar,
-			&post.Likes,
-			&post.Dislikes,
-			&post.Comments,
-		)
-		if err != nil {
-			return nil, err
-		}
+// InsertComment adds a comment to postID, replying to parentID unless it is
+// zero. It returns sql.ErrNoRows if the post doesn't exist or was deleted, and
+// ErrBadParent if the parent is not a live comment on the same post.
+func InsertComment(db *sql.DB, postID int, parentID int, userID int, content string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-		// Get categories for this post
-		categories, err := GetCategoriesForPost(db, post.PostID)
+	var postOK bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM post WHERE postid = ? AND deleted_at IS NULL)", postID).Scan(&postOK)
+	if err != nil {
+		return 0, err
+	}
+	if !postOK {
+		return 0, sql.ErrNoRows
+	}
+
+	var parent any
+	if parentID != 0 {
+		var ok bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM comment WHERE commentid = ? AND post_postid = ? AND deleted_at IS NULL)", parentID, postID).Scan(&ok)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
-		post.Categories = categories
-
-		// Convert image to base64 if present
-		if post.Image.Valid {
-			post.ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
+		if !ok {
+			return 0, ErrBadParent
 		}
+		parent = parentID
+	}
 
-		posts = append(posts, post)
+	res, err := tx.Exec("INSERT INTO comment (content, comment_at, post_postid, user_userid, parent_comment_id) VALUES (?, ?, ?, ?, ?)", content, time.Now(), postID, userID, parent)
+	if err != nil {
+		return 0, err
+	}
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE post SET comment_count = comment_count + 1 WHERE postid = ?", postID); err != nil {
+		return 0, err
 	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(lastID), nil
+}
 
-	return posts, nil
+func InsertCategory(db *sql.DB, name string) error {
+	_, err := db.Exec("INSERT INTO categories (name) VALUES (?)", name)
+	return err
+}
+
+// SetCategoryMaxLength sets the longest post allowed in a category. A
+// maxLength of 0 goes back to the configured default.
+func SetCategoryMaxLength(db *sql.DB, categoryID, maxLength int) error {
+	res, err := db.Exec("UPDATE categories SET max_length = NULLIF(?, 0) WHERE idcategories = ?", maxLength, categoryID)
+	if err != nil {
+		return fmt.Errorf("SetCategoryMaxLength: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("SetCategoryMaxLength: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func DeleteCategory(db *sql.DB, categoryID int) error {
+	_, err := db.Exec("DELETE FROM categories WHERE idcategories = ?", categoryID)
+	return err
+}
+
+func InsertReport(db *sql.DB, postID int, reportedBy int, reason string) error {
+	_, err := db.Exec("INSERT INTO reports (post_id, reported_by, report_reason) VALUES (?, ?, ?)", postID, reportedBy, reason)
+	return err
+}
+
+func InsertCommentReport(db *sql.DB, commentID int, reportedBy int, reason string) error {
+	_, err := db.Exec("INSERT INTO reports (comment_id, reported_by, report_reason) VALUES (?, ?, ?)", commentID, reportedBy, reason)
+	return err
+}
+
+func DeleteReport(db *sql.DB, reportID int) error {
+	_, err := db.Exec("DELETE FROM reports WHERE id = ?", reportID)
+	return err
+}
+
+func GetUserRoleID(db *sql.DB, userID int) (int, error) {
+	var roleID int
+	err := db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	return roleID, err
+}
+
+func GetUserAvatarAndRole(db *sql.DB, userID int) (sql.NullString, int, error) {
+	var avatar sql.NullString
+	var roleID int
+	err := db.QueryRow("SELECT avatar, role_id FROM user WHERE userid = ?", userID).Scan(&avatar, &roleID)
+	return avatar, roleID, err
 }
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// openTestDB returns a migrated in-memory database, closed when the test
+// ends.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// addTestUser inserts a user named username and returns its id.
+func addTestUser(t *testing.T, db *sql.DB, username string) int {
+	t.Helper()
+	res, err := db.Exec("INSERT INTO user (F_name, L_name, Username, Email, password, role_id) VALUES (?, ?, ?, ?, ?, ?)",
+		username, username, username, username+"@example.com", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// addTestPost inserts a post by userID and returns its id.
+func addTestPost(t *testing.T, db *sql.DB, userID int, title, content string) int {
+	t.Helper()
+	postID, err := InsertPost(db, content, title, userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return postID
+}
+
+func TestInsertComment(t *testing.T) {
+	db := openTestDB(t)
+	user := addTestUser(t, db, "alice")
+	live := addTestPost(t, db, user, "Live", "still here")
+	trashed := addTestPost(t, db, user, "Trashed", "gone")
+	if err := DeletePost(db, trashed, user); err != nil {
+		t.Fatal(err)
+	}
+	comment, err := InsertComment(db, live, 0, user, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		postID   int
+		parentID int
+		wantErr  error
+	}{
+		{"live post", live, 0, nil},
+		{"reply", live, comment, nil},
+		{"deleted post", trashed, 0, sql.ErrNoRows},
+		{"missing post", 999, 0, sql.ErrNoRows},
+		{"parent on another post", trashed, comment, sql.ErrNoRows},
+		{"missing parent", live, 999, ErrBadParent},
+	}
+	for _, tt := range tests {
+		_, err := InsertComment(db, tt.postID, tt.parentID, user, "text")
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: InsertComment error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	for _, post := range []struct {
+		id, count int
+	}{{live, 3}, {trashed, 0}} {
+		var got int
+		if err := db.QueryRow("SELECT comment_count FROM post WHERE postid = ?", post.id).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != post.count {
+			t.Errorf("post %d comment_count = %d, want %d", post.id, got, post.count)
+		}
+	}
+	var orphans int
+	if err := db.QueryRow("SELECT COUNT(*) FROM comment WHERE post_postid NOT IN (?)", live).Scan(&orphans); err != nil {
+		t.Fatal(err)
+	}
+	if orphans != 0 {
+		t.Errorf("%d comments were added outside the live post", orphans)
+	}
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Token scopes. read allows safe requests, write allows requests that change
+// state, and moderate allows using the owner's moderation permissions.
+const (
+	ScopeRead     = "read"
+	ScopeWrite    = "write"
+	ScopeModerate = "moderate"
+)
+
+// Scopes lists every token scope in the order the UI shows them.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeModerate}
+
+// APIToken is a personal access token. The plaintext is only known when the
+// token is created; afterwards it is looked up by Hash.
+type APIToken struct {
+	ID        int
+	UserID    int
+	Username  string
+	Name      string
+	Hash      string
+	Prefix    string
+	Scopes    []string
+	CreatedAt time.Time
+	LastUsed  sql.NullTime
+}
+
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const apiTokenColumns = `api_token.id, api_token.userid, user.Username, api_token.name, api_token.token_hash,
+               api_token.prefix, api_token.scopes, api_token.created_at, api_token.last_used`
+
+func scanAPIToken(row interface{ Scan(...any) error }) (APIToken, error) {
+	var token APIToken
+	var scopes string
+	err := row.Scan(&token.ID, &token.UserID, &token.Username, &token.Name, &token.Hash, &token.Prefix, &scopes, &token.CreatedAt, &token.LastUsed)
+	token.Scopes = strings.Fields(scopes)
+	return token, err
+}
+
+func queryAPITokens(db *sql.DB, where string, args ...any) ([]APIToken, error) {
+	rows, err := db.Query(`
+        SELECT `+apiTokenColumns+`
+        FROM api_token
+        JOIN user ON user.userid = api_token.userid
+        `+where+`
+        ORDER BY api_token.created_at DESC, api_token.id DESC
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// CreateAPIToken stores a new token and returns its ID.
+func CreateAPIToken(db *sql.DB, token APIToken) (int, error) {
+	res, err := db.Exec("INSERT INTO api_token (userid, name, token_hash, prefix, scopes, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		token.UserID, token.Name, token.Hash, token.Prefix, strings.Join(token.Scopes, " "), time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("CreateAPIToken: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreateAPIToken: %v", err)
+	}
+	return int(id), nil
+}
+
+// GetAPITokenByHash returns the token with the given hash, or sql.ErrNoRows.
+// Tokens of deleted users are not found.
+func GetAPITokenByHash(db *sql.DB, hash string) (APIToken, error) {
+	row := db.QueryRow(`
+        SELECT `+apiTokenColumns+`
+        FROM api_token
+        JOIN user ON user.userid = api_token.userid
+        WHERE api_token.token_hash = ?
+    `, hash)
+	return scanAPIToken(row)
+}
+
+// TouchAPIToken records that a token was just used.
+func TouchAPIToken(db *sql.DB, tokenID int) error {
+	if _, err := db.Exec("UPDATE api_token SET last_used = ? WHERE id = ?", time.Now().UTC(), tokenID); err != nil {
+		return fmt.Errorf("TouchAPIToken: %v", err)
+	}
+	return nil
+}
+
+// GetUserAPITokens lists a user's tokens, newest first.
+func GetUserAPITokens(db *sql.DB, userID int) ([]APIToken, error) {
+	tokens, err := queryAPITokens(db, "WHERE api_token.userid = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserAPITokens: %v", err)
+	}
+	return tokens, nil
+}
+
+// GetAllAPITokens lists every user's tokens, newest first.
+func GetAllAPITokens(db *sql.DB) ([]APIToken, error) {
+	tokens, err := queryAPITokens(db, "")
+	if err != nil {
+		return nil, fmt.Errorf("GetAllAPITokens: %v", err)
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken deletes one of a user's tokens. It returns sql.ErrNoRows if
+// the token does not belong to the user.
+func RevokeAPIToken(db *sql.DB, userID, tokenID int) error {
+	result, err := db.Exec("DELETE FROM api_token WHERE id = ? AND userid = ?", tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("RevokeAPIToken: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("RevokeAPIToken: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteAPIToken deletes any user's token, for admins.
+func DeleteAPIToken(db *sql.DB, tokenID int) error {
+	if _, err := db.Exec("DELETE FROM api_token WHERE id = ?", tokenID); err != nil {
+		return fmt.Errorf("DeleteAPIToken: %v", err)
+	}
+	return nil
+}
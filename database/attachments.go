@@ -0,0 +1,104 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Attachment is a file carried by a post. Filename is the name it was
+// uploaded with, which is empty for images posted before attachments.
+type Attachment struct {
+	ID          int
+	PostID      int
+	Position    int
+	Hash        string
+	Filename    string
+	Caption     string
+	ContentType string
+	Width       int
+	Height      int
+	Size        int
+}
+
+// IsImage reports whether the attachment is shown in the gallery rather than
+// listed as a download.
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// Images returns the post's image attachments in order.
+func (p Post) Images() []Attachment {
+	var images []Attachment
+	for _, a := range p.Attachments {
+		if a.IsImage() {
+			images = append(images, a)
+		}
+	}
+	return images
+}
+
+// Files returns the post's attachments that aren't images, in order.
+func (p Post) Files() []Attachment {
+	var files []Attachment
+	for _, a := range p.Attachments {
+		if !a.IsImage() {
+			files = append(files, a)
+		}
+	}
+	return files
+}
+
+// insertAttachments adds attachments to a post in the order given. Their
+// files must already be recorded with SaveMedia.
+func insertAttachments(tx *sql.Tx, postID int, attachments []Attachment) error {
+	for i, a := range attachments {
+		_, err := tx.Exec(`INSERT INTO post_attachments (post_id, position, media_hash, filename, caption)
+			VALUES (?, ?, ?, ?, ?)`, postID, i, a.Hash, a.Filename, a.Caption)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachFiles fills in Attachments for every post with one query.
+func attachFiles(db *sql.DB, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(posts))
+	args := make([]any, len(posts))
+	for i, post := range posts {
+		index[post.PostID] = i
+		args[i] = post.PostID
+	}
+
+	rows, err := db.Query(`
+        SELECT a.attachmentid, a.post_id, a.position, a.media_hash, a.filename, a.caption,
+               m.content_type, m.width, m.height, m.size
+        FROM post_attachments a
+        JOIN media m ON m.hash = a.media_hash
+        WHERE a.post_id IN (?`+strings.Repeat(", ?", len(posts)-1)+`)
+        ORDER BY a.post_id, a.position
+    `, args...)
+	if err != nil {
+		return fmt.Errorf("attachFiles: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.ID, &a.PostID, &a.Position, &a.Hash, &a.Filename, &a.Caption,
+			&a.ContentType, &a.Width, &a.Height, &a.Size); err != nil {
+			return fmt.Errorf("attachFiles: %v", err)
+		}
+		i := index[a.PostID]
+		posts[i].Attachments = append(posts[i].Attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("attachFiles: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrCollectionExists means the user already has a collection by that name.
+var ErrCollectionExists = errors.New("you already have a collection with that name")
+
+// Collection is a named list a user sorts their bookmarks into. Only its
+// owner sees it unless Public is set, in which case anyone signed in can
+// open it through ShareToken.
+type Collection struct {
+	ID         int
+	UserID     int
+	OwnerName  string
+	Name       string
+	Public     bool
+	ShareToken string
+	CreatedAt  time.Time
+	// Posts is how many posts it holds.
+	Posts int
+	// HasPost is only filled in by GetPostCollections.
+	HasPost bool
+}
+
+// SetBookmark bookmarks a post for a user, or removes the bookmark, which
+// also takes the post out of their collections. It returns sql.ErrNoRows
+// when bookmarking a post that doesn't exist or is in the trash.
+func SetBookmark(db *sql.DB, userID, postID int, on bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("SetBookmark: %v", err)
+	}
+	defer tx.Rollback()
+
+	if on {
+		if err := bookmark(tx, userID, postID); err != nil {
+			if err == sql.ErrNoRows {
+				return err
+			}
+			return fmt.Errorf("SetBookmark: %v", err)
+		}
+	} else {
+		if _, err := tx.Exec("DELETE FROM bookmarks WHERE user_userid = ? AND post_id = ?", userID, postID); err != nil {
+			return fmt.Errorf("SetBookmark: %v", err)
+		}
+		_, err := tx.Exec(`
+            DELETE FROM collection_posts
+            WHERE post_id = ? AND collection_id IN (SELECT collectionid FROM collections WHERE user_userid = ?)
+        `, postID, userID)
+		if err != nil {
+			return fmt.Errorf("SetBookmark: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SetBookmark: %v", err)
+	}
+	return nil
+}
+
+// bookmark adds a bookmark unless the user already has one, returning
+// sql.ErrNoRows if the post can't be bookmarked.
+func bookmark(tx *sql.Tx, userID, postID int) error {
+	var exists int
+	err := tx.QueryRow("SELECT 1 FROM post WHERE postid = ? AND deleted_at IS NULL", postID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT OR IGNORE INTO bookmarks (user_userid, post_id) VALUES (?, ?)", userID, postID)
+	return err
+}
+
+// AttachPostBookmarks sets Bookmarked on each post viewerID has bookmarked.
+// A viewerID of 0 is a guest.
+func AttachPostBookmarks(db *sql.DB, posts []Post, viewerID int) error {
+	if len(posts) == 0 || viewerID == 0 {
+		return nil
+	}
+	args := []any{viewerID}
+	for _, post := range posts {
+		args = append(args, post.PostID)
+	}
+	rows, err := db.Query(`
+        SELECT post_id FROM bookmarks
+        WHERE user_userid = ? AND post_id IN (?`+strings.Repeat(", ?", len(posts)-1)+`)
+    `, args...)
+	if err != nil {
+		return fmt.Errorf("AttachPostBookmarks: %v", err)
+	}
+	defer rows.Close()
+
+	saved := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("AttachPostBookmarks: %v", err)
+		}
+		saved[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("AttachPostBookmarks: %v", err)
+	}
+	for i := range posts {
+		posts[i].Bookmarked = saved[posts[i].PostID]
+	}
+	return nil
+}
+
+// GetBookmarkedPosts lists the posts a user has bookmarked, newest first.
+func GetBookmarkedPosts(db *sql.DB, userID int, page Page) ([]Post, string, error) {
+	posts, next, err := queryPosts(db, postQuery{
+		where: []string{"post.postid IN (SELECT post_id FROM bookmarks WHERE user_userid = ?)"},
+		args:  []any{userID},
+		order: newestFirst,
+	}, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetBookmarkedPosts: %w", err)
+	}
+	return posts, next, nil
+}
+
+// GetCollectionPosts lists the posts in a collection, newest first.
+func GetCollectionPosts(db *sql.DB, collectionID int, page Page) ([]Post, string, error) {
+	posts, next, err := queryPosts(db, postQuery{
+		where: []string{"post.postid IN (SELECT post_id FROM collection_posts WHERE collection_id = ?)"},
+		args:  []any{collectionID},
+		order: newestFirst,
+	}, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetCollectionPosts: %w", err)
+	}
+	return posts, next, nil
+}
+
+const collectionColumns = `
+    c.collectionid, c.user_userid, u.Username, c.name, c.is_public, c.share_token, c.created_at,
+    (SELECT COUNT(*) FROM collection_posts cp JOIN post p ON p.postid = cp.post_id
+     WHERE cp.collection_id = c.collectionid AND p.deleted_at IS NULL)`
+
+func scanCollection(row interface{ Scan(...any) error }, extra ...any) (Collection, error) {
+	var c Collection
+	dest := append([]any{&c.ID, &c.UserID, &c.OwnerName, &c.Name, &c.Public, &c.ShareToken, &c.CreatedAt, &c.Posts}, extra...)
+	err := row.Scan(dest...)
+	return c, err
+}
+
+// CreateCollection makes a new, private collection for a user.
+func CreateCollection(db *sql.DB, userID int, name, shareToken string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("CreateCollection: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := collectionNameFree(tx, userID, 0, name); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec("INSERT INTO collections (user_userid, name, share_token) VALUES (?, ?, ?)", userID, name, shareToken)
+	if err != nil {
+		return 0, fmt.Errorf("CreateCollection: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreateCollection: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("CreateCollection: %v", err)
+	}
+	return int(id), nil
+}
+
+// collectionNameFree returns ErrCollectionExists if the user has a
+// collection other than exceptID called name.
+func collectionNameFree(tx *sql.Tx, userID, exceptID int, name string) error {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM collections WHERE user_userid = ? AND name = ? AND collectionid != ?",
+		userID, name, exceptID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("collectionNameFree: %v", err)
+	}
+	if count > 0 {
+		return ErrCollectionExists
+	}
+	return nil
+}
+
+// GetUserCollections lists a user's collections by name.
+func GetUserCollections(db *sql.DB, userID int) ([]Collection, error) {
+	rows, err := db.Query(`
+        SELECT `+collectionColumns+`
+        FROM collections c
+        JOIN user u ON u.userid = c.user_userid
+        WHERE c.user_userid = ?
+        ORDER BY c.name COLLATE NOCASE
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserCollections: %v", err)
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("GetUserCollections: %v", err)
+		}
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetUserCollections: %v", err)
+	}
+	return collections, nil
+}
+
+// GetPostCollections lists a user's collections by name, with HasPost set on
+// those holding the post.
+func GetPostCollections(db *sql.DB, userID, postID int) ([]Collection, error) {
+	rows, err := db.Query(`
+        SELECT `+collectionColumns+`,
+               EXISTS (SELECT 1 FROM collection_posts WHERE collection_id = c.collectionid AND post_id = ?)
+        FROM collections c
+        JOIN user u ON u.userid = c.user_userid
+        WHERE c.user_userid = ?
+        ORDER BY c.name COLLATE NOCASE
+    `, postID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetPostCollections: %v", err)
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		var hasPost bool
+		c, err := scanCollection(rows, &hasPost)
+		if err != nil {
+			return nil, fmt.Errorf("GetPostCollections: %v", err)
+		}
+		c.HasPost = hasPost
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetPostCollections: %v", err)
+	}
+	return collections, nil
+}
+
+// GetCollectionByToken returns the collection with the given share token,
+// whatever its visibility. The owner must not be in the trash.
+func GetCollectionByToken(db *sql.DB, token string) (Collection, error) {
+	row := db.QueryRow(`
+        SELECT `+collectionColumns+`
+        FROM collections c
+        JOIN user u ON u.userid = c.user_userid
+        WHERE c.share_token = ? AND u.deleted_at IS NULL
+    `, token)
+	c, err := scanCollection(row)
+	if err == sql.ErrNoRows {
+		return c, err
+	} else if err != nil {
+		return c, fmt.Errorf("GetCollectionByToken: %v", err)
+	}
+	return c, nil
+}
+
+// RenameCollection renames one of a user's collections. It returns
+// sql.ErrNoRows if the collection is not theirs.
+func RenameCollection(db *sql.DB, userID, collectionID int, name string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("RenameCollection: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := collectionNameFree(tx, userID, collectionID, name); err != nil {
+		return err
+	}
+	result, err := tx.Exec("UPDATE collections SET name = ? WHERE collectionid = ? AND user_userid = ?", name, collectionID, userID)
+	if err != nil {
+		return fmt.Errorf("RenameCollection: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("RenameCollection: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RenameCollection: %v", err)
+	}
+	return nil
+}
+
+// SetCollectionPublic shares one of a user's collections through its link,
+// or makes it private again. It returns sql.ErrNoRows if the collection is
+// not theirs.
+func SetCollectionPublic(db *sql.DB, userID, collectionID int, public bool) error {
+	result, err := db.Exec("UPDATE collections SET is_public = ? WHERE collectionid = ? AND user_userid = ?", public, collectionID, userID)
+	if err != nil {
+		return fmt.Errorf("SetCollectionPublic: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("SetCollectionPublic: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteCollection removes one of a user's collections. The posts in it stay
+// bookmarked. It returns sql.ErrNoRows if the collection is not theirs.
+func DeleteCollection(db *sql.DB, userID, collectionID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("DeleteCollection: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM collections WHERE collectionid = ? AND user_userid = ?", collectionID, userID)
+	if err != nil {
+		return fmt.Errorf("DeleteCollection: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("DeleteCollection: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM collection_posts WHERE collection_id = ?", collectionID); err != nil {
+		return fmt.Errorf("DeleteCollection: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteCollection: %v", err)
+	}
+	return nil
+}
+
+// SetCollectionPost adds a post to one of a user's collections, bookmarking
+// it if it wasn't already, or takes it out again. It returns sql.ErrNoRows if
+// the collection is not theirs or the post can't be bookmarked.
+func SetCollectionPost(db *sql.DB, userID, collectionID, postID int, on bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("SetCollectionPost: %v", err)
+	}
+	defer tx.Rollback()
+
+	var owner int
+	err = tx.QueryRow("SELECT user_userid FROM collections WHERE collectionid = ?", collectionID).Scan(&owner)
+	if err == sql.ErrNoRows || (err == nil && owner != userID) {
+		return sql.ErrNoRows
+	} else if err != nil {
+		return fmt.Errorf("SetCollectionPost: %v", err)
+	}
+
+	if on {
+		if err := bookmark(tx, userID, postID); err != nil {
+			if err == sql.ErrNoRows {
+				return err
+			}
+			return fmt.Errorf("SetCollectionPost: %v", err)
+		}
+		_, err = tx.Exec("INSERT OR IGNORE INTO collection_posts (collection_id, post_id) VALUES (?, ?)", collectionID, postID)
+	} else {
+		_, err = tx.Exec("DELETE FROM collection_posts WHERE collection_id = ? AND post_id = ?", collectionID, postID)
+	}
+	if err != nil {
+		return fmt.Errorf("SetCollectionPost: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SetCollectionPost: %v", err)
+	}
+	return nil
+}
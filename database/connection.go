@@ -0,0 +1,65 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open returns the single connection pool shared by the whole application.
+// WAL lets readers proceed while a writer holds the lock, and the busy timeout
+// makes concurrent writers wait for each other instead of failing with
+// "database is locked".
+func Open(path string) (*sql.DB, error) {
+	memory := path == ":memory:" || strings.HasPrefix(path, "file::memory:")
+	if path == ":memory:" {
+		path = "file::memory:?cache=shared"
+	}
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	dsn := path + sep + "_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_txlock=immediate"
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+
+	if memory {
+		// Every connection to an in-memory database sees its own copy unless
+		// they share one, so keep the pool to a single connection.
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+	} else {
+		db.SetMaxOpenConns(16)
+		db.SetMaxIdleConns(4)
+		db.SetConnMaxIdleTime(5 * time.Minute)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+
+	// Search needs FTS5, which go-sqlite3 only compiles in when asked to.
+	var fts5 bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+	if !fts5 {
+		db.Close()
+		return nil, fmt.Errorf("open database: SQLite was built without FTS5; build with -tags sqlite_fts5")
+	}
+
+	return db, nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type PostCounts struct {
+	PostID   int `json:"post_id"`
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+	Comments int `json:"comments"`
+}
+
+type CommentCounts struct {
+	CommentID int `json:"comment_id"`
+	PostID    int `json:"post_id"`
+	Likes     int `json:"likes"`
+	Dislikes  int `json:"dislikes"`
+}
+
+func GetPostCounts(db *sql.DB, postID int) (PostCounts, error) {
+	counts := PostCounts{PostID: postID}
+	err := db.QueryRow("SELECT like_count, dislike_count, comment_count FROM post WHERE postid = ?", postID).
+		Scan(&counts.Likes, &counts.Dislikes, &counts.Comments)
+	return counts, err
+}
+
+func GetCommentCounts(db *sql.DB, commentID int) (CommentCounts, error) {
+	counts := CommentCounts{CommentID: commentID}
+	err := db.QueryRow("SELECT post_postid, like_count, dislike_count FROM comment WHERE commentid = ?", commentID).
+		Scan(&counts.PostID, &counts.Likes, &counts.Dislikes)
+	return counts, err
+}
+
+// CountRepairs says how many rows RepairCounts found out of step.
+type CountRepairs struct {
+	Posts     int
+	Comments  int
+	Reactions int
+}
+
+// RepairCounts recomputes every post and comment counter, and the per-type
+// reaction totals, from the rows they count.
+func RepairCounts(db *sql.DB) (CountRepairs, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return CountRepairs{}, fmt.Errorf("RepairCounts: %v", err)
+	}
+	defer tx.Rollback()
+
+	repairs, err := repairCounts(tx)
+	if err != nil {
+		return repairs, fmt.Errorf("RepairCounts: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return repairs, fmt.Errorf("RepairCounts: %v", err)
+	}
+	return repairs, nil
+}
+
+func repairCounts(tx *sql.Tx) (CountRepairs, error) {
+	var repairs CountRepairs
+	steps := []struct {
+		query string
+		n     *int
+	}{
+		{`
+        WITH actual AS (
+            SELECT postid,
+                (SELECT COUNT(*) FROM reactions WHERE target_type = 'post' AND target_id = post.postid AND kind = 'like') AS likes,
+                (SELECT COUNT(*) FROM reactions WHERE target_type = 'post' AND target_id = post.postid AND kind = 'dislike') AS dislikes,
+                (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid AND comment.deleted_at IS NULL) AS comments
+            FROM post
+        )
+        UPDATE post SET like_count = actual.likes, dislike_count = actual.dislikes, comment_count = actual.comments
+        FROM actual
+        WHERE post.postid = actual.postid
+          AND (post.like_count, post.dislike_count, post.comment_count) IS NOT (actual.likes, actual.dislikes, actual.comments)
+    `, &repairs.Posts},
+		{`
+        WITH actual AS (
+            SELECT commentid,
+                (SELECT COUNT(*) FROM reactions WHERE target_type = 'comment' AND target_id = comment.commentid AND kind = 'like') AS likes,
+                (SELECT COUNT(*) FROM reactions WHERE target_type = 'comment' AND target_id = comment.commentid AND kind = 'dislike') AS dislikes
+            FROM comment
+        )
+        UPDATE comment SET like_count = actual.likes, dislike_count = actual.dislikes
+        FROM actual
+        WHERE comment.commentid = actual.commentid
+          AND (comment.like_count, comment.dislike_count) IS NOT (actual.likes, actual.dislikes)
+    `, &repairs.Comments},
+		{`
+        DELETE FROM reaction_counts
+        WHERE count != 0 AND NOT EXISTS (
+            SELECT 1 FROM reactions r
+            WHERE r.target_type = reaction_counts.target_type AND r.target_id = reaction_counts.target_id AND r.kind = reaction_counts.kind
+        )
+    `, &repairs.Reactions},
+		{`
+        INSERT INTO reaction_counts (target_type, target_id, kind, count)
+        SELECT target_type, target_id, kind, COUNT(*) FROM reactions r
+        GROUP BY target_type, target_id, kind
+        HAVING COUNT(*) IS NOT (
+            SELECT count FROM reaction_counts c
+            WHERE c.target_type = r.target_type AND c.target_id = r.target_id AND c.kind = r.kind
+        )
+        ON CONFLICT (target_type, target_id, kind) DO UPDATE SET count = excluded.count
+    `, &repairs.Reactions},
+	}
+	for _, step := range steps {
+		result, err := tx.Exec(step.query)
+		if err != nil {
+			return repairs, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return repairs, err
+		}
+		*step.n += int(n)
+	}
+	return repairs, nil
+}
+
+func GetCommentByID(db *sql.DB, commentID int) (Comment, error) {
+	var comment Comment
+	var commentAt time.Time
+	err := db.QueryRow(`
+        SELECT comment.commentid, comment.post_postid, comment.user_userid, user.F_name, user.L_name, user.Username,
+            CASE WHEN comment.deleted_at IS NULL THEN comment.content ELSE '' END, comment.comment_at, user.Avatar,
+            comment.dislike_count,
+            comment.like_count,
+            IFNULL(comment.parent_comment_id, 0),
+            comment.deleted_at IS NOT NULL,
+            comment.edited_at
+        FROM comment
+        JOIN user ON comment.user_userid = user.userid
+        JOIN post ON comment.post_postid = post.postid
+        WHERE comment.commentid = ? AND post.deleted_at IS NULL
+    `, commentID).Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.FirstName, &comment.LastName, &comment.Username, &comment.Content, &commentAt, &comment.Avatar, &comment.Dislikes, &comment.Likes,
+		&comment.ParentID, &comment.Deleted, &comment.EditedAt)
+	comment.CreatedAt = commentAt
+	return comment, err
+}
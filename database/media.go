@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Media describes an image file in the media store.
+type Media struct {
+	Hash        string
+	ContentType string
+	Width       int
+	Height      int
+	Size        int
+}
+
+// SaveMedia records a stored image and its variants by name. Files are
+// content-addressed, so saving one that is already recorded does nothing.
+func SaveMedia(db *sql.DB, m Media, variants map[string]Media) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("SaveMedia: %v", err)
+	}
+	defer tx.Rollback()
+
+	insert := func(m Media) error {
+		_, err := tx.Exec(`INSERT OR IGNORE INTO media (hash, content_type, width, height, size)
+			VALUES (?, ?, ?, ?, ?)`, m.Hash, m.ContentType, m.Width, m.Height, m.Size)
+		return err
+	}
+	if err := insert(m); err != nil {
+		return fmt.Errorf("SaveMedia: %v", err)
+	}
+	for name, v := range variants {
+		if err := insert(v); err != nil {
+			return fmt.Errorf("SaveMedia: %v", err)
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO media_variants (media_hash, variant, variant_hash)
+			VALUES (?, ?, ?)`, m.Hash, name, v.Hash); err != nil {
+			return fmt.Errorf("SaveMedia: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SaveMedia: %v", err)
+	}
+	return nil
+}
+
+// GetMedia returns the named variant of an image, or the image itself when
+// variant is empty or the image is too small to have one.
+func GetMedia(db *sql.DB, hash, variant string) (Media, error) {
+	var m Media
+	err := db.QueryRow(`
+		SELECT hash, content_type, width, height, size FROM media
+		WHERE hash = IFNULL((SELECT variant_hash FROM media_variants WHERE media_hash = ?1 AND variant = ?2), ?1)
+	`, hash, variant).Scan(&m.Hash, &m.ContentType, &m.Width, &m.Height, &m.Size)
+	if err == sql.ErrNoRows {
+		return Media{}, err
+	} else if err != nil {
+		return Media{}, fmt.Errorf("GetMedia: %v", err)
+	}
+	return m, nil
+}
+
+// NextPostImageBlob returns a post whose image is still stored in the
+// database from before the media store, or sql.ErrNoRows once there are none
+// left.
+func NextPostImageBlob(db *sql.DB) (postID int, image []byte, err error) {
+	err = db.QueryRow("SELECT postid, image FROM post WHERE image IS NOT NULL LIMIT 1").Scan(&postID, &image)
+	if err == sql.ErrNoRows {
+		return 0, nil, err
+	} else if err != nil {
+		return 0, nil, fmt.Errorf("NextPostImageBlob: %v", err)
+	}
+	return postID, image, nil
+}
+
+// SetPostImage clears a post's image BLOB, attaching instead the image in the
+// media store with the given hash, or nothing when hash is empty. Posts with
+// a BLOB predate attachments, so the image becomes their only one.
+func SetPostImage(db *sql.DB, postID int, hash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("SetPostImage: %v", err)
+	}
+	defer tx.Rollback()
+
+	if hash != "" {
+		_, err := tx.Exec("INSERT INTO post_attachments (post_id, position, media_hash) VALUES (?, 0, ?)", postID, hash)
+		if err != nil {
+			return fmt.Errorf("SetPostImage: %v", err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE post SET image = NULL WHERE postid = ?", postID); err != nil {
+		return fmt.Errorf("SetPostImage: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SetPostImage: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change. Files live in migrations/ as
+// NNNN_name.up.sql and NNNN_name.down.sql and are compiled into the binary.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+const createSchemaVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("LoadMigrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		prefix, name, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("LoadMigrations: bad file name %q", fileName)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("LoadMigrations: bad version in %q", fileName)
+		}
+
+		body, err := migrationFiles.ReadFile("migrations/" + fileName)
+		if err != nil {
+			return nil, fmt.Errorf("LoadMigrations: %v", err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("LoadMigrations: version %d used by %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("LoadMigrations: migration %04d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies every pending migration in order, each in its own
+// transaction.
+func Migrate(db *sql.DB) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := prepareSchemaVersion(db, migrations)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := runMigration(db, m.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec("INSERT INTO schema_version (version, name) VALUES (?, ?)", m.Version, m.Name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Migrate: %04d_%s: %v", m.Version, m.Name, err)
+		}
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+	return nil
+}
+
+// Rollback reverts the last steps applied migrations, newest first. A steps
+// value below 1 reverts all of them.
+func Rollback(db *sql.DB, steps int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := prepareSchemaVersion(db, migrations)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		err := runMigration(db, m.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec("DELETE FROM schema_version WHERE version = ?", m.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Rollback: %04d_%s: %v", m.Version, m.Name, err)
+		}
+		log.Printf("Rolled back migration %04d_%s", m.Version, m.Name)
+
+		steps--
+		if steps == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+func MigrationStatus(db *sql.DB) ([]MigrationState, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := prepareSchemaVersion(db, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		states = append(states, MigrationState{Migration: m, Applied: ok, AppliedAt: appliedAt})
+	}
+	return states, nil
+}
+
+// prepareSchemaVersion creates the schema_version table and returns the
+// versions already applied. Databases created before migrations existed have
+// every table from the first migration but no version rows; they are recorded
+// as being at version 1 so the initial schema and seed data are not replayed.
+func prepareSchemaVersion(db *sql.DB, migrations []Migration) (map[int]time.Time, error) {
+	if _, err := db.Exec(createSchemaVersionTable); err != nil {
+		return nil, fmt.Errorf("schema_version: %v", err)
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(applied) == 0 && len(migrations) > 0 && migrations[0].Version == 1 {
+		var legacy bool
+		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type='table' AND name='categories')").Scan(&legacy)
+		if err != nil {
+			return nil, fmt.Errorf("schema_version: %v", err)
+		}
+		if legacy {
+			_, err := db.Exec("INSERT INTO schema_version (version, name) VALUES (?, ?)", migrations[0].Version, migrations[0].Name)
+			if err != nil {
+				return nil, fmt.Errorf("schema_version: %v", err)
+			}
+			log.Println("Existing database found, recorded as schema version 1")
+			return appliedVersions(db)
+		}
+	}
+	return applied, nil
+}
+
+func appliedVersions(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, fmt.Errorf("schema_version: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("schema_version: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func runMigration(db *sql.DB, statements string, record func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(statements); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
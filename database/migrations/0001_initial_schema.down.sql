@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS google;
+DROP TABLE IF EXISTS github;
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS following;
+DROP TABLE IF EXISTS followers;
+DROP TABLE IF EXISTS friends;
+DROP TABLE IF EXISTS comment_dislikes;
+DROP TABLE IF EXISTS comment_likes;
+DROP TABLE IF EXISTS dislikes;
+DROP TABLE IF EXISTS likes;
+DROP TABLE IF EXISTS comment;
+DROP TABLE IF EXISTS post_has_categories;
+DROP TABLE IF EXISTS post;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS session;
+DROP TABLE IF EXISTS user;
+DROP TABLE IF EXISTS user_roles;
@@ -0,0 +1,27 @@
+CREATE TABLE followers_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_userid INTEGER NOT NULL,
+	follower_userid INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_userid) REFERENCES user(userid),
+	FOREIGN KEY (follower_userid) REFERENCES user(userid),
+	UNIQUE(user_userid, follower_userid)
+);
+
+CREATE TABLE following (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_userid INTEGER NOT NULL,
+	following_userid INTEGER NOT NULL,
+	FOREIGN KEY (user_userid) REFERENCES user(userid),
+	FOREIGN KEY (following_userid) REFERENCES user(userid)
+);
+
+INSERT INTO followers_old (user_userid, follower_userid, created_at)
+	SELECT following_id, follower_id, created_at FROM followers;
+
+INSERT INTO following (user_userid, following_userid)
+	SELECT follower_id, following_id FROM followers;
+
+DROP INDEX IF EXISTS idx_followers_following;
+DROP TABLE followers;
+ALTER TABLE followers_old RENAME TO followers;
@@ -0,0 +1,23 @@
+-- followers and following stored the same relationship twice, once from each
+-- side. Keep a single directed table: follower_id follows following_id.
+CREATE TABLE followers_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	follower_id INTEGER NOT NULL,
+	following_id INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (follower_id) REFERENCES user(userid),
+	FOREIGN KEY (following_id) REFERENCES user(userid),
+	UNIQUE(follower_id, following_id)
+);
+
+INSERT OR IGNORE INTO followers_new (follower_id, following_id, created_at)
+	SELECT follower_userid, user_userid, created_at FROM followers;
+
+INSERT OR IGNORE INTO followers_new (follower_id, following_id)
+	SELECT user_userid, following_userid FROM following;
+
+DROP TABLE followers;
+DROP TABLE following;
+ALTER TABLE followers_new RENAME TO followers;
+
+CREATE INDEX idx_followers_following ON followers(following_id);
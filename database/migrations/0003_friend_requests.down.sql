@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_friends_pair;
+DROP INDEX IF EXISTS idx_friend_requests_receiver;
+DROP TABLE IF EXISTS friend_requests;
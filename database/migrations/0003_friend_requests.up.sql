@@ -0,0 +1,19 @@
+-- A pending request is a row in friend_requests. Accepting it removes the row
+-- and records the friendship in both directions in friends.
+CREATE TABLE friend_requests (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sender_id INTEGER NOT NULL,
+	receiver_id INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (sender_id) REFERENCES user(userid),
+	FOREIGN KEY (receiver_id) REFERENCES user(userid),
+	UNIQUE(sender_id, receiver_id)
+);
+
+CREATE INDEX idx_friend_requests_receiver ON friend_requests(receiver_id);
+
+DELETE FROM friends WHERE id NOT IN (
+	SELECT MIN(id) FROM friends GROUP BY user_userid, friend_userid
+);
+
+CREATE UNIQUE INDEX idx_friends_pair ON friends(user_userid, friend_userid);
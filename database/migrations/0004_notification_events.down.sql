@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS notification_actors;
+
+CREATE TABLE notifications_old (
+	notificationid INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_userid INTEGER NOT NULL,
+	post_id INTEGER NOT NULL,
+	message TEXT NOT NULL,
+	created_at DATETIME default CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_userid) REFERENCES user(userid),
+	FOREIGN KEY (post_id) REFERENCES post(postid)
+);
+
+INSERT INTO notifications_old (notificationid, user_userid, post_id, message, created_at)
+	SELECT notificationid, user_userid, post_id, message, created_at FROM notifications WHERE post_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_notifications_user;
+DROP TABLE notifications;
+ALTER TABLE notifications_old RENAME TO notifications;
@@ -0,0 +1,39 @@
+-- Notifications now remember who acted, what kind of event it was and whether
+-- the recipient has read it. Repeated events on the same target are collapsed
+-- into one unread row; notification_actors lists everyone folded into it.
+CREATE TABLE notifications_new (
+	notificationid INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_userid INTEGER NOT NULL,
+	actor_id INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	post_id INTEGER,
+	comment_id INTEGER,
+	message TEXT NOT NULL,
+	actor_count INTEGER NOT NULL DEFAULT 1,
+	is_read INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_userid) REFERENCES user(userid),
+	FOREIGN KEY (actor_id) REFERENCES user(userid),
+	FOREIGN KEY (post_id) REFERENCES post(postid),
+	FOREIGN KEY (comment_id) REFERENCES comment(commentid)
+);
+
+INSERT INTO notifications_new (notificationid, user_userid, actor_id, type, post_id, message, created_at)
+	SELECT notificationid, user_userid, user_userid, 'legacy', post_id, message, created_at FROM notifications;
+
+DROP TABLE notifications;
+ALTER TABLE notifications_new RENAME TO notifications;
+
+CREATE INDEX idx_notifications_user ON notifications(user_userid, is_read);
+
+CREATE TABLE notification_actors (
+	notification_id INTEGER NOT NULL,
+	actor_id INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (notification_id, actor_id),
+	FOREIGN KEY (notification_id) REFERENCES notifications(notificationid) ON DELETE CASCADE,
+	FOREIGN KEY (actor_id) REFERENCES user(userid)
+);
+
+INSERT INTO notification_actors (notification_id, actor_id)
+	SELECT notificationid, actor_id FROM notifications;
@@ -0,0 +1,18 @@
+-- Only one session per user fits the old table; keep the most recently used.
+CREATE TABLE session_old (
+	sessionid TEXT PRIMARY KEY,
+	userid INTEGER NOT NULL UNIQUE,
+	endtime DATETIME NOT NULL,
+	FOREIGN KEY (userid) REFERENCES user(userid)
+);
+
+INSERT INTO session_old (sessionid, userid, endtime)
+	SELECT sessionid, userid, endtime FROM session s
+	WHERE s.id = (SELECT id FROM session WHERE userid = s.userid ORDER BY last_seen DESC, id DESC LIMIT 1);
+
+DROP INDEX IF EXISTS idx_session_user;
+DROP INDEX IF EXISTS idx_session_endtime;
+DROP TABLE session;
+ALTER TABLE session_old RENAME TO session;
+
+UPDATE user SET current_session = (SELECT sessionid FROM session WHERE session.userid = user.userid);
@@ -0,0 +1,23 @@
+-- A user may now be signed in on several devices at once. Each row is one
+-- device; user.current_session is no longer read or written.
+CREATE TABLE session_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sessionid TEXT NOT NULL UNIQUE,
+	userid INTEGER NOT NULL,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_seen DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	endtime DATETIME NOT NULL,
+	FOREIGN KEY (userid) REFERENCES user(userid)
+);
+
+INSERT INTO session_new (sessionid, userid, endtime)
+	SELECT sessionid, userid, endtime FROM session;
+
+UPDATE user SET current_session = NULL;
+DROP TABLE session;
+ALTER TABLE session_new RENAME TO session;
+
+CREATE INDEX idx_session_user ON session(userid);
+CREATE INDEX idx_session_endtime ON session(endtime);
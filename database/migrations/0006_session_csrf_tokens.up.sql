@@ -0,0 +1,4 @@
+-- Every session carries its own token that state-changing requests must echo
+-- back. Sessions that already exist get a fresh random one.
+ALTER TABLE session ADD COLUMN csrf_token TEXT NOT NULL DEFAULT '';
+UPDATE session SET csrf_token = lower(hex(randomblob(32)));
@@ -0,0 +1,46 @@
+CREATE TABLE permissions (
+	permissionid INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+	role_id INTEGER NOT NULL,
+	permission_id INTEGER NOT NULL,
+	PRIMARY KEY (role_id, permission_id),
+	FOREIGN KEY (role_id) REFERENCES user_roles(roleid),
+	FOREIGN KEY (permission_id) REFERENCES permissions(permissionid)
+);
+
+INSERT INTO permissions (name, description) VALUES
+	('post.delete.own', 'Delete your own posts'),
+	('post.delete.any', 'Delete any post'),
+	('post.report', 'Report posts'),
+	('comment.delete.own', 'Delete your own comments'),
+	('comment.delete.any', 'Delete any comment'),
+	('comment.report', 'Report comments'),
+	('report.resolve', 'Resolve reports'),
+	('category.manage', 'Add and delete categories'),
+	('user.manage', 'View users, their activity and sessions, and delete users'),
+	('user.role.assign', 'Change the role of a user'),
+	('moderation.panel', 'Open the moderator panel'),
+	('admin.panel', 'Open the admin dashboard');
+
+-- Admin
+INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.roleid, p.permissionid FROM user_roles r, permissions p
+	WHERE r.role_name = 'Admin';
+
+-- Moderator
+INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.roleid, p.permissionid FROM user_roles r, permissions p
+	WHERE r.role_name = 'Moderator' AND p.name IN (
+		'post.delete.own', 'post.delete.any', 'post.report',
+		'comment.delete.own', 'comment.delete.any', 'comment.report',
+		'moderation.panel'
+	);
+
+-- User
+INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.roleid, p.permissionid FROM user_roles r, permissions p
+	WHERE r.role_name = 'User' AND p.name IN ('post.delete.own', 'comment.delete.own');
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_api_token_user;
+DROP TABLE api_token;
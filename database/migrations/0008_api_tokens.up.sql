@@ -0,0 +1,17 @@
+-- Personal access tokens let scripts use the API without a browser session.
+-- Only a SHA-256 hash of each token is kept; prefix is the start of the
+-- plaintext so people can tell their tokens apart. scopes is a space
+-- separated list drawn from read, write and moderate.
+CREATE TABLE api_token (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	userid INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	prefix TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_used DATETIME,
+	FOREIGN KEY (userid) REFERENCES user(userid)
+);
+
+CREATE INDEX idx_api_token_user ON api_token(userid);
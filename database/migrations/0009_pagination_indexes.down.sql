@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_notifications_created;
+DROP INDEX IF EXISTS idx_comment_dislikes_comment;
+DROP INDEX IF EXISTS idx_comment_likes_comment;
+DROP INDEX IF EXISTS idx_dislikes_post;
+DROP INDEX IF EXISTS idx_likes_post;
+DROP INDEX IF EXISTS idx_comment_post;
+DROP INDEX IF EXISTS idx_post_has_categories_category;
+DROP INDEX IF EXISTS idx_post_has_categories_post;
+DROP INDEX IF EXISTS idx_post_user;
+DROP INDEX IF EXISTS idx_post_post_at;
@@ -0,0 +1,12 @@
+-- Keyset pagination walks these indexes in order and stops after a page,
+-- and the per-row reaction and comment counts look rows up by their parent.
+CREATE INDEX idx_post_post_at ON post(post_at, postid);
+CREATE INDEX idx_post_user ON post(user_userid, post_at, postid);
+CREATE INDEX idx_post_has_categories_post ON post_has_categories(post_postid);
+CREATE INDEX idx_post_has_categories_category ON post_has_categories(categories_idcategories, post_postid);
+CREATE INDEX idx_comment_post ON comment(post_postid, comment_at, commentid);
+CREATE INDEX idx_likes_post ON likes(post_postid);
+CREATE INDEX idx_dislikes_post ON dislikes(post_postid);
+CREATE INDEX idx_comment_likes_comment ON comment_likes(commentid);
+CREATE INDEX idx_comment_dislikes_comment ON comment_dislikes(commentid);
+CREATE INDEX idx_notifications_created ON notifications(user_userid, created_at, notificationid);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_post_like_count;
+ALTER TABLE comment DROP COLUMN dislike_count;
+ALTER TABLE comment DROP COLUMN like_count;
+ALTER TABLE post DROP COLUMN comment_count;
+ALTER TABLE post DROP COLUMN dislike_count;
+ALTER TABLE post DROP COLUMN like_count;
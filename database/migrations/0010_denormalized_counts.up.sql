@@ -0,0 +1,18 @@
+-- Reaction and comment totals are kept on the row they belong to, so feeds
+-- read them instead of counting per row. The toggles and comment inserts and
+-- deletes maintain them; "repair-counts" recomputes them if they drift.
+ALTER TABLE post ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE post ADD COLUMN dislike_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE post ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comment ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comment ADD COLUMN dislike_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE post SET
+    like_count = (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid),
+    dislike_count = (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid),
+    comment_count = (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid);
+UPDATE comment SET
+    like_count = (SELECT COUNT(*) FROM comment_likes WHERE comment_likes.commentid = comment.commentid),
+    dislike_count = (SELECT COUNT(*) FROM comment_dislikes WHERE comment_dislikes.commentid = comment.commentid);
+
+CREATE INDEX idx_post_like_count ON post(like_count, post_at, postid);
@@ -0,0 +1,51 @@
+CREATE TABLE likes (
+	likeid INTEGER PRIMARY KEY AUTOINCREMENT,
+	like_at DATETIME NULL,
+	post_postid INTEGER NOT NULL,
+	user_userid INTEGER NOT NULL,
+	FOREIGN KEY (post_postid) REFERENCES post(postid),
+	FOREIGN KEY (user_userid) REFERENCES user(userid)
+);
+
+CREATE TABLE dislikes (
+	dislikeid INTEGER PRIMARY KEY AUTOINCREMENT,
+	dislike_at DATETIME NULL,
+	post_postid INTEGER NOT NULL,
+	user_userid INTEGER NOT NULL,
+	FOREIGN KEY (post_postid) REFERENCES post(postid),
+	FOREIGN KEY (user_userid) REFERENCES user(userid)
+);
+
+CREATE TABLE comment_likes (
+	likeid INTEGER PRIMARY KEY AUTOINCREMENT,
+	like_at DATETIME NULL,
+	commentid INTEGER NOT NULL,
+	userid INTEGER NOT NULL,
+	FOREIGN KEY (commentid) REFERENCES comment(commentid)
+	FOREIGN KEY (userid) REFERENCES user(userid)
+);
+
+CREATE TABLE comment_dislikes (
+	dislikeid INTEGER PRIMARY KEY AUTOINCREMENT,
+	dislike_at DATETIME NULL,
+	commentid INTEGER NOT NULL,
+	userid INTEGER NOT NULL,
+	FOREIGN KEY (commentid) REFERENCES comment(commentid)
+	FOREIGN KEY (userid) REFERENCES user(userid)
+);
+
+INSERT INTO likes (like_at, post_postid, user_userid)
+SELECT created_at, target_id, user_userid FROM reactions WHERE target_type = 'post' AND kind = 'like' ORDER BY id;
+INSERT INTO dislikes (dislike_at, post_postid, user_userid)
+SELECT created_at, target_id, user_userid FROM reactions WHERE target_type = 'post' AND kind = 'dislike' ORDER BY id;
+INSERT INTO comment_likes (like_at, commentid, userid)
+SELECT created_at, target_id, user_userid FROM reactions WHERE target_type = 'comment' AND kind = 'like' ORDER BY id;
+INSERT INTO comment_dislikes (dislike_at, commentid, userid)
+SELECT created_at, target_id, user_userid FROM reactions WHERE target_type = 'comment' AND kind = 'dislike' ORDER BY id;
+
+CREATE INDEX idx_likes_post ON likes(post_postid);
+CREATE INDEX idx_dislikes_post ON dislikes(post_postid);
+CREATE INDEX idx_comment_likes_comment ON comment_likes(commentid);
+CREATE INDEX idx_comment_dislikes_comment ON comment_dislikes(commentid);
+
+DROP TABLE reactions;
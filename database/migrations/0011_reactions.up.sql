@@ -0,0 +1,41 @@
+-- One table holds every reaction, keyed so a user has at most one reaction to
+-- any post or comment. Toggles upsert into it inside a transaction.
+CREATE TABLE reactions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment')),
+	target_id INTEGER NOT NULL,
+	user_userid INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (target_type, target_id, user_userid),
+	FOREIGN KEY (user_userid) REFERENCES user(userid)
+);
+CREATE INDEX idx_reactions_user ON reactions(user_userid, target_type, kind);
+
+-- The old toggles stored the literal layout string instead of a time, and a
+-- race could leave a user with duplicates or both a like and a dislike; the
+-- first like is kept in that case.
+INSERT OR IGNORE INTO reactions (target_type, target_id, user_userid, kind, created_at)
+SELECT 'post', post_postid, user_userid, 'like', COALESCE(NULLIF(like_at, '2006-01-02 15:04:05'), CURRENT_TIMESTAMP)
+FROM likes ORDER BY likeid;
+INSERT OR IGNORE INTO reactions (target_type, target_id, user_userid, kind, created_at)
+SELECT 'post', post_postid, user_userid, 'dislike', COALESCE(NULLIF(dislike_at, '2006-01-02 15:04:05'), CURRENT_TIMESTAMP)
+FROM dislikes ORDER BY dislikeid;
+INSERT OR IGNORE INTO reactions (target_type, target_id, user_userid, kind, created_at)
+SELECT 'comment', commentid, userid, 'like', COALESCE(NULLIF(like_at, '2006-01-02 15:04:05'), CURRENT_TIMESTAMP)
+FROM comment_likes ORDER BY likeid;
+INSERT OR IGNORE INTO reactions (target_type, target_id, user_userid, kind, created_at)
+SELECT 'comment', commentid, userid, 'dislike', COALESCE(NULLIF(dislike_at, '2006-01-02 15:04:05'), CURRENT_TIMESTAMP)
+FROM comment_dislikes ORDER BY dislikeid;
+
+DROP TABLE likes;
+DROP TABLE dislikes;
+DROP TABLE comment_likes;
+DROP TABLE comment_dislikes;
+
+UPDATE post SET
+    like_count = (SELECT COUNT(*) FROM reactions WHERE target_type = 'post' AND target_id = post.postid AND kind = 'like'),
+    dislike_count = (SELECT COUNT(*) FROM reactions WHERE target_type = 'post' AND target_id = post.postid AND kind = 'dislike');
+UPDATE comment SET
+    like_count = (SELECT COUNT(*) FROM reactions WHERE target_type = 'comment' AND target_id = comment.commentid AND kind = 'like'),
+    dislike_count = (SELECT COUNT(*) FROM reactions WHERE target_type = 'comment' AND target_id = comment.commentid AND kind = 'dislike');
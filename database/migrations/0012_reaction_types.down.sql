@@ -0,0 +1,5 @@
+DELETE FROM role_permissions WHERE permission_id IN (SELECT permissionid FROM permissions WHERE name = 'reaction.manage');
+DELETE FROM permissions WHERE name = 'reaction.manage';
+DELETE FROM reactions WHERE kind NOT IN ('like', 'dislike');
+DROP TABLE reaction_counts;
+DROP TABLE reaction_types;
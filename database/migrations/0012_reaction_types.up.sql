@@ -0,0 +1,38 @@
+-- The reactions users can pick from. like and dislike back the vote buttons
+-- and the top rated feed, so they are built in; admins manage the rest.
+CREATE TABLE reaction_types (
+	name TEXT PRIMARY KEY,
+	emoji TEXT NOT NULL,
+	label TEXT NOT NULL,
+	position INTEGER NOT NULL DEFAULT 0,
+	builtin INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO reaction_types (name, emoji, label, position, builtin) VALUES
+	('like', '👍', 'Thumbs up', 1, 1),
+	('dislike', '👎', 'Thumbs down', 2, 1),
+	('heart', '❤️', 'Love', 3, 0),
+	('laugh', '😂', 'Funny', 4, 0),
+	('insightful', '💡', 'Insightful', 5, 0),
+	('confused', '😕', 'Confused', 6, 0);
+
+-- Totals per reaction type, kept in step with reactions the same way as the
+-- like_count and dislike_count columns. Existing likes and dislikes are
+-- counted in.
+CREATE TABLE reaction_counts (
+	target_type TEXT NOT NULL,
+	target_id INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	count INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (target_type, target_id, kind)
+);
+
+INSERT INTO reaction_counts (target_type, target_id, kind, count)
+SELECT target_type, target_id, kind, COUNT(*) FROM reactions GROUP BY target_type, target_id, kind;
+
+INSERT INTO permissions (name, description) VALUES
+	('reaction.manage', 'Add, edit and remove reaction types');
+
+INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.roleid, p.permissionid FROM user_roles r, permissions p
+	WHERE r.role_name = 'Admin' AND p.name = 'reaction.manage';
@@ -0,0 +1,6 @@
+DELETE FROM comment WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_comment_post;
+CREATE INDEX idx_comment_post ON comment(post_postid, comment_at, commentid);
+DROP INDEX IF EXISTS idx_comment_parent;
+ALTER TABLE comment DROP COLUMN deleted_at;
+ALTER TABLE comment DROP COLUMN parent_comment_id;
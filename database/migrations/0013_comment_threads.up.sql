@@ -0,0 +1,9 @@
+-- Comments can answer another comment on the same post. A deleted comment
+-- that still has replies is kept as a "[deleted]" placeholder, with its
+-- content cleared, so the thread below it survives.
+ALTER TABLE comment ADD COLUMN parent_comment_id INTEGER NULL;
+ALTER TABLE comment ADD COLUMN deleted_at DATETIME NULL;
+
+CREATE INDEX idx_comment_parent ON comment(parent_comment_id, comment_at, commentid);
+DROP INDEX IF EXISTS idx_comment_post;
+CREATE INDEX idx_comment_post ON comment(post_postid, parent_comment_id, comment_at, commentid);
@@ -0,0 +1,9 @@
+DELETE FROM role_permissions WHERE permission_id IN (
+	SELECT permissionid FROM permissions
+	WHERE name IN ('post.edit.own', 'post.edit.any', 'comment.edit.own', 'comment.edit.any', 'revision.view')
+);
+DELETE FROM permissions WHERE name IN ('post.edit.own', 'post.edit.any', 'comment.edit.own', 'comment.edit.any', 'revision.view');
+DROP TABLE comment_revisions;
+DROP TABLE post_revisions;
+ALTER TABLE comment DROP COLUMN edited_at;
+ALTER TABLE post DROP COLUMN edited_at;
@@ -0,0 +1,45 @@
+-- Posts and comments can be edited. Each revisions row is a version that an
+-- edit replaced, along with who made that edit and when, so the full history
+-- is the revisions in order followed by the current row.
+ALTER TABLE post ADD COLUMN edited_at DATETIME NULL;
+ALTER TABLE comment ADD COLUMN edited_at DATETIME NULL;
+
+CREATE TABLE post_revisions (
+	revisionid INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL,
+	title TEXT NULL,
+	content TEXT NOT NULL,
+	edited_by INTEGER NOT NULL,
+	edited_at DATETIME NOT NULL,
+	FOREIGN KEY (post_id) REFERENCES post(postid),
+	FOREIGN KEY (edited_by) REFERENCES user(userid)
+);
+CREATE INDEX idx_post_revisions_post ON post_revisions(post_id, revisionid);
+
+CREATE TABLE comment_revisions (
+	revisionid INTEGER PRIMARY KEY AUTOINCREMENT,
+	comment_id INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	edited_by INTEGER NOT NULL,
+	edited_at DATETIME NOT NULL,
+	FOREIGN KEY (comment_id) REFERENCES comment(commentid),
+	FOREIGN KEY (edited_by) REFERENCES user(userid)
+);
+CREATE INDEX idx_comment_revisions_comment ON comment_revisions(comment_id, revisionid);
+
+INSERT INTO permissions (name, description) VALUES
+	('post.edit.own', 'Edit your own posts'),
+	('post.edit.any', 'Edit any post'),
+	('comment.edit.own', 'Edit your own comments'),
+	('comment.edit.any', 'Edit any comment'),
+	('revision.view', 'See the edit history of posts and comments');
+
+INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.roleid, p.permissionid FROM user_roles r, permissions p
+	WHERE r.role_name IN ('Admin', 'Moderator') AND p.name IN (
+		'post.edit.own', 'post.edit.any', 'comment.edit.own', 'comment.edit.any', 'revision.view'
+	);
+
+INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.roleid, p.permissionid FROM user_roles r, permissions p
+	WHERE r.role_name = 'User' AND p.name IN ('post.edit.own', 'comment.edit.own');
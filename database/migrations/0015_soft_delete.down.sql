@@ -0,0 +1,21 @@
+-- Without the trash, deleted posts and users are gone for good and deleted
+-- comments go back to being blank placeholders.
+DELETE FROM role_permissions WHERE permission_id IN (
+	SELECT permissionid FROM permissions WHERE name = 'trash.manage'
+);
+DELETE FROM permissions WHERE name = 'trash.manage';
+
+UPDATE comment SET content = '' WHERE deleted_at IS NOT NULL;
+DELETE FROM comment WHERE post_postid IN (SELECT postid FROM post WHERE deleted_at IS NOT NULL);
+DELETE FROM post_has_categories WHERE post_postid IN (SELECT postid FROM post WHERE deleted_at IS NOT NULL);
+DELETE FROM post WHERE deleted_at IS NOT NULL;
+DELETE FROM user WHERE deleted_at IS NOT NULL;
+
+DROP INDEX idx_user_deleted;
+DROP INDEX idx_comment_deleted;
+DROP INDEX idx_post_deleted;
+ALTER TABLE user DROP COLUMN deleted_by;
+ALTER TABLE user DROP COLUMN deleted_at;
+ALTER TABLE comment DROP COLUMN deleted_by;
+ALTER TABLE post DROP COLUMN deleted_by;
+ALTER TABLE post DROP COLUMN deleted_at;
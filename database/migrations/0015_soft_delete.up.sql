@@ -0,0 +1,20 @@
+-- Deleting a post, comment or user now moves it to the trash: deleted_at and
+-- deleted_by are set and the row is hidden, but kept until it is restored or
+-- purged. comment.deleted_at already exists for "[deleted]" placeholders,
+-- which become trashed comments like any other.
+ALTER TABLE post ADD COLUMN deleted_at DATETIME NULL;
+ALTER TABLE post ADD COLUMN deleted_by INTEGER NULL REFERENCES user(userid);
+ALTER TABLE comment ADD COLUMN deleted_by INTEGER NULL REFERENCES user(userid);
+ALTER TABLE user ADD COLUMN deleted_at DATETIME NULL;
+ALTER TABLE user ADD COLUMN deleted_by INTEGER NULL REFERENCES user(userid);
+
+CREATE INDEX idx_post_deleted ON post(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_comment_deleted ON comment(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_user_deleted ON user(deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO permissions (name, description) VALUES
+	('trash.manage', 'See deleted posts, comments and users, and restore them');
+
+INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.roleid, p.permissionid FROM user_roles r, permissions p
+	WHERE r.role_name = 'Admin' AND p.name = 'trash.manage';
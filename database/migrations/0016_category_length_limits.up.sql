@@ -0,0 +1,3 @@
+-- A category may allow longer or shorter posts than max_post_length in the
+-- config. NULL keeps the configured default.
+ALTER TABLE categories ADD COLUMN max_length INTEGER;
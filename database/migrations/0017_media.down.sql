@@ -0,0 +1,5 @@
+-- Images stay in the media directory but posts lose them: there is no going
+-- back to BLOBs.
+ALTER TABLE post DROP COLUMN image_hash;
+DROP TABLE media_variants;
+DROP TABLE media;
@@ -0,0 +1,24 @@
+-- Uploaded images live on disk, named by the SHA-256 of their contents, and
+-- are described here. Variants are the resized copies made of each image,
+-- themselves stored as media.
+CREATE TABLE media (
+	hash TEXT PRIMARY KEY,
+	content_type TEXT NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	size INTEGER NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE media_variants (
+	media_hash TEXT NOT NULL,
+	variant TEXT NOT NULL,
+	variant_hash TEXT NOT NULL,
+	PRIMARY KEY (media_hash, variant),
+	FOREIGN KEY (media_hash) REFERENCES media(hash),
+	FOREIGN KEY (variant_hash) REFERENCES media(hash)
+);
+
+-- post.image keeps the old BLOBs until the server moves them into the media
+-- store on startup, after which it is always NULL.
+ALTER TABLE post ADD COLUMN image_hash TEXT NULL;
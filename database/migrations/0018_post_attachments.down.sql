@@ -0,0 +1,10 @@
+-- Posts keep their first image and lose every other attachment.
+ALTER TABLE post ADD COLUMN image_hash TEXT NULL;
+
+UPDATE post SET image_hash = (
+	SELECT a.media_hash FROM post_attachments a JOIN media m ON m.hash = a.media_hash
+	WHERE a.post_id = post.postid AND m.content_type LIKE 'image/%'
+	ORDER BY a.position LIMIT 1
+);
+
+DROP TABLE post_attachments;
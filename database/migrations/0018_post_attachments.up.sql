@@ -0,0 +1,19 @@
+-- A post can carry several files, images or otherwise, shown in the order its
+-- author chose. The files themselves are media; a post's image moves here as
+-- its first attachment.
+CREATE TABLE post_attachments (
+	attachmentid INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	media_hash TEXT NOT NULL,
+	filename TEXT NOT NULL DEFAULT '',
+	caption TEXT NOT NULL DEFAULT '',
+	UNIQUE (post_id, position),
+	FOREIGN KEY (post_id) REFERENCES post(postid),
+	FOREIGN KEY (media_hash) REFERENCES media(hash)
+);
+
+INSERT INTO post_attachments (post_id, position, media_hash)
+	SELECT postid, 0, image_hash FROM post WHERE image_hash IS NOT NULL;
+
+ALTER TABLE post DROP COLUMN image_hash;
@@ -0,0 +1,14 @@
+-- Drops the full-text indexes along with the triggers that fill them.
+DROP TRIGGER user_search_update;
+DROP TRIGGER user_search_delete;
+DROP TRIGGER user_search_insert;
+DROP TRIGGER comment_search_update;
+DROP TRIGGER comment_search_delete;
+DROP TRIGGER comment_search_insert;
+DROP TRIGGER post_search_update;
+DROP TRIGGER post_search_delete;
+DROP TRIGGER post_search_insert;
+
+DROP TABLE user_search;
+DROP TABLE comment_search;
+DROP TABLE post_search;
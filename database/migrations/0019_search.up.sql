@@ -0,0 +1,59 @@
+-- Full-text indexes over posts, comments and users. They are external
+-- content tables: the text stays in the source tables and the triggers keep
+-- the indexes in step with every insert, edit and purge. Trashed rows stay
+-- indexed and are filtered out by the queries, so restoring one needs no
+-- reindexing.
+CREATE VIRTUAL TABLE post_search USING fts5(
+	title, content,
+	content='post', content_rowid='postid',
+	tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE VIRTUAL TABLE comment_search USING fts5(
+	content,
+	content='comment', content_rowid='commentid',
+	tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE VIRTUAL TABLE user_search USING fts5(
+	Username, F_name, L_name,
+	content='user', content_rowid='userid',
+	tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE TRIGGER post_search_insert AFTER INSERT ON post BEGIN
+	INSERT INTO post_search(rowid, title, content) VALUES (new.postid, new.title, new.content);
+END;
+CREATE TRIGGER post_search_delete AFTER DELETE ON post BEGIN
+	INSERT INTO post_search(post_search, rowid, title, content) VALUES ('delete', old.postid, old.title, old.content);
+END;
+CREATE TRIGGER post_search_update AFTER UPDATE OF title, content ON post BEGIN
+	INSERT INTO post_search(post_search, rowid, title, content) VALUES ('delete', old.postid, old.title, old.content);
+	INSERT INTO post_search(rowid, title, content) VALUES (new.postid, new.title, new.content);
+END;
+
+CREATE TRIGGER comment_search_insert AFTER INSERT ON comment BEGIN
+	INSERT INTO comment_search(rowid, content) VALUES (new.commentid, new.content);
+END;
+CREATE TRIGGER comment_search_delete AFTER DELETE ON comment BEGIN
+	INSERT INTO comment_search(comment_search, rowid, content) VALUES ('delete', old.commentid, old.content);
+END;
+CREATE TRIGGER comment_search_update AFTER UPDATE OF content ON comment BEGIN
+	INSERT INTO comment_search(comment_search, rowid, content) VALUES ('delete', old.commentid, old.content);
+	INSERT INTO comment_search(rowid, content) VALUES (new.commentid, new.content);
+END;
+
+CREATE TRIGGER user_search_insert AFTER INSERT ON user BEGIN
+	INSERT INTO user_search(rowid, Username, F_name, L_name) VALUES (new.userid, new.Username, new.F_name, new.L_name);
+END;
+CREATE TRIGGER user_search_delete AFTER DELETE ON user BEGIN
+	INSERT INTO user_search(user_search, rowid, Username, F_name, L_name) VALUES ('delete', old.userid, old.Username, old.F_name, old.L_name);
+END;
+CREATE TRIGGER user_search_update AFTER UPDATE OF Username, F_name, L_name ON user BEGIN
+	INSERT INTO user_search(user_search, rowid, Username, F_name, L_name) VALUES ('delete', old.userid, old.Username, old.F_name, old.L_name);
+	INSERT INTO user_search(rowid, Username, F_name, L_name) VALUES (new.userid, new.Username, new.F_name, new.L_name);
+END;
+
+INSERT INTO post_search(post_search) VALUES ('rebuild');
+INSERT INTO comment_search(comment_search) VALUES ('rebuild');
+INSERT INTO user_search(user_search) VALUES ('rebuild');
@@ -0,0 +1,11 @@
+-- Saved searches, their matches and the alerts they raised go away.
+DELETE FROM notification_actors WHERE notification_id IN (
+	SELECT notificationid FROM notifications WHERE type = 'search_match'
+);
+DELETE FROM notifications WHERE type = 'search_match';
+
+DROP TABLE search_alert_progress;
+DROP INDEX idx_saved_search_matches_pending;
+DROP TABLE saved_search_matches;
+DROP INDEX idx_saved_searches_user;
+DROP TABLE saved_searches;
@@ -0,0 +1,41 @@
+-- Users can save a search, optionally limited to one category, and be told
+-- when new posts and comments match it. saved_search_matches records each
+-- match so it is only reported once and so email digests can list what came
+-- in since the last one. search_alert_progress holds the newest post and
+-- comment the matcher has looked at; it starts from what exists now, so only
+-- content added from here on raises alerts.
+CREATE TABLE saved_searches (
+	searchid INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_userid INTEGER NOT NULL,
+	query TEXT NOT NULL,
+	category_id INTEGER NULL,
+	email_digest INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_userid) REFERENCES user(userid),
+	FOREIGN KEY (category_id) REFERENCES categories(idcategories)
+);
+
+CREATE INDEX idx_saved_searches_user ON saved_searches(user_userid);
+
+CREATE TABLE saved_search_matches (
+	matchid INTEGER PRIMARY KEY AUTOINCREMENT,
+	search_id INTEGER NOT NULL,
+	post_id INTEGER NOT NULL,
+	comment_id INTEGER NOT NULL DEFAULT 0,
+	matched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	emailed_at DATETIME NULL,
+	UNIQUE (search_id, post_id, comment_id),
+	FOREIGN KEY (search_id) REFERENCES saved_searches(searchid) ON DELETE CASCADE,
+	FOREIGN KEY (post_id) REFERENCES post(postid)
+);
+
+CREATE INDEX idx_saved_search_matches_pending ON saved_search_matches(emailed_at, search_id);
+
+CREATE TABLE search_alert_progress (
+	kind TEXT PRIMARY KEY,
+	last_id INTEGER NOT NULL
+);
+
+INSERT INTO search_alert_progress (kind, last_id) VALUES
+	('post', (SELECT COALESCE(MAX(postid), 0) FROM post)),
+	('comment', (SELECT COALESCE(MAX(commentid), 0) FROM comment));
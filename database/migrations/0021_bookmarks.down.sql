@@ -0,0 +1,6 @@
+-- Bookmarks and collections go away.
+DROP INDEX idx_collection_posts_post;
+DROP TABLE collection_posts;
+DROP TABLE collections;
+DROP INDEX idx_bookmarks_post;
+DROP TABLE bookmarks;
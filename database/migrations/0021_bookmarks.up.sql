@@ -0,0 +1,37 @@
+-- Users can bookmark posts to read later and sort their bookmarks into named
+-- collections. A collection only holds bookmarked posts, so removing a
+-- bookmark takes the post out of the user's collections too. Each collection
+-- has a random share_token; anyone signed in can open a public collection
+-- with it, while a private one is only shown to its owner.
+CREATE TABLE bookmarks (
+	user_userid INTEGER NOT NULL,
+	post_id INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_userid, post_id),
+	FOREIGN KEY (user_userid) REFERENCES user(userid),
+	FOREIGN KEY (post_id) REFERENCES post(postid)
+);
+
+CREATE INDEX idx_bookmarks_post ON bookmarks(post_id);
+
+CREATE TABLE collections (
+	collectionid INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_userid INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	is_public INTEGER NOT NULL DEFAULT 0,
+	share_token TEXT NOT NULL UNIQUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (user_userid, name),
+	FOREIGN KEY (user_userid) REFERENCES user(userid)
+);
+
+CREATE TABLE collection_posts (
+	collection_id INTEGER NOT NULL,
+	post_id INTEGER NOT NULL,
+	added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (collection_id, post_id),
+	FOREIGN KEY (collection_id) REFERENCES collections(collectionid) ON DELETE CASCADE,
+	FOREIGN KEY (post_id) REFERENCES post(postid)
+);
+
+CREATE INDEX idx_collection_posts_post ON collection_posts(post_id);
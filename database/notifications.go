@@ -0,0 +1,261 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const (
+	NotificationPostLike      = "post_like"
+	NotificationCommentLike   = "comment_like"
+	NotificationComment       = "comment"
+	NotificationReply         = "comment_reply"
+	NotificationFollow        = "follow"
+	NotificationFriendRequest = "friend_request"
+	NotificationFriendAccept  = "friend_accept"
+	NotificationReport        = "report"
+	NotificationSearchMatch   = "search_match"
+)
+
+// NotificationEvent is one thing that happened to RecipientID. PostID and
+// CommentID are zero when the event is not about a post or comment.
+type NotificationEvent struct {
+	RecipientID int
+	ActorID     int
+	Type        string
+	PostID      int
+	CommentID   int
+	Message     string
+}
+
+// Link is the page a notification opens: the post it is about, or the profile
+// of whoever triggered it. Replies open the thread of the comment replied to,
+// and search alerts the thread of the comment that matched.
+func (n Notification) Link() string {
+	if (n.Type == NotificationReply || n.Type == NotificationSearchMatch) && n.CommentID != 0 {
+		return fmt.Sprintf("/post?id=%d&thread=%d", n.PostID, n.CommentID)
+	}
+	if n.PostID != 0 {
+		return fmt.Sprintf("/post?id=%d", n.PostID)
+	}
+	return fmt.Sprintf("/profile?user=%d", n.ActorID)
+}
+
+// AddNotification records an event. If the recipient already has an unread
+// notification of the same type about the same post or comment, the actor is
+// folded into it instead of creating a new row, so repeated likes read as
+// "alice and 4 others liked your post". Search alerts name the saved search
+// that matched, so only those from the same search are folded together.
+func AddNotification(db *sql.DB, event NotificationEvent) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("AddNotification: %v", err)
+	}
+	defer tx.Rollback()
+
+	postID, commentID := nullableID(event.PostID), nullableID(event.CommentID)
+
+	where := "user_userid = ? AND type = ? AND post_id IS ? AND comment_id IS ? AND is_read = 0"
+	args := []any{event.RecipientID, event.Type, postID, commentID}
+	if event.Type == NotificationSearchMatch {
+		where += " AND message = ?"
+		args = append(args, event.Message)
+	}
+
+	var notificationID int
+	err = tx.QueryRow("SELECT notificationid FROM notifications WHERE "+where+" ORDER BY created_at DESC LIMIT 1", args...).Scan(&notificationID)
+	if err == sql.ErrNoRows {
+		result, err := tx.Exec("INSERT INTO notifications (user_userid, actor_id, type, post_id, comment_id, message) VALUES (?, ?, ?, ?, ?, ?)",
+			event.RecipientID, event.ActorID, event.Type, postID, commentID, event.Message)
+		if err != nil {
+			return 0, fmt.Errorf("AddNotification: %v", err)
+		}
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("AddNotification: %v", err)
+		}
+		notificationID = int(lastID)
+	} else if err != nil {
+		return 0, fmt.Errorf("AddNotification: %v", err)
+	}
+
+	result, err := tx.Exec("INSERT OR IGNORE INTO notification_actors (notification_id, actor_id) VALUES (?, ?)", notificationID, event.ActorID)
+	if err != nil {
+		return 0, fmt.Errorf("AddNotification: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, fmt.Errorf("AddNotification: %v", err)
+	} else if n > 0 {
+		_, err = tx.Exec(`
+            UPDATE notifications
+            SET actor_id = ?, created_at = CURRENT_TIMESTAMP,
+                actor_count = (SELECT COUNT(*) FROM notification_actors WHERE notification_id = ?)
+            WHERE notificationid = ?
+        `, event.ActorID, notificationID, notificationID)
+		if err != nil {
+			return 0, fmt.Errorf("AddNotification: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("AddNotification: %v", err)
+	}
+	return notificationID, nil
+}
+
+// GetNotifications returns one page of userID's notifications, newest first.
+func GetNotifications(db *sql.DB, userID int, page Page) ([]Notification, string, error) {
+	after, more, err := page.cursor()
+	if err != nil {
+		return nil, "", fmt.Errorf("GetNotifications: %w", err)
+	}
+	var before *cursor
+	if more {
+		before = &after
+	}
+
+	size := page.size()
+	notifications, keys, err := queryNotifications(db, userID, before, size+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetNotifications: %v", err)
+	}
+
+	var next string
+	if len(notifications) > size {
+		notifications = notifications[:size]
+		next = cursor{At: keys[size-1], ID: notifications[size-1].ID}.encode()
+	}
+	return notifications, next, nil
+}
+
+func GetNotification(db *sql.DB, userID int, notificationID int) (Notification, error) {
+	var notification Notification
+	var avatar sql.NullString
+	var postID, commentID sql.NullInt64
+	err := db.QueryRow(`
+        SELECT n.notificationid, n.user_userid, n.actor_id, n.type, n.post_id, n.comment_id, n.message, n.actor_count, n.is_read, n.created_at, u.Avatar, u.Username
+        FROM notifications n
+        JOIN user u ON n.actor_id = u.userid
+        WHERE n.notificationid = ? AND n.user_userid = ?
+    `, notificationID, userID).Scan(&notification.ID, &notification.UserID, &notification.ActorID, &notification.Type, &postID, &commentID, &notification.Message, &notification.Others, &notification.IsRead, &notification.CreatedAt, &avatar, &notification.UserName)
+	if err != nil {
+		return notification, err
+	}
+	notification.PostID, notification.CommentID = int(postID.Int64), int(commentID.Int64)
+	notification.Others--
+	notification.UserImage = avatar.String
+	return notification, nil
+}
+
+// MarkNotificationRead returns sql.ErrNoRows if the notification does not
+// belong to userID.
+func MarkNotificationRead(db *sql.DB, userID int, notificationID int) error {
+	result, err := db.Exec("UPDATE notifications SET is_read = 1 WHERE notificationid = ? AND user_userid = ?", notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("MarkNotificationRead: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("MarkNotificationRead: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func MarkAllNotificationsRead(db *sql.DB, userID int) error {
+	_, err := db.Exec("UPDATE notifications SET is_read = 1 WHERE user_userid = ? AND is_read = 0", userID)
+	if err != nil {
+		return fmt.Errorf("MarkAllNotificationsRead: %v", err)
+	}
+	return nil
+}
+
+func CountUnreadNotifications(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_userid = ? AND is_read = 0", userID).Scan(&count)
+	return count, err
+}
+
+func GetPostAuthorID(db *sql.DB, postID int) (int, error) {
+	var userID int
+	err := db.QueryRow("SELECT user_userid FROM post WHERE postid = ?", postID).Scan(&userID)
+	return userID, err
+}
+
+// GetCommentAuthor returns who wrote the comment and the post it belongs to.
+func GetCommentAuthor(db *sql.DB, commentID int) (int, int, error) {
+	var userID, postID int
+	err := db.QueryRow("SELECT user_userid, post_postid FROM comment WHERE commentid = ?", commentID).Scan(&userID, &postID)
+	return userID, postID, err
+}
+
+// GetStaffUserIDs lists admins and moderators, who are told about reports.
+func GetStaffUserIDs(db *sql.DB) ([]int, error) {
+	rows, err := db.Query("SELECT userid FROM user WHERE role_id IN (1, 2) AND deleted_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// queryNotifications lists up to limit of userID's notifications newest
+// first, starting after before if it is set. keys holds each one's created_at
+// as stored, for building cursors.
+func queryNotifications(db *sql.DB, userID int, before *cursor, limit int) (notifications []Notification, keys []string, err error) {
+	where, args := "n.user_userid = ?", []any{userID}
+	if before != nil {
+		where += " AND (n.created_at, n.notificationid) < (?, ?)"
+		args = append(args, before.At, before.ID)
+	}
+
+	rows, err := db.Query(`
+        SELECT n.notificationid, n.user_userid, n.actor_id, n.type, n.post_id, n.comment_id, n.message, n.actor_count, n.is_read, n.created_at, CAST(n.created_at AS TEXT), u.Avatar, u.Username
+        FROM notifications n
+        JOIN user u ON n.actor_id = u.userid
+        WHERE `+where+`
+        ORDER BY n.created_at DESC, n.notificationid DESC
+        LIMIT ?
+    `, append(args, limit)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var notification Notification
+		var key string
+		var avatar sql.NullString
+		var postID, commentID sql.NullInt64
+
+		err := rows.Scan(&notification.ID, &notification.UserID, &notification.ActorID, &notification.Type, &postID, &commentID, &notification.Message, &notification.Others, &notification.IsRead, &notification.CreatedAt, &key, &avatar, &notification.UserName)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		notification.PostID, notification.CommentID = int(postID.Int64), int(commentID.Int64)
+		notification.Others--
+		notification.UserImage = avatar.String
+		notifications = append(notifications, notification)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return notifications, keys, nil
+}
+
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
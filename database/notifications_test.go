@@ -0,0 +1,60 @@
+package database
+
+import "testing"
+
+func TestAddNotificationMerging(t *testing.T) {
+	db := openTestDB(t)
+	owner := addTestUser(t, db, "owner")
+	alice := addTestUser(t, db, "alice")
+	bob := addTestUser(t, db, "bob")
+	post := addTestPost(t, db, owner, "Go tips", "goroutines and channels")
+	other := addTestPost(t, db, owner, "More", "channels")
+
+	events := []struct {
+		name  string
+		event NotificationEvent
+		// merged is the event whose notification this one should join, or -1
+		// for a new notification.
+		merged int
+	}{
+		{"first like", NotificationEvent{owner, alice, NotificationPostLike, post, 0, "liked your post"}, -1},
+		{"second like", NotificationEvent{owner, bob, NotificationPostLike, post, 0, "liked your post"}, 0},
+		{"like on another post", NotificationEvent{owner, bob, NotificationPostLike, other, 0, "liked your post"}, -1},
+		{"search match", NotificationEvent{owner, alice, NotificationSearchMatch, post, 0, `matched "go"`}, -1},
+		{"another search, same post", NotificationEvent{owner, alice, NotificationSearchMatch, post, 0, `matched "channels"`}, -1},
+		{"same search again", NotificationEvent{owner, bob, NotificationSearchMatch, post, 0, `matched "go"`}, 3},
+	}
+	ids := make([]int, len(events))
+	seen := map[int]bool{}
+	for i, e := range events {
+		id, err := AddNotification(db, e.event)
+		if err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+		ids[i] = id
+		if e.merged >= 0 && id != ids[e.merged] {
+			t.Errorf("%s: got notification %d, want it merged into %d", e.name, id, ids[e.merged])
+		}
+		if e.merged < 0 && seen[id] {
+			t.Errorf("%s: merged into notification %d, want a new one", e.name, id)
+		}
+		seen[id] = true
+	}
+
+	var messages []string
+	rows, err := db.Query("SELECT message FROM notifications WHERE type = ? ORDER BY notificationid", NotificationSearchMatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, m)
+	}
+	if len(messages) != 2 || messages[0] != `matched "go"` || messages[1] != `matched "channels"` {
+		t.Errorf("search alerts = %q, want one for each saved search", messages)
+	}
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrBadCursor means a page cursor was not one this package handed out.
+var ErrBadCursor = errors.New("invalid page cursor")
+
+// Page asks for one page of a list: up to Limit rows following After, the
+// cursor returned along with the previous page. The zero Page is the first
+// page at the default size.
+type Page struct {
+	After string
+	Limit int
+}
+
+func (p Page) size() int {
+	if p.Limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(p.Limit, MaxPageSize)
+}
+
+// cursor is the sort key of the last row on a page. At holds a timestamp
+// column exactly as stored, so comparing against it matches the ORDER BY.
+// Score is the leading key of orders that rank by a count, and Rank of
+// search results, which go by how well they match.
+type cursor struct {
+	Score int     `json:"s,omitempty"`
+	Rank  float64 `json:"r,omitempty"`
+	At    string  `json:"t,omitempty"`
+	ID    int     `json:"i"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// cursor parses After. ok is false for the first page.
+func (p Page) cursor() (c cursor, ok bool, err error) {
+	if p.After == "" {
+		return c, false, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(p.After)
+	if err != nil {
+		return c, false, ErrBadCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.ID <= 0 {
+		return c, false, ErrBadCursor
+	}
+	return c, true, nil
+}
+
+type postOrder int
+
+const (
+	newestFirst postOrder = iota
+	oldestFirst
+	topRated
+	// bestMatch orders search results by their BM25 rank, most relevant
+	// first. Titles weigh ten times as much as content.
+	bestMatch
+)
+
+// postOrderFor maps the filter names used by the pages to an order.
+func postOrderFor(filter string) postOrder {
+	switch filter {
+	case "oldest":
+		return oldestFirst
+	case "top-rated":
+		return topRated
+	}
+	return newestFirst
+}
+
+// postQuery selects a feed of posts. where holds conditions on post that are
+// ANDed together, and match is a full-text query the posts must also match.
+type postQuery struct {
+	where []string
+	args  []any
+	order postOrder
+	match string
+}
+
+// queryPosts returns one page of the posts q selects, with their categories,
+// and the cursor of the next page or "" if this is the last.
+func queryPosts(db *sql.DB, q postQuery, page Page) ([]Post, string, error) {
+	after, more, err := page.cursor()
+	if err != nil {
+		return nil, "", err
+	}
+
+	where := append([]string{"post.deleted_at IS NULL"}, q.where...)
+	args := q.args
+	from, rank, snippet := "post", "0", "''"
+	if q.match != "" {
+		from = "post_search JOIN post ON post.postid = post_search.rowid"
+		rank = "bm25(post_search, 10.0, 1.0)"
+		snippet = "snippet(post_search, 1, " + snippetArgs + ")"
+		where = append(where, "post_search MATCH ?")
+		args = append(args, q.match)
+	}
+	var orderBy string
+	switch q.order {
+	case oldestFirst:
+		orderBy = "post.post_at ASC, post.postid ASC"
+		if more {
+			where = append(where, "(post.post_at, post.postid) > (?, ?)")
+			args = append(args, after.At, after.ID)
+		}
+	case topRated:
+		orderBy = "post.like_count DESC, post.post_at DESC, post.postid DESC"
+		if more {
+			where = append(where, "(post.like_count, post.post_at, post.postid) < (?, ?, ?)")
+			args = append(args, after.Score, after.At, after.ID)
+		}
+	case bestMatch:
+		orderBy = rank + ", post.postid"
+		if more {
+			where = append(where, "("+rank+", post.postid) > (?, ?)")
+			args = append(args, after.Rank, after.ID)
+		}
+	default:
+		orderBy = "post.post_at DESC, post.postid DESC"
+		if more {
+			where = append(where, "(post.post_at, post.postid) < (?, ?)")
+			args = append(args, after.At, after.ID)
+		}
+	}
+
+	size := page.size()
+	rows, err := db.Query(`
+        SELECT post.postid, post.title, post.content, post.post_at, CAST(post.post_at AS TEXT), post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
+               post.like_count AS Likes,
+               post.dislike_count AS Dislikes,
+               post.comment_count AS Comments,
+               post.edited_at, `+rank+`, `+snippet+`
+        FROM `+from+`
+        JOIN user ON post.user_userid = user.userid
+        WHERE `+strings.Join(where, " AND ")+`
+        ORDER BY `+orderBy+`
+        LIMIT ?
+    `, append(args, size+1)...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	var keys []cursor
+	for rows.Next() {
+		var post Post
+		var key cursor
+		if err := rows.Scan(&post.PostID, &post.Title, &post.Content, &post.PostAt, &key.At, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments, &post.EditedAt, &key.Rank, &post.Snippet); err != nil {
+			return nil, "", err
+		}
+		key.ID = post.PostID
+		if q.order == topRated {
+			key.Score = post.Likes
+		}
+		posts = append(posts, post)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(posts) > size {
+		posts = posts[:size]
+		next = keys[size-1].encode()
+	}
+
+	if err := attachCategories(db, posts); err != nil {
+		return nil, "", err
+	}
+	if err := attachFiles(db, posts); err != nil {
+		return nil, "", err
+	}
+	return posts, next, nil
+}
+
+// attachCategories fills in Categories for every post with one query.
+func attachCategories(db *sql.DB, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(posts))
+	args := make([]any, len(posts))
+	for i, post := range posts {
+		index[post.PostID] = i
+		args[i] = post.PostID
+	}
+
+	rows, err := db.Query(`
+        SELECT phc.post_postid, c.idcategories, c.name
+        FROM categories c
+        JOIN post_has_categories phc ON c.idcategories = phc.categories_idcategories
+        WHERE phc.post_postid IN (?`+strings.Repeat(", ?", len(posts)-1)+`)
+        ORDER BY phc.id
+    `, args...)
+	if err != nil {
+		return fmt.Errorf("attachCategories: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var category Category
+		if err := rows.Scan(&postID, &category.ID, &category.Name); err != nil {
+			return fmt.Errorf("attachCategories: %v", err)
+		}
+		i := index[postID]
+		posts[i].Categories = append(posts[i].Categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("attachCategories: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// GetRolePermissions returns the names of every permission granted to a role.
+func GetRolePermissions(db *sql.DB, roleID int) ([]string, error) {
+	rows, err := db.Query(`
+        SELECT permissions.name
+        FROM role_permissions
+        JOIN permissions ON permissions.permissionid = role_permissions.permission_id
+        WHERE role_permissions.role_id = ?
+    `, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("GetRolePermissions: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("GetRolePermissions: %v", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetRolePermissions: %v", err)
+	}
+	return names, nil
+}
+
+// Role IDs as seeded in user_roles. Lower IDs are more privileged.
+const (
+	RoleAdmin     = 1
+	RoleModerator = 2
+	RoleUser      = 3
+	RoleGuest     = 4
+)
+
+// PromoteUserByEmail gives the account registered with email the role roleID
+// unless it already holds that role or a more privileged one. It reports
+// whether an account was changed.
+func PromoteUserByEmail(db *sql.DB, email string, roleID int) (bool, error) {
+	result, err := db.Exec("UPDATE user SET role_id = ? WHERE lower(Email) = lower(?) AND role_id > ?", roleID, email, roleID)
+	if err != nil {
+		return false, fmt.Errorf("PromoteUserByEmail: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("PromoteUserByEmail: %v", err)
+	}
+	return n > 0, nil
+}
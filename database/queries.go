@@ -3,20 +3,13 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
 )
 
-func Select(colToReturn string, table string, where string, input string) (string, error) {
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-
+func Select(db *sql.DB, colToReturn string, table string, where string, input string) (string, error) {
 	statement := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", colToReturn, table, where)
 
 	var returnedValue string
-	err = db.QueryRow(statement, input).Scan(&returnedValue)
+	err := db.QueryRow(statement, input).Scan(&returnedValue)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,377 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+)
+
+// Things a reaction can be on, as stored in reactions.target_type.
+const (
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+var (
+	// ErrUnknownReaction means a reaction kind or target that is not set up.
+	ErrUnknownReaction = errors.New("unknown reaction")
+	// ErrBuiltinReaction means an attempt to remove like or dislike.
+	ErrBuiltinReaction = errors.New("built-in reactions cannot be removed")
+)
+
+// ReactionType is one of the reactions users can pick. Builtin types back the
+// vote buttons and cannot be removed.
+type ReactionType struct {
+	Name     string
+	Emoji    string
+	Label    string
+	Position int
+	Builtin  bool
+}
+
+// ReactionState is a user's reaction to a post or comment after a change,
+// along with the totals it leaves. Reaction is "" when the user has none.
+type ReactionState struct {
+	Reaction string         `json:"reaction"`
+	Likes    int            `json:"likes"`
+	Dislikes int            `json:"dislikes"`
+	Counts   map[string]int `json:"counts"`
+	// Previous is the reaction the change replaced.
+	Previous string `json:"-"`
+}
+
+// reactionCounters are the columns on post and comment that also total the
+// built-in reactions.
+var reactionCounters = map[string]string{
+	ReactionLike:    "like_count",
+	ReactionDislike: "dislike_count",
+}
+
+var reactionTargets = map[string]struct{ table, key string }{
+	TargetPost:    {"post", "postid"},
+	TargetComment: {"comment", "commentid"},
+}
+
+// ToggleReaction takes back userID's reaction of kind if they have it, and
+// otherwise sets it, replacing any other reaction they had. It returns
+// sql.ErrNoRows if the target does not exist.
+func ToggleReaction(db *sql.DB, target string, targetID, userID int, kind string) (ReactionState, error) {
+	state, err := react(db, target, targetID, userID, kind, func(previous string) string {
+		if previous == kind {
+			return ""
+		}
+		return kind
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, ErrUnknownReaction) {
+		return state, fmt.Errorf("ToggleReaction: %v", err)
+	}
+	return state, err
+}
+
+// SetReaction is the idempotent form of ToggleReaction: with on it makes kind
+// userID's reaction, and without it removes kind if that is their reaction.
+// Repeating a call changes nothing.
+func SetReaction(db *sql.DB, target string, targetID, userID int, kind string, on bool) (ReactionState, error) {
+	state, err := react(db, target, targetID, userID, kind, func(previous string) string {
+		if on {
+			return kind
+		}
+		if previous == kind {
+			return ""
+		}
+		return previous
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, ErrUnknownReaction) {
+		return state, fmt.Errorf("SetReaction: %v", err)
+	}
+	return state, err
+}
+
+// react moves userID's reaction to the one next picks given the current one,
+// and keeps the target's counters in step, all in one transaction. The unique
+// key on reactions means concurrent requests can never leave a user with two.
+func react(db *sql.DB, target string, targetID, userID int, kind string, next func(previous string) string) (ReactionState, error) {
+	parent, ok := reactionTargets[target]
+	if !ok {
+		return ReactionState{}, ErrUnknownReaction
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return ReactionState{}, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM reaction_types WHERE name = ?)", kind).Scan(&exists)
+	if err != nil {
+		return ReactionState{}, err
+	}
+	if !exists {
+		return ReactionState{}, ErrUnknownReaction
+	}
+
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM "+parent.table+" WHERE "+parent.key+" = ? AND deleted_at IS NULL)", targetID).Scan(&exists)
+	if err != nil {
+		return ReactionState{}, err
+	}
+	if !exists {
+		return ReactionState{}, sql.ErrNoRows
+	}
+
+	var state ReactionState
+	err = tx.QueryRow("SELECT kind FROM reactions WHERE target_type = ? AND target_id = ? AND user_userid = ?",
+		target, targetID, userID).Scan(&state.Previous)
+	if err != nil && err != sql.ErrNoRows {
+		return ReactionState{}, err
+	}
+	state.Reaction = next(state.Previous)
+
+	if state.Reaction != state.Previous {
+		if state.Reaction == "" {
+			_, err = tx.Exec("DELETE FROM reactions WHERE target_type = ? AND target_id = ? AND user_userid = ?",
+				target, targetID, userID)
+		} else {
+			_, err = tx.Exec(`
+                INSERT INTO reactions (target_type, target_id, user_userid, kind, created_at) VALUES (?, ?, ?, ?, ?)
+                ON CONFLICT (target_type, target_id, user_userid) DO UPDATE SET kind = excluded.kind, created_at = excluded.created_at
+            `, target, targetID, userID, state.Reaction, time.Now())
+		}
+		if err != nil {
+			return ReactionState{}, err
+		}
+
+		if state.Previous != "" {
+			_, err = tx.Exec("UPDATE reaction_counts SET count = MAX(count - 1, 0) WHERE target_type = ? AND target_id = ? AND kind = ?",
+				target, targetID, state.Previous)
+			if err != nil {
+				return ReactionState{}, err
+			}
+		}
+		if state.Reaction != "" {
+			_, err = tx.Exec(`
+                INSERT INTO reaction_counts (target_type, target_id, kind, count) VALUES (?, ?, ?, 1)
+                ON CONFLICT (target_type, target_id, kind) DO UPDATE SET count = count + 1
+            `, target, targetID, state.Reaction)
+			if err != nil {
+				return ReactionState{}, err
+			}
+		}
+		if counter, ok := reactionCounters[state.Previous]; ok {
+			_, err = tx.Exec("UPDATE "+parent.table+" SET "+counter+" = MAX("+counter+" - 1, 0) WHERE "+parent.key+" = ?", targetID)
+			if err != nil {
+				return ReactionState{}, err
+			}
+		}
+		if counter, ok := reactionCounters[state.Reaction]; ok {
+			_, err = tx.Exec("UPDATE "+parent.table+" SET "+counter+" = "+counter+" + 1 WHERE "+parent.key+" = ?", targetID)
+			if err != nil {
+				return ReactionState{}, err
+			}
+		}
+	}
+
+	err = tx.QueryRow("SELECT like_count, dislike_count FROM "+parent.table+" WHERE "+parent.key+" = ?", targetID).
+		Scan(&state.Likes, &state.Dislikes)
+	if err != nil {
+		return ReactionState{}, err
+	}
+	counts, err := reactionCounts(tx, target, []int{targetID})
+	if err != nil {
+		return ReactionState{}, err
+	}
+	state.Counts = counts[targetID]
+	if state.Counts == nil {
+		state.Counts = map[string]int{}
+	}
+	if err := tx.Commit(); err != nil {
+		return ReactionState{}, err
+	}
+	return state, nil
+}
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// reactionCounts returns the non-zero totals per reaction type of each target.
+func reactionCounts(q querier, target string, ids []int) (map[int]map[string]int, error) {
+	counts := make(map[int]map[string]int, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	args := []any{target}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := q.Query(`
+        SELECT target_id, kind, count FROM reaction_counts
+        WHERE target_type = ? AND target_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) AND count > 0
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, count int
+		var kind string
+		if err := rows.Scan(&id, &kind, &count); err != nil {
+			return nil, err
+		}
+		if counts[id] == nil {
+			counts[id] = make(map[string]int)
+		}
+		counts[id][kind] = count
+	}
+	return counts, rows.Err()
+}
+
+// viewerReactions returns userID's reaction to each of the targets that they
+// reacted to.
+func viewerReactions(db *sql.DB, target string, ids []int, userID int) (map[int]string, error) {
+	mine := make(map[int]string)
+	if len(ids) == 0 || userID == 0 {
+		return mine, nil
+	}
+	args := []any{target, userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := db.Query(`
+        SELECT target_id, kind FROM reactions
+        WHERE target_type = ? AND user_userid = ? AND target_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var kind string
+		if err := rows.Scan(&id, &kind); err != nil {
+			return nil, err
+		}
+		mine[id] = kind
+	}
+	return mine, rows.Err()
+}
+
+// AttachPostReactions fills in Reactions and, for viewerID, MyReaction on
+// each post. A viewerID of 0 is a guest.
+func AttachPostReactions(db *sql.DB, posts []Post, viewerID int) error {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.PostID
+	}
+	counts, err := reactionCounts(db, TargetPost, ids)
+	if err != nil {
+		return fmt.Errorf("AttachPostReactions: %v", err)
+	}
+	mine, err := viewerReactions(db, TargetPost, ids, viewerID)
+	if err != nil {
+		return fmt.Errorf("AttachPostReactions: %v", err)
+	}
+	for i := range posts {
+		posts[i].Reactions = counts[posts[i].PostID]
+		posts[i].MyReaction = mine[posts[i].PostID]
+	}
+	return nil
+}
+
+// AttachCommentReactions is AttachPostReactions for comments.
+func AttachCommentReactions(db *sql.DB, comments []Comment, viewerID int) error {
+	ids := make([]int, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	counts, err := reactionCounts(db, TargetComment, ids)
+	if err != nil {
+		return fmt.Errorf("AttachCommentReactions: %v", err)
+	}
+	mine, err := viewerReactions(db, TargetComment, ids, viewerID)
+	if err != nil {
+		return fmt.Errorf("AttachCommentReactions: %v", err)
+	}
+	for i := range comments {
+		comments[i].Reactions = counts[comments[i].ID]
+		comments[i].MyReaction = mine[comments[i].ID]
+	}
+	return nil
+}
+
+func GetReactionTypes(db *sql.DB) ([]ReactionType, error) {
+	rows, err := db.Query("SELECT name, emoji, label, position, builtin FROM reaction_types ORDER BY position, name")
+	if err != nil {
+		return nil, fmt.Errorf("GetReactionTypes: %v", err)
+	}
+	defer rows.Close()
+
+	var types []ReactionType
+	for rows.Next() {
+		var t ReactionType
+		if err := rows.Scan(&t.Name, &t.Emoji, &t.Label, &t.Position, &t.Builtin); err != nil {
+			return nil, fmt.Errorf("GetReactionTypes: %v", err)
+		}
+		types = append(types, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetReactionTypes: %v", err)
+	}
+	return types, nil
+}
+
+// SaveReactionType adds t, or updates the emoji, label and position of the
+// type with its name.
+func SaveReactionType(db *sql.DB, t ReactionType) error {
+	_, err := db.Exec(`
+        INSERT INTO reaction_types (name, emoji, label, position) VALUES (?, ?, ?, ?)
+        ON CONFLICT (name) DO UPDATE SET emoji = excluded.emoji, label = excluded.label, position = excluded.position
+    `, t.Name, t.Emoji, t.Label, t.Position)
+	if err != nil {
+		return fmt.Errorf("SaveReactionType: %v", err)
+	}
+	return nil
+}
+
+// DeleteReactionType removes a reaction type along with every reaction of
+// that type. It returns ErrBuiltinReaction for like and dislike, and
+// sql.ErrNoRows when there is no such type.
+func DeleteReactionType(db *sql.DB, name string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("DeleteReactionType: %v", err)
+	}
+	defer tx.Rollback()
+
+	var builtin bool
+	err = tx.QueryRow("DELETE FROM reaction_types WHERE name = ? RETURNING builtin", name).Scan(&builtin)
+	if err == sql.ErrNoRows {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("DeleteReactionType: %v", err)
+	}
+	if builtin {
+		return ErrBuiltinReaction
+	}
+
+	if _, err := tx.Exec("DELETE FROM reactions WHERE kind = ?", name); err != nil {
+		return fmt.Errorf("DeleteReactionType: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM reaction_counts WHERE kind = ?", name); err != nil {
+		return fmt.Errorf("DeleteReactionType: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteReactionType: %v", err)
+	}
+	return nil
+}
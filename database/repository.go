@@ -0,0 +1,725 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Repository is everything the HTTP layer needs from storage. Handlers depend
+// on this interface rather than on *sql.DB so that a Store over an in-memory
+// database can stand in for the real one.
+type Repository interface {
+	GetAllCategories() ([]Category, error)
+	GetComments() ([]Comment, error)
+	GetUserReaction(userid int, filter string) ([]Post, error)
+	GetUserLikedPosts(userID int) ([]Post, error)
+	GetUserDislikedPosts(userID int) ([]Post, error)
+	GetUserCommentedPosts(userid int, filter string) ([]Post, error)
+	GetAllPosts(page Page) ([]Post, string, error)
+	GetCategoriesForPost(postID int) ([]Category, error)
+	GetAllUsers(page Page) ([]User, string, error)
+	GetFilteredPosts(filter string, page Page) ([]Post, string, error)
+	GetPostsByMultiCategory(categoryName string) ([]Post, error)
+	GetPostsByCategory(categoryName string, page Page) ([]Post, string, error)
+	GetLastNotifications(userID int) ([]Notification, error)
+	InsertPost(content string, title string, userID int, attachments []Attachment) (int, error)
+	InsertPostCategory(postID int, categoryID int) error
+	GetUserPosts(userID int, filter string, page Page) ([]Post, string, error)
+	GetFollowersCount(userID int) (int, error)
+	GetFollowingCount(userID int) (int, error)
+	GetFriendsCount(userID int) (int, error)
+	IsFollowing(userID int, profileUserID int) (bool, error)
+	GetTotalUsersCount() (int, error)
+	GetTotalPostsCount() (int, error)
+	GetTotalCategoriesCount() (int, error)
+	GetAllReports() ([]Report, error)
+	GetCommentsForPost(postID int, page Page) ([]Comment, string, error)
+	GetUserLogs(userID int) ([]UserLog, error)
+	GetFollowers(userID int) ([]User, error)
+	GetFollowing(userID int) ([]User, error)
+	GetFriends(userID int) ([]User, error)
+	GetTotalLikes(userID int) (int, error)
+	GetTotalPosts(userID int) (int, error)
+	GetUserByID(userID int) (User, error)
+	GetRoleNameByID(roleID int) (string, error)
+	GetFriendsPosts(userID int, page Page) ([]Post, string, error)
+	GetFollowingPosts(userID int, page Page) ([]Post, string, error)
+	GetUserCredentials(email string) (User, error)
+	UsernameExists(username string) (bool, error)
+	EmailExists(email string) (bool, error)
+	InsertUser(user User, provider string, session UserSession) (int, error)
+	UpsertOAuthUser(account OAuthAccount, session UserSession) (int, error)
+	GetUserPassword(userID int) (string, error)
+	UpdateUserPassword(userID int, passwordHash string) error
+	UpdateUserProfile(user User) error
+	UpdateUserRole(userID int, roleID int) error
+	GetPostByID(postID int) (Post, error)
+	InsertComment(postID int, parentID int, userID int, content string) (int, error)
+	InsertCategory(name string) error
+	SetCategoryMaxLength(categoryID, maxLength int) error
+	DeleteCategory(categoryID int) error
+	InsertReport(postID int, reportedBy int, reason string) error
+	InsertCommentReport(commentID int, reportedBy int, reason string) error
+	DeleteReport(reportID int) error
+	GetUserRoleID(userID int) (int, error)
+	GetUserAvatarAndRole(userID int) (sql.NullString, int, error)
+	CreateAPIToken(token APIToken) (int, error)
+	GetAPITokenByHash(hash string) (APIToken, error)
+	TouchAPIToken(tokenID int) error
+	GetUserAPITokens(userID int) ([]APIToken, error)
+	GetAllAPITokens() ([]APIToken, error)
+	RevokeAPIToken(userID, tokenID int) error
+	DeleteAPIToken(tokenID int) error
+	SetBookmark(userID, postID int, on bool) error
+	AttachPostBookmarks(posts []Post, viewerID int) error
+	GetBookmarkedPosts(userID int, page Page) ([]Post, string, error)
+	GetCollectionPosts(collectionID int, page Page) ([]Post, string, error)
+	CreateCollection(userID int, name, shareToken string) (int, error)
+	GetUserCollections(userID int) ([]Collection, error)
+	GetPostCollections(userID, postID int) ([]Collection, error)
+	GetCollectionByToken(token string) (Collection, error)
+	RenameCollection(userID, collectionID int, name string) error
+	SetCollectionPublic(userID, collectionID int, public bool) error
+	DeleteCollection(userID, collectionID int) error
+	SetCollectionPost(userID, collectionID, postID int, on bool) error
+	GetPostCounts(postID int) (PostCounts, error)
+	GetCommentCounts(commentID int) (CommentCounts, error)
+	RepairCounts() (CountRepairs, error)
+	GetCommentByID(commentID int) (Comment, error)
+	SaveMedia(m Media, variants map[string]Media) error
+	GetMedia(hash, variant string) (Media, error)
+	NextPostImageBlob() (postID int, image []byte, err error)
+	SetPostImage(postID int, hash string) error
+	AddNotification(event NotificationEvent) (int, error)
+	GetNotifications(userID int, page Page) ([]Notification, string, error)
+	GetNotification(userID int, notificationID int) (Notification, error)
+	MarkNotificationRead(userID int, notificationID int) error
+	MarkAllNotificationsRead(userID int) error
+	CountUnreadNotifications(userID int) (int, error)
+	GetPostAuthorID(postID int) (int, error)
+	GetCommentAuthor(commentID int) (int, int, error)
+	GetStaffUserIDs() ([]int, error)
+	GetRolePermissions(roleID int) ([]string, error)
+	PromoteUserByEmail(email string, roleID int) (bool, error)
+	Select(colToReturn string, table string, where string, input string) (string, error)
+	ToggleReaction(target string, targetID, userID int, kind string) (ReactionState, error)
+	SetReaction(target string, targetID, userID int, kind string, on bool) (ReactionState, error)
+	AttachPostReactions(posts []Post, viewerID int) error
+	AttachCommentReactions(comments []Comment, viewerID int) error
+	GetReactionTypes() ([]ReactionType, error)
+	SaveReactionType(t ReactionType) error
+	DeleteReactionType(name string) error
+	UpdatePost(postID, editorID int, title, content string) error
+	UpdateComment(commentID, editorID int, content string) error
+	GetPostHistory(postID int) ([]Version, error)
+	GetCommentHistory(commentID int) ([]Version, error)
+	SaveSearch(search SavedSearch) (int, error)
+	GetSavedSearches(userID int) ([]SavedSearch, error)
+	SetSavedSearchDigest(userID, searchID int, on bool) error
+	DeleteSavedSearch(userID, searchID int) error
+	MatchSavedSearches() ([]SearchMatch, error)
+	GetPendingDigests(since time.Time) ([]SearchDigest, error)
+	MarkDigestSent(matchIDs []int) error
+	SearchPosts(q SearchQuery, page Page) ([]Post, string, error)
+	SearchComments(q SearchQuery, page Page) ([]Comment, string, error)
+	SearchUsers(q SearchQuery, page Page) ([]User, string, error)
+	SearchCategories(q SearchQuery) ([]Category, error)
+	CreateSession(session UserSession) error
+	GetSession(token string) (UserSession, error)
+	GetUserBySession(token string) (User, error)
+	SessionExists(token string) (bool, error)
+	RenewSession(token string, expiresAt time.Time) error
+	GetUserSessions(userID int) ([]UserSession, error)
+	RevokeSession(userID, sessionID int) error
+	DeleteSession(userID int) error
+	DeleteExpiredSessions() (int64, error)
+	FollowUser(followerID int, followingID int) error
+	UnfollowUser(followerID int, followingID int) error
+	SendFriendRequest(senderID int, receiverID int) error
+	AcceptFriendRequest(receiverID int, senderID int) error
+	DeclineFriendRequest(receiverID int, senderID int) error
+	GetFriendStatus(userID int, otherID int) (string, error)
+	GetFriendRequests(userID int) ([]User, error)
+	GetCommentThread(postID, commentID int) ([]Comment, error)
+	DeletePost(postID, deletedBy int) error
+	DeleteComment(commentID, deletedBy int) error
+	DeleteUser(userID, deletedBy int) error
+	RestorePost(postID int) error
+	RestoreComment(commentID int) error
+	RestoreUser(userID int) error
+	GetTrash(page Page) ([]TrashItem, string, error)
+	PurgeTrash(cutoff time.Time) (TrashPurge, error)
+}
+
+// Store implements Repository on top of the shared connection pool.
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+func (s *Store) GetAllCategories() ([]Category, error) {
+	return GetAllCategories(s.db)
+}
+
+func (s *Store) GetComments() ([]Comment, error) {
+	return GetComments(s.db)
+}
+
+func (s *Store) GetUserReaction(userid int, filter string) ([]Post, error) {
+	return GetUserReaction(s.db, userid, filter)
+}
+
+func (s *Store) GetUserLikedPosts(userID int) ([]Post, error) {
+	return GetUserLikedPosts(s.db, userID)
+}
+
+func (s *Store) GetUserDislikedPosts(userID int) ([]Post, error) {
+	return GetUserDislikedPosts(s.db, userID)
+}
+
+func (s *Store) GetUserCommentedPosts(userid int, filter string) ([]Post, error) {
+	return GetUserCommentedPosts(s.db, userid, filter)
+}
+
+func (s *Store) GetAllPosts(page Page) ([]Post, string, error) {
+	return GetAllPosts(s.db, page)
+}
+
+func (s *Store) GetCategoriesForPost(postID int) ([]Category, error) {
+	return GetCategoriesForPost(s.db, postID)
+}
+
+func (s *Store) GetAllUsers(page Page) ([]User, string, error) {
+	return GetAllUsers(s.db, page)
+}
+
+func (s *Store) GetFilteredPosts(filter string, page Page) ([]Post, string, error) {
+	return GetFilteredPosts(s.db, filter, page)
+}
+
+func (s *Store) GetPostsByMultiCategory(categoryName string) ([]Post, error) {
+	return GetPostsByMultiCategory(s.db, categoryName)
+}
+
+func (s *Store) GetPostsByCategory(categoryName string, page Page) ([]Post, string, error) {
+	return GetPostsByCategory(s.db, categoryName, page)
+}
+
+func (s *Store) GetLastNotifications(userID int) ([]Notification, error) {
+	return GetLastNotifications(s.db, userID)
+}
+
+func (s *Store) InsertPost(content string, title string, userID int, attachments []Attachment) (int, error) {
+	return InsertPost(s.db, content, title, userID, attachments)
+}
+
+func (s *Store) InsertPostCategory(postID int, categoryID int) error {
+	return InsertPostCategory(s.db, postID, categoryID)
+}
+
+func (s *Store) GetUserPosts(userID int, filter string, page Page) ([]Post, string, error) {
+	return GetUserPosts(s.db, userID, filter, page)
+}
+
+func (s *Store) GetFollowersCount(userID int) (int, error) {
+	return GetFollowersCount(s.db, userID)
+}
+
+func (s *Store) GetFollowingCount(userID int) (int, error) {
+	return GetFollowingCount(s.db, userID)
+}
+
+func (s *Store) GetFriendsCount(userID int) (int, error) {
+	return GetFriendsCount(s.db, userID)
+}
+
+func (s *Store) IsFollowing(userID int, profileUserID int) (bool, error) {
+	return IsFollowing(s.db, userID, profileUserID)
+}
+
+func (s *Store) GetTotalUsersCount() (int, error) {
+	return GetTotalUsersCount(s.db)
+}
+
+func (s *Store) GetTotalPostsCount() (int, error) {
+	return GetTotalPostsCount(s.db)
+}
+
+func (s *Store) GetTotalCategoriesCount() (int, error) {
+	return GetTotalCategoriesCount(s.db)
+}
+
+func (s *Store) GetAllReports() ([]Report, error) {
+	return GetAllReports(s.db)
+}
+
+func (s *Store) GetCommentsForPost(postID int, page Page) ([]Comment, string, error) {
+	return GetCommentsForPost(s.db, postID, page)
+}
+
+func (s *Store) GetUserLogs(userID int) ([]UserLog, error) {
+	return GetUserLogs(s.db, userID)
+}
+
+func (s *Store) GetFollowers(userID int) ([]User, error) {
+	return GetFollowers(s.db, userID)
+}
+
+func (s *Store) GetFollowing(userID int) ([]User, error) {
+	return GetFollowing(s.db, userID)
+}
+
+func (s *Store) GetFriends(userID int) ([]User, error) {
+	return GetFriends(s.db, userID)
+}
+
+func (s *Store) GetTotalLikes(userID int) (int, error) {
+	return GetTotalLikes(s.db, userID)
+}
+
+func (s *Store) GetTotalPosts(userID int) (int, error) {
+	return GetTotalPosts(s.db, userID)
+}
+
+func (s *Store) GetUserByID(userID int) (User, error) {
+	return GetUserByID(s.db, userID)
+}
+
+func (s *Store) GetRoleNameByID(roleID int) (string, error) {
+	return GetRoleNameByID(s.db, roleID)
+}
+
+func (s *Store) GetFriendsPosts(userID int, page Page) ([]Post, string, error) {
+	return GetFriendsPosts(s.db, userID, page)
+}
+
+func (s *Store) GetFollowingPosts(userID int, page Page) ([]Post, string, error) {
+	return GetFollowingPosts(s.db, userID, page)
+}
+
+func (s *Store) GetUserCredentials(email string) (User, error) {
+	return GetUserCredentials(s.db, email)
+}
+
+func (s *Store) UsernameExists(username string) (bool, error) {
+	return UsernameExists(s.db, username)
+}
+
+func (s *Store) EmailExists(email string) (bool, error) {
+	return EmailExists(s.db, email)
+}
+
+func (s *Store) InsertUser(user User, provider string, session UserSession) (int, error) {
+	return InsertUser(s.db, user, provider, session)
+}
+
+func (s *Store) UpsertOAuthUser(account OAuthAccount, session UserSession) (int, error) {
+	return UpsertOAuthUser(s.db, account, session)
+}
+
+func (s *Store) GetUserPassword(userID int) (string, error) {
+	return GetUserPassword(s.db, userID)
+}
+
+func (s *Store) UpdateUserPassword(userID int, passwordHash string) error {
+	return UpdateUserPassword(s.db, userID, passwordHash)
+}
+
+func (s *Store) UpdateUserProfile(user User) error {
+	return UpdateUserProfile(s.db, user)
+}
+
+func (s *Store) UpdateUserRole(userID int, roleID int) error {
+	return UpdateUserRole(s.db, userID, roleID)
+}
+
+func (s *Store) GetPostByID(postID int) (Post, error) {
+	return GetPostByID(s.db, postID)
+}
+
+func (s *Store) InsertComment(postID int, parentID int, userID int, content string) (int, error) {
+	return InsertComment(s.db, postID, parentID, userID, content)
+}
+
+func (s *Store) InsertCategory(name string) error {
+	return InsertCategory(s.db, name)
+}
+
+func (s *Store) SetCategoryMaxLength(categoryID, maxLength int) error {
+	return SetCategoryMaxLength(s.db, categoryID, maxLength)
+}
+
+func (s *Store) DeleteCategory(categoryID int) error {
+	return DeleteCategory(s.db, categoryID)
+}
+
+func (s *Store) InsertReport(postID int, reportedBy int, reason string) error {
+	return InsertReport(s.db, postID, reportedBy, reason)
+}
+
+func (s *Store) InsertCommentReport(commentID int, reportedBy int, reason string) error {
+	return InsertCommentReport(s.db, commentID, reportedBy, reason)
+}
+
+func (s *Store) DeleteReport(reportID int) error {
+	return DeleteReport(s.db, reportID)
+}
+
+func (s *Store) GetUserRoleID(userID int) (int, error) {
+	return GetUserRoleID(s.db, userID)
+}
+
+func (s *Store) GetUserAvatarAndRole(userID int) (sql.NullString, int, error) {
+	return GetUserAvatarAndRole(s.db, userID)
+}
+
+func (s *Store) CreateAPIToken(token APIToken) (int, error) {
+	return CreateAPIToken(s.db, token)
+}
+
+func (s *Store) GetAPITokenByHash(hash string) (APIToken, error) {
+	return GetAPITokenByHash(s.db, hash)
+}
+
+func (s *Store) TouchAPIToken(tokenID int) error {
+	return TouchAPIToken(s.db, tokenID)
+}
+
+func (s *Store) GetUserAPITokens(userID int) ([]APIToken, error) {
+	return GetUserAPITokens(s.db, userID)
+}
+
+func (s *Store) GetAllAPITokens() ([]APIToken, error) {
+	return GetAllAPITokens(s.db)
+}
+
+func (s *Store) RevokeAPIToken(userID, tokenID int) error {
+	return RevokeAPIToken(s.db, userID, tokenID)
+}
+
+func (s *Store) DeleteAPIToken(tokenID int) error {
+	return DeleteAPIToken(s.db, tokenID)
+}
+
+func (s *Store) SetBookmark(userID, postID int, on bool) error {
+	return SetBookmark(s.db, userID, postID, on)
+}
+
+func (s *Store) AttachPostBookmarks(posts []Post, viewerID int) error {
+	return AttachPostBookmarks(s.db, posts, viewerID)
+}
+
+func (s *Store) GetBookmarkedPosts(userID int, page Page) ([]Post, string, error) {
+	return GetBookmarkedPosts(s.db, userID, page)
+}
+
+func (s *Store) GetCollectionPosts(collectionID int, page Page) ([]Post, string, error) {
+	return GetCollectionPosts(s.db, collectionID, page)
+}
+
+func (s *Store) CreateCollection(userID int, name, shareToken string) (int, error) {
+	return CreateCollection(s.db, userID, name, shareToken)
+}
+
+func (s *Store) GetUserCollections(userID int) ([]Collection, error) {
+	return GetUserCollections(s.db, userID)
+}
+
+func (s *Store) GetPostCollections(userID, postID int) ([]Collection, error) {
+	return GetPostCollections(s.db, userID, postID)
+}
+
+func (s *Store) GetCollectionByToken(token string) (Collection, error) {
+	return GetCollectionByToken(s.db, token)
+}
+
+func (s *Store) RenameCollection(userID, collectionID int, name string) error {
+	return RenameCollection(s.db, userID, collectionID, name)
+}
+
+func (s *Store) SetCollectionPublic(userID, collectionID int, public bool) error {
+	return SetCollectionPublic(s.db, userID, collectionID, public)
+}
+
+func (s *Store) DeleteCollection(userID, collectionID int) error {
+	return DeleteCollection(s.db, userID, collectionID)
+}
+
+func (s *Store) SetCollectionPost(userID, collectionID, postID int, on bool) error {
+	return SetCollectionPost(s.db, userID, collectionID, postID, on)
+}
+
+func (s *Store) GetPostCounts(postID int) (PostCounts, error) {
+	return GetPostCounts(s.db, postID)
+}
+
+func (s *Store) GetCommentCounts(commentID int) (CommentCounts, error) {
+	return GetCommentCounts(s.db, commentID)
+}
+
+func (s *Store) RepairCounts() (CountRepairs, error) {
+	return RepairCounts(s.db)
+}
+
+func (s *Store) GetCommentByID(commentID int) (Comment, error) {
+	return GetCommentByID(s.db, commentID)
+}
+
+func (s *Store) SaveMedia(m Media, variants map[string]Media) error {
+	return SaveMedia(s.db, m, variants)
+}
+
+func (s *Store) GetMedia(hash, variant string) (Media, error) {
+	return GetMedia(s.db, hash, variant)
+}
+
+func (s *Store) NextPostImageBlob() (postID int, image []byte, err error) {
+	return NextPostImageBlob(s.db)
+}
+
+func (s *Store) SetPostImage(postID int, hash string) error {
+	return SetPostImage(s.db, postID, hash)
+}
+
+func (s *Store) AddNotification(event NotificationEvent) (int, error) {
+	return AddNotification(s.db, event)
+}
+
+func (s *Store) GetNotifications(userID int, page Page) ([]Notification, string, error) {
+	return GetNotifications(s.db, userID, page)
+}
+
+func (s *Store) GetNotification(userID int, notificationID int) (Notification, error) {
+	return GetNotification(s.db, userID, notificationID)
+}
+
+func (s *Store) MarkNotificationRead(userID int, notificationID int) error {
+	return MarkNotificationRead(s.db, userID, notificationID)
+}
+
+func (s *Store) MarkAllNotificationsRead(userID int) error {
+	return MarkAllNotificationsRead(s.db, userID)
+}
+
+func (s *Store) CountUnreadNotifications(userID int) (int, error) {
+	return CountUnreadNotifications(s.db, userID)
+}
+
+func (s *Store) GetPostAuthorID(postID int) (int, error) {
+	return GetPostAuthorID(s.db, postID)
+}
+
+func (s *Store) GetCommentAuthor(commentID int) (int, int, error) {
+	return GetCommentAuthor(s.db, commentID)
+}
+
+func (s *Store) GetStaffUserIDs() ([]int, error) {
+	return GetStaffUserIDs(s.db)
+}
+
+func (s *Store) GetRolePermissions(roleID int) ([]string, error) {
+	return GetRolePermissions(s.db, roleID)
+}
+
+func (s *Store) PromoteUserByEmail(email string, roleID int) (bool, error) {
+	return PromoteUserByEmail(s.db, email, roleID)
+}
+
+func (s *Store) Select(colToReturn string, table string, where string, input string) (string, error) {
+	return Select(s.db, colToReturn, table, where, input)
+}
+
+func (s *Store) ToggleReaction(target string, targetID, userID int, kind string) (ReactionState, error) {
+	return ToggleReaction(s.db, target, targetID, userID, kind)
+}
+
+func (s *Store) SetReaction(target string, targetID, userID int, kind string, on bool) (ReactionState, error) {
+	return SetReaction(s.db, target, targetID, userID, kind, on)
+}
+
+func (s *Store) AttachPostReactions(posts []Post, viewerID int) error {
+	return AttachPostReactions(s.db, posts, viewerID)
+}
+
+func (s *Store) AttachCommentReactions(comments []Comment, viewerID int) error {
+	return AttachCommentReactions(s.db, comments, viewerID)
+}
+
+func (s *Store) GetReactionTypes() ([]ReactionType, error) {
+	return GetReactionTypes(s.db)
+}
+
+func (s *Store) SaveReactionType(t ReactionType) error {
+	return SaveReactionType(s.db, t)
+}
+
+func (s *Store) DeleteReactionType(name string) error {
+	return DeleteReactionType(s.db, name)
+}
+
+func (s *Store) UpdatePost(postID, editorID int, title, content string) error {
+	return UpdatePost(s.db, postID, editorID, title, content)
+}
+
+func (s *Store) UpdateComment(commentID, editorID int, content string) error {
+	return UpdateComment(s.db, commentID, editorID, content)
+}
+
+func (s *Store) GetPostHistory(postID int) ([]Version, error) {
+	return GetPostHistory(s.db, postID)
+}
+
+func (s *Store) GetCommentHistory(commentID int) ([]Version, error) {
+	return GetCommentHistory(s.db, commentID)
+}
+
+func (s *Store) SaveSearch(search SavedSearch) (int, error) {
+	return SaveSearch(s.db, search)
+}
+
+func (s *Store) GetSavedSearches(userID int) ([]SavedSearch, error) {
+	return GetSavedSearches(s.db, userID)
+}
+
+func (s *Store) SetSavedSearchDigest(userID, searchID int, on bool) error {
+	return SetSavedSearchDigest(s.db, userID, searchID, on)
+}
+
+func (s *Store) DeleteSavedSearch(userID, searchID int) error {
+	return DeleteSavedSearch(s.db, userID, searchID)
+}
+
+func (s *Store) MatchSavedSearches() ([]SearchMatch, error) {
+	return MatchSavedSearches(s.db)
+}
+
+func (s *Store) GetPendingDigests(since time.Time) ([]SearchDigest, error) {
+	return GetPendingDigests(s.db, since)
+}
+
+func (s *Store) MarkDigestSent(matchIDs []int) error {
+	return MarkDigestSent(s.db, matchIDs)
+}
+
+func (s *Store) SearchPosts(q SearchQuery, page Page) ([]Post, string, error) {
+	return SearchPosts(s.db, q, page)
+}
+
+func (s *Store) SearchComments(q SearchQuery, page Page) ([]Comment, string, error) {
+	return SearchComments(s.db, q, page)
+}
+
+func (s *Store) SearchUsers(q SearchQuery, page Page) ([]User, string, error) {
+	return SearchUsers(s.db, q, page)
+}
+
+func (s *Store) SearchCategories(q SearchQuery) ([]Category, error) {
+	return SearchCategories(s.db, q)
+}
+
+func (s *Store) CreateSession(session UserSession) error {
+	return CreateSession(s.db, session)
+}
+
+func (s *Store) GetSession(token string) (UserSession, error) {
+	return GetSession(s.db, token)
+}
+
+func (s *Store) GetUserBySession(token string) (User, error) {
+	return GetUserBySession(s.db, token)
+}
+
+func (s *Store) SessionExists(token string) (bool, error) {
+	return SessionExists(s.db, token)
+}
+
+func (s *Store) RenewSession(token string, expiresAt time.Time) error {
+	return RenewSession(s.db, token, expiresAt)
+}
+
+func (s *Store) GetUserSessions(userID int) ([]UserSession, error) {
+	return GetUserSessions(s.db, userID)
+}
+
+func (s *Store) RevokeSession(userID, sessionID int) error {
+	return RevokeSession(s.db, userID, sessionID)
+}
+
+func (s *Store) DeleteSession(userID int) error {
+	return DeleteSession(s.db, userID)
+}
+
+func (s *Store) DeleteExpiredSessions() (int64, error) {
+	return DeleteExpiredSessions(s.db)
+}
+
+func (s *Store) FollowUser(followerID int, followingID int) error {
+	return FollowUser(s.db, followerID, followingID)
+}
+
+func (s *Store) UnfollowUser(followerID int, followingID int) error {
+	return UnfollowUser(s.db, followerID, followingID)
+}
+
+func (s *Store) SendFriendRequest(senderID int, receiverID int) error {
+	return SendFriendRequest(s.db, senderID, receiverID)
+}
+
+func (s *Store) AcceptFriendRequest(receiverID int, senderID int) error {
+	return AcceptFriendRequest(s.db, receiverID, senderID)
+}
+
+func (s *Store) DeclineFriendRequest(receiverID int, senderID int) error {
+	return DeclineFriendRequest(s.db, receiverID, senderID)
+}
+
+func (s *Store) GetFriendStatus(userID int, otherID int) (string, error) {
+	return GetFriendStatus(s.db, userID, otherID)
+}
+
+func (s *Store) GetFriendRequests(userID int) ([]User, error) {
+	return GetFriendRequests(s.db, userID)
+}
+
+func (s *Store) GetCommentThread(postID, commentID int) ([]Comment, error) {
+	return GetCommentThread(s.db, postID, commentID)
+}
+
+func (s *Store) DeletePost(postID, deletedBy int) error {
+	return DeletePost(s.db, postID, deletedBy)
+}
+
+func (s *Store) DeleteComment(commentID, deletedBy int) error {
+	return DeleteComment(s.db, commentID, deletedBy)
+}
+
+func (s *Store) DeleteUser(userID, deletedBy int) error {
+	return DeleteUser(s.db, userID, deletedBy)
+}
+
+func (s *Store) RestorePost(postID int) error {
+	return RestorePost(s.db, postID)
+}
+
+func (s *Store) RestoreComment(commentID int) error {
+	return RestoreComment(s.db, commentID)
+}
+
+func (s *Store) RestoreUser(userID int) error {
+	return RestoreUser(s.db, userID)
+}
+
+func (s *Store) GetTrash(page Page) ([]TrashItem, string, error) {
+	return GetTrash(s.db, page)
+}
+
+func (s *Store) PurgeTrash(cutoff time.Time) (TrashPurge, error) {
+	return PurgeTrash(s.db, cutoff)
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Version is one version of a post or comment: the text as it stood, and who
+// wrote it when. Title is empty for comments.
+type Version struct {
+	Title      string
+	Content    string
+	EditorID   int
+	EditorName string
+	At         time.Time
+}
+
+// UpdatePost saves a new title and content for postID on behalf of editorID,
+// keeping the version it replaces in post_revisions. Saving the text already
+// there changes nothing. It returns sql.ErrNoRows if there is no such post.
+func UpdatePost(db *sql.DB, postID, editorID int, title, content string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("UpdatePost: %v", err)
+	}
+	defer tx.Rollback()
+
+	var oldTitle, oldContent string
+	err = tx.QueryRow("SELECT IFNULL(title, ''), content FROM post WHERE postid = ?", postID).Scan(&oldTitle, &oldContent)
+	if err == sql.ErrNoRows {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("UpdatePost: %v", err)
+	}
+	if oldTitle == title && oldContent == content {
+		return nil
+	}
+
+	now := time.Now()
+	_, err = tx.Exec("INSERT INTO post_revisions (post_id, title, content, edited_by, edited_at) VALUES (?, ?, ?, ?, ?)",
+		postID, oldTitle, oldContent, editorID, now)
+	if err != nil {
+		return fmt.Errorf("UpdatePost: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE post SET title = ?, content = ?, edited_at = ? WHERE postid = ?", title, content, now, postID); err != nil {
+		return fmt.Errorf("UpdatePost: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("UpdatePost: %v", err)
+	}
+	return nil
+}
+
+// UpdateComment is UpdatePost for comments. Deleted comments can't be edited,
+// and count as missing.
+func UpdateComment(db *sql.DB, commentID, editorID int, content string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("UpdateComment: %v", err)
+	}
+	defer tx.Rollback()
+
+	var oldContent string
+	err = tx.QueryRow("SELECT content FROM comment WHERE commentid = ? AND deleted_at IS NULL", commentID).Scan(&oldContent)
+	if err == sql.ErrNoRows {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("UpdateComment: %v", err)
+	}
+	if oldContent == content {
+		return nil
+	}
+
+	now := time.Now()
+	_, err = tx.Exec("INSERT INTO comment_revisions (comment_id, content, edited_by, edited_at) VALUES (?, ?, ?, ?)",
+		commentID, oldContent, editorID, now)
+	if err != nil {
+		return fmt.Errorf("UpdateComment: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE comment SET content = ?, edited_at = ? WHERE commentid = ?", content, now, commentID); err != nil {
+		return fmt.Errorf("UpdateComment: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("UpdateComment: %v", err)
+	}
+	return nil
+}
+
+// GetPostHistory returns every version of postID, oldest first and ending
+// with the current one. It returns sql.ErrNoRows if there is no such post.
+func GetPostHistory(db *sql.DB, postID int) ([]Version, error) {
+	var current Version
+	err := db.QueryRow(`
+        SELECT IFNULL(post.title, ''), post.content, post.user_userid, IFNULL(user.Username, ''), post.post_at
+        FROM post
+        LEFT JOIN user ON post.user_userid = user.userid
+        WHERE post.postid = ?
+    `, postID).Scan(&current.Title, &current.Content, &current.EditorID, &current.EditorName, &current.At)
+	if err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("GetPostHistory: %v", err)
+	}
+
+	rows, err := db.Query(`
+        SELECT IFNULL(r.title, ''), r.content, r.edited_by, IFNULL(user.Username, ''), r.edited_at
+        FROM post_revisions r
+        LEFT JOIN user ON r.edited_by = user.userid
+        WHERE r.post_id = ?
+        ORDER BY r.revisionid
+    `, postID)
+	if err != nil {
+		return nil, fmt.Errorf("GetPostHistory: %v", err)
+	}
+	versions, err := collectVersions(rows, current)
+	if err != nil {
+		return nil, fmt.Errorf("GetPostHistory: %v", err)
+	}
+	return versions, nil
+}
+
+// GetCommentHistory is GetPostHistory for comments.
+func GetCommentHistory(db *sql.DB, commentID int) ([]Version, error) {
+	var current Version
+	err := db.QueryRow(`
+        SELECT comment.content, comment.user_userid, IFNULL(user.Username, ''), comment.comment_at
+        FROM comment
+        LEFT JOIN user ON comment.user_userid = user.userid
+        WHERE comment.commentid = ?
+    `, commentID).Scan(&current.Content, &current.EditorID, &current.EditorName, &current.At)
+	if err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("GetCommentHistory: %v", err)
+	}
+
+	rows, err := db.Query(`
+        SELECT '', r.content, r.edited_by, IFNULL(user.Username, ''), r.edited_at
+        FROM comment_revisions r
+        LEFT JOIN user ON r.edited_by = user.userid
+        WHERE r.comment_id = ?
+        ORDER BY r.revisionid
+    `, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("GetCommentHistory: %v", err)
+	}
+	versions, err := collectVersions(rows, current)
+	if err != nil {
+		return nil, fmt.Errorf("GetCommentHistory: %v", err)
+	}
+	return versions, nil
+}
+
+// collectVersions turns revision rows into versions. A revision row holds the
+// text an edit replaced but the editor and time of that edit, which belong to
+// the version after it, so current starts out with the original author and
+// time and each row passes them along.
+func collectVersions(rows *sql.Rows, current Version) ([]Version, error) {
+	defer rows.Close()
+
+	var versions []Version
+	editorID, editorName, at := current.EditorID, current.EditorName, current.At
+	for rows.Next() {
+		var v Version
+		var nextID int
+		var nextName string
+		var nextAt time.Time
+		if err := rows.Scan(&v.Title, &v.Content, &nextID, &nextName, &nextAt); err != nil {
+			return nil, err
+		}
+		v.EditorID, v.EditorName, v.At = editorID, editorName, at
+		versions = append(versions, v)
+		editorID, editorName, at = nextID, nextName, nextAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	current.EditorID, current.EditorName, current.At = editorID, editorName, at
+	return append(versions, current), nil
+}
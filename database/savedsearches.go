@@ -0,0 +1,371 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SavedSearch is a search a user is alerted about when new posts or comments
+// match it. CategoryID limits it to one category when it isn't zero.
+type SavedSearch struct {
+	ID           int
+	UserID       int
+	Query        string
+	CategoryID   int
+	CategoryName string
+	EmailDigest  bool
+	CreatedAt    time.Time
+	// Matches is how many posts and comments have matched it so far.
+	Matches int
+}
+
+// SearchQuery is the saved search parsed, with its category applied.
+func (s SavedSearch) SearchQuery() SearchQuery {
+	q := ParseSearch(s.Query)
+	q.CategoryID = s.CategoryID
+	return q
+}
+
+// SaveSearch stores a search for its user.
+func SaveSearch(db *sql.DB, search SavedSearch) (int, error) {
+	result, err := db.Exec("INSERT INTO saved_searches (user_userid, query, category_id, email_digest) VALUES (?, ?, ?, ?)",
+		search.UserID, search.Query, nullableID(search.CategoryID), search.EmailDigest)
+	if err != nil {
+		return 0, fmt.Errorf("SaveSearch: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("SaveSearch: %v", err)
+	}
+	return int(id), nil
+}
+
+// GetSavedSearches lists a user's saved searches, oldest first.
+func GetSavedSearches(db *sql.DB, userID int) ([]SavedSearch, error) {
+	rows, err := db.Query(`
+        SELECT s.searchid, s.user_userid, s.query, COALESCE(s.category_id, 0), COALESCE(c.name, ''), s.email_digest, s.created_at,
+               (SELECT COUNT(*) FROM saved_search_matches m WHERE m.search_id = s.searchid)
+        FROM saved_searches s
+        LEFT JOIN categories c ON c.idcategories = s.category_id
+        WHERE s.user_userid = ?
+        ORDER BY s.searchid
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetSavedSearches: %v", err)
+	}
+	defer rows.Close()
+
+	var searches []SavedSearch
+	for rows.Next() {
+		var s SavedSearch
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Query, &s.CategoryID, &s.CategoryName, &s.EmailDigest, &s.CreatedAt, &s.Matches); err != nil {
+			return nil, fmt.Errorf("GetSavedSearches: %v", err)
+		}
+		searches = append(searches, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetSavedSearches: %v", err)
+	}
+	return searches, nil
+}
+
+// SetSavedSearchDigest turns the email digest of one of a user's saved
+// searches on or off. It returns sql.ErrNoRows if the search is not theirs.
+func SetSavedSearchDigest(db *sql.DB, userID, searchID int, on bool) error {
+	result, err := db.Exec("UPDATE saved_searches SET email_digest = ? WHERE searchid = ? AND user_userid = ?", on, searchID, userID)
+	if err != nil {
+		return fmt.Errorf("SetSavedSearchDigest: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("SetSavedSearchDigest: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteSavedSearch removes one of a user's saved searches and its matches.
+// It returns sql.ErrNoRows if the search is not theirs.
+func DeleteSavedSearch(db *sql.DB, userID, searchID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("DeleteSavedSearch: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM saved_searches WHERE searchid = ? AND user_userid = ?", searchID, userID)
+	if err != nil {
+		return fmt.Errorf("DeleteSavedSearch: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("DeleteSavedSearch: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM saved_search_matches WHERE search_id = ?", searchID); err != nil {
+		return fmt.Errorf("DeleteSavedSearch: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteSavedSearch: %v", err)
+	}
+	return nil
+}
+
+// SearchMatch is a new post or comment that matched a saved search.
+// CommentID is zero when the post itself matched.
+type SearchMatch struct {
+	SearchID  int
+	UserID    int
+	Query     string
+	PostID    int
+	CommentID int
+	AuthorID  int
+}
+
+// searchMatchRetention is how long matches are remembered. They only need
+// to outlive the next digest.
+const searchMatchRetention = 30 * 24 * time.Hour
+
+// MatchSavedSearches runs every saved search over the posts and comments
+// added since it last ran and records what matched. Users aren't alerted
+// about their own posts and comments, nor through searches they can no
+// longer use because their account is in the trash.
+func MatchSavedSearches(db *sql.DB) ([]SearchMatch, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+	}
+	defer tx.Rollback()
+
+	var lastPost, lastComment, newestPost, newestComment int
+	err = tx.QueryRow(`
+        SELECT (SELECT last_id FROM search_alert_progress WHERE kind = 'post'),
+               (SELECT last_id FROM search_alert_progress WHERE kind = 'comment'),
+               (SELECT COALESCE(MAX(postid), 0) FROM post),
+               (SELECT COALESCE(MAX(commentid), 0) FROM comment)
+    `).Scan(&lastPost, &lastComment, &newestPost, &newestComment)
+	if err != nil {
+		return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+	}
+	if newestPost <= lastPost && newestComment <= lastComment {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+        SELECT s.searchid, s.user_userid, s.query, COALESCE(s.category_id, 0)
+        FROM saved_searches s
+        JOIN user ON user.userid = s.user_userid
+        WHERE user.deleted_at IS NULL
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+	}
+	var searches []SavedSearch
+	for rows.Next() {
+		var s SavedSearch
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Query, &s.CategoryID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+		}
+		searches = append(searches, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+	}
+
+	var matches []SearchMatch
+	for _, s := range searches {
+		q := s.SearchQuery()
+		if q.IsEmpty() || q.DateError() != "" {
+			continue
+		}
+		found, err := matchNewPosts(tx, s, q, lastPost, newestPost)
+		if err != nil {
+			return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+		}
+		matches = append(matches, found...)
+		found, err = matchNewComments(tx, s, q, lastComment, newestComment)
+		if err != nil {
+			return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+		}
+		matches = append(matches, found...)
+	}
+
+	recorded := matches[:0]
+	for _, m := range matches {
+		result, err := tx.Exec("INSERT OR IGNORE INTO saved_search_matches (search_id, post_id, comment_id) VALUES (?, ?, ?)",
+			m.SearchID, m.PostID, m.CommentID)
+		if err != nil {
+			return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+		} else if n > 0 {
+			recorded = append(recorded, m)
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE search_alert_progress SET last_id = CASE kind WHEN 'post' THEN ? ELSE ? END`,
+		max(lastPost, newestPost), max(lastComment, newestComment))
+	if err != nil {
+		return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+	}
+	_, err = tx.Exec("DELETE FROM saved_search_matches WHERE matched_at < ?", time.Now().UTC().Add(-searchMatchRetention))
+	if err != nil {
+		return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("MatchSavedSearches: %v", err)
+	}
+	return recorded, nil
+}
+
+// matchNewPosts finds the posts after afterID, up to uptoID, that match s.
+func matchNewPosts(tx *sql.Tx, s SavedSearch, q SearchQuery, afterID, uptoID int) ([]SearchMatch, error) {
+	where, args := q.filters("post.user_userid", "post.postid", "post.post_at")
+	where = append(where, "post.postid > ?", "post.postid <= ?", "post.deleted_at IS NULL", "post.user_userid != ?")
+	args = append(args, afterID, uptoID, s.UserID)
+	from := "post"
+	if len(q.Terms) > 0 {
+		from = "post_search JOIN post ON post.postid = post_search.rowid"
+		where = append(where, "post_search MATCH ?")
+		args = append(args, q.match(false))
+	}
+
+	rows, err := tx.Query("SELECT post.postid, post.user_userid FROM "+from+" WHERE "+strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []SearchMatch
+	for rows.Next() {
+		m := SearchMatch{SearchID: s.ID, UserID: s.UserID, Query: s.Query}
+		if err := rows.Scan(&m.PostID, &m.AuthorID); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// matchNewComments finds the comments after afterID, up to uptoID, that
+// match s.
+func matchNewComments(tx *sql.Tx, s SavedSearch, q SearchQuery, afterID, uptoID int) ([]SearchMatch, error) {
+	where, args := q.filters("comment.user_userid", "comment.post_postid", "comment.comment_at")
+	where = append(where, "comment.commentid > ?", "comment.commentid <= ?", "comment.deleted_at IS NULL", "comment.user_userid != ?",
+		"comment.post_postid IN (SELECT postid FROM post WHERE deleted_at IS NULL)")
+	args = append(args, afterID, uptoID, s.UserID)
+	from := "comment"
+	if len(q.Terms) > 0 {
+		from = "comment_search JOIN comment ON comment.commentid = comment_search.rowid"
+		where = append(where, "comment_search MATCH ?")
+		args = append(args, q.match(false))
+	}
+
+	rows, err := tx.Query("SELECT comment.commentid, comment.post_postid, comment.user_userid FROM "+from+" WHERE "+strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []SearchMatch
+	for rows.Next() {
+		m := SearchMatch{SearchID: s.ID, UserID: s.UserID, Query: s.Query}
+		if err := rows.Scan(&m.CommentID, &m.PostID, &m.AuthorID); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// SearchDigest is what a user's email digest lists: every match of their
+// saved searches with digests on that no digest has included yet.
+type SearchDigest struct {
+	UserID   int
+	Email    string
+	Username string
+	Matches  []DigestItem
+}
+
+// DigestItem is one post or comment in a digest. Title is the post's.
+type DigestItem struct {
+	MatchID   int
+	Query     string
+	PostID    int
+	CommentID int
+	Title     string
+}
+
+// Link is the page the item opens: the post, or the thread of the comment.
+func (d DigestItem) Link() string {
+	if d.CommentID != 0 {
+		return fmt.Sprintf("/post?id=%d&thread=%d", d.PostID, d.CommentID)
+	}
+	return fmt.Sprintf("/post?id=%d", d.PostID)
+}
+
+// GetPendingDigests returns the digests due: those of users with matches
+// waiting whose last digest went out before since, or who never had one.
+// Matches whose post or comment has since been deleted are left out.
+func GetPendingDigests(db *sql.DB, since time.Time) ([]SearchDigest, error) {
+	rows, err := db.Query(`
+        SELECT u.userid, u.Email, u.Username, m.matchid, s.query, m.post_id, m.comment_id, p.title
+        FROM saved_search_matches m
+        JOIN saved_searches s ON s.searchid = m.search_id
+        JOIN user u ON u.userid = s.user_userid
+        JOIN post p ON p.postid = m.post_id
+        LEFT JOIN comment c ON c.commentid = m.comment_id
+        WHERE m.emailed_at IS NULL AND s.email_digest = 1 AND u.deleted_at IS NULL
+          AND p.deleted_at IS NULL AND (m.comment_id = 0 OR c.deleted_at IS NULL)
+          AND NOT EXISTS (
+              SELECT 1 FROM saved_search_matches sent
+              JOIN saved_searches mine ON mine.searchid = sent.search_id
+              WHERE mine.user_userid = u.userid AND sent.emailed_at >= ?)
+        ORDER BY u.userid, m.matchid
+    `, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("GetPendingDigests: %v", err)
+	}
+	defer rows.Close()
+
+	var digests []SearchDigest
+	for rows.Next() {
+		var d SearchDigest
+		var item DigestItem
+		if err := rows.Scan(&d.UserID, &d.Email, &d.Username, &item.MatchID, &item.Query, &item.PostID, &item.CommentID, &item.Title); err != nil {
+			return nil, fmt.Errorf("GetPendingDigests: %v", err)
+		}
+		if n := len(digests); n == 0 || digests[n-1].UserID != d.UserID {
+			digests = append(digests, d)
+		}
+		last := &digests[len(digests)-1]
+		last.Matches = append(last.Matches, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetPendingDigests: %v", err)
+	}
+	return digests, nil
+}
+
+// MarkDigestSent records that a digest listing matchIDs went out.
+func MarkDigestSent(db *sql.DB, matchIDs []int) error {
+	if len(matchIDs) == 0 {
+		return nil
+	}
+	args := []any{time.Now().UTC()}
+	for _, id := range matchIDs {
+		args = append(args, id)
+	}
+	_, err := db.Exec("UPDATE saved_search_matches SET emailed_at = ? WHERE matchid IN (?"+strings.Repeat(", ?", len(matchIDs)-1)+")", args...)
+	if err != nil {
+		return fmt.Errorf("MarkDigestSent: %v", err)
+	}
+	return nil
+}
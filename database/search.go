@@ -0,0 +1,417 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Snippets mark the matched words with these private use characters, which
+// survive Markdown rendering, so a page can turn them into highlights after
+// escaping the text.
+const (
+	SnippetOpen  = "\ue000"
+	SnippetClose = "\ue001"
+)
+
+// snippetArgs is the part of an FTS5 snippet() call after the column: the
+// markers, the ellipsis and the most words to show.
+const snippetArgs = "char(57344), char(57345), '…', 24"
+
+// searchDate is how before: and after: dates are written.
+const searchDate = "2006-01-02"
+
+// SearchQuery is what was typed in the search box taken apart. Words and
+// "quoted phrases" must all appear; a word ending in * matches any word it
+// starts. author:name and category:name, which may be quoted, and
+// before:YYYY-MM-DD and after:YYYY-MM-DD narrow the results down. Anything
+// else that doesn't parse as a filter is searched for as words.
+type SearchQuery struct {
+	Terms    []SearchTerm
+	Author   string
+	Category string
+	// CategoryID limits the search to a category picked from a list rather
+	// than typed, as saved searches are.
+	CategoryID int
+	// Before and After are exclusive: before:2024-05-01 is up to the end of
+	// April, after:2024-05-01 from the start of the second of May.
+	Before time.Time
+	After  time.Time
+	// BadDates are the before: and after: filters, as typed, whose value
+	// isn't a date. They are left out rather than searched for, so callers
+	// should tell the user instead of searching.
+	BadDates []string
+}
+
+// SearchTerm is a word or phrase of a search.
+type SearchTerm struct {
+	Text   string
+	Prefix bool
+}
+
+// ParseSearch splits a search box entry into terms and filters.
+func ParseSearch(input string) SearchQuery {
+	var q SearchQuery
+	s := []rune(input)
+	for i := 0; i < len(s); {
+		if unicode.IsSpace(s[i]) {
+			i++
+			continue
+		}
+
+		if s[i] == '"' {
+			var text string
+			text, i = readQuoted(s, i)
+			prefix := i < len(s) && s[i] == '*'
+			if prefix {
+				i++
+			}
+			q.addTerm(text, prefix)
+			continue
+		}
+
+		start := i
+		for i < len(s) && !unicode.IsSpace(s[i]) && s[i] != '"' {
+			i++
+		}
+		word := string(s[start:i])
+		key, value, found := strings.Cut(word, ":")
+		if found && value == "" && i < len(s) && s[i] == '"' {
+			value, i = readQuoted(s, i)
+		}
+		if found && q.addFilter(strings.ToLower(key), value) {
+			continue
+		}
+		q.addTerm(strings.TrimSuffix(word, "*"), strings.HasSuffix(word, "*"))
+	}
+	return q
+}
+
+// readQuoted reads the string opened by the quote at s[i], up to the closing
+// quote or the end, and returns it with the index just past it.
+func readQuoted(s []rune, i int) (string, int) {
+	start := i + 1
+	end := start
+	for end < len(s) && s[end] != '"' {
+		end++
+	}
+	if end < len(s) {
+		return string(s[start:end]), end + 1
+	}
+	return string(s[start:end]), end
+}
+
+func (q *SearchQuery) addTerm(text string, prefix bool) {
+	text = strings.TrimSpace(strings.Trim(text, "*"))
+	if text != "" {
+		q.Terms = append(q.Terms, SearchTerm{Text: text, Prefix: prefix})
+	}
+}
+
+// addFilter applies key:value, reporting false if it isn't a filter. A date
+// filter with a bad date is still one, and goes in BadDates.
+func (q *SearchQuery) addFilter(key, value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+	switch key {
+	case "author":
+		q.Author = strings.TrimPrefix(value, "@")
+	case "category":
+		q.Category = value
+	case "before", "after":
+		day, err := time.ParseInLocation(searchDate, value, time.Local)
+		if err != nil {
+			q.BadDates = append(q.BadDates, key+":"+value)
+			return true
+		}
+		if key == "before" {
+			q.Before = day
+		} else {
+			q.After = day
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// IsEmpty reports whether there is nothing to search for.
+func (q SearchQuery) IsEmpty() bool {
+	return len(q.Terms) == 0 && !q.HasFilters()
+}
+
+// HasFilters reports whether any filter was given.
+func (q SearchQuery) HasFilters() bool {
+	return q.Author != "" || q.Category != "" || q.CategoryID != 0 || !q.Before.IsZero() || !q.After.IsZero()
+}
+
+// DateError describes the bad dates, or is "" if there are none.
+func (q SearchQuery) DateError() string {
+	if len(q.BadDates) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s: dates are written YYYY-MM-DD", strings.Join(q.BadDates, ", "))
+}
+
+// Text is the terms as typed, without the filters.
+func (q SearchQuery) Text() string {
+	words := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		words[i] = t.Text
+	}
+	return strings.Join(words, " ")
+}
+
+// AsYouType treats the last term as the start of a word, for suggestions
+// while the user is still typing it.
+func (q SearchQuery) AsYouType() SearchQuery {
+	if len(q.Terms) > 0 {
+		terms := append([]SearchTerm(nil), q.Terms...)
+		terms[len(terms)-1].Prefix = true
+		q.Terms = terms
+	}
+	return q
+}
+
+// match is the terms as an FTS5 query. Each is quoted so that the operators
+// and punctuation users type are searched for rather than interpreted.
+// allPrefix matches every term as the start of a word.
+func (q SearchQuery) match(allPrefix bool) string {
+	parts := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		parts[i] = `"` + strings.ReplaceAll(t.Text, `"`, `""`) + `"`
+		if t.Prefix || allPrefix {
+			parts[i] += "*"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// filters turns the author, category and date filters into conditions on the
+// columns holding the author, the post and the time of what is searched.
+func (q SearchQuery) filters(authorCol, postCol, atCol string) ([]string, []any) {
+	var where []string
+	var args []any
+	if q.Author != "" {
+		where = append(where, authorCol+" IN (SELECT userid FROM user WHERE Username = ? COLLATE NOCASE)")
+		args = append(args, q.Author)
+	}
+	if q.Category != "" {
+		where = append(where, postCol+` IN (
+            SELECT phc.post_postid FROM post_has_categories phc
+            JOIN categories c ON c.idcategories = phc.categories_idcategories
+            WHERE c.name = ? COLLATE NOCASE)`)
+		args = append(args, q.Category)
+	}
+	if q.CategoryID != 0 {
+		where = append(where, postCol+" IN (SELECT post_postid FROM post_has_categories WHERE categories_idcategories = ?)")
+		args = append(args, q.CategoryID)
+	}
+	// Times are stored as text starting with the date, so they compare
+	// against a bare date the same way the dates do.
+	if !q.Before.IsZero() {
+		where = append(where, atCol+" < ?")
+		args = append(args, q.Before.Format(searchDate))
+	}
+	if !q.After.IsZero() {
+		where = append(where, atCol+" >= ?")
+		args = append(args, q.After.AddDate(0, 0, 1).Format(searchDate))
+	}
+	return where, args
+}
+
+// SearchPosts returns one page of the posts q finds, best match first, each
+// with a Snippet of its content around the match. A search with only filters
+// lists what they select, newest first.
+func SearchPosts(db *sql.DB, q SearchQuery, page Page) ([]Post, string, error) {
+	if q.IsEmpty() {
+		return nil, "", nil
+	}
+	pq := postQuery{order: newestFirst}
+	pq.where, pq.args = q.filters("post.user_userid", "post.postid", "post.post_at")
+	if len(q.Terms) > 0 {
+		pq.match, pq.order = q.match(false), bestMatch
+	}
+	posts, next, err := queryPosts(db, pq, page)
+	if err != nil {
+		return nil, "", fmt.Errorf("SearchPosts: %w", err)
+	}
+	return posts, next, nil
+}
+
+// SearchComments returns one page of the comments q finds, best match first,
+// with the title of their post and a Snippet around the match. Filters work
+// as for SearchPosts, with category: going by the comment's post.
+func SearchComments(db *sql.DB, q SearchQuery, page Page) ([]Comment, string, error) {
+	if q.IsEmpty() {
+		return nil, "", nil
+	}
+	after, more, err := page.cursor()
+	if err != nil {
+		return nil, "", fmt.Errorf("SearchComments: %w", err)
+	}
+
+	where, args := q.filters("comment.user_userid", "comment.post_postid", "comment.comment_at")
+	where = append([]string{"comment.deleted_at IS NULL", "post.deleted_at IS NULL"}, where...)
+	from, rank, snippet := "comment", "0", "substr(comment.content, 1, 200)"
+	orderBy := "comment.comment_at DESC, comment.commentid DESC"
+	if len(q.Terms) > 0 {
+		from = "comment_search JOIN comment ON comment.commentid = comment_search.rowid"
+		rank = "bm25(comment_search)"
+		snippet = "snippet(comment_search, 0, " + snippetArgs + ")"
+		orderBy = rank + ", comment.commentid"
+		where = append(where, "comment_search MATCH ?")
+		args = append(args, q.match(false))
+		if more {
+			where = append(where, "("+rank+", comment.commentid) > (?, ?)")
+			args = append(args, after.Rank, after.ID)
+		}
+	} else if more {
+		where = append(where, "(comment.comment_at, comment.commentid) < (?, ?)")
+		args = append(args, after.At, after.ID)
+	}
+
+	size := page.size()
+	rows, err := db.Query(`
+        SELECT comment.commentid, comment.post_postid, comment.user_userid, user.F_name, user.L_name, user.Username, user.Avatar,
+               comment.content, comment.comment_at, CAST(comment.comment_at AS TEXT), comment.edited_at, post.title,
+               `+rank+`, `+snippet+`
+        FROM `+from+`
+        JOIN user ON user.userid = comment.user_userid
+        JOIN post ON post.postid = comment.post_postid
+        WHERE `+strings.Join(where, " AND ")+`
+        ORDER BY `+orderBy+`
+        LIMIT ?
+    `, append(args, size+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("SearchComments: %v", err)
+	}
+	defer rows.Close()
+
+	var comments []Comment
+	var keys []cursor
+	for rows.Next() {
+		var c Comment
+		var content sql.NullString
+		var key cursor
+		if err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.FirstName, &c.LastName, &c.Username, &c.Avatar,
+			&content, &c.CreatedAt, &key.At, &c.EditedAt, &c.PostTitle, &key.Rank, &c.Snippet); err != nil {
+			return nil, "", fmt.Errorf("SearchComments: %v", err)
+		}
+		c.Content = content.String
+		key.ID = c.ID
+		comments = append(comments, c)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("SearchComments: %v", err)
+	}
+
+	var next string
+	if len(comments) > size {
+		comments = comments[:size]
+		next = keys[size-1].encode()
+	}
+	return comments, next, nil
+}
+
+// SearchUsers returns one page of the users whose username or name q's terms
+// start, best match first. Usernames weigh more than names, and filters
+// don't apply to users.
+func SearchUsers(db *sql.DB, q SearchQuery, page Page) ([]User, string, error) {
+	if len(q.Terms) == 0 {
+		return nil, "", nil
+	}
+	after, more, err := page.cursor()
+	if err != nil {
+		return nil, "", fmt.Errorf("SearchUsers: %w", err)
+	}
+
+	const rank = "bm25(user_search, 5.0, 1.0, 1.0)"
+	where, args := "user_search MATCH ? AND user.deleted_at IS NULL", []any{q.match(true)}
+	if more {
+		where += " AND (" + rank + ", user.userid) > (?, ?)"
+		args = append(args, after.Rank, after.ID)
+	}
+
+	size := page.size()
+	rows, err := db.Query(`
+        SELECT user.userid, user.Username, user.F_name, user.L_name, user.Avatar, `+rank+`
+        FROM user_search
+        JOIN user ON user.userid = user_search.rowid
+        WHERE `+where+`
+        ORDER BY `+rank+`, user.userid
+        LIMIT ?
+    `, append(args, size+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("SearchUsers: %v", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	var ranks []float64
+	for rows.Next() {
+		var user User
+		var r float64
+		if err := rows.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Avatar, &r); err != nil {
+			return nil, "", fmt.Errorf("SearchUsers: %v", err)
+		}
+		users = append(users, user)
+		ranks = append(ranks, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("SearchUsers: %v", err)
+	}
+
+	var next string
+	if len(users) > size {
+		users = users[:size]
+		next = cursor{Rank: ranks[size-1], ID: users[size-1].ID}.encode()
+	}
+	return users, next, nil
+}
+
+// SearchCategories returns the categories whose name contains all of q's
+// terms. There are few enough of them to scan.
+func SearchCategories(db *sql.DB, q SearchQuery) ([]Category, error) {
+	if len(q.Terms) == 0 {
+		return nil, nil
+	}
+	var where []string
+	var args []any
+	for _, t := range q.Terms {
+		where = append(where, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(t.Text)+"%")
+	}
+
+	rows, err := db.Query(`
+        SELECT idcategories, name
+        FROM categories
+        WHERE `+strings.Join(where, " AND ")+`
+        ORDER BY name
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("SearchCategories: %v", err)
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(&category.ID, &category.Name); err != nil {
+			return nil, fmt.Errorf("SearchCategories: %v", err)
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("SearchCategories: %v", err)
+	}
+	return categories, nil
+}
+
+// likeEscaper stops % and _ in a search from acting as LIKE wildcards.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
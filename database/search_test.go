@@ -0,0 +1,128 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.ParseInLocation(searchDate, s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		input string
+		want  SearchQuery
+	}{
+		{"", SearchQuery{}},
+		{"   ", SearchQuery{}},
+		{"go sqlite", SearchQuery{Terms: []SearchTerm{{Text: "go"}, {Text: "sqlite"}}}},
+		{"data*", SearchQuery{Terms: []SearchTerm{{Text: "data", Prefix: true}}}},
+		{"*", SearchQuery{}},
+		{`"hello world"`, SearchQuery{Terms: []SearchTerm{{Text: "hello world"}}}},
+		{`"hello wor"*`, SearchQuery{Terms: []SearchTerm{{Text: "hello wor", Prefix: true}}}},
+		{`"unclosed phrase`, SearchQuery{Terms: []SearchTerm{{Text: "unclosed phrase"}}}},
+		{`""`, SearchQuery{}},
+		{`say"hi"`, SearchQuery{Terms: []SearchTerm{{Text: "say"}, {Text: "hi"}}}},
+		{"author:alice", SearchQuery{Author: "alice"}},
+		{"author:@alice go", SearchQuery{Author: "alice", Terms: []SearchTerm{{Text: "go"}}}},
+		{"AUTHOR:alice", SearchQuery{Author: "alice"}},
+		{`category:"Tech Talk" go`, SearchQuery{Category: "Tech Talk", Terms: []SearchTerm{{Text: "go"}}}},
+		{"category:news", SearchQuery{Category: "news"}},
+		{"before:2024-05-01", SearchQuery{Before: day("2024-05-01")}},
+		{"after:2024-05-01 before:2024-06-01", SearchQuery{After: day("2024-05-01"), Before: day("2024-06-01")}},
+		{"before:garbage", SearchQuery{BadDates: []string{"before:garbage"}}},
+		{"go after:2024-13-01", SearchQuery{Terms: []SearchTerm{{Text: "go"}}, BadDates: []string{"after:2024-13-01"}}},
+		{"author:", SearchQuery{Terms: []SearchTerm{{Text: "author:"}}}},
+		{`author:""`, SearchQuery{Terms: []SearchTerm{{Text: "author:"}}}},
+		{"http://example.com", SearchQuery{Terms: []SearchTerm{{Text: "http://example.com"}}}},
+		{"tag:go", SearchQuery{Terms: []SearchTerm{{Text: "tag:go"}}}},
+	}
+	for _, tt := range tests {
+		if got := ParseSearch(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSearch(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSearchQueryState(t *testing.T) {
+	tests := []struct {
+		input      string
+		empty      bool
+		hasFilters bool
+		dateError  string
+	}{
+		{"", true, false, ""},
+		{"go", false, false, ""},
+		{"author:alice", false, true, ""},
+		{"after:2024-01-01", false, true, ""},
+		{"before:garbage", true, false, "before:garbage: dates are written YYYY-MM-DD"},
+		{"before:x after:y", true, false, "before:x, after:y: dates are written YYYY-MM-DD"},
+	}
+	for _, tt := range tests {
+		q := ParseSearch(tt.input)
+		if q.IsEmpty() != tt.empty {
+			t.Errorf("ParseSearch(%q).IsEmpty() = %v, want %v", tt.input, q.IsEmpty(), tt.empty)
+		}
+		if q.HasFilters() != tt.hasFilters {
+			t.Errorf("ParseSearch(%q).HasFilters() = %v, want %v", tt.input, q.HasFilters(), tt.hasFilters)
+		}
+		if q.DateError() != tt.dateError {
+			t.Errorf("ParseSearch(%q).DateError() = %q, want %q", tt.input, q.DateError(), tt.dateError)
+		}
+	}
+}
+
+func TestSearchMatch(t *testing.T) {
+	tests := []struct {
+		input     string
+		allPrefix bool
+		want      string
+	}{
+		{"go sqlite", false, `"go" "sqlite"`},
+		{"go sqlite", true, `"go"* "sqlite"*`},
+		{"data* base", false, `"data"* "base"`},
+		{`"hello world"`, false, `"hello world"`},
+		{`a"b`, false, `"a" "b"`},
+		{`"say ""hi"""`, false, `"say" "hi"`},
+		{"NOT OR AND", false, `"NOT" "OR" "AND"`},
+		{"c++ (x)", false, `"c++" "(x)"`},
+		{`it's`, false, `"it's"`},
+	}
+	for _, tt := range tests {
+		if got := ParseSearch(tt.input).match(tt.allPrefix); got != tt.want {
+			t.Errorf("match of %q = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	q := SearchQuery{Terms: []SearchTerm{{Text: `say "hi"`}}}
+	if got, want := q.match(false), `"say ""hi"""`; got != want {
+		t.Errorf("match of a term with quotes = %s, want %s", got, want)
+	}
+}
+
+func TestSearchAsYouType(t *testing.T) {
+	q := ParseSearch("go sql")
+	got := q.AsYouType()
+	want := []SearchTerm{{Text: "go"}, {Text: "sql", Prefix: true}}
+	if !reflect.DeepEqual(got.Terms, want) {
+		t.Errorf("AsYouType terms = %+v, want %+v", got.Terms, want)
+	}
+	if q.Terms[1].Prefix {
+		t.Error("AsYouType changed the terms of the query it was called on")
+	}
+}
+
+func TestSearchDateFilters(t *testing.T) {
+	where, args := ParseSearch("after:2024-04-30 before:2024-05-03").filters("a", "p", "at")
+	wantWhere := []string{"at < ?", "at >= ?"}
+	wantArgs := []any{"2024-05-03", "2024-05-01"}
+	if !reflect.DeepEqual(where, wantWhere) || !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("filters = %q %q, want %q %q", where, args, wantWhere, wantArgs)
+	}
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// UserSession is one signed in device. Token is the cookie value and is never
+// shown back to users; ID identifies the session in the UI. CSRFToken is
+// embedded in pages and must accompany every state-changing request.
+type UserSession struct {
+	ID        int
+	UserID    int
+	Token     string
+	CSRFToken string
+	UserAgent string
+	IP        string
+	CreatedAt time.Time
+	LastSeen  time.Time
+	ExpiresAt time.Time
+}
+
+func insertSession(tx *sql.Tx, session UserSession) error {
+	_, err := tx.Exec("INSERT INTO session (sessionid, csrf_token, userid, user_agent, ip, created_at, last_seen, endtime) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		session.Token, session.CSRFToken, session.UserID, session.UserAgent, session.IP, session.CreatedAt.UTC(), session.LastSeen.UTC(), session.ExpiresAt.UTC())
+	return err
+}
+
+// CreateSession signs a user in on one more device. Sessions on other devices
+// are left alone.
+func CreateSession(db *sql.DB, session UserSession) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("CreateSession: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err = insertSession(tx, session); err != nil {
+		return fmt.Errorf("CreateSession: %v", err)
+	}
+	return tx.Commit()
+}
+
+// GetSession returns the unexpired session for a cookie token, or
+// sql.ErrNoRows.
+func GetSession(db *sql.DB, token string) (UserSession, error) {
+	var session UserSession
+	err := db.QueryRow(`
+        SELECT id, userid, sessionid, csrf_token, user_agent, ip, created_at, last_seen, endtime
+        FROM session
+        WHERE sessionid = ? AND endtime > ?
+    `, token, time.Now().UTC()).Scan(&session.ID, &session.UserID, &session.Token, &session.CSRFToken, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeen, &session.ExpiresAt)
+	return session, err
+}
+
+func GetUserBySession(db *sql.DB, token string) (User, error) {
+	var user User
+	err := db.QueryRow(`
+        SELECT user.userid, user.Username, user.role_id, user.Avatar
+        FROM session
+        JOIN user ON session.userid = user.userid
+        WHERE session.sessionid = ? AND session.endtime > ?
+    `, token, time.Now().UTC()).Scan(&user.ID, &user.Username, &user.RoleID, &user.Avatar)
+	return user, err
+}
+
+func SessionExists(db *sql.DB, token string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM session WHERE sessionid = ? AND endtime > ?)", token, time.Now().UTC()).Scan(&exists)
+	return exists, err
+}
+
+// RenewSession records activity on a session and slides its expiry forward.
+func RenewSession(db *sql.DB, token string, expiresAt time.Time) error {
+	_, err := db.Exec("UPDATE session SET last_seen = ?, endtime = ? WHERE sessionid = ?", time.Now().UTC(), expiresAt.UTC(), token)
+	if err != nil {
+		return fmt.Errorf("RenewSession: %v", err)
+	}
+	return nil
+}
+
+// GetUserSessions lists a user's unexpired sessions, most recently used first.
+func GetUserSessions(db *sql.DB, userID int) ([]UserSession, error) {
+	rows, err := db.Query(`
+        SELECT id, userid, sessionid, csrf_token, user_agent, ip, created_at, last_seen, endtime
+        FROM session
+        WHERE userid = ? AND endtime > ?
+        ORDER BY last_seen DESC, id DESC
+    `, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("GetUserSessions: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []UserSession
+	for rows.Next() {
+		var session UserSession
+		if err := rows.Scan(&session.ID, &session.UserID, &session.Token, &session.CSRFToken, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeen, &session.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("GetUserSessions: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetUserSessions: %v", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs out one of a user's devices. It returns sql.ErrNoRows if
+// the session does not belong to the user.
+func RevokeSession(db *sql.DB, userID, sessionID int) error {
+	result, err := db.Exec("DELETE FROM session WHERE id = ? AND userid = ?", sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("RevokeSession: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("RevokeSession: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteSession signs a user out everywhere.
+func DeleteSession(db *sql.DB, userID int) error {
+	if _, err := db.Exec("DELETE FROM session WHERE userid = ?", userID); err != nil {
+		return fmt.Errorf("DeleteSession: %v", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes sessions past their expiry and reports how
+// many were removed.
+func DeleteExpiredSessions(db *sql.DB) (int64, error) {
+	result, err := db.Exec("DELETE FROM session WHERE endtime <= ?", time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("DeleteExpiredSessions: %v", err)
+	}
+	return result.RowsAffected()
+}
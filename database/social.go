@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const (
+	FriendStatusNone            = "none"
+	FriendStatusRequestSent     = "request_sent"
+	FriendStatusRequestReceived = "request_received"
+	FriendStatusFriends         = "friends"
+)
+
+func FollowUser(db *sql.DB, followerID int, followingID int) error {
+	_, err := db.Exec("INSERT OR IGNORE INTO followers (follower_id, following_id) VALUES (?, ?)", followerID, followingID)
+	if err != nil {
+		return fmt.Errorf("FollowUser: %v", err)
+	}
+	return nil
+}
+
+func UnfollowUser(db *sql.DB, followerID int, followingID int) error {
+	_, err := db.Exec("DELETE FROM followers WHERE follower_id = ? AND following_id = ?", followerID, followingID)
+	if err != nil {
+		return fmt.Errorf("UnfollowUser: %v", err)
+	}
+	return nil
+}
+
+// SendFriendRequest records a pending request from senderID to receiverID. If
+// receiverID already asked senderID, the two requests meet and the friendship
+// is created straight away.
+func SendFriendRequest(db *sql.DB, senderID int, receiverID int) error {
+	status, err := GetFriendStatus(db, senderID, receiverID)
+	if err != nil {
+		return fmt.Errorf("SendFriendRequest: %v", err)
+	}
+
+	switch status {
+	case FriendStatusFriends, FriendStatusRequestSent:
+		return nil
+	case FriendStatusRequestReceived:
+		return AcceptFriendRequest(db, senderID, receiverID)
+	}
+
+	_, err = db.Exec("INSERT OR IGNORE INTO friend_requests (sender_id, receiver_id) VALUES (?, ?)", senderID, receiverID)
+	if err != nil {
+		return fmt.Errorf("SendFriendRequest: %v", err)
+	}
+	return nil
+}
+
+// AcceptFriendRequest turns the pending request from senderID into a
+// friendship. Friends always follow each other, so both follow rows are added
+// as well. It returns sql.ErrNoRows when there is no such request.
+func AcceptFriendRequest(db *sql.DB, receiverID int, senderID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("AcceptFriendRequest: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM friend_requests WHERE sender_id = ? AND receiver_id = ?", senderID, receiverID)
+	if err != nil {
+		return fmt.Errorf("AcceptFriendRequest: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("AcceptFriendRequest: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	pairs := [][2]int{{receiverID, senderID}, {senderID, receiverID}}
+	for _, pair := range pairs {
+		_, err = tx.Exec("INSERT OR IGNORE INTO friends (user_userid, friend_userid) VALUES (?, ?)", pair[0], pair[1])
+		if err != nil {
+			return fmt.Errorf("AcceptFriendRequest: %v", err)
+		}
+		_, err = tx.Exec("INSERT OR IGNORE INTO followers (follower_id, following_id) VALUES (?, ?)", pair[0], pair[1])
+		if err != nil {
+			return fmt.Errorf("AcceptFriendRequest: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("AcceptFriendRequest: %v", err)
+	}
+	return nil
+}
+
+// DeclineFriendRequest drops the pending request from senderID. It returns
+// sql.ErrNoRows when there is no such request.
+func DeclineFriendRequest(db *sql.DB, receiverID int, senderID int) error {
+	result, err := db.Exec("DELETE FROM friend_requests WHERE sender_id = ? AND receiver_id = ?", senderID, receiverID)
+	if err != nil {
+		return fmt.Errorf("DeclineFriendRequest: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("DeclineFriendRequest: %v", err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetFriendStatus describes the relationship between userID and otherID from
+// userID's point of view.
+func GetFriendStatus(db *sql.DB, userID int, otherID int) (string, error) {
+	var friends, sent, received bool
+	err := db.QueryRow(`
+        SELECT
+            EXISTS(SELECT 1 FROM friends WHERE user_userid = ? AND friend_userid = ?),
+            EXISTS(SELECT 1 FROM friend_requests WHERE sender_id = ? AND receiver_id = ?),
+            EXISTS(SELECT 1 FROM friend_requests WHERE sender_id = ? AND receiver_id = ?)
+    `, userID, otherID, userID, otherID, otherID, userID).Scan(&friends, &sent, &received)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case friends:
+		return FriendStatusFriends, nil
+	case sent:
+		return FriendStatusRequestSent, nil
+	case received:
+		return FriendStatusRequestReceived, nil
+	}
+	return FriendStatusNone, nil
+}
+
+// GetFriendRequests returns the users waiting for userID to answer their
+// friend request, oldest first.
+func GetFriendRequests(db *sql.DB, userID int) ([]User, error) {
+	rows, err := db.Query(`
+        SELECT user.userid, user.F_name, user.L_name, user.Username, user.Avatar
+        FROM friend_requests
+        JOIN user ON friend_requests.sender_id = user.userid
+        WHERE friend_requests.receiver_id = ?
+        ORDER BY friend_requests.created_at
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Username, &user.Avatar); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
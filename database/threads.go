@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxCommentDepth is how many levels of a thread are shown together, counting
+// the comment it starts from. Deeper replies are reached through that
+// thread's "continue thread" link.
+const MaxCommentDepth = 4
+
+// ErrBadParent means a reply named a comment that is deleted or belongs to
+// another post.
+var ErrBadParent = errors.New("cannot reply to that comment")
+
+// loadThreads returns the comments in roots, in that order, each followed by
+// its replies down to MaxCommentDepth levels. Replies follow their parent
+// oldest first, and Depth counts levels below the root. Deleted comments are
+// left out unless they have replies to hold a place for.
+func loadThreads(db *sql.DB, roots []int) ([]Comment, error) {
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.Repeat("?, ", len(roots)-1) + "?"
+	args := make([]any, 0, len(roots)+1)
+	for _, id := range roots {
+		args = append(args, id)
+	}
+	args = append(args, MaxCommentDepth)
+
+	rows, err := db.Query(`
+        WITH RECURSIVE thread(id, depth) AS (
+            SELECT commentid, 0 FROM comment WHERE commentid IN (`+placeholders+`)
+            UNION ALL
+            SELECT comment.commentid, thread.depth + 1
+            FROM comment JOIN thread ON comment.parent_comment_id = thread.id
+            WHERE thread.depth + 1 < ?
+        )
+        SELECT comment.commentid, comment.post_postid, comment.user_userid, IFNULL(user.F_name, ''), IFNULL(user.L_name, ''), IFNULL(user.Username, ''),
+            CASE WHEN comment.deleted_at IS NULL THEN comment.content ELSE '' END, comment.comment_at, user.Avatar,
+            comment.dislike_count, comment.like_count,
+            IFNULL(comment.parent_comment_id, 0), comment.deleted_at IS NOT NULL, comment.edited_at, thread.depth,
+            (SELECT COUNT(*) FROM comment AS reply WHERE reply.parent_comment_id = comment.commentid AND reply.deleted_at IS NULL)
+        FROM thread
+        JOIN comment ON comment.commentid = thread.id
+        LEFT JOIN user ON comment.user_userid = user.userid
+        ORDER BY comment.comment_at, comment.commentid
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("loadThreads: %v", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int]Comment)
+	replies := make(map[int][]int)
+	for rows.Next() {
+		var comment Comment
+		var commentAt time.Time
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.FirstName, &comment.LastName, &comment.Username, &comment.Content, &commentAt, &comment.Avatar,
+			&comment.Dislikes, &comment.Likes, &comment.ParentID, &comment.Deleted, &comment.EditedAt, &comment.Depth, &comment.ReplyCount); err != nil {
+			return nil, fmt.Errorf("loadThreads: %v", err)
+		}
+		comment.CreatedAt = commentAt
+		byID[comment.ID] = comment
+		if comment.Depth > 0 {
+			replies[comment.ParentID] = append(replies[comment.ParentID], comment.ID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("loadThreads: %v", err)
+	}
+
+	// walk appends id and what shows of its replies, and reports whether
+	// anything was appended. A deleted comment is only kept if something
+	// below it is, or it has replies past the depth limit.
+	comments := make([]Comment, 0, len(byID))
+	var walk func(id int) bool
+	walk = func(id int) bool {
+		comment, ok := byID[id]
+		if !ok {
+			return false
+		}
+		at := len(comments)
+		comments = append(comments, comment)
+		shown := !comment.Deleted || (comment.AtDepthLimit() && comment.ReplyCount > 0)
+		for _, reply := range replies[id] {
+			if walk(reply) {
+				shown = true
+			}
+		}
+		if !shown {
+			comments = comments[:at]
+		}
+		return shown
+	}
+	for _, id := range roots {
+		walk(id)
+	}
+	return comments, nil
+}
+
+// GetCommentThread returns the comment commentID on postID followed by its
+// replies, as GetCommentsForPost lays them out. It returns sql.ErrNoRows if
+// there is no such comment on that post.
+func GetCommentThread(db *sql.DB, postID, commentID int) ([]Comment, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM comment WHERE commentid = ? AND post_postid = ?)", commentID, postID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("GetCommentThread: %v", err)
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	return loadThreads(db, []int{commentID})
+}
+
+// AtDepthLimit reports whether c is as deep as a thread is shown, so any
+// replies to it are left behind a "continue thread" link.
+func (c Comment) AtDepthLimit() bool {
+	return c.Depth >= MaxCommentDepth-1
+}
@@ -0,0 +1,428 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kinds of things the trash holds.
+const (
+	TrashPost    = "post"
+	TrashComment = "comment"
+	TrashUser    = "user"
+)
+
+// ErrAuthorDeleted means a post or comment can't come back out of the trash
+// on its own because its author is in there too. Restoring the author brings
+// it back with them.
+var ErrAuthorDeleted = errors.New("restore the author first")
+
+// ErrAccountDeleted means someone tried to sign in to an account that is in
+// the trash.
+var ErrAccountDeleted = errors.New("account deleted")
+
+// TrashItem is one deleted post, comment or user. Title is a post's title or
+// a user's username, and Content a post's or comment's text. Posts and
+// comments that went to the trash with their author are listed under the
+// author, counted in Posts and Comments.
+type TrashItem struct {
+	Kind          string
+	ID            int
+	PostID        int
+	Title         string
+	Content       string
+	AuthorID      int
+	AuthorName    string
+	DeletedAt     time.Time
+	DeletedByID   int
+	DeletedByName string
+	Posts         int
+	Comments      int
+}
+
+// DeletePost moves postID to the trash on behalf of deletedBy. Its comments,
+// reactions, categories and reports stay as they are, hidden along with it.
+func DeletePost(db *sql.DB, postID, deletedBy int) error {
+	_, err := db.Exec("UPDATE post SET deleted_at = ?, deleted_by = ? WHERE postid = ? AND deleted_at IS NULL", time.Now(), deletedBy, postID)
+	if err != nil {
+		return fmt.Errorf("DeletePost: %v", err)
+	}
+	return nil
+}
+
+// DeleteComment moves commentID to the trash on behalf of deletedBy. While it
+// has replies still showing it is shown as a "[deleted]" placeholder so the
+// thread keeps its shape.
+func DeleteComment(db *sql.DB, commentID, deletedBy int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("DeleteComment: %v", err)
+	}
+	defer tx.Rollback()
+
+	var postID int
+	err = tx.QueryRow("UPDATE comment SET deleted_at = ?, deleted_by = ? WHERE commentid = ? AND deleted_at IS NULL RETURNING post_postid",
+		time.Now(), deletedBy, commentID).Scan(&postID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("DeleteComment: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE post SET comment_count = MAX(comment_count - 1, 0) WHERE postid = ?", postID); err != nil {
+		return fmt.Errorf("DeleteComment: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteComment: %v", err)
+	}
+	return nil
+}
+
+// DeleteUser moves userID to the trash on behalf of deletedBy, signing them
+// out everywhere. Their posts and comments go with them, stamped with the
+// same time so RestoreUser can tell them from ones deleted earlier.
+func DeleteUser(db *sql.DB, userID, deletedBy int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("DeleteUser: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec("UPDATE user SET deleted_at = ?, deleted_by = ? WHERE userid = ? AND deleted_at IS NULL", now, deletedBy, userID)
+	if err != nil {
+		return fmt.Errorf("DeleteUser: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("DeleteUser: %v", err)
+	} else if n == 0 {
+		return nil
+	}
+
+	steps := []struct {
+		query string
+		args  []any
+	}{
+		{"UPDATE post SET deleted_at = ?, deleted_by = ? WHERE user_userid = ? AND deleted_at IS NULL", []any{now, deletedBy, userID}},
+		{`UPDATE post SET comment_count = MAX(comment_count - (
+              SELECT COUNT(*) FROM comment
+              WHERE comment.post_postid = post.postid AND comment.user_userid = ? AND comment.deleted_at IS NULL), 0)
+          WHERE postid IN (SELECT post_postid FROM comment WHERE user_userid = ? AND deleted_at IS NULL)`, []any{userID, userID}},
+		{"UPDATE comment SET deleted_at = ?, deleted_by = ? WHERE user_userid = ? AND deleted_at IS NULL", []any{now, deletedBy, userID}},
+		{"DELETE FROM session WHERE userid = ?", []any{userID}},
+		{"DELETE FROM api_token WHERE userid = ?", []any{userID}},
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step.query, step.args...); err != nil {
+			return fmt.Errorf("DeleteUser: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteUser: %v", err)
+	}
+	return nil
+}
+
+// RestorePost takes postID back out of the trash. It returns sql.ErrNoRows if
+// the post isn't in the trash, and ErrAuthorDeleted if its author is.
+func RestorePost(db *sql.DB, postID int) error {
+	var authorDeleted bool
+	err := db.QueryRow(`SELECT user.deleted_at IS NOT NULL FROM post JOIN user ON post.user_userid = user.userid
+	    WHERE post.postid = ? AND post.deleted_at IS NOT NULL`, postID).Scan(&authorDeleted)
+	if err == sql.ErrNoRows {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("RestorePost: %v", err)
+	}
+	if authorDeleted {
+		return ErrAuthorDeleted
+	}
+	if _, err := db.Exec("UPDATE post SET deleted_at = NULL, deleted_by = NULL WHERE postid = ?", postID); err != nil {
+		return fmt.Errorf("RestorePost: %v", err)
+	}
+	return nil
+}
+
+// RestoreComment is RestorePost for comments.
+func RestoreComment(db *sql.DB, commentID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("RestoreComment: %v", err)
+	}
+	defer tx.Rollback()
+
+	var postID int
+	var authorDeleted bool
+	err = tx.QueryRow(`SELECT comment.post_postid, user.deleted_at IS NOT NULL FROM comment JOIN user ON comment.user_userid = user.userid
+	    WHERE comment.commentid = ? AND comment.deleted_at IS NOT NULL`, commentID).Scan(&postID, &authorDeleted)
+	if err == sql.ErrNoRows {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("RestoreComment: %v", err)
+	}
+	if authorDeleted {
+		return ErrAuthorDeleted
+	}
+	if _, err := tx.Exec("UPDATE comment SET deleted_at = NULL, deleted_by = NULL WHERE commentid = ?", commentID); err != nil {
+		return fmt.Errorf("RestoreComment: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE post SET comment_count = comment_count + 1 WHERE postid = ?", postID); err != nil {
+		return fmt.Errorf("RestoreComment: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RestoreComment: %v", err)
+	}
+	return nil
+}
+
+// RestoreUser takes userID back out of the trash along with the posts and
+// comments that went in with them. It returns sql.ErrNoRows if the user isn't
+// in the trash.
+func RestoreUser(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("RestoreUser: %v", err)
+	}
+	defer tx.Rollback()
+
+	var deleted bool
+	err = tx.QueryRow("SELECT deleted_at IS NOT NULL FROM user WHERE userid = ?", userID).Scan(&deleted)
+	if err == nil && !deleted {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("RestoreUser: %v", err)
+	}
+
+	// ?1 is the user, and stamp the time they were deleted at.
+	const stamp = "(SELECT deleted_at FROM user WHERE userid = ?1)"
+	for _, query := range []string{
+		"UPDATE post SET deleted_at = NULL, deleted_by = NULL WHERE user_userid = ?1 AND deleted_at = " + stamp,
+		`UPDATE post SET comment_count = comment_count + (
+             SELECT COUNT(*) FROM comment
+             WHERE comment.post_postid = post.postid AND comment.user_userid = ?1 AND comment.deleted_at = ` + stamp + `)
+         WHERE postid IN (SELECT post_postid FROM comment WHERE user_userid = ?1 AND deleted_at = ` + stamp + `)`,
+		"UPDATE comment SET deleted_at = NULL, deleted_by = NULL WHERE user_userid = ?1 AND deleted_at = " + stamp,
+		"UPDATE user SET deleted_at = NULL, deleted_by = NULL WHERE userid = ?1",
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return fmt.Errorf("RestoreUser: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RestoreUser: %v", err)
+	}
+	return nil
+}
+
+// GetTrash returns one page of the trash, most recently deleted first.
+func GetTrash(db *sql.DB, page Page) ([]TrashItem, string, error) {
+	after, more, err := page.cursor()
+	if err != nil {
+		return nil, "", fmt.Errorf("GetTrash: %w", err)
+	}
+
+	// Each kind gets its own id range in the cursor's tie-breaker, so items
+	// deleted at the same moment still have a strict order.
+	where, args := "", []any{}
+	if more {
+		where = "WHERE (trash.deleted_at, trash.sort_id) < (?, ?)"
+		args = append(args, after.At, after.ID)
+	}
+	size := page.size()
+	rows, err := db.Query(`
+        WITH trash AS (
+            SELECT 'post' AS kind, post.postid AS id, post.postid AS post_id, IFNULL(post.title, '') AS title, IFNULL(post.content, '') AS content,
+                post.user_userid AS author_id, author.Username AS author_name, post.deleted_at, post.deleted_by,
+                0 AS posts, 0 AS comments, post.postid * 3 AS sort_id
+            FROM post JOIN user AS author ON post.user_userid = author.userid
+            WHERE post.deleted_at IS NOT NULL AND author.deleted_at IS NULL
+            UNION ALL
+            SELECT 'comment', comment.commentid, comment.post_postid, '', IFNULL(comment.content, ''),
+                comment.user_userid, author.Username, comment.deleted_at, comment.deleted_by,
+                0, 0, comment.commentid * 3 + 1
+            FROM comment JOIN user AS author ON comment.user_userid = author.userid
+            WHERE comment.deleted_at IS NOT NULL AND author.deleted_at IS NULL
+            UNION ALL
+            SELECT 'user', user.userid, 0, user.Username, '',
+                user.userid, user.Username, user.deleted_at, user.deleted_by,
+                (SELECT COUNT(*) FROM post WHERE post.user_userid = user.userid AND post.deleted_at = user.deleted_at),
+                (SELECT COUNT(*) FROM comment WHERE comment.user_userid = user.userid AND comment.deleted_at = user.deleted_at),
+                user.userid * 3 + 2
+            FROM user
+            WHERE user.deleted_at IS NOT NULL
+        )
+        SELECT trash.kind, trash.id, trash.post_id, trash.title, trash.content, trash.author_id, trash.author_name,
+            trash.deleted_at, CAST(trash.deleted_at AS TEXT), IFNULL(trash.deleted_by, 0), IFNULL(deleter.Username, ''),
+            trash.posts, trash.comments, trash.sort_id
+        FROM trash
+        LEFT JOIN user AS deleter ON trash.deleted_by = deleter.userid
+        `+where+`
+        ORDER BY trash.deleted_at DESC, trash.sort_id DESC
+        LIMIT ?
+    `, append(args, size+1)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetTrash: %v", err)
+	}
+	defer rows.Close()
+
+	var items []TrashItem
+	var keys []cursor
+	for rows.Next() {
+		var item TrashItem
+		var key cursor
+		if err := rows.Scan(&item.Kind, &item.ID, &item.PostID, &item.Title, &item.Content, &item.AuthorID, &item.AuthorName, &item.DeletedAt, &key.At,
+			&item.DeletedByID, &item.DeletedByName, &item.Posts, &item.Comments, &key.ID); err != nil {
+			return nil, "", fmt.Errorf("GetTrash: %v", err)
+		}
+		items = append(items, item)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("GetTrash: %v", err)
+	}
+
+	var next string
+	if len(items) > size {
+		items = items[:size]
+		next = keys[size-1].encode()
+	}
+	return items, next, nil
+}
+
+// TrashPurge says how many rows PurgeTrash removed for good.
+type TrashPurge struct {
+	Posts    int
+	Comments int
+	Users    int
+}
+
+// PurgeTrash permanently removes everything that went to the trash before
+// cutoff, along with the rows that hang off it: comments, reactions,
+// categories, attachments, reports, revisions, notifications, search
+// matches and bookmarks, and for users their sessions, tokens, saved
+// searches, bookmarks, collections and social connections. A purged comment
+// that still has replies is blanked rather than removed, so the thread below it
+// survives as a placeholder.
+func PurgeTrash(db *sql.DB, cutoff time.Time) (TrashPurge, error) {
+	var purged TrashPurge
+	tx, err := db.Begin()
+	if err != nil {
+		return purged, fmt.Errorf("PurgeTrash: %v", err)
+	}
+	defer tx.Rollback()
+
+	exec := func(query string, args ...any) (int, error) {
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		return int(n), err
+	}
+
+	// Posts first, taking their whole comment tree with them.
+	const oldPosts = "(SELECT postid FROM post WHERE deleted_at < ?)"
+	const oldPostComments = "(SELECT commentid FROM comment WHERE post_postid IN " + oldPosts + ")"
+	for _, query := range []string{
+		"DELETE FROM reactions WHERE target_type = 'comment' AND target_id IN " + oldPostComments,
+		"DELETE FROM reaction_counts WHERE target_type = 'comment' AND target_id IN " + oldPostComments,
+		"DELETE FROM comment_revisions WHERE comment_id IN " + oldPostComments,
+		"DELETE FROM notifications WHERE comment_id IN " + oldPostComments,
+		"DELETE FROM comment WHERE post_postid IN " + oldPosts,
+		"DELETE FROM reactions WHERE target_type = 'post' AND target_id IN " + oldPosts,
+		"DELETE FROM reaction_counts WHERE target_type = 'post' AND target_id IN " + oldPosts,
+		"DELETE FROM post_revisions WHERE post_id IN " + oldPosts,
+		"DELETE FROM post_has_categories WHERE post_postid IN " + oldPosts,
+		"DELETE FROM post_attachments WHERE post_id IN " + oldPosts,
+		"DELETE FROM saved_search_matches WHERE post_id IN " + oldPosts,
+		"DELETE FROM bookmarks WHERE post_id IN " + oldPosts,
+		"DELETE FROM collection_posts WHERE post_id IN " + oldPosts,
+		"DELETE FROM reports WHERE post_id IN " + oldPosts,
+		"DELETE FROM notifications WHERE post_id IN " + oldPosts,
+	} {
+		if _, err := exec(query, cutoff); err != nil {
+			return purged, fmt.Errorf("PurgeTrash: %v", err)
+		}
+	}
+	if purged.Posts, err = exec("DELETE FROM post WHERE deleted_at < ?", cutoff); err != nil {
+		return purged, fmt.Errorf("PurgeTrash: %v", err)
+	}
+
+	// Then comments, from the leaves up: removing one can leave its parent
+	// with no replies, ready to go on the next pass.
+	const oldLeaves = `(SELECT commentid FROM comment WHERE deleted_at < ?
+	    AND NOT EXISTS (SELECT 1 FROM comment AS reply WHERE reply.parent_comment_id = comment.commentid))`
+	for {
+		for _, query := range []string{
+			"DELETE FROM reactions WHERE target_type = 'comment' AND target_id IN " + oldLeaves,
+			"DELETE FROM reaction_counts WHERE target_type = 'comment' AND target_id IN " + oldLeaves,
+			"DELETE FROM comment_revisions WHERE comment_id IN " + oldLeaves,
+			"DELETE FROM notifications WHERE comment_id IN " + oldLeaves,
+			"DELETE FROM saved_search_matches WHERE comment_id IN " + oldLeaves,
+		} {
+			if _, err := exec(query, cutoff); err != nil {
+				return purged, fmt.Errorf("PurgeTrash: %v", err)
+			}
+		}
+		n, err := exec("DELETE FROM comment WHERE commentid IN "+oldLeaves, cutoff)
+		if err != nil {
+			return purged, fmt.Errorf("PurgeTrash: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+		purged.Comments += n
+	}
+	for _, query := range []string{
+		"DELETE FROM comment_revisions WHERE comment_id IN (SELECT commentid FROM comment WHERE deleted_at < ?)",
+		"UPDATE comment SET content = '' WHERE deleted_at < ? AND content != ''",
+	} {
+		if _, err := exec(query, cutoff); err != nil {
+			return purged, fmt.Errorf("PurgeTrash: %v", err)
+		}
+	}
+
+	// Last the users. Their posts and comments were trashed with them, so
+	// all that is left is what they did elsewhere.
+	const oldUsers = "(SELECT userid FROM user WHERE deleted_at < ?1)"
+	for _, query := range []string{
+		"DELETE FROM reactions WHERE user_userid IN " + oldUsers,
+		"DELETE FROM followers WHERE follower_id IN " + oldUsers + " OR following_id IN " + oldUsers,
+		"DELETE FROM friends WHERE user_userid IN " + oldUsers + " OR friend_userid IN " + oldUsers,
+		"DELETE FROM friend_requests WHERE sender_id IN " + oldUsers + " OR receiver_id IN " + oldUsers,
+		"DELETE FROM notification_actors WHERE actor_id IN " + oldUsers,
+		"DELETE FROM notifications WHERE user_userid IN " + oldUsers + " OR actor_id IN " + oldUsers,
+		"DELETE FROM reports WHERE reported_by IN " + oldUsers,
+		"DELETE FROM session WHERE userid IN " + oldUsers,
+		"DELETE FROM api_token WHERE userid IN " + oldUsers,
+		"DELETE FROM github WHERE user_userid IN " + oldUsers,
+		"DELETE FROM google WHERE user_userid IN " + oldUsers,
+		"DELETE FROM saved_search_matches WHERE search_id IN (SELECT searchid FROM saved_searches WHERE user_userid IN " + oldUsers + ")",
+		"DELETE FROM saved_searches WHERE user_userid IN " + oldUsers,
+		"DELETE FROM bookmarks WHERE user_userid IN " + oldUsers,
+		"DELETE FROM collection_posts WHERE collection_id IN (SELECT collectionid FROM collections WHERE user_userid IN " + oldUsers + ")",
+		"DELETE FROM collections WHERE user_userid IN " + oldUsers,
+	} {
+		if _, err := exec(query, cutoff); err != nil {
+			return purged, fmt.Errorf("PurgeTrash: %v", err)
+		}
+	}
+	if purged.Users, err = exec("DELETE FROM user WHERE deleted_at < ?", cutoff); err != nil {
+		return purged, fmt.Errorf("PurgeTrash: %v", err)
+	}
+	if purged.Users > 0 {
+		// Their reactions were counted on posts and comments that remain.
+		if _, err := repairCounts(tx); err != nil {
+			return purged, fmt.Errorf("PurgeTrash: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return purged, fmt.Errorf("PurgeTrash: %v", err)
+	}
+	return purged, nil
+}
@@ -1,50 +1,147 @@
 package main
 
 import (
-	db "01connecthub/database"
+	"01connecthub/database"
 	auth "01connecthub/src/authentication"
+	"01connecthub/src/config"
+	"01connecthub/src/media"
+	"01connecthub/src/permission"
 	"01connecthub/src/server"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 )
 
-func init() {
-	db.DataBase()
-}
+const (
+	sessionSweepInterval = 10 * time.Minute
+	trashPurgeInterval   = time.Hour
+	searchAlertInterval  = time.Minute
+	searchDigestInterval = time.Hour
+)
 
 func main() {
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := database.Open(cfg.DatabasePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(db, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := database.Migrate(db); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "repair-counts" {
+		repairs, err := database.RepairCounts(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Repaired counters on %d posts, %d comments and %d reaction totals\n", repairs.Posts, repairs.Comments, repairs.Reactions)
+		return
+	}
+
+	store, err := media.NewStore(cfg.MediaDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := server.LoadTemplates("templates"); err != nil {
+		log.Fatal(err)
+	}
+
+	repo := database.NewStore(db)
+	app := server.NewApp(repo, cfg, store)
+	oauth := auth.New(repo, cfg)
+
+	if err := app.BootstrapRoles(); err != nil {
+		log.Fatal(err)
+	}
+	if err := app.MigrateImageBlobs(); err != nil {
+		log.Fatal(err)
+	}
+
+	go app.SweepSessions(sessionSweepInterval)
+	go app.PurgeTrash(trashPurgeInterval)
+	go app.MatchSavedSearches(searchAlertInterval)
+	if app.Mail != nil {
+		go app.SendSearchDigests(searchDigestInterval)
+	}
+
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static/"))))
+	http.HandleFunc("/media/{hash}", app.ServeMedia)
+
+	http.HandleFunc("/", app.ReverseMiddleware(app.LoginPage))
+	http.HandleFunc("/logout", app.AuthMiddleware(app.Logout))
+	http.HandleFunc("/signup", app.SignupPage)
+	http.HandleFunc("/home", app.GuestMiddleware(app.HomePage))
+	http.HandleFunc("/newpost", app.AuthMiddleware(app.NewPostPage))
+	http.HandleFunc("/settings", app.AuthMiddleware(app.SettingsPage))
+	http.HandleFunc("/sessions/revoke", app.AuthMiddleware(app.RevokeSession))
+	http.HandleFunc("/sessions/revoke-all", app.AuthMiddleware(app.RevokeAllSessions))
+	http.HandleFunc("/tokens/create", app.AuthMiddleware(app.CreateAPIToken))
+	http.HandleFunc("/tokens/revoke", app.AuthMiddleware(app.RevokeAPIToken))
+	http.HandleFunc("/savedsearches/create", app.AuthMiddleware(app.SaveSearch))
+	http.HandleFunc("/savedsearches/digest", app.AuthMiddleware(app.SetSavedSearchDigest))
+	http.HandleFunc("/savedsearches/delete", app.AuthMiddleware(app.DeleteSavedSearch))
+	http.HandleFunc("/notifications", app.AuthMiddleware(app.NotificationsPage))
+	http.HandleFunc("/notifications/read", app.AuthMiddleware(app.ReadNotification))
+	http.HandleFunc("/notifications/read-all", app.AuthMiddleware(app.ReadAllNotifications))
+	http.HandleFunc("/events", app.Events)
+	http.HandleFunc("/myprofile", app.AuthMiddleware(app.MyProfilePage))
+	http.HandleFunc("/profile", app.AuthMiddleware(app.ProfilePage))
+	http.HandleFunc("/admin", app.AuthMiddleware(app.RequirePermission(permission.AdminPanel, app.AdminPage)))
+	http.HandleFunc("/trash", app.AuthMiddleware(app.RequirePermission(permission.TrashManage, app.TrashPage)))
+	http.HandleFunc("/trash/restore", app.AuthMiddleware(app.RequirePermission(permission.TrashManage, app.RestoreFromTrash)))
+	http.HandleFunc("/moderator", app.AuthMiddleware(app.RequirePermission(permission.ModerationPanel, app.ModeratorPage)))
+	http.HandleFunc("/post", app.AuthMiddleware(app.PostPage))
+	http.HandleFunc("/like", app.AuthMiddleware(app.LikePost))
+	http.HandleFunc("/dislike", app.AuthMiddleware(app.DislikePost))
+	http.HandleFunc("/commentlike", app.AuthMiddleware(app.LikeComment))
+	http.HandleFunc("/commentdislike", app.AuthMiddleware(app.DislikeComment))
+	http.HandleFunc("/react", app.AuthMiddleware(app.React))
+	http.HandleFunc("/bookmark", app.AuthMiddleware(app.Bookmark))
+	http.HandleFunc("/collections/create", app.AuthMiddleware(app.CreateCollection))
+	http.HandleFunc("/collections/rename", app.AuthMiddleware(app.RenameCollection))
+	http.HandleFunc("/collections/share", app.AuthMiddleware(app.ShareCollection))
+	http.HandleFunc("/collections/delete", app.AuthMiddleware(app.DeleteCollection))
+	http.HandleFunc("/collections/posts", app.AuthMiddleware(app.CollectPost))
+	http.HandleFunc("/deletepost", app.AuthMiddleware(app.DeletePost))
+	http.HandleFunc("/reportpost", app.AuthMiddleware(app.RequirePermission(permission.PostReport, app.ReportPost)))
+	http.HandleFunc("/deletecomment", app.AuthMiddleware(app.DeleteComment))
+	http.HandleFunc("/editpost", app.AuthMiddleware(app.EditPost))
+	http.HandleFunc("/editcomment", app.AuthMiddleware(app.EditComment))
+	http.HandleFunc("/revisions", app.AuthMiddleware(app.RequirePermission(permission.RevisionView, app.RevisionsPage)))
+	http.HandleFunc("/changepassword", app.AuthMiddleware(app.ChangePassword))
+	// http.HandleFunc("/togglepassword", app.AuthMiddleware(app.TogglePassword))
+	http.HandleFunc("/addcomment", app.AuthMiddleware(app.AddComment))
+	http.HandleFunc("/reply", app.AuthMiddleware(app.ReplyComment))
+	http.HandleFunc("/follow", app.AuthMiddleware(app.Follow))
+	http.HandleFunc("/unfollow", app.AuthMiddleware(app.Unfollow))
+	http.HandleFunc("/friend-request", app.AuthMiddleware(app.FriendRequest))
+	http.HandleFunc("/friend-accept", app.AuthMiddleware(app.FriendAccept))
+	http.HandleFunc("/friend-decline", app.AuthMiddleware(app.FriendDecline))
+	http.HandleFunc("/callbackGoogle", oauth.CallbackGoogle)
+	http.HandleFunc("/auth/google", oauth.LoginPageGoogle)
+	http.HandleFunc("/callback", oauth.Callback)
+	http.HandleFunc("/auth/github", oauth.LoginPageGit)
+	http.HandleFunc("/search", app.AuthMiddleware(app.SearchHandler))
+	http.HandleFunc("/searchpage", app.AuthMiddleware(app.SearchPageHandler))
+	http.Handle(server.APIPrefix, app.APIHandler())
+
+	fmt.Printf("Server running on %s\nTo stop the server press Ctrl+C\n", cfg.BaseURL)
 
-	http.HandleFunc("/", server.ReverseMiddleware(server.LoginPage))
-	http.HandleFunc("/logout", server.AuthMiddleware(server.Logout))
-	http.HandleFunc("/signup", server.SignupPage)
-	http.HandleFunc("/home", server.HomePage)
-	http.HandleFunc("/newpost", server.AuthMiddleware(server.NewPostPage))
-	http.HandleFunc("/settings", server.AuthMiddleware(server.SettingsPage))
-	http.HandleFunc("/notifications", server.AuthMiddleware(server.NotificationsPage))
-	http.HandleFunc("/myprofile", server.AuthMiddleware(server.MyProfilePage))
-	http.HandleFunc("/profile", server.AuthMiddleware(server.ProfilePage))
-	http.HandleFunc("/admin", server.AuthMiddleware(server.AdminPage))
-	http.HandleFunc("/moderator", server.AuthMiddleware(server.ModeratorPage))
-	http.HandleFunc("/post", server.AuthMiddleware(server.PostPage))
-	http.HandleFunc("/like", server.AuthMiddleware(server.LikePost))
-	http.HandleFunc("/dislike", server.AuthMiddleware(server.DislikePost))
-	http.HandleFunc("/commentlike", server.AuthMiddleware(server.LikeComment))
-	http.HandleFunc("/commentdislike", server.AuthMiddleware(server.DislikeComment))
-	http.HandleFunc("/deletepost", server.AuthMiddleware(server.DeletePost))
-	http.HandleFunc("/reportpost", server.AuthMiddleware(server.ReportPost))
-	http.HandleFunc("/changepassword", server.AuthMiddleware(server.ChangePassword))
-	// http.HandleFunc("/togglepassword", server.AuthMiddleware(server.TogglePassword))
-	http.HandleFunc("/addcomment", server.AuthMiddleware(server.AddComment))
-	http.HandleFunc("/callbackGoogle", auth.CallbackGoogle)
-	http.HandleFunc("/auth/google", auth.LoginPageGoogle)
-	http.HandleFunc("/callback", auth.Callback)
-	http.HandleFunc("/auth/github", auth.LoginPageGit)
-	http.HandleFunc("/search", server.AuthMiddleware(server.SearchHandler))
-	http.HandleFunc("/searchpage", server.AuthMiddleware(server.SearchPageHandler))
-
-	fmt.Println("Server running on http://localhost:8080\nTo stop the server press Ctrl+C")
-
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	log.Fatal(http.ListenAndServe(cfg.Addr, app.CSRFMiddleware(http.DefaultServeMux)))
 }
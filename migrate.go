@@ -0,0 +1,56 @@
+package main
+
+import (
+	"01connecthub/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const migrateUsage = `usage: go run . [flags] migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [n]    roll back the last n migrations (default 1, "all" for every one)
+  status      list migrations and whether they are applied`
+
+func runMigrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		return database.Migrate(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if args[1] == "all" {
+				steps = 0
+			} else {
+				n, err := strconv.Atoi(args[1])
+				if err != nil || n < 1 {
+					return fmt.Errorf("invalid number of migrations: %q", args[1])
+				}
+				steps = n
+			}
+		}
+		return database.Rollback(db, steps)
+	case "status":
+		states, err := database.MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			if state.Applied {
+				fmt.Printf("%04d_%-40s applied %s\n", state.Version, state.Name, state.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d_%-40s pending\n", state.Version, state.Name)
+			}
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
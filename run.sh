@@ -2,7 +2,7 @@
 
 echo "Choose an option to run the application:"
 echo "1. Run with Docker"
-echo "2. Run with go run main.go"
+echo "2. Run with go run ."
 read -p "Enter your choice [1 or 2]: " choice
 
 if [ "$choice" -eq 1 ]; then
@@ -22,9 +22,9 @@ if [ "$choice" -eq 1 ]; then
     # Run the Docker container
     docker run -p 8080:8080 --name forum forum
 elif [ "$choice" -eq 2 ]; then
-    # Run the application using go run main.go
+    # Run the application using go run .
     clear
-    go run main.go
+    go run -tags sqlite_fts5 .
 else
     echo "Invalid choice. Please run the script again and choose either 1 or 2."
     exit 1
@@ -1,55 +1,98 @@
 package Handlers
 
 import (
+	"01connecthub/database"
+	"01connecthub/src/config"
 	"01connecthub/src/security"
 	"01connecthub/src/server"
 	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
-	html "html/template"
 	"log"
 	"net/http"
 	"strings"
-	"time"
 
-	"github.com/google/uuid"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 	"golang.org/x/oauth2/google"
 )
 
-var (
-	oauthConfigGithub = &oauth2.Config{
-		ClientID:     "Ov23liYl2L30q080Nif5",
-		ClientSecret: "b8815439273a6e564a6f0d7f82c775c3d7e2383a",
-		RedirectURL:  "http://localhost:8080/callback",
-		Scopes:       []string{"user"},
-		Endpoint:     github.Endpoint,
+// Auth holds the OAuth handlers. Accounts are created or linked through the
+// shared repository rather than a connection of their own. A provider whose
+// client is not configured is nil and its login routes answer 404.
+type Auth struct {
+	Repo   database.Repository
+	Config config.Config
+	github *oauth2.Config
+	google *oauth2.Config
+}
+
+func New(repo database.Repository, cfg config.Config) *Auth {
+	a := &Auth{Repo: repo, Config: cfg}
+	if cfg.GitHub.Enabled() {
+		a.github = &oauth2.Config{
+			ClientID:     cfg.GitHub.ClientID,
+			ClientSecret: cfg.GitHub.ClientSecret,
+			RedirectURL:  cfg.BaseURL + "/callback",
+			Scopes:       []string{"user"},
+			Endpoint:     github.Endpoint,
+		}
 	}
-	oauthStateStringGit = "randomstring"
-)
+	if cfg.Google.Enabled() {
+		a.google = &oauth2.Config{
+			ClientID:     cfg.Google.ClientID,
+			ClientSecret: cfg.Google.ClientSecret,
+			RedirectURL:  cfg.BaseURL + "/callbackGoogle",
+			Scopes: []string{
+				"https://www.googleapis.com/auth/userinfo.profile",
+				"https://www.googleapis.com/auth/userinfo.email",
+			},
+			Endpoint: google.Endpoint,
+		}
+	}
+	return a
+}
 
-var templates = html.Must(html.ParseGlob("templates/*.html"))
+// providerDisabled answers for a login provider that is not configured.
+func providerDisabled(w http.ResponseWriter, r *http.Request, name string) {
+	log.Println(name, "login is not configured")
+	errData := server.ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
+	server.ErrHandler(w, r, &errData)
+}
 
-func LoginPageGit(w http.ResponseWriter, r *http.Request) {
-	url := oauthConfigGithub.AuthCodeURL(oauthStateStringGit)
+func (a *Auth) LoginPageGit(w http.ResponseWriter, r *http.Request) {
+	if a.github == nil {
+		providerDisabled(w, r, "GitHub")
+		return
+	}
+	state, err := security.NewOAuthState(w)
+	if err != nil {
+		log.Println("Failed to generate OAuth state:", err)
+		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		server.ErrHandler(w, r, &errData)
+		return
+	}
+	url := a.github.AuthCodeURL(state)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
-func Callback(w http.ResponseWriter, r *http.Request) {
-	if r.FormValue("state") != oauthStateStringGit {
+func (a *Auth) Callback(w http.ResponseWriter, r *http.Request) {
+	if a.github == nil {
+		providerDisabled(w, r, "GitHub")
+		return
+	}
+	if !security.CheckOAuthState(w, r) {
 		http.Error(w, "State is invalid", http.StatusBadRequest)
 		return
 	}
 
-	token, err := oauthConfigGithub.Exchange(context.Background(), r.FormValue("code"))
+	token, err := a.github.Exchange(context.Background(), r.FormValue("code"))
 	if err != nil {
 		http.Error(w, "Code exchange failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	client := oauthConfigGithub.Client(context.Background(), token)
+	client := a.github.Client(context.Background(), token)
 	userInfo, err := client.Get("https://api.github.com/user")
 	if err != nil {
 		http.Error(w, "Failed to get user info: "+err.Error(), http.StatusInternalServerError)
@@ -91,15 +134,7 @@ func Callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine role_id based on email
-	var roleID int
-	if primaryEmail == "sayedahmed97.sad@gmail.com" {
-		roleID = 1 // Admin
-	} else if primaryEmail == "qassimhassan9@gmail.com" {
-		roleID = 2 // Moderator
-	} else {
-		roleID = 3 // Regular user
-	}
+	roleID := server.SignupRoleID(a.Config, primaryEmail)
 
 	username := strings.Split(primaryEmail, "@")[0]
 	hashed, err := server.HashPassword("github_oauth") // Use a short consistent password
@@ -108,219 +143,90 @@ func Callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		server.ErrHandler(w, r, &errData)
-		return
+	defaultAvatar := "static/assets/default-avatar.png"
+	githubAvatar, _ := user["avatar_url"].(string)
+	if githubAvatar == "" {
+		githubAvatar = defaultAvatar
 	}
-	defer db.Close()
 
-	// Begin transaction
-	tx, err := db.Begin()
-	if err != nil {
-		log.Println("Failed to begin transaction:", err)
-		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		server.ErrHandler(w, r, &errData)
+	githubID, ok := user["id"].(float64)
+	if !ok {
+		http.Error(w, "GitHub ID is missing or invalid", http.StatusInternalServerError)
 		return
 	}
 
+	account := database.OAuthAccount{
+		Provider:     "Github",
+		ExternalID:   fmt.Sprintf("%.0f", githubID),
+		Username:     username,
+		Email:        primaryEmail,
+		PasswordHash: hashed,
+		Avatar:       githubAvatar,
+		RoleID:       roleID,
+	}
+
 	// Generate session token
-	sessionToken, err := security.GenerateToken()
+	session, err := security.NewSession(r)
 	if err != nil {
 		log.Println("Failed to generate session token:", err)
-		tx.Rollback()
 		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		server.ErrHandler(w, r, &errData)
 		return
 	}
 
-	// Check if user exists
-	var userExists bool
-	var userID int
-
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE email = ?)", primaryEmail).Scan(&userExists)
-	if err != nil {
-		log.Println("Error checking if user exists:", err)
-		tx.Rollback()
-		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+	// Create or link the user together with its session
+	_, err = a.Repo.UpsertOAuthUser(account, session)
+	if err == database.ErrAccountDeleted {
+		log.Println("Refused sign in to deleted account:", account.Email)
+		errData := server.ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
 		server.ErrHandler(w, r, &errData)
 		return
-	}
-
-	defaultAvatar := "static/assets/default-avatar.png"
-	githubAvatar, _ := user["avatar_url"].(string)
-	if githubAvatar == "" {
-		githubAvatar = defaultAvatar
-	}
-
-	if userExists {
-		// User exists - update user information and session
-		err = db.QueryRow("SELECT userid FROM user WHERE email = ?", primaryEmail).Scan(&userID)
-		if err != nil {
-			log.Println("Error getting user ID:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		// Update user details
-		_, err = tx.Exec("UPDATE user SET provider = ?, current_session = ? WHERE userid = ?",
-			"Github", sessionToken, userID)
-		if err != nil {
-			log.Println("Error updating user:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		// Check if GitHub record exists
-		var githubExists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM github WHERE user_userid = ?)", userID).Scan(&githubExists)
-		if err != nil {
-			log.Println("Error checking if GitHub record exists:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		githubID, ok := user["id"].(float64)
-		if !ok {
-			http.Error(w, "GitHub ID is missing or invalid", http.StatusInternalServerError)
-			tx.Rollback()
-			return
-		}
-		githubIDString := fmt.Sprintf("%.0f", githubID)
-
-		if githubExists {
-			// Update GitHub record
-			_, err = tx.Exec("UPDATE github SET gitAvatar = ? WHERE user_userid = ?",
-				githubAvatar, userID)
-			if err != nil {
-				log.Println("Error updating GitHub record:", err)
-				tx.Rollback()
-				errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				server.ErrHandler(w, r, &errData)
-				return
-			}
-		} else {
-			// Insert GitHub record
-			_, err = tx.Exec("INSERT INTO github (gituserid, gitF_name, gitL_name, gitUsername, gitEmail, gitpassword, gitAvatar, user_userid) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-				githubIDString, "", "", username, primaryEmail, hashed, githubAvatar, userID)
-			if err != nil {
-				log.Println("Error inserting GitHub record:", err)
-				tx.Rollback()
-				errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				server.ErrHandler(w, r, &errData)
-				return
-			}
-		}
-	} else {
-		// Insert new user
-		res, err := tx.Exec("INSERT INTO user (F_name, L_name, Username, Email, password, current_session, role_id, Avatar, provider) VALUES (?, ?, ?, ?, ?, ?, ?, ?,?)",
-			username, "", username, primaryEmail, hashed, sessionToken.String(), roleID, githubAvatar, "Github")
-		if err != nil {
-			log.Println("Failed to insert user data:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		lastID, err := res.LastInsertId()
-		if err != nil {
-			log.Println("Failed to get last insert ID:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		userID = int(lastID)
-
-		githubID, ok := user["id"].(float64)
-		if !ok {
-			http.Error(w, "GitHub ID is missing or invalid", http.StatusInternalServerError)
-			tx.Rollback()
-			return
-		}
-		githubIDString := fmt.Sprintf("%.0f", githubID)
-
-		// Insert GitHub record
-		_, err = tx.Exec("INSERT INTO github (gituserid, gitF_name, gitL_name, gitUsername, gitEmail, gitpassword, gitAvatar, user_userid) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-			githubIDString, "", "", username, primaryEmail, hashed, githubAvatar, userID)
-		if err != nil {
-			log.Println("Failed to insert GitHub data:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-	}
-
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		log.Println("Failed to commit transaction:", err)
+	} else if err != nil {
+		log.Println("Failed to store GitHub user:", err)
 		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		server.ErrHandler(w, r, &errData)
 		return
 	}
 
 	// Create session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionToken.String(),
-		Expires:  time.Now().Add(1 * time.Hour),
-		HttpOnly: true,
-	})
-
-	// Insert or update session record
-	_, err = db.Exec("INSERT OR REPLACE INTO session (sessionid, userid, endtime) VALUES (?, ?, ?)",
-		sessionToken.String(), userID, time.Now().Add(1*time.Hour))
-	if err != nil {
-		log.Println("Error creating session:", err)
-		// Non-fatal error, continue
-	}
+	security.SetSessionCookie(w, session.Token, session.ExpiresAt)
 
 	// Redirect to home page
 	http.Redirect(w, r, "/home?tab=posts&filter=all", http.StatusSeeOther)
 }
 
-var (
-	oauthConfigGoogle = &oauth2.Config{
-		ClientID:     "308640975130-ejeugakggjsq2n0gco97minddk4b8elt.apps.googleusercontent.com",
-		ClientSecret: "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",
-		RedirectURL:  "http://localhost:8080/callbackGoogle",
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.profile",
-			"https://www.googleapis.com/auth/userinfo.email",
-		},
-		Endpoint: google.Endpoint,
-	}
-	oauthStateStringGoogle = "randomstring"
-)
-
-func LoginPageGoogle(w http.ResponseWriter, r *http.Request) {
-	url := oauthConfigGoogle.AuthCodeURL(oauthStateStringGoogle)
+func (a *Auth) LoginPageGoogle(w http.ResponseWriter, r *http.Request) {
+	if a.google == nil {
+		providerDisabled(w, r, "Google")
+		return
+	}
+	state, err := security.NewOAuthState(w)
+	if err != nil {
+		log.Println("Failed to generate OAuth state:", err)
+		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		server.ErrHandler(w, r, &errData)
+		return
+	}
+	url := a.google.AuthCodeURL(state)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
-func CallbackGoogle(w http.ResponseWriter, r *http.Request) {
-	if r.FormValue("state") != oauthStateStringGoogle {
+func (a *Auth) CallbackGoogle(w http.ResponseWriter, r *http.Request) {
+	if a.google == nil {
+		providerDisabled(w, r, "Google")
+		return
+	}
+	if !security.CheckOAuthState(w, r) {
 		http.Error(w, "State is invalid", http.StatusBadRequest)
 		return
 	}
 
-	token, err := oauthConfigGoogle.Exchange(context.Background(), r.FormValue("code"))
+	token, err := a.google.Exchange(context.Background(), r.FormValue("code"))
 	if err != nil {
 		http.Error(w, "Code exchange failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	client := oauthConfigGoogle.Client(context.Background(), token)
+	client := a.google.Client(context.Background(), token)
 	userInfo, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
 	if err != nil {
 		http.Error(w, "Failed to get user info: "+err.Error(), http.StatusInternalServerError)
@@ -347,15 +253,7 @@ func CallbackGoogle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine role_id based on email
-	var roleID int
-	if email == "sayedahmed97.sad@gmail.com" {
-		roleID = 1 // Admin
-	} else if email == "qassimhassan9@gmail.com" {
-		roleID = 2 // Moderator
-	} else {
-		roleID = 3 // Regular user
-	}
+	roleID := server.SignupRoleID(a.Config, email)
 
 	firstName, _ := user["given_name"].(string)
 	lastName, _ := user["family_name"].(string)
@@ -369,258 +267,44 @@ func CallbackGoogle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		server.ErrHandler(w, r, &errData)
-		return
+	account := database.OAuthAccount{
+		Provider:     "Google",
+		ExternalID:   googleID,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Username:     username,
+		Email:        email,
+		PasswordHash: hashed,
+		Avatar:       profilePicture,
+		RoleID:       roleID,
 	}
-	defer db.Close()
 
-	// Begin a transaction to ensure database consistency
-	tx, err := db.Begin()
-	if err != nil {
-		log.Println("Failed to begin transaction:", err)
-		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		server.ErrHandler(w, r, &errData)
-		return
-	}
-
-	// Generate a session token
-	sessionToken, err := security.GenerateToken()
+	// Generate session token
+	session, err := security.NewSession(r)
 	if err != nil {
 		log.Println("Failed to generate session token:", err)
-		tx.Rollback()
 		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		server.ErrHandler(w, r, &errData)
 		return
 	}
 
-	// Check if user exists with this email
-	var userExists bool
-	var userID int
-
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE email = ?)", email).Scan(&userExists)
-	if err != nil {
-		log.Println("Error checking if user exists:", err)
-		tx.Rollback()
-		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+	// Create or link the user together with its session
+	_, err = a.Repo.UpsertOAuthUser(account, session)
+	if err == database.ErrAccountDeleted {
+		log.Println("Refused sign in to deleted account:", account.Email)
+		errData := server.ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
 		server.ErrHandler(w, r, &errData)
 		return
-	}
-
-	if userExists {
-		// User exists - update user information and session
-		err = db.QueryRow("SELECT userid FROM user WHERE email = ?", email).Scan(&userID)
-		if err != nil {
-			log.Println("Error getting user ID:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		// Update user details
-		_, err = tx.Exec("UPDATE user SET   provider = ?, current_session = ? WHERE userid = ?",
-			 "Google", sessionToken,  userID)
-		if err != nil {
-			log.Println("Error updating user:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		// Check if Google record exists for this user
-		var googleExists bool
-
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM google WHERE user_userid = ?)", userID).Scan(&googleExists)
-		if err != nil {
-			log.Println("Error checking if Google record exists:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		if googleExists {
-			// Update Google record
-			_, err = tx.Exec("UPDATE google SET googleF_name = ?, googleL_name = ?, googleAvatar = ? WHERE user_userid = ?",
-				firstName, lastName, profilePicture, userID)
-			if err != nil {
-				log.Println("Error updating Google record:", err)
-				tx.Rollback()
-				errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				server.ErrHandler(w, r, &errData)
-				return
-			}
-		} else {
-			// Insert Google record
-			_, err = tx.Exec("INSERT INTO google (google_api_id, googleF_name, googleL_name, googleUsername, googleEmail, googlepassword, googleAvatar, user_userid) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-				googleID, firstName, lastName, username, email, hashed, profilePicture, userID)
-			if err != nil {
-				log.Println("Error inserting Google record:", err)
-				tx.Rollback()
-				errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				server.ErrHandler(w, r, &errData)
-				return
-			}
-		}
-	} else {
-		// Insert new user
-		res, err := tx.Exec("INSERT INTO user (F_name, L_name, Username, Email, password, current_session, role_id, Avatar, provider) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-			firstName, lastName, username, email, hashed, sessionToken.String(), roleID, profilePicture, "Google")
-		if err != nil {
-			log.Println("Failed to insert user data:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		lastID, err := res.LastInsertId()
-		if err != nil {
-			log.Println("Failed to get last insert ID:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-
-		userID = int(lastID)
-
-		// Insert Google record
-		_, err = tx.Exec("INSERT INTO google (google_api_id, googleF_name, googleL_name, googleUsername, googleEmail, googlepassword, googleAvatar, user_userid) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-			googleID, firstName, lastName, username, email, hashed, profilePicture, userID)
-		if err != nil {
-			log.Println("Failed to insert Google data:", err)
-			tx.Rollback()
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return
-		}
-	}
-
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		log.Println("Failed to commit transaction:", err)
+	} else if err != nil {
+		log.Println("Failed to store Google user:", err)
 		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		server.ErrHandler(w, r, &errData)
 		return
 	}
 
 	// Create session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionToken.String(),
-		Expires:  time.Now().Add(1 * time.Hour),
-		HttpOnly: true,
-	})
-
-	// Insert or update session record
-	_, err = db.Exec("INSERT OR REPLACE INTO session (sessionid, userid, endtime) VALUES (?, ?, ?)",
-		sessionToken.String(), userID, time.Now().Add(1*time.Hour))
-	if err != nil {
-		log.Println("Error creating session:", err)
-		// Non-fatal error, continue
-	}
+	security.SetSessionCookie(w, session.Token, session.ExpiresAt)
 
 	// Redirect to home page
 	http.Redirect(w, r, "/home?tab=posts&filter=all", http.StatusSeeOther)
 }
-func Emailexists(email string, w http.ResponseWriter, r *http.Request) (bool, error) {
-	var emailExists bool
-
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		server.ErrHandler(w, r, &errData)
-		return false, err
-	}
-
-	defer db.Close()
-	if r.Method == "POST" {
-
-		email := r.FormValue("email")
-
-		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE email = ?)", email).Scan(&emailExists)
-		if err != nil {
-			log.Println("Failed to check if email exists")
-			errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			server.ErrHandler(w, r, &errData)
-			return false, err
-		}
-
-		if emailExists {
-			err := templates.ExecuteTemplate(w, "signup.html", map[string]string{
-				"ErrorMessage": "Email already exists",
-			})
-			if err != nil {
-				log.Println("Error rendering signup page:", err)
-				errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				server.ErrHandler(w, r, &errData)
-			}
-			return true, err
-		}
-	}
-	return false, nil
-}
-
-func Login(InputEmail, inputPassword, provider string, w http.ResponseWriter, r *http.Request) (int, uuid.UUID, error) {
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		errData := server.ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		server.ErrHandler(nil, nil, &errData)
-		return 0, uuid.Nil, err
-	}
-	defer db.Close()
-
-	statement, err := db.Prepare("SELECT userid, F_name, L_name, username, email, password, current_session, provider FROM user WHERE email = ? AND provider = ?")
-	if err != nil {
-		return 0, uuid.Nil, err
-	}
-	defer statement.Close()
-
-	var id int
-	var firstName string
-	var lastName string
-	var username string
-	var email string
-	var password string
-	var sessionID sql.NullString
-	var Provider string
-
-	row := statement.QueryRow(InputEmail, provider)
-	err = row.Scan(&id, &firstName, &lastName, &username, &email, &password, &sessionID, &Provider)
-	if err != nil {
-		log.Println("Login scan error:", err)
-		return 0, uuid.Nil, err
-	}
-
-	// For OAuth logins, skip password verification or use a different approach
-	if provider == "Github" || provider == "Google" {
-		// OAuth login - we stored a shortened token or "ggg", not a real password
-	} else {
-		if !server.VerifyPassword(inputPassword, password) {
-			return 0, uuid.Nil, fmt.Errorf("invalid credentials")
-		}
-	}
-
-	// Check for existing session
-	cook, valid := r.Cookie("session_id")
-	if valid == nil && cook.Value != "" {
-		return 0, uuid.Nil, fmt.Errorf("user already has an active session")
-	}
-
-	newSessionID, _ := security.GenerateToken()
-
-	_, err = db.Exec("UPDATE user SET current_session = ? WHERE userid = ?", newSessionID, id)
-	if err != nil {
-		return 0, uuid.Nil, err
-	}
-
-	return id, newSessionID, nil
-}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config is everything that differs between deployments. Load builds it from,
+// in increasing order of precedence, the defaults, a JSON file, environment
+// variables and command line flags.
+type Config struct {
+	// Addr is the address the server listens on.
+	Addr string `json:"addr"`
+	// BaseURL is where users reach the server. OAuth redirect URLs are built
+	// from it.
+	BaseURL      string `json:"base_url"`
+	DatabasePath string `json:"database_path"`
+	// MediaDir is where uploaded images and other attachments are stored.
+	MediaDir string `json:"media_dir"`
+	GitHub   OAuth  `json:"github"`
+	Google   OAuth  `json:"google"`
+	// Admins and Moderators are email addresses whose accounts hold those
+	// roles. Everyone else signs up as a regular user.
+	Admins     []string `json:"admins"`
+	Moderators []string `json:"moderators"`
+	// TrashRetentionDays is how long deleted posts, comments and users can
+	// still be restored before they are purged for good.
+	TrashRetentionDays int `json:"trash_retention_days"`
+	// MaxPostLength is how many characters a post may have in categories
+	// without a limit of their own.
+	MaxPostLength int `json:"max_post_length"`
+	// SMTP is the mail server saved search digests are sent through.
+	SMTP SMTP `json:"smtp"`
+}
+
+// OAuth is the client registered with a login provider. Leaving both fields
+// empty turns the provider off.
+type OAuth struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+func (o OAuth) Enabled() bool {
+	return o.ClientID != ""
+}
+
+// SMTP is an outgoing mail server. Leaving Host empty turns email off.
+// Username and Password may be empty for servers that don't require a login.
+type SMTP struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// From is the address emails are sent from.
+	From string `json:"from"`
+}
+
+func (s SMTP) Enabled() bool {
+	return s.Host != ""
+}
+
+// DefaultFile is read when no config file is named. Unlike a named file it
+// may be missing.
+const DefaultFile = "config.json"
+
+func Default() Config {
+	return Config{
+		Addr:               ":8080",
+		BaseURL:            "http://localhost:8080",
+		DatabasePath:       "./database/main.db",
+		MediaDir:           "./media",
+		TrashRetentionDays: 30,
+		MaxPostLength:      500,
+		SMTP:               SMTP{Port: 587},
+	}
+}
+
+// Load builds the configuration from args, which are the command line
+// arguments without the program name, and validates it. Arguments after the
+// flags, such as a subcommand, are returned as rest.
+func Load(args []string) (cfg Config, rest []string, err error) {
+	fs := flag.NewFlagSet("01connecthub", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a JSON config file (default "+DefaultFile+", or $CONNECTHUB_CONFIG)")
+	addr := fs.String("addr", "", "address to listen on")
+	baseURL := fs.String("base-url", "", "public URL of the server")
+	dbPath := fs.String("db", "", "path to the SQLite database")
+	mediaDir := fs.String("media-dir", "", "directory uploaded images are stored in")
+	admins := fs.String("admins", "", "comma separated emails of admin accounts")
+	moderators := fs.String("moderators", "", "comma separated emails of moderator accounts")
+	retention := fs.Int("trash-retention-days", 0, "days deleted content stays restorable")
+	maxPost := fs.Int("max-post-length", 0, "characters allowed in a post outside categories with their own limit")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg = Default()
+
+	path, required := *file, true
+	if path == "" {
+		path = os.Getenv("CONNECTHUB_CONFIG")
+	}
+	if path == "" {
+		path, required = DefaultFile, false
+	}
+	if err := cfg.readFile(path, required); err != nil {
+		return Config{}, nil, err
+	}
+
+	if err := cfg.readEnv(); err != nil {
+		return Config{}, nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = *addr
+		case "base-url":
+			cfg.BaseURL = *baseURL
+		case "db":
+			cfg.DatabasePath = *dbPath
+		case "media-dir":
+			cfg.MediaDir = *mediaDir
+		case "admins":
+			cfg.Admins = splitList(*admins)
+		case "moderators":
+			cfg.Moderators = splitList(*moderators)
+		case "trash-retention-days":
+			cfg.TrashRetentionDays = *retention
+		case "max-post-length":
+			cfg.MaxPostLength = *maxPost
+		}
+	})
+
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+func (c *Config) readFile(path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("config: %s: %v", path, err)
+	}
+	return nil
+}
+
+func (c *Config) readEnv() error {
+	setFromEnv(&c.Addr, "CONNECTHUB_ADDR")
+	setFromEnv(&c.BaseURL, "CONNECTHUB_BASE_URL")
+	setFromEnv(&c.DatabasePath, "CONNECTHUB_DATABASE")
+	setFromEnv(&c.MediaDir, "CONNECTHUB_MEDIA_DIR")
+	setFromEnv(&c.GitHub.ClientID, "GITHUB_CLIENT_ID")
+	setFromEnv(&c.GitHub.ClientSecret, "GITHUB_CLIENT_SECRET")
+	setFromEnv(&c.Google.ClientID, "GOOGLE_CLIENT_ID")
+	setFromEnv(&c.Google.ClientSecret, "GOOGLE_CLIENT_SECRET")
+	setFromEnv(&c.SMTP.Host, "CONNECTHUB_SMTP_HOST")
+	setFromEnv(&c.SMTP.Username, "CONNECTHUB_SMTP_USERNAME")
+	setFromEnv(&c.SMTP.Password, "CONNECTHUB_SMTP_PASSWORD")
+	setFromEnv(&c.SMTP.From, "CONNECTHUB_SMTP_FROM")
+	if v, ok := os.LookupEnv("CONNECTHUB_ADMINS"); ok {
+		c.Admins = splitList(v)
+	}
+	if v, ok := os.LookupEnv("CONNECTHUB_MODERATORS"); ok {
+		c.Moderators = splitList(v)
+	}
+	if err := setIntFromEnv(&c.TrashRetentionDays, "CONNECTHUB_TRASH_RETENTION_DAYS"); err != nil {
+		return err
+	}
+	if err := setIntFromEnv(&c.SMTP.Port, "CONNECTHUB_SMTP_PORT"); err != nil {
+		return err
+	}
+	return setIntFromEnv(&c.MaxPostLength, "CONNECTHUB_MAX_POST_LENGTH")
+}
+
+func setFromEnv(field *string, name string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*field = v
+	}
+}
+
+func setIntFromEnv(field *int, name string) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("config: %s %q is not a number", name, v)
+	}
+	*field = n
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate reports every problem with c at once so a bad deployment can be
+// fixed in one go.
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr %q: %v", c.Addr, err))
+	}
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("base_url %q must be an absolute http or https URL", c.BaseURL))
+	}
+	if c.DatabasePath == "" {
+		errs = append(errs, errors.New("database_path must be set"))
+	}
+	if c.MediaDir == "" {
+		errs = append(errs, errors.New("media_dir must be set"))
+	}
+	if c.TrashRetentionDays < 1 {
+		errs = append(errs, fmt.Errorf("trash_retention_days %d must be at least 1", c.TrashRetentionDays))
+	}
+	if c.MaxPostLength < 1 {
+		errs = append(errs, fmt.Errorf("max_post_length %d must be at least 1", c.MaxPostLength))
+	}
+
+	for _, p := range []struct {
+		name  string
+		oauth OAuth
+	}{{"github", c.GitHub}, {"google", c.Google}} {
+		if (p.oauth.ClientID == "") != (p.oauth.ClientSecret == "") {
+			errs = append(errs, fmt.Errorf("%s: client_id and client_secret must be set together", p.name))
+		}
+	}
+
+	if c.SMTP.Enabled() {
+		if c.SMTP.Port < 1 || c.SMTP.Port > 65535 {
+			errs = append(errs, fmt.Errorf("smtp: port %d is not a valid port", c.SMTP.Port))
+		}
+		if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+			errs = append(errs, fmt.Errorf("smtp: from %q is not an email address", c.SMTP.From))
+		}
+	}
+
+	admins := make(map[string]bool)
+	for _, email := range c.Admins {
+		if !strings.Contains(email, "@") {
+			errs = append(errs, fmt.Errorf("admins: %q is not an email address", email))
+		}
+		admins[strings.ToLower(email)] = true
+	}
+	for _, email := range c.Moderators {
+		if !strings.Contains(email, "@") {
+			errs = append(errs, fmt.Errorf("moderators: %q is not an email address", email))
+		}
+		if admins[strings.ToLower(email)] {
+			errs = append(errs, fmt.Errorf("%q is listed as both admin and moderator", email))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c Config) IsAdmin(email string) bool {
+	return containsEmail(c.Admins, email)
+}
+
+func (c Config) IsModerator(email string) bool {
+	return containsEmail(c.Moderators, email)
+}
+
+func containsEmail(list []string, email string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// FeedTopic carries reaction counts for every post, for pages that list many
+// posts at once.
+const FeedTopic = "feed"
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped. Browsers reconnect on their own, so a slow client costs itself a
+// reconnect instead of holding up everyone else.
+const subscriberBuffer = 32
+
+func UserTopic(userID int) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+func PostTopic(postID int) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+// Event is one message pushed to subscribers. Name becomes the SSE event name
+// and Data is sent as JSON.
+type Event struct {
+	Name string
+	Data any
+}
+
+// Hub is an in-process publish/subscribe broker. Publishing never blocks on a
+// subscriber.
+type Hub struct {
+	mu     sync.Mutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+// Subscription receives events on C until it is closed, either by Close or by
+// the hub when the subscriber falls too far behind.
+type Subscription struct {
+	C <-chan Event
+
+	hub    *Hub
+	ch     chan Event
+	topics []string
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{topics: make(map[string]map[*Subscription]struct{})}
+}
+
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, hub: h, ch: ch, topics: topics}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		subs, ok := h.topics[topic]
+		if !ok {
+			subs = make(map[*Subscription]struct{})
+			h.topics[topic] = subs
+		}
+		subs[sub] = struct{}{}
+	}
+	return sub
+}
+
+// Publish delivers event to every subscriber of any of the topics. A
+// subscriber listening on several of them receives it once.
+func (h *Hub) Publish(event Event, topics ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sent := make(map[*Subscription]bool)
+	for _, topic := range topics {
+		for sub := range h.topics[topic] {
+			if sent[sub] {
+				continue
+			}
+			sent[sub] = true
+			select {
+			case sub.ch <- event:
+			default:
+				log.Printf("Dropping slow subscriber on %s", topic)
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	for _, topic := range sub.topics {
+		delete(h.topics[topic], sub)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	close(sub.ch)
+}
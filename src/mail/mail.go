@@ -0,0 +1,59 @@
+// Package mail sends plain text email through the configured SMTP server.
+package mail
+
+import (
+	"01connecthub/src/config"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBadHeader means an address or subject held a line break, which would
+// let it add headers of its own.
+var ErrBadHeader = errors.New("mail: line break in header")
+
+type Sender struct {
+	cfg config.SMTP
+}
+
+func NewSender(cfg config.SMTP) *Sender {
+	return &Sender{cfg: cfg}
+}
+
+// Send emails body to the address to. The connection is upgraded to TLS
+// when the server offers it, which net/smtp requires before logging in.
+func (s *Sender) Send(to, subject, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return ErrBadHeader
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+	// The envelope wants the bare address of a From like "Hub <hub@example.com>".
+	from, err := netmail.ParseAddress(s.cfg.From)
+	if err != nil {
+		return fmt.Errorf("mail: %v", err)
+	}
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	if err := smtp.SendMail(addr, auth, from.Address, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("mail: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package markdown turns the Markdown users write in posts and comments into
+// HTML that is safe to put on a page.
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// converter handles CommonMark plus bare links and ~~strikethrough~~. Raw
+// HTML in the source is dropped rather than passed through, and single
+// newlines are kept as line breaks as they were before posts had Markdown.
+var converter = goldmark.New(
+	goldmark.WithExtensions(extension.Linkify, extension.Strikethrough),
+	goldmark.WithRendererOptions(goldhtml.WithHardWraps()),
+)
+
+// policy is every element and attribute rendered Markdown may keep. Anything
+// else that makes it out of the converter is stripped.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+		"ul", "ol", "li", "blockquote", "pre", "code", "em", "strong", "del")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	// Fenced code keeps its language tag for syntax highlighting.
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// textOnly strips every tag, for PlainText.
+var textOnly = bluemonday.StrictPolicy()
+
+// Render converts source to sanitized HTML. Source that cannot be converted
+// is shown as escaped plain text.
+func Render(source string) template.HTML {
+	var buf bytes.Buffer
+	if err := converter.Convert([]byte(source), &buf); err != nil {
+		return template.HTML("<p>" + template.HTMLEscapeString(source) + "</p>")
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes()))
+}
+
+// PlainText is source with the Markdown formatting taken out, for excerpts
+// shown where links and blocks don't fit, such as inside a clickable card.
+func PlainText(source string) string {
+	return strings.TrimSpace(html.UnescapeString(textOnly.Sanitize(string(Render(source)))))
+}
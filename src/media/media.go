@@ -0,0 +1,273 @@
+// Package media validates uploaded images and other files and keeps them on
+// disk, named by the SHA-256 of their contents so the same file is only stored
+// once and a stored file never changes.
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	// ErrUnsupported is returned for anything that isn't a JPEG, PNG, GIF or
+	// WebP image, whatever its name or declared type says.
+	ErrUnsupported = errors.New("media: not a JPEG, PNG, GIF or WebP image")
+	// ErrUnsupportedFile is returned by SaveFile for anything that isn't a
+	// PDF, a zip archive or UTF-8 text.
+	ErrUnsupportedFile = errors.New("media: not a PDF, zip archive or UTF-8 text file")
+	// ErrTooLarge is returned for images with more pixels than MaxPixels.
+	ErrTooLarge = errors.New("media: image dimensions are too large")
+)
+
+// MaxPixels caps width times height, so a small file can't claim a size that
+// would take gigabytes to decode.
+const MaxPixels = 40_000_000
+
+// formats maps the sniffed content type of each accepted format to the name
+// image.DecodeConfig reports for it.
+var formats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// Kinds of file, as told by Kind.
+const (
+	KindImage = "image"
+	KindPDF   = "pdf"
+	KindZip   = "zip"
+	KindText  = "text"
+)
+
+// fileKinds maps the sniffed content type of each accepted file that isn't
+// an image to its kind.
+var fileKinds = map[string]string{
+	"application/pdf":           KindPDF,
+	"application/zip":           KindZip,
+	"text/plain; charset=utf-8": KindText,
+}
+
+// Kind tells from its first bytes which kind of file data is, or returns ""
+// for one that isn't accepted at all. An image still has to pass Save's
+// checks.
+func Kind(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if _, ok := formats[contentType]; ok {
+		return KindImage
+	}
+	return fileKinds[contentType]
+}
+
+// Variant is a smaller copy made of every image wider or taller than Size.
+type Variant struct {
+	Name string
+	Size int
+}
+
+// Variants are the sizes pages ask for with ?size=. Images already within a
+// variant's size are served as they are instead.
+var Variants = []Variant{
+	{Name: "thumb", Size: 320},
+	{Name: "medium", Size: 1024},
+}
+
+// File is one stored file. Width and Height are zero for files that aren't
+// images.
+type File struct {
+	Hash        string
+	ContentType string
+	Width       int
+	Height      int
+	Size        int
+}
+
+// Upload is a stored image with the variants made from it, by name.
+type Upload struct {
+	File
+	Variants map[string]File
+}
+
+// Store keeps files under dir, spread over subdirectories by the first two
+// characters of their hash.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("media: %v", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// ValidHash reports whether hash could name a stored file, which keeps
+// anything else out of file paths.
+func ValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// Open opens the stored file with the given hash.
+func (s *Store) Open(hash string) (*os.File, error) {
+	if !ValidHash(hash) {
+		return nil, os.ErrNotExist
+	}
+	return os.Open(s.path(hash))
+}
+
+// Save checks that data is an image of an accepted format, strips its
+// metadata and stores it along with its variants.
+func (s *Store) Save(data []byte) (Upload, error) {
+	contentType := http.DetectContentType(data)
+	format, ok := formats[contentType]
+	if !ok {
+		return Upload{}, ErrUnsupported
+	}
+	data, err := stripMetadata(format, data)
+	if err != nil {
+		return Upload{}, ErrUnsupported
+	}
+	cfg, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decoded != format {
+		return Upload{}, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return Upload{}, ErrUnsupported
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return Upload{}, ErrTooLarge
+	}
+
+	upload := Upload{Variants: make(map[string]File)}
+	upload.File, err = s.write(data, contentType, cfg.Width, cfg.Height)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	var img image.Image
+	for _, v := range Variants {
+		if cfg.Width <= v.Size && cfg.Height <= v.Size {
+			continue
+		}
+		if img == nil {
+			// Animated GIFs become their first frame. An image that passed
+			// DecodeConfig but can't be decoded, such as an animated WebP,
+			// is kept without variants.
+			if img, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+				break
+			}
+		}
+		file, err := s.writeVariant(img, v.Size)
+		if err != nil {
+			return Upload{}, err
+		}
+		upload.Variants[v.Name] = file
+	}
+	return upload, nil
+}
+
+// SaveFile checks that data is a PDF, a zip archive or UTF-8 text and stores
+// it as it is. Images go through Save instead.
+func (s *Store) SaveFile(data []byte) (File, error) {
+	contentType := http.DetectContentType(data)
+	kind, ok := fileKinds[contentType]
+	if !ok || len(data) == 0 {
+		return File{}, ErrUnsupportedFile
+	}
+	// Sniffing only looks at the start of the file.
+	if kind == KindText && !utf8.Valid(data) {
+		return File{}, ErrUnsupportedFile
+	}
+	return s.write(data, contentType, 0, 0)
+}
+
+// writeVariant scales img to fit within size by size and stores it, as a
+// JPEG when it has no transparency and a PNG when it does.
+func (s *Store) writeVariant(img image.Image, size int) (File, error) {
+	b := img.Bounds()
+	w, h := size, b.Dy()*size/b.Dx()
+	if b.Dy() > b.Dx() {
+		w, h = b.Dx()*size/b.Dy(), size
+	}
+	w, h = max(w, 1), max(h, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	contentType := "image/jpeg"
+	if dst.Opaque() {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return File{}, fmt.Errorf("media: %v", err)
+		}
+	} else {
+		contentType = "image/png"
+		if err := png.Encode(&buf, dst); err != nil {
+			return File{}, fmt.Errorf("media: %v", err)
+		}
+	}
+	return s.write(buf.Bytes(), contentType, w, h)
+}
+
+// write stores data under its hash unless a file with that hash is already
+// there. It writes to a temporary file first so a file is never seen half
+// written.
+func (s *Store) write(data []byte, contentType string, width, height int) (File, error) {
+	sum := sha256.Sum256(data)
+	file := File{
+		Hash:        hex.EncodeToString(sum[:]),
+		ContentType: contentType,
+		Width:       width,
+		Height:      height,
+		Size:        len(data),
+	}
+	path := s.path(file.Hash)
+	if _, err := os.Stat(path); err == nil {
+		return file, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return File{}, fmt.Errorf("media: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return File{}, fmt.Errorf("media: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return File{}, fmt.Errorf("media: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return File{}, fmt.Errorf("media: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return File{}, fmt.Errorf("media: %v", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,170 @@
+package notification
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/events"
+	"fmt"
+)
+
+// Service turns things users do into notifications for the people they
+// affect. Handlers call it after their own write has succeeded; a failure here
+// should be logged but must not undo the action.
+type Service struct {
+	repo database.Repository
+	hub  *events.Hub
+}
+
+func NewService(repo database.Repository, hub *events.Hub) *Service {
+	return &Service{repo: repo, hub: hub}
+}
+
+// Pushed is what live subscribers receive for a new or updated notification.
+type Pushed struct {
+	ID        int    `json:"id"`
+	UserName  string `json:"user_name"`
+	UserImage string `json:"user_image"`
+	Others    int    `json:"others"`
+	Message   string `json:"message"`
+	Link      string `json:"link"`
+	Unread    int    `json:"unread"`
+}
+
+// Unread is pushed when the recipient's unread count changes without a new
+// notification, such as after marking notifications as read.
+type Unread struct {
+	Unread int `json:"unread"`
+}
+
+var messages = map[string]string{
+	database.NotificationPostLike:      "liked your post",
+	database.NotificationCommentLike:   "liked your comment",
+	database.NotificationComment:       "commented on your post",
+	database.NotificationReply:         "replied to your comment",
+	database.NotificationFollow:        "started following you",
+	database.NotificationFriendRequest: "sent you a friend request",
+	database.NotificationFriendAccept:  "accepted your friend request",
+	database.NotificationReport:        "reported a post",
+	database.NotificationSearchMatch:   "posted something matching your saved search",
+}
+
+func (s *Service) PostLiked(actorID, postID int) error {
+	authorID, err := s.repo.GetPostAuthorID(postID)
+	if err != nil {
+		return fmt.Errorf("PostLiked: %v", err)
+	}
+	return s.notify(database.NotificationEvent{RecipientID: authorID, ActorID: actorID, Type: database.NotificationPostLike, PostID: postID})
+}
+
+func (s *Service) CommentLiked(actorID, commentID int) error {
+	authorID, postID, err := s.repo.GetCommentAuthor(commentID)
+	if err != nil {
+		return fmt.Errorf("CommentLiked: %v", err)
+	}
+	return s.notify(database.NotificationEvent{RecipientID: authorID, ActorID: actorID, Type: database.NotificationCommentLike, PostID: postID, CommentID: commentID})
+}
+
+// Commented notifies the post author. Comments are grouped per post, so the
+// event is keyed on the post alone.
+func (s *Service) Commented(actorID, postID int) error {
+	authorID, err := s.repo.GetPostAuthorID(postID)
+	if err != nil {
+		return fmt.Errorf("Commented: %v", err)
+	}
+	return s.notify(database.NotificationEvent{RecipientID: authorID, ActorID: actorID, Type: database.NotificationComment, PostID: postID})
+}
+
+// Replied notifies the author of the comment parentID. Replies are grouped
+// per parent comment.
+func (s *Service) Replied(actorID, parentID int) error {
+	authorID, postID, err := s.repo.GetCommentAuthor(parentID)
+	if err != nil {
+		return fmt.Errorf("Replied: %v", err)
+	}
+	return s.notify(database.NotificationEvent{RecipientID: authorID, ActorID: actorID, Type: database.NotificationReply, PostID: postID, CommentID: parentID})
+}
+
+func (s *Service) Followed(actorID, userID int) error {
+	return s.notify(database.NotificationEvent{RecipientID: userID, ActorID: actorID, Type: database.NotificationFollow})
+}
+
+func (s *Service) FriendRequested(actorID, userID int) error {
+	return s.notify(database.NotificationEvent{RecipientID: userID, ActorID: actorID, Type: database.NotificationFriendRequest})
+}
+
+func (s *Service) FriendAccepted(actorID, userID int) error {
+	return s.notify(database.NotificationEvent{RecipientID: userID, ActorID: actorID, Type: database.NotificationFriendAccept})
+}
+
+// PostReported tells every admin and moderator. The author is not told who
+// reported them.
+func (s *Service) PostReported(actorID, postID int) error {
+	staff, err := s.repo.GetStaffUserIDs()
+	if err != nil {
+		return fmt.Errorf("PostReported: %v", err)
+	}
+	for _, staffID := range staff {
+		err := s.notify(database.NotificationEvent{RecipientID: staffID, ActorID: actorID, Type: database.NotificationReport, PostID: postID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchMatched tells the owner of a saved search about a new post or comment
+// that matches it.
+func (s *Service) SearchMatched(match database.SearchMatch) error {
+	return s.notify(database.NotificationEvent{
+		RecipientID: match.UserID,
+		ActorID:     match.AuthorID,
+		Type:        database.NotificationSearchMatch,
+		PostID:      match.PostID,
+		CommentID:   match.CommentID,
+		Message:     fmt.Sprintf("%s %q", messages[database.NotificationSearchMatch], match.Query),
+	})
+}
+
+func (s *Service) notify(event database.NotificationEvent) error {
+	if event.RecipientID == event.ActorID {
+		return nil
+	}
+	if event.Message == "" {
+		event.Message = messages[event.Type]
+	}
+	notificationID, err := s.repo.AddNotification(event)
+	if err != nil {
+		return err
+	}
+	return s.push(event.RecipientID, notificationID)
+}
+
+func (s *Service) push(userID, notificationID int) error {
+	n, err := s.repo.GetNotification(userID, notificationID)
+	if err != nil {
+		return fmt.Errorf("push: %v", err)
+	}
+	unread, err := s.repo.CountUnreadNotifications(userID)
+	if err != nil {
+		return fmt.Errorf("push: %v", err)
+	}
+	s.hub.Publish(events.Event{Name: "notification", Data: Pushed{
+		ID:        n.ID,
+		UserName:  n.UserName,
+		UserImage: n.UserImage,
+		Others:    n.Others,
+		Message:   n.Message,
+		Link:      n.Link(),
+		Unread:    unread,
+	}}, events.UserTopic(userID))
+	return nil
+}
+
+// ReadChanged tells userID's open pages their unread count has changed.
+func (s *Service) ReadChanged(userID int) error {
+	unread, err := s.repo.CountUnreadNotifications(userID)
+	if err != nil {
+		return fmt.Errorf("ReadChanged: %v", err)
+	}
+	s.hub.Publish(events.Event{Name: "unread", Data: Unread{Unread: unread}}, events.UserTopic(userID))
+	return nil
+}
@@ -0,0 +1,81 @@
+package permission
+
+// Permission names match the rows of the permissions table. Which roles hold
+// which permissions lives in role_permissions.
+type Permission string
+
+const (
+	PostDeleteOwn    Permission = "post.delete.own"
+	PostDeleteAny    Permission = "post.delete.any"
+	PostEditOwn      Permission = "post.edit.own"
+	PostEditAny      Permission = "post.edit.any"
+	PostReport       Permission = "post.report"
+	CommentDeleteOwn Permission = "comment.delete.own"
+	CommentDeleteAny Permission = "comment.delete.any"
+	CommentEditOwn   Permission = "comment.edit.own"
+	CommentEditAny   Permission = "comment.edit.any"
+	CommentReport    Permission = "comment.report"
+	ReportResolve    Permission = "report.resolve"
+	RevisionView     Permission = "revision.view"
+	TrashManage      Permission = "trash.manage"
+	CategoryManage   Permission = "category.manage"
+	ReactionManage   Permission = "reaction.manage"
+	UserManage       Permission = "user.manage"
+	UserRoleAssign   Permission = "user.role.assign"
+	ModerationPanel  Permission = "moderation.panel"
+	AdminPanel       Permission = "admin.panel"
+)
+
+// Moderation is every permission that acts on other people's content or
+// accounts. API tokens without the moderate scope don't carry these.
+var Moderation = []Permission{
+	PostDeleteAny, PostEditAny, CommentDeleteAny, CommentEditAny, ReportResolve,
+	RevisionView, TrashManage, CategoryManage, ReactionManage, UserManage, UserRoleAssign,
+	ModerationPanel, AdminPanel,
+}
+
+// Action is a permission prefix that comes in ".own" and ".any" variants.
+type Action string
+
+const (
+	PostDelete    Action = "post.delete"
+	PostEdit      Action = "post.edit"
+	CommentDelete Action = "comment.delete"
+	CommentEdit   Action = "comment.edit"
+)
+
+// Set is the permissions one user holds. The zero value holds none.
+type Set map[Permission]bool
+
+func NewSet(names []string) Set {
+	set := make(Set, len(names))
+	for _, name := range names {
+		set[Permission(name)] = true
+	}
+	return set
+}
+
+func (s Set) Has(p Permission) bool {
+	return s[p]
+}
+
+// HasOn reports whether userID may perform action on something owned by
+// ownerID: always with the ".any" permission, and with ".own" when it is theirs.
+func (s Set) HasOn(action Action, userID, ownerID int) bool {
+	if s[Permission(action+".any")] {
+		return true
+	}
+	return userID == ownerID && s[Permission(action+".own")]
+}
+
+// Without returns a copy of s lacking every permission in ps.
+func (s Set) Without(ps ...Permission) Set {
+	out := make(Set, len(s))
+	for p, ok := range s {
+		out[p] = ok
+	}
+	for _, p := range ps {
+		delete(out, p)
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package security
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+)
+
+const oauthStateCookie = "oauth_state"
+
+// oauthStateLifetime is how long a user has to finish signing in with a
+// provider.
+const oauthStateLifetime = 10 * time.Minute
+
+// NewOAuthState starts an OAuth sign in from this browser. It returns a random
+// state to send to the provider and keeps it in a short-lived cookie, so that
+// the callback can tell the sign in was started here and not by another site.
+func NewOAuthState(w http.ResponseWriter) (string, error) {
+	state, err := GenerateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(oauthStateLifetime / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return state, nil
+}
+
+// CheckOAuthState reports whether the state a provider sent back to the
+// callback r is the one NewOAuthState gave this browser. The cookie is cleared
+// either way, so each state is used once.
+func CheckOAuthState(w http.ResponseWriter, r *http.Request) bool {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.FormValue("state"))) == 1
+}
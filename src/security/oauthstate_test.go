@@ -0,0 +1,45 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOAuthState(t *testing.T) {
+	login := httptest.NewRecorder()
+	state, err := NewOAuthState(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := login.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("state cookie = %+v, want one HttpOnly SameSite=Lax cookie", cookies)
+	}
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		state  string
+		want   bool
+	}{
+		{"matching state", cookies[0], state, true},
+		{"other state", cookies[0], "randomstring", false},
+		{"no state", cookies[0], "", false},
+		{"no cookie", nil, state, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/callback?code=x&state="+tt.state, nil)
+		if tt.cookie != nil {
+			r.AddCookie(tt.cookie)
+		}
+		w := httptest.NewRecorder()
+		if got := CheckOAuthState(w, r); got != tt.want {
+			t.Errorf("%s: CheckOAuthState = %v, want %v", tt.name, got, tt.want)
+		}
+		cleared := w.Result().Cookies()
+		if len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+			t.Errorf("%s: callback cookies = %+v, want the state cookie cleared", tt.name, cleared)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package security
+
+import (
+	"01connecthub/database"
+	"net"
+	"net/http"
+	"time"
+)
+
+const SessionCookie = "session_token"
+
+// SessionLifetime is how long a session lasts without activity. Every request
+// made with it pushes the expiry this far ahead again.
+const SessionLifetime = 1 * time.Hour
+
+// NewSession starts a session for the device making r. The caller fills in
+// UserID, stores it, and then calls SetSessionCookie.
+func NewSession(r *http.Request) (database.UserSession, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return database.UserSession{}, err
+	}
+	csrfToken, err := GenerateCSRFToken()
+	if err != nil {
+		return database.UserSession{}, err
+	}
+	now := time.Now().UTC()
+	return database.UserSession{
+		Token:     token.String(),
+		CSRFToken: csrfToken,
+		UserAgent: r.UserAgent(),
+		IP:        ClientIP(r),
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(SessionLifetime),
+	}, nil
+}
+
+func SetSessionCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Now().Add(-time.Hour),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClientIP is the address of the peer connected to us. Forwarding headers are
+// ignored since nothing in front of the server vouches for them.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
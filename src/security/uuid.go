@@ -1,6 +1,9 @@
 package security
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+
 	"github.com/google/uuid"
 )
 
@@ -8,3 +11,22 @@ func GenerateToken() (uuid.UUID, error) {
 	token, err := uuid.NewRandom()
 	return token, err
 }
+
+// GenerateCSRFToken returns 32 random bytes, hex encoded.
+func GenerateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateShareToken returns 16 random bytes, hex encoded, for links that
+// open something only its owner could otherwise see.
+func GenerateShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -2,15 +2,33 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/permission"
 	"database/sql"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
-	"time"
+	"strings"
+	"unicode/utf8"
 )
 
-func AdminPage(w http.ResponseWriter, r *http.Request) {
+// adminActions is the permission each admin dashboard form needs. Role
+// changes are checked separately since their field names carry the user ID.
+var adminActions = map[string]permission.Permission{
+	"delete_user":     permission.UserManage,
+	"delete_post":     permission.PostDeleteAny,
+	"delete_category": permission.CategoryManage,
+	"add_category":    permission.CategoryManage,
+	"set_max_length":  permission.CategoryManage,
+	"save_reaction":   permission.ReactionManage,
+	"delete_reaction": permission.ReactionManage,
+	"resolve_report":  permission.ReportResolve,
+	"delete_comment":  permission.CommentDeleteAny,
+	"revoke_token":    permission.UserManage,
+}
+
+func (app *App) AdminPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/admin" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -18,62 +36,11 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
-	var hasSession bool
-	var userID int
-	var userName string
-	seshCok, err := r.Cookie("session_token")
-	if err != nil {
-		fmt.Println("No cookie found, treated as guest")
-	} else if seshCok.Value == "" {
-		hasSession = false
-	} else {
-		hasSession = true
-
-		seshVal := seshCok.Value
-
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
-		if err == sql.ErrNoRows {
-			http.SetCookie(w, &http.Cookie{
-				Name:     "session_token",
-				Value:    "",
-				Expires:  time.Now().Add(-time.Hour),
-				HttpOnly: true,
-			})
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-		} else if err != nil {
-			log.Println("Error fetching userid ID from user table:", err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
-		} else {
-			log.Println("User is logged in:", userName)
-		}
-	}
-
-	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
-	if err == sql.ErrNoRows {
-		log.Println("No user found with the given user ID:", userID)
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
-	} else if err != nil || roleID != 1 {
-		log.Println("User is not an admin or error occurred")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
-	}
+	user, hasSession := CurrentUserFrom(r)
+	userID, userName := user.ID, user.Username
 
 	if hasSession {
-		var avatar sql.NullString
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		_, roleID, err := app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -86,34 +53,29 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var roleName string
-		if roleID == 1 {
-			roleName = "Admin"
-		} else if roleID == 2 {
-			roleName = "Moderator"
-		} else {
-			roleName = "User"
+		roleName, err := app.Repo.GetRoleNameByID(roleID)
+		if err != nil {
+			log.Println("Failed to fetch role name:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
 		}
 
 		switch r.Method {
 		case "GET":
-			users, err := database.GetAllUsers(db)
+			users, nextUsers, err := app.Repo.GetAllUsers(pageFrom(r, "users_after"))
 			if err != nil {
-				log.Println("Failed to fetch users")
-				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &errData)
+				listError(w, r, "Failed to fetch users:", err)
 				return
 			}
 
-			posts, err := database.GetAllPosts(db)
+			posts, nextPosts, err := app.Repo.GetAllPosts(pageFrom(r, "posts_after"))
 			if err != nil {
-				log.Println("Failed to fetch posts")
-				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &errData)
+				listError(w, r, "Failed to fetch posts:", err)
 				return
 			}
 
-			categories, err := database.GetAllCategories(db)
+			categories, err := app.Repo.GetAllCategories()
 			if err != nil {
 				log.Println("Failed to fetch categories")
 				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -121,7 +83,7 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			reports, err := database.GetAllReports(db)
+			reports, err := app.Repo.GetAllReports()
 			if err != nil {
 				log.Println("Failed to fetch reports")
 				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -129,7 +91,7 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			totalUsers, err := database.GetTotalUsersCount(db)
+			totalUsers, err := app.Repo.GetTotalUsersCount()
 			if err != nil {
 				log.Println("Failed to fetch total users count")
 				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -137,7 +99,7 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			totalPostsc, err := database.GetTotalPostsCount(db)
+			totalPostsc, err := app.Repo.GetTotalPostsCount()
 			if err != nil {
 				log.Println("Failed to fetch total posts count")
 				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -145,7 +107,7 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			totalCategories, err := database.GetTotalCategoriesCount(db)
+			totalCategories, err := app.Repo.GetTotalCategoriesCount()
 			if err != nil {
 				log.Println("Failed to fetch total categories count")
 				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -153,7 +115,7 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			notifications, err := database.GetLastNotifications(db, userID)
+			notifications, err := app.Repo.GetLastNotifications(userID)
 			if err != nil {
 				log.Println("Failed to fetch notifications:", err)
 				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -161,12 +123,20 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			unreadCount, err := app.Repo.CountUnreadNotifications(userID)
+			if err != nil {
+				log.Println("Failed to count unread notifications:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
 			var userLogs []database.UserLog
-			var userSessions []database.UserSession
+			var userSessions []SessionView
 			if userID := r.URL.Query().Get("user_logs"); userID != "" {
 				userIDInt, err := strconv.Atoi(userID)
 				if err == nil {
-					userLogs, err = database.GetUserLogs(db, userIDInt)
+					userLogs, err = app.Repo.GetUserLogs(userIDInt)
 					if err != nil {
 						log.Println("Failed to fetch user logs")
 						errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -179,18 +149,36 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 			if userID := r.URL.Query().Get("user_sessions"); userID != "" {
 				userIDInt, err := strconv.Atoi(userID)
 				if err == nil {
-					userSessions, err = database.GetUserSessions(db, userIDInt)
+					sessions, err := app.Repo.GetUserSessions(userIDInt)
 					if err != nil {
 						log.Println("Failed to fetch user sessions:", err)
 						errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 						ErrHandler(w, r, &errData)
 						return
 					}
+					current, _ := CurrentUserFrom(r)
+					userSessions = sessionViews(sessions, current.SessionID)
 				}
 			}
 
+			reactionTypes, err := app.Repo.GetReactionTypes()
+			if err != nil {
+				log.Println("Failed to fetch reaction types:", err)
+				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &errData)
+				return
+			}
+
+			tokens, err := app.Repo.GetAllAPITokens()
+			if err != nil {
+				log.Println("Failed to fetch API tokens:", err)
+				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &errData)
+				return
+			}
+
 			var totalLikes, totalPosts int
-			err = db.QueryRow("SELECT COUNT(*) FROM likes WHERE user_userID = ?", userID).Scan(&totalLikes)
+			totalLikes, err = app.Repo.GetTotalLikes(userID)
 			if err != nil {
 				log.Println("Failed to fetch total likes:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -198,7 +186,7 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			err = db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userID = ?", userID).Scan(&totalPosts)
+			totalPosts, err = app.Repo.GetTotalPosts(userID)
 			if err != nil {
 				log.Println("Failed to fetch total posts:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -206,7 +194,7 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			user, err := database.GetUserByID(db, userID)
+			user, err := app.Repo.GetUserByID(userID)
 			if err != nil {
 				log.Println("Failed to fetch user data")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -224,14 +212,22 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				RoleName:        roleName,
 				Users:           users,
 				Posts:           posts,
+				NextUsersPage:   nextPageURL(r, "users_after", nextUsers),
+				NextPostsPage:   nextPageURL(r, "posts_after", nextPosts),
 				Categories:      categories,
+				MaxLength:       app.Config.MaxPostLength,
 				Reports:         reports,
 				TotalUsers:      totalUsers,
 				TotalPostsc:     totalPostsc,
 				TotalCategories: totalCategories,
 				UserLogs:        userLogs,
 				UserSessions:    userSessions,
+				APITokens:       tokenViews(tokens),
+				ReactionTypes:   reactionTypes,
 				Notifications:   notifications,
+				UnreadCount:     unreadCount,
+				CSRFToken:       csrfToken(r),
+				Perms:           userPermissions(r),
 				TotalLikes:      totalLikes,
 				SelectedTab:     "admin",
 				TotalPosts:      totalPosts,
@@ -247,9 +243,18 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 			}
 		case "POST":
 			r.ParseForm()
+			if !requireFormPermissions(w, r, adminActions) {
+				return
+			}
 			if r.FormValue("delete_user") != "" {
-				userID := r.FormValue("delete_user")
-				_, err := db.Exec("DELETE FROM user WHERE id = ?", userID)
+				deleteID, _ := strconv.Atoi(r.FormValue("delete_user"))
+				if deleteID == userID {
+					log.Println("Admin tried to delete their own account")
+					err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				err := app.Repo.DeleteUser(deleteID, userID)
 				if err != nil {
 					log.Println("Failed to delete user:", err)
 					errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -257,8 +262,8 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 			} else if r.FormValue("delete_post") != "" {
-				postID := r.FormValue("delete_post")
-				_, err := db.Exec("DELETE FROM post WHERE postid = ?", postID)
+				postID, _ := strconv.Atoi(r.FormValue("delete_post"))
+				err := app.Repo.DeletePost(postID, userID)
 				if err != nil {
 					log.Println("Failed to delete post")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -266,8 +271,8 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 			} else if r.FormValue("delete_category") != "" {
-				categoryID := r.FormValue("delete_category")
-				_, err := db.Exec("DELETE FROM categories WHERE idcategories = ?", categoryID)
+				categoryID, _ := strconv.Atoi(r.FormValue("delete_category"))
+				err := app.Repo.DeleteCategory(categoryID)
 				if err != nil {
 					log.Println("Failed to delete category")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -276,16 +281,68 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 				}
 			} else if r.FormValue("add_category") != "" {
 				categoryName := r.FormValue("new_category")
-				_, err := db.Exec("INSERT INTO categories (name) VALUES (?)", categoryName)
+				err := app.Repo.InsertCategory(categoryName)
 				if err != nil {
 					log.Println("Failed to add category")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 					ErrHandler(w, r, &err)
 					return
 				}
+			} else if r.FormValue("set_max_length") != "" {
+				// Each row has its own max_length_<id> field; the button
+				// names the row. An empty field goes back to the default.
+				categoryID, _ := strconv.Atoi(r.FormValue("set_max_length"))
+				maxLength := 0
+				if v := strings.TrimSpace(r.FormValue("max_length_" + strconv.Itoa(categoryID))); v != "" {
+					n, err := strconv.Atoi(v)
+					if err != nil || n < 1 {
+						log.Println("Invalid category length limit:", v)
+						err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+						ErrHandler(w, r, &err)
+						return
+					}
+					maxLength = n
+				}
+				err := app.Repo.SetCategoryMaxLength(categoryID, maxLength)
+				if err == sql.ErrNoRows {
+					err := ErrorPageData{Code: "404", ErrorMsg: "CATEGORY NOT FOUND"}
+					ErrHandler(w, r, &err)
+					return
+				} else if err != nil {
+					log.Println("Failed to set category length limit:", err)
+					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+					ErrHandler(w, r, &err)
+					return
+				}
+			} else if r.FormValue("save_reaction") != "" {
+				reaction, ok := reactionTypeFromForm(r)
+				if !ok {
+					err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				if err := app.Repo.SaveReactionType(reaction); err != nil {
+					log.Println("Failed to save reaction type:", err)
+					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+					ErrHandler(w, r, &err)
+					return
+				}
+			} else if r.FormValue("delete_reaction") != "" {
+				err := app.Repo.DeleteReactionType(r.FormValue("delete_reaction"))
+				if errors.Is(err, database.ErrBuiltinReaction) || errors.Is(err, sql.ErrNoRows) {
+					log.Println("Refused to delete reaction type:", err)
+					err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+					ErrHandler(w, r, &err)
+					return
+				} else if err != nil {
+					log.Println("Failed to delete reaction type:", err)
+					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+					ErrHandler(w, r, &err)
+					return
+				}
 			} else if r.FormValue("resolve_report") != "" {
-				reportID := r.FormValue("resolve_report")
-				_, err := db.Exec("DELETE FROM reports WHERE id = ?", reportID)
+				reportID, _ := strconv.Atoi(r.FormValue("resolve_report"))
+				err := app.Repo.DeleteReport(reportID)
 				if err != nil {
 					log.Println("Failed to resolve report")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -293,20 +350,35 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 			} else if r.FormValue("delete_comment") != "" {
-				commentID := r.FormValue("delete_comment")
-				_, err := db.Exec("DELETE FROM comment WHERE commentid = ?", commentID)
+				commentID, _ := strconv.Atoi(r.FormValue("delete_comment"))
+				err := app.Repo.DeleteComment(commentID, userID)
 				if err != nil {
 					log.Println("Failed to delete comment")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 					ErrHandler(w, r, &err)
 					return
 				}
+			} else if r.FormValue("revoke_token") != "" {
+				tokenID, _ := strconv.Atoi(r.FormValue("revoke_token"))
+				err := app.Repo.DeleteAPIToken(tokenID)
+				if err != nil {
+					log.Println("Failed to revoke API token:", err)
+					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+					ErrHandler(w, r, &err)
+					return
+				}
 			} else {
+				if user, _ := CurrentUserFrom(r); !user.Can(permission.UserRoleAssign) {
+					log.Printf("User %d denied role change: missing permission %s", user.ID, permission.UserRoleAssign)
+					err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+					ErrHandler(w, r, &err)
+					return
+				}
 				for key, values := range r.Form {
-					if len(values) > 0 && key[:5] == "role_" {
-						userID := key[5:]
-						roleID := values[0]
-						_, err := db.Exec("UPDATE user SET role_id = ? WHERE id = ?", roleID, userID)
+					if len(values) > 0 && strings.HasPrefix(key, "role_") {
+						userID, _ := strconv.Atoi(key[5:])
+						roleID, _ := strconv.Atoi(values[0])
+						err := app.Repo.UpdateUserRole(userID, roleID)
 						if err != nil {
 							log.Println("Failed to update user role:", err)
 							err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -326,3 +398,29 @@ func AdminPage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+var reactionNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// reactionTypeFromForm reads the add or update reaction form. Names are
+// short lowercase identifiers since they end up in URLs and JSON keys.
+func reactionTypeFromForm(r *http.Request) (database.ReactionType, bool) {
+	reaction := database.ReactionType{
+		Name:  strings.TrimSpace(r.FormValue("reaction_name")),
+		Emoji: strings.TrimSpace(r.FormValue("reaction_emoji")),
+		Label: strings.TrimSpace(r.FormValue("reaction_label")),
+	}
+	reaction.Position, _ = strconv.Atoi(r.FormValue("reaction_position"))
+	if !reactionNamePattern.MatchString(reaction.Name) {
+		log.Printf("Invalid reaction name %q", reaction.Name)
+		return reaction, false
+	}
+	if reaction.Emoji == "" || utf8.RuneCountInString(reaction.Emoji) > 8 {
+		log.Printf("Invalid reaction emoji %q", reaction.Emoji)
+		return reaction, false
+	}
+	if reaction.Label == "" || utf8.RuneCountInString(reaction.Label) > 32 {
+		log.Printf("Invalid reaction label %q", reaction.Label)
+		return reaction, false
+	}
+	return reaction, true
+}
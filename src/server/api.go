@@ -0,0 +1,198 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/permission"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIPrefix is where version 1 of the JSON API is mounted.
+const APIPrefix = "/api/v1/"
+
+// maxAPIBody caps request bodies. It leaves room for base64 encoded
+// attachments of maxUploadSize altogether.
+const maxAPIBody = maxUploadSize*4/3 + 1<<20
+
+// apiEnvelope wraps every API response body. Successful responses carry data
+// and failed ones carry error, so clients can always tell the two apart:
+//
+//	{"data": {"id": 7, ...}}
+//	{"error": {"status": 404, "code": "not_found", "message": "post not found"}}
+//
+// Lists come a page at a time. While there are more, next_cursor is set and
+// passing it back as ?after= fetches the following page:
+//
+//	{"data": [...], "next_cursor": "eyJ0Ijo..."}
+type apiEnvelope struct {
+	Data       any       `json:"data,omitempty"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	Error      *apiError `json:"error,omitempty"`
+}
+
+type apiError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIHandler serves the JSON API. Every endpoint needs a signed in user. The
+// session cookie works as it does for pages, with the CSRF token sent in the
+// X-CSRF-Token header on anything that changes state; scripts send a personal
+// access token in an "Authorization: Bearer" header instead.
+func (app *App) APIHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v1/posts", app.apiPosts)
+	mux.HandleFunc("/api/v1/posts/{id}", app.apiPost)
+	mux.HandleFunc("/api/v1/posts/{id}/comments", app.apiPostComments)
+	mux.HandleFunc("/api/v1/posts/{id}/reactions", app.apiPostReactions)
+	mux.HandleFunc("/api/v1/posts/{id}/reports", app.apiPostReports)
+	mux.HandleFunc("/api/v1/posts/{id}/revisions", app.apiPostRevisions)
+	mux.HandleFunc("/api/v1/preview", app.apiPreview)
+	mux.HandleFunc("/api/v1/comments/{id}", app.apiComment)
+	mux.HandleFunc("/api/v1/comments/{id}/reactions", app.apiCommentReactions)
+	mux.HandleFunc("/api/v1/comments/{id}/revisions", app.apiCommentRevisions)
+	mux.HandleFunc("/api/v1/categories", app.apiCategories)
+	mux.HandleFunc("/api/v1/categories/{id}", app.apiCategory)
+	mux.HandleFunc("/api/v1/users", app.apiUsers)
+	mux.HandleFunc("/api/v1/users/me", app.apiMe)
+	mux.HandleFunc("/api/v1/users/{id}", app.apiUser)
+	mux.HandleFunc("/api/v1/users/{id}/role", app.apiUserRole)
+	mux.HandleFunc("/api/v1/users/{id}/posts", app.apiUserPosts)
+	mux.HandleFunc("/api/v1/users/{id}/followers", app.apiFollowers)
+	mux.HandleFunc("/api/v1/users/{id}/following", app.apiFollowing)
+	mux.HandleFunc("/api/v1/users/{id}/follow", app.apiFollow)
+	mux.HandleFunc("/api/v1/notifications", app.apiNotifications)
+	mux.HandleFunc("/api/v1/notifications/read-all", app.apiReadAllNotifications)
+	mux.HandleFunc("/api/v1/notifications/{id}/read", app.apiReadNotification)
+	mux.HandleFunc("/api/v1/reports", app.apiReports)
+	mux.HandleFunc("/api/v1/reports/{id}", app.apiReport)
+	mux.HandleFunc("/api/v1/reaction-types", app.apiReactionTypes)
+	mux.HandleFunc("/api/v1/trash", app.apiTrash)
+	mux.HandleFunc("/api/v1/trash/{kind}/{id}/restore", app.apiRestore)
+	mux.HandleFunc(APIPrefix, func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "no such endpoint")
+	})
+
+	return app.apiAuth(mux)
+}
+
+// apiAuth is AuthMiddleware for the API: it answers 401 instead of
+// redirecting to the login page.
+func (app *App) apiAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.authenticate(w, r)
+		if err == errNotSignedIn {
+			writeAPIError(w, http.StatusUnauthorized, "sign in required")
+			return
+		} else if err == errTokenScope {
+			writeAPIError(w, http.StatusForbidden, err.Error())
+			return
+		} else if err != nil {
+			apiInternalError(w, "Error authenticating API request:", err)
+			return
+		}
+		next.ServeHTTP(w, withCurrentUser(r, user))
+	})
+}
+
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, APIPrefix)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(apiEnvelope{Data: data}); err != nil {
+		log.Println("Error writing API response:", err)
+	}
+}
+
+// writeJSONPage sends one page of a list along with the cursor of the next.
+func writeJSONPage(w http.ResponseWriter, data any, next string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(apiEnvelope{Data: data, NextCursor: next}); err != nil {
+		log.Println("Error writing API response:", err)
+	}
+}
+
+// writeAPIError sends the error envelope. The code is the status text in
+// snake case, such as "not_found", so clients can switch on it.
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	code := strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(apiEnvelope{Error: &apiError{Status: status, Code: code, Message: message}})
+	if err != nil {
+		log.Println("Error writing API response:", err)
+	}
+}
+
+func apiInternalError(w http.ResponseWriter, context string, err error) {
+	log.Println(context, err)
+	writeAPIError(w, http.StatusInternalServerError, "internal server error")
+}
+
+// apiListError answers a failed page fetch, blaming the client for a bad
+// cursor.
+func apiListError(w http.ResponseWriter, context string, err error) {
+	if errors.Is(err, database.ErrBadCursor) {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	apiInternalError(w, context, err)
+}
+
+func apiMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// apiPathID parses the {id} wildcard. When it returns false the error response
+// has already been written.
+func apiPathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid id "+strconv.Quote(r.PathValue("id")))
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeJSON reads the request body into v. When it returns false the error
+// response has already been written.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody)).Decode(v)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return false
+	} else if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// apiUserFrom returns the user apiAuth stored in the request.
+func apiUserFrom(r *http.Request) CurrentUser {
+	user, _ := CurrentUserFrom(r)
+	return user
+}
+
+// apiRequire checks that user holds perm. When it returns false the error
+// response has already been written.
+func apiRequire(w http.ResponseWriter, r *http.Request, user CurrentUser, perm permission.Permission) bool {
+	if user.Can(perm) {
+		return true
+	}
+	log.Printf("User %d denied %s %s: missing permission %s", user.ID, r.Method, r.URL.Path, perm)
+	writeAPIError(w, http.StatusForbidden, "missing permission "+string(perm))
+	return false
+}
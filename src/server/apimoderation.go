@@ -0,0 +1,208 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/permission"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type apiNewCategory struct {
+	Name string `json:"name"`
+}
+
+// apiCategoryEdit sets how long posts in a category may be. Zero or null goes
+// back to the configured default.
+type apiCategoryEdit struct {
+	MaxLength *int `json:"max_length"`
+}
+
+func (app *App) apiCategories(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		categories, err := app.Repo.GetAllCategories()
+		if err != nil {
+			apiInternalError(w, "Error fetching categories:", err)
+			return
+		}
+		if categories == nil {
+			categories = []database.Category{}
+		}
+		writeJSON(w, http.StatusOK, categories)
+	case "POST":
+		if !apiRequire(w, r, apiUserFrom(r), permission.CategoryManage) {
+			return
+		}
+		var req apiNewCategory
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			writeAPIError(w, http.StatusUnprocessableEntity, "name is required")
+			return
+		}
+		if err := app.Repo.InsertCategory(req.Name); err != nil {
+			apiInternalError(w, "Error adding category:", err)
+			return
+		}
+
+		categories, err := app.Repo.GetAllCategories()
+		if err != nil {
+			apiInternalError(w, "Error fetching categories:", err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, categories)
+	default:
+		apiMethodNotAllowed(w, "GET", "POST")
+	}
+}
+
+func (app *App) apiCategory(w http.ResponseWriter, r *http.Request) {
+	categoryID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "PATCH" && r.Method != "DELETE" {
+		apiMethodNotAllowed(w, "PATCH", "DELETE")
+		return
+	}
+	if !apiRequire(w, r, apiUserFrom(r), permission.CategoryManage) {
+		return
+	}
+
+	if r.Method == "DELETE" {
+		if err := app.Repo.DeleteCategory(categoryID); err != nil {
+			apiInternalError(w, "Error deleting category:", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var req apiCategoryEdit
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	maxLength := 0
+	if req.MaxLength != nil {
+		maxLength = *req.MaxLength
+	}
+	if maxLength < 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "max_length must not be negative")
+		return
+	}
+	err := app.Repo.SetCategoryMaxLength(categoryID, maxLength)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "category not found")
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error setting category length limit:", err)
+		return
+	}
+
+	categories, err := app.Repo.GetAllCategories()
+	if err != nil {
+		apiInternalError(w, "Error fetching categories:", err)
+		return
+	}
+	for _, c := range categories {
+		if c.ID == categoryID {
+			writeJSON(w, http.StatusOK, c)
+			return
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "category not found")
+}
+
+func (app *App) apiReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+	if !apiRequire(w, r, apiUserFrom(r), permission.ReportResolve) {
+		return
+	}
+
+	reports, err := app.Repo.GetAllReports()
+	if err != nil {
+		apiInternalError(w, "Error fetching reports:", err)
+		return
+	}
+	views := make([]apiReportView, 0, len(reports))
+	for _, report := range reports {
+		views = append(views, newAPIReport(report))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// apiReport resolves a report by deleting it.
+func (app *App) apiReport(w http.ResponseWriter, r *http.Request) {
+	reportID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "DELETE" {
+		apiMethodNotAllowed(w, "DELETE")
+		return
+	}
+	if !apiRequire(w, r, apiUserFrom(r), permission.ReportResolve) {
+		return
+	}
+
+	if err := app.Repo.DeleteReport(reportID); err != nil {
+		apiInternalError(w, "Error resolving report:", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiTrash lists deleted posts, comments and users, most recently deleted
+// first.
+func (app *App) apiTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+	if !apiRequire(w, r, apiUserFrom(r), permission.TrashManage) {
+		return
+	}
+	items, next, err := app.Repo.GetTrash(pageFrom(r, "after"))
+	if err != nil {
+		apiListError(w, "Error fetching trash:", err)
+		return
+	}
+	writeJSONPage(w, newAPITrash(items), next)
+}
+
+// apiRestore takes a post, comment or user back out of the trash.
+func (app *App) apiRestore(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "POST" {
+		apiMethodNotAllowed(w, "POST")
+		return
+	}
+	if !apiRequire(w, r, apiUserFrom(r), permission.TrashManage) {
+		return
+	}
+	err := app.restore(r.PathValue("kind"), id)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "not in the trash")
+		return
+	} else if errors.Is(err, errUnknownTrashKind) {
+		writeAPIError(w, http.StatusNotFound, "no such endpoint")
+		return
+	} else if errors.Is(err, database.ErrAuthorDeleted) {
+		writeAPIError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error restoring from trash:", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
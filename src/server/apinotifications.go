@@ -0,0 +1,73 @@
+package server
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+)
+
+func (app *App) apiNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+
+	notifications, next, err := app.Repo.GetNotifications(apiUserFrom(r).ID, pageFrom(r, "after"))
+	if err != nil {
+		apiListError(w, "Error fetching notifications:", err)
+		return
+	}
+	views := make([]apiNotificationView, 0, len(notifications))
+	for _, n := range notifications {
+		views = append(views, newAPINotification(n))
+	}
+	writeJSONPage(w, views, next)
+}
+
+func (app *App) apiReadNotification(w http.ResponseWriter, r *http.Request) {
+	notificationID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "POST" {
+		apiMethodNotAllowed(w, "POST")
+		return
+	}
+	userID := apiUserFrom(r).ID
+
+	notification, err := app.Repo.GetNotification(userID, notificationID)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "notification not found")
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error fetching notification:", err)
+		return
+	}
+
+	if err := app.Repo.MarkNotificationRead(userID, notificationID); err != nil {
+		apiInternalError(w, "Error marking notification as read:", err)
+		return
+	}
+	if err := app.Notify.ReadChanged(userID); err != nil {
+		log.Println("Failed to publish unread count:", err)
+	}
+	notification.IsRead = true
+	writeJSON(w, http.StatusOK, newAPINotification(notification))
+}
+
+func (app *App) apiReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		apiMethodNotAllowed(w, "POST")
+		return
+	}
+	userID := apiUserFrom(r).ID
+
+	if err := app.Repo.MarkAllNotificationsRead(userID); err != nil {
+		apiInternalError(w, "Error marking notifications as read:", err)
+		return
+	}
+	if err := app.Notify.ReadChanged(userID); err != nil {
+		log.Println("Failed to publish unread count:", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,612 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/markdown"
+	"01connecthub/src/permission"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type apiNewPost struct {
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	CategoryIDs []int  `json:"category_ids"`
+	// Image is optional base64 encoded image data, attached ahead of
+	// Attachments.
+	Image       string                `json:"image"`
+	Attachments []apiAttachmentUpload `json:"attachments"`
+}
+
+// apiAttachmentUpload is a file to attach to a new post, base64 encoded.
+type apiAttachmentUpload struct {
+	Data     string `json:"data"`
+	Filename string `json:"filename"`
+	Caption  string `json:"caption"`
+}
+
+// apiNewComment is a comment on a post, or a reply to ParentID when that is
+// given.
+// apiPostEdit changes a post's title and content; fields left out keep their
+// current value.
+type apiPostEdit struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+}
+
+// apiPreviewRequest is a draft post. CategoryIDs decide the length limit
+// reported back.
+type apiPreviewRequest struct {
+	Content     string `json:"content"`
+	CategoryIDs []int  `json:"category_ids"`
+}
+
+type apiNewComment struct {
+	Content  string `json:"content"`
+	ParentID int    `json:"parent_id,omitempty"`
+}
+
+// apiReaction toggles the user's reaction of Type, as the buttons on the
+// pages do, or sets it to Active when that is given.
+type apiReaction struct {
+	Type   string `json:"type"`
+	Active *bool  `json:"active,omitempty"`
+}
+
+type apiNewReport struct {
+	Reason string `json:"reason"`
+}
+
+// apiPosts lists posts, newest first, optionally only those in ?category=name.
+func (app *App) apiPosts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		var posts []database.Post
+		var next string
+		var err error
+		page := pageFrom(r, "after")
+		if category := r.URL.Query().Get("category"); category != "" {
+			posts, next, err = app.Repo.GetPostsByCategory(category, page)
+		} else {
+			posts, next, err = app.Repo.GetAllPosts(page)
+		}
+		if err != nil {
+			apiListError(w, "Error fetching posts:", err)
+			return
+		}
+		writeJSONPage(w, newAPIPosts(posts), next)
+	case "POST":
+		app.apiCreatePost(w, r)
+	default:
+		apiMethodNotAllowed(w, "GET", "POST")
+	}
+}
+
+func (app *App) apiCreatePost(w http.ResponseWriter, r *http.Request) {
+	user := apiUserFrom(r)
+
+	var req apiNewPost
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "content is required")
+		return
+	}
+
+	uploads := req.Attachments
+	if req.Image != "" {
+		uploads = append([]apiAttachmentUpload{{Data: req.Image}}, uploads...)
+	}
+	if len(uploads) > maxAttachments {
+		writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("a post may have at most %d attachments", maxAttachments))
+		return
+	}
+	files := make([][]byte, len(uploads))
+	for i, upload := range uploads {
+		data, err := base64.StdEncoding.DecodeString(upload.Data)
+		if err != nil {
+			writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("attachment %d is not valid base64", i+1))
+			return
+		}
+		if len(data) > maxFileSize {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("attachment %d exceeds the %d MB limit", i+1, maxFileSize>>20))
+			return
+		}
+		files[i] = data
+	}
+
+	categories, err := app.Repo.GetAllCategories()
+	if err != nil {
+		apiInternalError(w, "Error fetching categories:", err)
+		return
+	}
+	categories, ok := selectCategories(categories, req.CategoryIDs)
+	if !ok {
+		writeAPIError(w, http.StatusUnprocessableEntity, "unknown category id")
+		return
+	}
+	if postLength(req.Content) > app.maxPostLength(categories) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "content exceeds the character limit")
+		return
+	}
+
+	var attachments []database.Attachment
+	for i, upload := range uploads {
+		attachment, err := app.saveAttachment(files[i], upload.Filename, upload.Caption)
+		var refused attachmentError
+		if errors.As(err, &refused) {
+			writeAPIError(w, http.StatusUnprocessableEntity, string(refused))
+			return
+		} else if err != nil {
+			apiInternalError(w, "Error saving attachment:", err)
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	postID, err := app.Repo.InsertPost(req.Content, req.Title, user.ID, attachments)
+	if err != nil {
+		apiInternalError(w, "Error inserting post:", err)
+		return
+	}
+	for _, c := range categories {
+		if err := app.Repo.InsertPostCategory(postID, c.ID); err != nil {
+			log.Println("Failed to insert post category:", err)
+		}
+	}
+
+	post, ok := app.apiLoadPost(w, postID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusCreated, newAPIPost(post))
+}
+
+func (app *App) apiPost(w http.ResponseWriter, r *http.Request) {
+	postID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		post, ok := app.apiLoadPost(w, postID)
+		if !ok {
+			return
+		}
+		posts := []database.Post{post}
+		if err := app.Repo.AttachPostReactions(posts, apiUserFrom(r).ID); err != nil {
+			apiInternalError(w, "Error fetching reactions:", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newAPIPost(posts[0]))
+	case "DELETE":
+		user := apiUserFrom(r)
+		post, ok := app.apiLoadPost(w, postID)
+		if !ok {
+			return
+		}
+		if !user.Permissions.HasOn(permission.PostDelete, user.ID, post.UserUserID) {
+			log.Printf("User %d may not delete post %d", user.ID, postID)
+			writeAPIError(w, http.StatusForbidden, "you may not delete this post")
+			return
+		}
+		if err := app.Repo.DeletePost(postID, user.ID); err != nil {
+			apiInternalError(w, "Error deleting post:", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "PATCH":
+		user := apiUserFrom(r)
+		post, ok := app.apiLoadPost(w, postID)
+		if !ok {
+			return
+		}
+		if !user.Permissions.HasOn(permission.PostEdit, user.ID, post.UserUserID) {
+			log.Printf("User %d may not edit post %d", user.ID, postID)
+			writeAPIError(w, http.StatusForbidden, "you may not edit this post")
+			return
+		}
+		var req apiPostEdit
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		title, content := post.Title, post.Content
+		if req.Title != nil {
+			title = strings.TrimSpace(*req.Title)
+		}
+		if req.Content != nil {
+			content = strings.TrimSpace(*req.Content)
+		}
+		if content == "" {
+			writeAPIError(w, http.StatusUnprocessableEntity, "content is required")
+			return
+		}
+		categories, err := app.Repo.GetCategoriesForPost(postID)
+		if err != nil {
+			apiInternalError(w, "Error fetching categories:", err)
+			return
+		}
+		if postLength(content) > app.maxPostLength(categories) {
+			writeAPIError(w, http.StatusUnprocessableEntity, "content exceeds the character limit")
+			return
+		}
+		if err := app.Repo.UpdatePost(postID, user.ID, title, content); err != nil {
+			apiInternalError(w, "Error editing post:", err)
+			return
+		}
+		post, ok = app.apiLoadPost(w, postID)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, newAPIPost(post))
+	default:
+		apiMethodNotAllowed(w, "GET", "PATCH", "DELETE")
+	}
+}
+
+// apiPostRevisions lists every version of a post, newest first.
+func (app *App) apiPostRevisions(w http.ResponseWriter, r *http.Request) {
+	postID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+	if !apiRequire(w, r, apiUserFrom(r), permission.RevisionView) {
+		return
+	}
+	versions, err := app.Repo.GetPostHistory(postID)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "post not found")
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error fetching post history:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIRevisions(versions, true))
+}
+
+func (app *App) apiPostComments(w http.ResponseWriter, r *http.Request) {
+	postID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "GET" && r.Method != "POST" {
+		apiMethodNotAllowed(w, "GET", "POST")
+		return
+	}
+	if _, ok := app.apiLoadPost(w, postID); !ok {
+		return
+	}
+
+	if r.Method == "GET" {
+		comments, next, err := app.Repo.GetCommentsForPost(postID, pageFrom(r, "after"))
+		if err != nil {
+			apiListError(w, "Error fetching comments:", err)
+			return
+		}
+		if err := app.Repo.AttachCommentReactions(comments, apiUserFrom(r).ID); err != nil {
+			apiInternalError(w, "Error fetching reactions:", err)
+			return
+		}
+		writeJSONPage(w, newAPIComments(comments), next)
+		return
+	}
+
+	user := apiUserFrom(r)
+	var req apiNewComment
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "content is required")
+		return
+	}
+
+	commentID, err := app.Repo.InsertComment(postID, req.ParentID, user.ID, req.Content)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "post not found")
+		return
+	} else if errors.Is(err, database.ErrBadParent) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "parent_id must be a comment on this post")
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error inserting comment:", err)
+		return
+	}
+	app.commentAdded(user.ID, postID, req.ParentID, commentID)
+
+	comment, ok := app.apiLoadComment(w, commentID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusCreated, newAPIComment(comment))
+}
+
+func (app *App) apiPostReactions(w http.ResponseWriter, r *http.Request) {
+	postID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "POST" {
+		apiMethodNotAllowed(w, "POST")
+		return
+	}
+	user := apiUserFrom(r)
+
+	var req apiReaction
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var state string
+	if req.Active != nil {
+		state = "off"
+		if *req.Active {
+			state = "on"
+		}
+	}
+
+	result, err := app.applyReaction(database.TargetPost, postID, user.ID, req.Type, state)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, http.StatusNotFound, "post not found")
+		return
+	} else if errors.Is(err, database.ErrUnknownReaction) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "unknown reaction type; see /api/v1/reaction-types")
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error setting reaction:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiReactionTypes lists the reaction types the reactions endpoints accept.
+func (app *App) apiReactionTypes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+	types, err := app.Repo.GetReactionTypes()
+	if err != nil {
+		apiInternalError(w, "Error fetching reaction types:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIReactionTypes(types))
+}
+
+func (app *App) apiPostReports(w http.ResponseWriter, r *http.Request) {
+	postID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "POST" {
+		apiMethodNotAllowed(w, "POST")
+		return
+	}
+	user := apiUserFrom(r)
+	if !apiRequire(w, r, user, permission.PostReport) {
+		return
+	}
+
+	var req apiNewReport
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "reason is required")
+		return
+	}
+	if _, ok := app.apiLoadPost(w, postID); !ok {
+		return
+	}
+
+	if err := app.Repo.InsertReport(postID, user.ID, req.Reason); err != nil {
+		apiInternalError(w, "Error reporting post:", err)
+		return
+	}
+	if err := app.Notify.PostReported(user.ID, postID); err != nil {
+		log.Println("Failed to create notification:", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *App) apiComment(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		comment, ok := app.apiLoadComment(w, commentID)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, newAPIComment(comment))
+	case "DELETE":
+		user := apiUserFrom(r)
+		comment, ok := app.apiLoadComment(w, commentID)
+		if !ok {
+			return
+		}
+		if !user.Permissions.HasOn(permission.CommentDelete, user.ID, comment.UserID) {
+			log.Printf("User %d may not delete comment %d", user.ID, commentID)
+			writeAPIError(w, http.StatusForbidden, "you may not delete this comment")
+			return
+		}
+		if err := app.Repo.DeleteComment(commentID, user.ID); err != nil {
+			apiInternalError(w, "Error deleting comment:", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "PATCH":
+		user := apiUserFrom(r)
+		comment, ok := app.apiLoadComment(w, commentID)
+		if !ok {
+			return
+		}
+		if comment.Deleted {
+			writeAPIError(w, http.StatusNotFound, "comment not found")
+			return
+		}
+		if !user.Permissions.HasOn(permission.CommentEdit, user.ID, comment.UserID) {
+			log.Printf("User %d may not edit comment %d", user.ID, commentID)
+			writeAPIError(w, http.StatusForbidden, "you may not edit this comment")
+			return
+		}
+		var req apiNewComment
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		content := strings.TrimSpace(req.Content)
+		if content == "" {
+			writeAPIError(w, http.StatusUnprocessableEntity, "content is required")
+			return
+		}
+		if err := app.Repo.UpdateComment(commentID, user.ID, content); err != nil {
+			apiInternalError(w, "Error editing comment:", err)
+			return
+		}
+		comment, ok = app.apiLoadComment(w, commentID)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, newAPIComment(comment))
+	default:
+		apiMethodNotAllowed(w, "GET", "PATCH", "DELETE")
+	}
+}
+
+// apiCommentRevisions lists every version of a comment, newest first.
+func (app *App) apiCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+	if !apiRequire(w, r, apiUserFrom(r), permission.RevisionView) {
+		return
+	}
+	versions, err := app.Repo.GetCommentHistory(commentID)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "comment not found")
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error fetching comment history:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIRevisions(versions, false))
+}
+
+func (app *App) apiCommentReactions(w http.ResponseWriter, r *http.Request) {
+	commentID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "POST" {
+		apiMethodNotAllowed(w, "POST")
+		return
+	}
+	user := apiUserFrom(r)
+
+	var req apiReaction
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var state string
+	if req.Active != nil {
+		state = "off"
+		if *req.Active {
+			state = "on"
+		}
+	}
+
+	result, err := app.applyReaction(database.TargetComment, commentID, user.ID, req.Type, state)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, http.StatusNotFound, "comment not found")
+		return
+	} else if errors.Is(err, database.ErrUnknownReaction) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "unknown reaction type; see /api/v1/reaction-types")
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error setting reaction:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiLoadPost fetches a post with its categories. When it returns false the
+// error response has already been written.
+func (app *App) apiLoadPost(w http.ResponseWriter, postID int) (database.Post, bool) {
+	post, err := app.Repo.GetPostByID(postID)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "post not found")
+		return post, false
+	} else if err != nil {
+		apiInternalError(w, "Error fetching post:", err)
+		return post, false
+	}
+	post.Categories, err = app.Repo.GetCategoriesForPost(postID)
+	if err != nil {
+		apiInternalError(w, "Error fetching post categories:", err)
+		return post, false
+	}
+	return post, true
+}
+
+func (app *App) apiLoadComment(w http.ResponseWriter, commentID int) (database.Comment, bool) {
+	comment, err := app.Repo.GetCommentByID(commentID)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "comment not found")
+		return comment, false
+	} else if err != nil {
+		apiInternalError(w, "Error fetching comment:", err)
+		return comment, false
+	}
+	return comment, true
+}
+
+// apiPreview renders a draft the way it will look once posted, for the
+// preview on the new post form.
+func (app *App) apiPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		apiMethodNotAllowed(w, "POST")
+		return
+	}
+	var req apiPreviewRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	categories, err := app.Repo.GetAllCategories()
+	if err != nil {
+		apiInternalError(w, "Error fetching categories:", err)
+		return
+	}
+	categories, ok := selectCategories(categories, req.CategoryIDs)
+	if !ok {
+		writeAPIError(w, http.StatusUnprocessableEntity, "unknown category id")
+		return
+	}
+	content := strings.TrimSpace(req.Content)
+	writeJSON(w, http.StatusOK, apiPreviewView{
+		HTML:      string(markdown.Render(content)),
+		Length:    postLength(content),
+		MaxLength: app.maxPostLength(categories),
+	})
+}
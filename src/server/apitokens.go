@@ -0,0 +1,154 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/security"
+	"database/sql"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const maxTokenNameLength = 64
+
+// TokenView is an API token as listed on the settings and admin pages.
+type TokenView struct {
+	ID        int
+	UserID    int
+	Username  string
+	Name      string
+	Prefix    string
+	Scopes    string
+	CreatedAt string
+	LastUsed  string
+}
+
+func tokenViews(tokens []database.APIToken) []TokenView {
+	views := make([]TokenView, 0, len(tokens))
+	for _, t := range tokens {
+		lastUsed := "Never"
+		if t.LastUsed.Valid {
+			lastUsed = t.LastUsed.Time.Local().Format("02/01/2006 - 15:04")
+		}
+		views = append(views, TokenView{
+			ID:        t.ID,
+			UserID:    t.UserID,
+			Username:  t.Username,
+			Name:      t.Name,
+			Prefix:    t.Prefix,
+			Scopes:    strings.Join(t.Scopes, ", "),
+			CreatedAt: t.CreatedAt.Local().Format("02/01/2006 - 15:04"),
+			LastUsed:  lastUsed,
+		})
+	}
+	return views
+}
+
+// tokenManager returns the current user if they may manage their tokens.
+// Tokens can't be used to mint more tokens, so this needs a browser session.
+// When it returns false the error response has already been written.
+func tokenManager(w http.ResponseWriter, r *http.Request) (CurrentUser, bool) {
+	if r.Method != "POST" {
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return CurrentUser{}, false
+	}
+	user, ok := actingUser(w, r)
+	if !ok {
+		return CurrentUser{}, false
+	}
+	if user.TokenID != 0 {
+		log.Printf("Token %d tried to manage API tokens", user.TokenID)
+		err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+		ErrHandler(w, r, &err)
+		return CurrentUser{}, false
+	}
+	return user, true
+}
+
+// CreateAPIToken issues the current user a personal access token. The
+// plaintext is shown once, on the settings page the request redirects to.
+func (app *App) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := tokenManager(w, r)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	scopes := r.Form["scopes"]
+	if name == "" || len(name) > maxTokenNameLength || len(scopes) == 0 {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(database.Scopes, scope) {
+			log.Printf("Unknown token scope %q", scope)
+			err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+			ErrHandler(w, r, &err)
+			return
+		}
+	}
+
+	plaintext, hash, prefix, err := security.GenerateAPIToken()
+	if err != nil {
+		log.Println("Failed to generate API token:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	_, err = app.Repo.CreateAPIToken(database.APIToken{
+		UserID: user.ID,
+		Name:   name,
+		Hash:   hash,
+		Prefix: prefix,
+		Scopes: scopes,
+	})
+	if err != nil {
+		log.Println("Failed to create API token:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	app.newTokens.Store(user.SessionID, plaintext)
+	http.Redirect(w, r, "/settings#tokens", http.StatusSeeOther)
+}
+
+// takeNewToken returns the plaintext of a token just created from this
+// session, once.
+func (app *App) takeNewToken(sessionID int) string {
+	plaintext, _ := app.newTokens.LoadAndDelete(sessionID)
+	s, _ := plaintext.(string)
+	return s
+}
+
+// RevokeAPIToken deletes one of the current user's tokens.
+func (app *App) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := tokenManager(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.RevokeAPIToken(user.ID, tokenID)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "TOKEN NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to revoke API token:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, "/settings#tokens", http.StatusSeeOther)
+}
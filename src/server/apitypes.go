@@ -0,0 +1,368 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/markdown"
+	"01connecthub/src/permission"
+	"database/sql"
+	"time"
+)
+
+// The API sends these views of the database types rather than the types
+// themselves, which hold password hashes and sql.NullString fields that don't
+// encode usefully.
+
+type apiAuthor struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Avatar    string `json:"avatar,omitempty"`
+}
+
+// apiPostView is a post. Content is the Markdown as written and
+// ContentHTML the sanitized HTML it renders to.
+type apiPostView struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	ContentHTML string `json:"content_html"`
+	// ImageURL is the first image attached, full size, and ThumbnailURL a
+	// copy of it at most 320 pixels across.
+	ImageURL     string              `json:"image_url,omitempty"`
+	ThumbnailURL string              `json:"thumbnail_url,omitempty"`
+	Attachments  []apiAttachmentView `json:"attachments"`
+	CreatedAt    time.Time           `json:"created_at"`
+	Author       apiAuthor           `json:"author"`
+	Likes        int                 `json:"likes"`
+	Dislikes     int                 `json:"dislikes"`
+	Comments     int                 `json:"comments"`
+	Categories   []database.Category `json:"categories"`
+	EditedAt     *time.Time          `json:"edited_at,omitempty"`
+	// Reactions and MyReaction are only sent for a single post.
+	Reactions  map[string]int `json:"reactions,omitempty"`
+	MyReaction string         `json:"my_reaction,omitempty"`
+}
+
+// apiAttachmentView is a file attached to a post. Only images have a
+// ThumbnailURL and dimensions.
+type apiAttachmentView struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	ContentType  string `json:"content_type"`
+	Filename     string `json:"filename,omitempty"`
+	Caption      string `json:"caption,omitempty"`
+	Size         int    `json:"size"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+}
+
+// apiCommentView is a comment. A deleted comment that still has replies is
+// sent with Deleted set and no content or author.
+type apiCommentView struct {
+	ID          int        `json:"id"`
+	PostID      int        `json:"post_id"`
+	ParentID    int        `json:"parent_id,omitempty"`
+	Deleted     bool       `json:"deleted,omitempty"`
+	Content     string     `json:"content"`
+	ContentHTML string     `json:"content_html"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Author      *apiAuthor `json:"author,omitempty"`
+	Likes       int        `json:"likes"`
+	Dislikes    int        `json:"dislikes"`
+	EditedAt    *time.Time `json:"edited_at,omitempty"`
+	// Depth and ReplyCount place the comment in its thread when listing a
+	// post's comments.
+	Depth      int `json:"depth"`
+	ReplyCount int `json:"reply_count"`
+	// Reactions and MyReaction are only sent when listing a post's comments.
+	Reactions  map[string]int `json:"reactions,omitempty"`
+	MyReaction string         `json:"my_reaction,omitempty"`
+}
+
+// apiRevisionView is one version of a post or comment, with what changed
+// since the version before it.
+type apiRevisionView struct {
+	Version   int        `json:"version"`
+	Title     string     `json:"title,omitempty"`
+	Content   string     `json:"content"`
+	Editor    apiAuthor  `json:"editor"`
+	At        time.Time  `json:"at"`
+	TitleDiff []diffPart `json:"title_diff,omitempty"`
+	Diff      []diffPart `json:"diff"`
+}
+
+// apiTrashItemView is a deleted post, comment or user. Posts and comments
+// deleted along with their author are counted on the user instead.
+type apiTrashItemView struct {
+	Kind      string     `json:"kind"`
+	ID        int        `json:"id"`
+	PostID    int        `json:"post_id,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	Author    apiAuthor  `json:"author"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	DeletedBy *apiAuthor `json:"deleted_by,omitempty"`
+	Posts     int        `json:"posts,omitempty"`
+	Comments  int        `json:"comments,omitempty"`
+}
+
+// apiPreviewView is a rendered draft and how its length compares with the
+// limit for its categories.
+type apiPreviewView struct {
+	HTML      string `json:"html"`
+	Length    int    `json:"length"`
+	MaxLength int    `json:"max_length"`
+}
+
+type apiReactionTypeView struct {
+	Name    string `json:"name"`
+	Emoji   string `json:"emoji"`
+	Label   string `json:"label"`
+	Builtin bool   `json:"builtin"`
+}
+
+type apiUserView struct {
+	apiAuthor
+	// Email is only shown to the user themselves and to user managers.
+	Email  string `json:"email,omitempty"`
+	RoleID int    `json:"role_id,omitempty"`
+}
+
+type apiProfileView struct {
+	apiUserView
+	Followers int  `json:"followers"`
+	Following int  `json:"following"`
+	Friends   int  `json:"friends"`
+	Posts     int  `json:"posts"`
+	Followed  bool `json:"followed"`
+}
+
+type apiNotificationView struct {
+	ID        int       `json:"id"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	Actor     apiAuthor `json:"actor"`
+	Others    int       `json:"others"`
+	PostID    int       `json:"post_id,omitempty"`
+	CommentID int       `json:"comment_id,omitempty"`
+	Link      string    `json:"link"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiReportView struct {
+	ID         int       `json:"id"`
+	PostID     int       `json:"post_id"`
+	ReportedBy int       `json:"reported_by"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func nullString(s sql.NullString) string {
+	if s.Valid {
+		return s.String
+	}
+	return ""
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if t.Valid {
+		return &t.Time
+	}
+	return nil
+}
+
+func newAPIPost(p database.Post) apiPostView {
+	view := apiPostView{
+		ID:          p.PostID,
+		Title:       p.Title,
+		Content:     p.Content,
+		ContentHTML: string(markdown.Render(p.Content)),
+		CreatedAt:   p.PostAt,
+		Author: apiAuthor{
+			ID:        p.UserUserID,
+			Username:  p.Username,
+			FirstName: p.FirstName,
+			LastName:  p.LastName,
+			Avatar:    nullString(p.Avatar),
+		},
+		Likes:      p.Likes,
+		Dislikes:   p.Dislikes,
+		Comments:   p.Comments,
+		Categories: p.Categories,
+		EditedAt:   nullTime(p.EditedAt),
+		Reactions:  p.Reactions,
+		MyReaction: p.MyReaction,
+	}
+	view.Attachments = make([]apiAttachmentView, 0, len(p.Attachments))
+	for _, a := range p.Attachments {
+		attachment := apiAttachmentView{
+			URL:         "/media/" + a.Hash,
+			ContentType: a.ContentType,
+			Filename:    a.Filename,
+			Caption:     a.Caption,
+			Size:        a.Size,
+			Width:       a.Width,
+			Height:      a.Height,
+		}
+		if a.IsImage() {
+			attachment.ThumbnailURL = attachment.URL + "?size=thumb"
+			if view.ImageURL == "" {
+				view.ImageURL, view.ThumbnailURL = attachment.URL, attachment.ThumbnailURL
+			}
+		}
+		view.Attachments = append(view.Attachments, attachment)
+	}
+	if view.Categories == nil {
+		view.Categories = []database.Category{}
+	}
+	return view
+}
+
+func newAPIPosts(posts []database.Post) []apiPostView {
+	views := make([]apiPostView, 0, len(posts))
+	for _, p := range posts {
+		views = append(views, newAPIPost(p))
+	}
+	return views
+}
+
+func newAPIComment(c database.Comment) apiCommentView {
+	view := apiCommentView{
+		ID:          c.ID,
+		PostID:      c.PostID,
+		ParentID:    c.ParentID,
+		Deleted:     c.Deleted,
+		Content:     c.Content,
+		ContentHTML: string(markdown.Render(c.Content)),
+		CreatedAt:   c.CreatedAt,
+		Likes:       c.Likes,
+		Dislikes:    c.Dislikes,
+		EditedAt:    nullTime(c.EditedAt),
+		Depth:       c.Depth,
+		ReplyCount:  c.ReplyCount,
+		Reactions:   c.Reactions,
+		MyReaction:  c.MyReaction,
+	}
+	if !c.Deleted {
+		view.Author = &apiAuthor{
+			ID:        c.UserID,
+			Username:  c.Username,
+			FirstName: c.FirstName,
+			LastName:  c.LastName,
+			Avatar:    nullString(c.Avatar),
+		}
+	}
+	return view
+}
+
+func newAPIComments(comments []database.Comment) []apiCommentView {
+	views := make([]apiCommentView, 0, len(comments))
+	for _, c := range comments {
+		views = append(views, newAPIComment(c))
+	}
+	return views
+}
+
+// newAPIUser hides the email unless viewer is the user or may manage users.
+func newAPIUser(u database.User, viewer CurrentUser) apiUserView {
+	view := apiUserView{
+		apiAuthor: apiAuthor{
+			ID:        u.ID,
+			Username:  u.Username,
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			Avatar:    nullString(u.Avatar),
+		},
+		RoleID: u.RoleID,
+	}
+	if viewer.ID == u.ID || viewer.Can(permission.UserManage) {
+		view.Email = u.Email
+	}
+	return view
+}
+
+func newAPIUsers(users []database.User, viewer CurrentUser) []apiUserView {
+	views := make([]apiUserView, 0, len(users))
+	for _, u := range users {
+		views = append(views, newAPIUser(u, viewer))
+	}
+	return views
+}
+
+func newAPINotification(n database.Notification) apiNotificationView {
+	return apiNotificationView{
+		ID:        n.ID,
+		Type:      n.Type,
+		Message:   n.Message,
+		Actor:     apiAuthor{ID: n.ActorID, Username: n.UserName, Avatar: n.UserImage},
+		Others:    n.Others,
+		PostID:    n.PostID,
+		CommentID: n.CommentID,
+		Link:      n.Link(),
+		Read:      n.IsRead,
+		CreatedAt: n.CreatedAt,
+	}
+}
+
+func newAPIReport(r database.Report) apiReportView {
+	return apiReportView{
+		ID:         r.ID,
+		PostID:     r.PostID,
+		ReportedBy: r.ReportedBy,
+		Reason:     r.ReportReason,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+func newAPIReactionTypes(types []database.ReactionType) []apiReactionTypeView {
+	views := make([]apiReactionTypeView, 0, len(types))
+	for _, t := range types {
+		views = append(views, apiReactionTypeView{Name: t.Name, Emoji: t.Emoji, Label: t.Label, Builtin: t.Builtin})
+	}
+	return views
+}
+
+// newAPIRevisions lists versions newest first, as the history page does.
+func newAPIRevisions(versions []database.Version, withTitle bool) []apiRevisionView {
+	views := make([]apiRevisionView, 0, len(versions))
+	for _, v := range newRevisionViews(versions) {
+		view := apiRevisionView{
+			Version: v.Number,
+			Content: v.Content,
+			Editor:  apiAuthor{ID: v.EditorID, Username: v.EditorName},
+			At:      v.At,
+			Diff:    v.ContentDiff,
+		}
+		if withTitle {
+			view.Title, view.TitleDiff = v.Title, v.TitleDiff
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+func newAPITrash(items []database.TrashItem) []apiTrashItemView {
+	views := make([]apiTrashItemView, 0, len(items))
+	for _, item := range items {
+		view := apiTrashItemView{
+			Kind:      item.Kind,
+			ID:        item.ID,
+			Title:     item.Title,
+			Content:   item.Content,
+			Author:    apiAuthor{ID: item.AuthorID, Username: item.AuthorName},
+			DeletedAt: item.DeletedAt,
+			Posts:     item.Posts,
+			Comments:  item.Comments,
+		}
+		if item.Kind == database.TrashComment {
+			view.PostID = item.PostID
+		}
+		if item.DeletedByID != 0 {
+			view.DeletedBy = &apiAuthor{ID: item.DeletedByID, Username: item.DeletedByName}
+		}
+		views = append(views, view)
+	}
+	return views
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/permission"
+	"database/sql"
+	"log"
+	"net/http"
+)
+
+type apiRoleChange struct {
+	RoleID int `json:"role_id"`
+}
+
+// apiUsers lists users a page at a time, or with ?q= those whose username
+// or name matches, best match first.
+func (app *App) apiUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+
+	var users []database.User
+	var next string
+	var err error
+	if q := r.URL.Query().Get("q"); q != "" {
+		users, next, err = app.Repo.SearchUsers(database.ParseSearch(q), pageFrom(r, "after"))
+	} else {
+		users, next, err = app.Repo.GetAllUsers(pageFrom(r, "after"))
+	}
+	if err != nil {
+		apiListError(w, "Error fetching users:", err)
+		return
+	}
+	writeJSONPage(w, newAPIUsers(users, apiUserFrom(r)), next)
+}
+
+func (app *App) apiMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+	app.apiWriteProfile(w, r, apiUserFrom(r).ID)
+}
+
+func (app *App) apiUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		app.apiWriteProfile(w, r, userID)
+	case "DELETE":
+		user := apiUserFrom(r)
+		if !apiRequire(w, r, user, permission.UserManage) {
+			return
+		}
+		if _, ok := app.apiLoadUser(w, userID); !ok {
+			return
+		}
+		if userID == user.ID {
+			writeAPIError(w, http.StatusUnprocessableEntity, "you cannot delete your own account")
+			return
+		}
+		if err := app.Repo.DeleteUser(userID, user.ID); err != nil {
+			apiInternalError(w, "Error deleting user:", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		apiMethodNotAllowed(w, "GET", "DELETE")
+	}
+}
+
+func (app *App) apiUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "PUT" {
+		apiMethodNotAllowed(w, "PUT")
+		return
+	}
+	viewer := apiUserFrom(r)
+	if !apiRequire(w, r, viewer, permission.UserRoleAssign) {
+		return
+	}
+
+	var req apiRoleChange
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if _, err := app.Repo.GetRoleNameByID(req.RoleID); err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusUnprocessableEntity, "unknown role_id")
+		return
+	} else if err != nil {
+		apiInternalError(w, "Error fetching role:", err)
+		return
+	}
+	user, ok := app.apiLoadUser(w, userID)
+	if !ok {
+		return
+	}
+
+	if err := app.Repo.UpdateUserRole(userID, req.RoleID); err != nil {
+		apiInternalError(w, "Error updating user role:", err)
+		return
+	}
+	user.RoleID = req.RoleID
+	writeJSON(w, http.StatusOK, newAPIUser(user, viewer))
+}
+
+// apiUserPosts lists a user's posts, newest first or with ?filter=oldest
+// oldest first.
+func (app *App) apiUserPosts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+	if _, ok := app.apiLoadUser(w, userID); !ok {
+		return
+	}
+
+	posts, next, err := app.Repo.GetUserPosts(userID, r.URL.Query().Get("filter"), pageFrom(r, "after"))
+	if err != nil {
+		apiListError(w, "Error fetching user posts:", err)
+		return
+	}
+	writeJSONPage(w, newAPIPosts(posts), next)
+}
+
+func (app *App) apiFollowers(w http.ResponseWriter, r *http.Request) {
+	app.apiWriteUserList(w, r, app.Repo.GetFollowers)
+}
+
+func (app *App) apiFollowing(w http.ResponseWriter, r *http.Request) {
+	app.apiWriteUserList(w, r, app.Repo.GetFollowing)
+}
+
+func (app *App) apiWriteUserList(w http.ResponseWriter, r *http.Request, list func(userID int) ([]database.User, error)) {
+	userID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "GET" {
+		apiMethodNotAllowed(w, "GET")
+		return
+	}
+	if _, ok := app.apiLoadUser(w, userID); !ok {
+		return
+	}
+
+	users, err := list(userID)
+	if err != nil {
+		apiInternalError(w, "Error fetching users:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIUsers(users, apiUserFrom(r)))
+}
+
+// apiFollow follows the user with PUT and unfollows them with DELETE.
+func (app *App) apiFollow(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := apiPathID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "PUT" && r.Method != "DELETE" {
+		apiMethodNotAllowed(w, "PUT", "DELETE")
+		return
+	}
+	viewer := apiUserFrom(r)
+	if targetID == viewer.ID {
+		writeAPIError(w, http.StatusUnprocessableEntity, "you cannot follow yourself")
+		return
+	}
+	if _, ok := app.apiLoadUser(w, targetID); !ok {
+		return
+	}
+
+	if r.Method == "PUT" {
+		following, err := app.Repo.IsFollowing(viewer.ID, targetID)
+		if err != nil {
+			apiInternalError(w, "Error fetching follow status:", err)
+			return
+		}
+		if !following {
+			if err := app.Repo.FollowUser(viewer.ID, targetID); err != nil {
+				apiInternalError(w, "Error following user:", err)
+				return
+			}
+			if err := app.Notify.Followed(viewer.ID, targetID); err != nil {
+				log.Println("Failed to create notification:", err)
+			}
+		}
+	} else {
+		if err := app.Repo.UnfollowUser(viewer.ID, targetID); err != nil {
+			apiInternalError(w, "Error unfollowing user:", err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *App) apiWriteProfile(w http.ResponseWriter, r *http.Request, userID int) {
+	viewer := apiUserFrom(r)
+	user, ok := app.apiLoadUser(w, userID)
+	if !ok {
+		return
+	}
+
+	profile := apiProfileView{apiUserView: newAPIUser(user, viewer)}
+	var err error
+	if profile.Followers, err = app.Repo.GetFollowersCount(userID); err != nil {
+		apiInternalError(w, "Error fetching followers count:", err)
+		return
+	}
+	if profile.Following, err = app.Repo.GetFollowingCount(userID); err != nil {
+		apiInternalError(w, "Error fetching following count:", err)
+		return
+	}
+	if profile.Friends, err = app.Repo.GetFriendsCount(userID); err != nil {
+		apiInternalError(w, "Error fetching friends count:", err)
+		return
+	}
+	if profile.Posts, err = app.Repo.GetTotalPosts(userID); err != nil {
+		apiInternalError(w, "Error fetching post count:", err)
+		return
+	}
+	if viewer.ID != userID {
+		if profile.Followed, err = app.Repo.IsFollowing(viewer.ID, userID); err != nil {
+			apiInternalError(w, "Error fetching follow status:", err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// apiLoadUser fetches a user. When it returns false the error response has
+// already been written.
+func (app *App) apiLoadUser(w http.ResponseWriter, userID int) (database.User, bool) {
+	user, err := app.Repo.GetUserByID(userID)
+	if err == sql.ErrNoRows {
+		writeAPIError(w, http.StatusNotFound, "user not found")
+		return user, false
+	} else if err != nil {
+		apiInternalError(w, "Error fetching user:", err)
+		return user, false
+	}
+	return user, true
+}
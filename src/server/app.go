@@ -0,0 +1,42 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/config"
+	"01connecthub/src/events"
+	"01connecthub/src/mail"
+	"01connecthub/src/media"
+	"01connecthub/src/notification"
+	"sync"
+)
+
+// App carries the dependencies shared by every handler. It is built once in
+// main and its methods are registered as the HTTP routes.
+type App struct {
+	Repo   database.Repository
+	Hub    *events.Hub
+	Notify *notification.Service
+	Media  *media.Store
+	Config config.Config
+	// Mail is nil unless SMTP is configured.
+	Mail *mail.Sender
+
+	// newTokens holds the plaintext of a just created API token by session
+	// ID until the settings page shows it.
+	newTokens sync.Map
+}
+
+func NewApp(repo database.Repository, cfg config.Config, store *media.Store) *App {
+	hub := events.NewHub()
+	app := &App{
+		Repo:   repo,
+		Config: cfg,
+		Hub:    hub,
+		Notify: notification.NewService(repo, hub),
+		Media:  store,
+	}
+	if cfg.SMTP.Enabled() {
+		app.Mail = mail.NewSender(cfg.SMTP)
+	}
+	return app
+}
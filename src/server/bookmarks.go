@@ -0,0 +1,331 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/security"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxCollections          = 50
+	maxCollectionNameLength = 64
+)
+
+// savedTabURL is the saved tab of the home page showing filter, which is
+// "all" or the name of a collection.
+func savedTabURL(filter string) string {
+	return "/home?tab=saved&filter=" + url.QueryEscape(filter)
+}
+
+// shareLink is where anyone signed in can open a public collection.
+func (app *App) shareLink(c database.Collection) string {
+	return app.Config.BaseURL + "/home?tab=collection&filter=" + url.QueryEscape(c.ShareToken)
+}
+
+// bookmarkUser returns the current user for the bookmark and collection
+// forms, which only take POST. When it returns false the error response has
+// already been written.
+func bookmarkUser(w http.ResponseWriter, r *http.Request) (CurrentUser, bool) {
+	if r.Method != "POST" {
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return CurrentUser{}, false
+	}
+	return actingUser(w, r, "user")
+}
+
+// formState reads the on/off state field of the bookmark forms.
+func formState(r *http.Request) (bool, bool) {
+	switch r.FormValue("state") {
+	case "on":
+		return true, true
+	case "off":
+		return false, true
+	}
+	return false, false
+}
+
+// collectionName checks a name typed for a collection. "all" is the saved
+// tab's filter for every bookmark, so it can't name a collection.
+func collectionName(r *http.Request) (string, bool) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || utf8.RuneCountInString(name) > maxCollectionNameLength || strings.EqualFold(name, "all") {
+		return "", false
+	}
+	return name, true
+}
+
+// Bookmark saves a post for later or removes it from the saved posts. The
+// state field says which, so a retried request is harmless. Requests that
+// accept JSON get {"bookmarked": bool} back instead of a redirect.
+func (app *App) Bookmark(w http.ResponseWriter, r *http.Request) {
+	asJSON := strings.Contains(r.Header.Get("Accept"), "application/json")
+	fail := func(status int, message string) {
+		if asJSON {
+			writeAPIError(w, status, message)
+			return
+		}
+		err := ErrorPageData{Code: strconv.Itoa(status), ErrorMsg: strings.ToUpper(http.StatusText(status))}
+		ErrHandler(w, r, &err)
+	}
+
+	if r.Method != "POST" {
+		fail(http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	postID, err := strconv.Atoi(r.FormValue("post_id"))
+	if err != nil {
+		fail(http.StatusBadRequest, "invalid post id")
+		return
+	}
+	on, ok := formState(r)
+	if !ok {
+		fail(http.StatusBadRequest, `state must be "on" or "off"`)
+		return
+	}
+	user, ok := actingUser(w, r, "user")
+	if !ok {
+		return
+	}
+
+	err = app.Repo.SetBookmark(user.ID, postID, on)
+	if err == sql.ErrNoRows {
+		fail(http.StatusNotFound, "post not found")
+		return
+	} else if err != nil {
+		log.Println("Error setting bookmark:", err)
+		fail(http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, map[string]bool{"bookmarked": on})
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
+
+// CreateCollection makes a new collection for the current user. With a
+// post_id it also puts that post in it and returns to the post; otherwise it
+// opens the new collection on the saved tab.
+func (app *App) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := bookmarkUser(w, r)
+	if !ok {
+		return
+	}
+
+	name, ok := collectionName(r)
+	if !ok {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	var postID int
+	if id := r.FormValue("post_id"); id != "" {
+		var err error
+		if postID, err = strconv.Atoi(id); err != nil {
+			err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+			ErrHandler(w, r, &err)
+			return
+		}
+	}
+
+	collections, err := app.Repo.GetUserCollections(user.ID)
+	if err != nil {
+		log.Println("Failed to fetch collections:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if len(collections) >= maxCollections {
+		err := ErrorPageData{Code: "400", ErrorMsg: fmt.Sprintf("You can have at most %d collections", maxCollections)}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	token, err := security.GenerateShareToken()
+	if err != nil {
+		log.Println("Failed to generate share token:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	collectionID, err := app.Repo.CreateCollection(user.ID, name, token)
+	if err == database.ErrCollectionExists {
+		err := ErrorPageData{Code: "400", ErrorMsg: "You already have a collection with that name"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to create collection:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	if postID == 0 {
+		http.Redirect(w, r, savedTabURL(name), http.StatusSeeOther)
+		return
+	}
+	err = app.Repo.SetCollectionPost(user.ID, collectionID, postID, true)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "POST NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to add post to collection:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
+
+// RenameCollection renames one of the current user's collections.
+func (app *App) RenameCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := bookmarkUser(w, r)
+	if !ok {
+		return
+	}
+
+	collectionID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	name, ok := collectionName(r)
+	if !ok {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.RenameCollection(user.ID, collectionID, name)
+	if err == database.ErrCollectionExists {
+		err := ErrorPageData{Code: "400", ErrorMsg: "You already have a collection with that name"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "COLLECTION NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to rename collection:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, savedTabURL(name), http.StatusSeeOther)
+}
+
+// ShareCollection makes one of the current user's collections public, so its
+// link can be shared, or private again.
+func (app *App) ShareCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := bookmarkUser(w, r)
+	if !ok {
+		return
+	}
+
+	collectionID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	public, ok := formState(r)
+	if !ok {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.SetCollectionPublic(user.ID, collectionID, public)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "COLLECTION NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to change collection visibility:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
+
+// DeleteCollection removes one of the current user's collections. Its posts
+// stay bookmarked.
+func (app *App) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := bookmarkUser(w, r)
+	if !ok {
+		return
+	}
+
+	collectionID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.DeleteCollection(user.ID, collectionID)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "COLLECTION NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to delete collection:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, savedTabURL("all"), http.StatusSeeOther)
+}
+
+// CollectPost puts a post in one of the current user's collections, or takes
+// it out, as the state field says.
+func (app *App) CollectPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := bookmarkUser(w, r)
+	if !ok {
+		return
+	}
+
+	collectionID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	postID, err := strconv.Atoi(r.FormValue("post_id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	on, ok := formState(r)
+	if !ok {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.SetCollectionPost(user.ID, collectionID, postID, on)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to update collection:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
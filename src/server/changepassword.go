@@ -8,7 +8,7 @@ import (
 	"time"
 )
 
-func ChangePassword(w http.ResponseWriter, r *http.Request) {
+func (app *App) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		log.Println("Method not allowed")
 		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
@@ -16,19 +16,10 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := r.FormValue("user_id")
+	var userID int
 	currentPassword := r.FormValue("current_password")
 	newPassword := r.FormValue("new_password")
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
 	var hasSession bool
 	var userName string
 	seshCok, err := r.Cookie("session_token")
@@ -41,7 +32,8 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 
 		seshVal := seshCok.Value
 
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
+		sessionUser, err := app.Repo.GetUserBySession(seshVal)
+		userID, userName = sessionUser.ID, sessionUser.Username
 		if err == sql.ErrNoRows {
 			http.SetCookie(w, &http.Cookie{
 				Name:     "session_token",
@@ -60,8 +52,7 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	_, err = app.Repo.GetUserRoleID(userID)
 	if err != nil {
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
@@ -69,8 +60,7 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if hasSession {
-		var avatar sql.NullString
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		_, _, err = app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -83,8 +73,7 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var storedPassword string
-		err = db.QueryRow("SELECT password FROM user WHERE userid = ?", userID).Scan(&storedPassword)
+		storedPassword, err := app.Repo.GetUserPassword(userID)
 		if err != nil {
 			log.Println("Error fetching user:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -92,14 +81,22 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if storedPassword != currentPassword {
+		if !VerifyPassword(currentPassword, storedPassword) {
 			log.Println("Current password is incorrect")
 			err := ErrorPageData{Code: "400", ErrorMsg: "Current password is incorrect"}
 			ErrHandler(w, r, &err)
 			return
 		}
 
-		_, err = db.Exec("UPDATE users SET password = ? WHERE id = ?", newPassword, userID)
+		hashedPassword, err := HashPassword(newPassword)
+		if err != nil {
+			log.Println("Error hashing password:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		err = app.Repo.UpdateUserPassword(userID, hashedPassword)
 		if err != nil {
 			log.Println("Error updating password:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
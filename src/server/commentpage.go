@@ -1,47 +1,102 @@
 package server
 
 import (
+	"01connecthub/database"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
-	"time"
+	"strconv"
 )
 
-func AddComment(w http.ResponseWriter, r *http.Request) {
+func (app *App) AddComment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		log.Println("Method not allowed")
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	user, ok := actingUser(w, r, "user_id")
+	if !ok {
 		return
 	}
-	defer db.Close()
+	userIDInt := user.ID
 
 	postID := r.FormValue("post_id")
-	userID := r.FormValue("user_id")
 	content := r.FormValue("content")
 
 	log.Println("post_id:", postID)
-	log.Println("user_id:", userID)
+	log.Println("user_id:", userIDInt)
 	log.Println("content:", content)
 
-	if postID == "" || userID == "" || content == "" {
+	if postID == "" || content == "" {
 		log.Println("Missing form values")
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	_, err = db.Exec("INSERT INTO comment (content, comment_at, post_postid, user_userid) VALUES (?, ?, ?, ?)", content, time.Now(), postID, userID)
+	postIDInt, err := strconv.Atoi(postID)
 	if err != nil {
+		log.Println("Invalid post ID")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	parentID := 0
+	if parent := r.FormValue("parent_id"); parent != "" {
+		parentID, err = strconv.Atoi(parent)
+		if err != nil {
+			log.Println("Invalid parent comment ID")
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	commentID, err := app.Repo.InsertComment(postIDInt, parentID, userIDInt, content)
+	if err == sql.ErrNoRows {
+		log.Println("Comment on missing post:", postIDInt)
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, database.ErrBadParent) {
+		log.Println("Invalid parent comment:", parentID)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	} else if err != nil {
 		log.Println("Error inserting comment:", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	app.commentAdded(userIDInt, postIDInt, parentID, commentID)
 
+	if parentID != 0 {
+		http.Redirect(w, r, "/post?id="+postID+"&thread="+strconv.Itoa(parentID), http.StatusSeeOther)
+		return
+	}
 	http.Redirect(w, r, "/post?id="+postID, http.StatusSeeOther)
 }
+
+// ReplyComment is AddComment for replies, which must name the comment they
+// answer in parent_id.
+func (app *App) ReplyComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" && r.FormValue("parent_id") == "" {
+		log.Println("Missing parent comment")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	app.AddComment(w, r)
+}
+
+// commentAdded tells the post's author about a new comment, or the parent
+// comment's author about a reply, and pushes the comment to viewers.
+func (app *App) commentAdded(userID, postID, parentID, commentID int) {
+	var err error
+	if parentID != 0 {
+		err = app.Notify.Replied(userID, parentID)
+	} else {
+		err = app.Notify.Commented(userID, postID)
+	}
+	if err != nil {
+		log.Println("Failed to create notification:", err)
+	}
+	app.publishComment(commentID)
+}
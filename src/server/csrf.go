@@ -0,0 +1,120 @@
+package server
+
+import (
+	"01connecthub/src/security"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// maxFormBody is how large a multipart body may be on routes that take no
+// uploads, and uploadLimits how large the files may be on those that do.
+// Finding the token in a multipart form means reading the whole body, so the
+// middleware has to cap it before anything parses it.
+const maxFormBody = 10 << 20
+
+var uploadLimits = map[string]int64{
+	"/newpost":  maxUploadSize,
+	"/settings": maxFileSize,
+}
+
+func isMultipart(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+}
+
+// CSRFMiddleware wraps the whole router. It makes the session's CSRF token
+// available to handlers through csrfToken, and rejects any POST, PUT, PATCH or
+// DELETE made with a valid session that doesn't echo the token back in the
+// csrf_token form field or the X-CSRF-Token header. Requests without a session
+// pass through; the handlers behind AuthMiddleware turn them away anyway.
+func (app *App) CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, "/media/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Uploads get room for the other form fields on top of their files.
+		allowed, limit := int64(maxFormBody), int64(maxFormBody)
+		if upload, ok := uploadLimits[r.URL.Path]; ok {
+			allowed, limit = upload, upload+1<<20
+		}
+		if isMultipart(r) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+
+		// Requests with an API token are authenticated by it alone, never by
+		// the cookie, and browsers don't attach one cross-site.
+		_, bearer := security.BearerToken(r)
+
+		var token string
+		if seshCok, err := r.Cookie(security.SessionCookie); !bearer && err == nil && seshCok.Value != "" {
+			session, err := app.Repo.GetSession(seshCok.Value)
+			if err == nil {
+				token = session.CSRFToken
+			} else if err != sql.ErrNoRows {
+				if isAPIRequest(r) {
+					apiInternalError(w, "Error fetching session:", err)
+					return
+				}
+				log.Println("Error fetching session:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+		}
+
+		if token != "" && !safeMethod(r.Method) {
+			sent := r.Header.Get(csrfHeader)
+			if sent == "" && isMultipart(r) {
+				var tooLarge *http.MaxBytesError
+				if err := r.ParseMultipartForm(10 << 20); errors.As(err, &tooLarge) {
+					log.Printf("Rejected %s %s: body over %d bytes", r.Method, r.URL.Path, limit)
+					if isAPIRequest(r) {
+						writeAPIError(w, http.StatusRequestEntityTooLarge, "request body too large")
+						return
+					}
+					err := ErrorPageData{Code: "400", ErrorMsg: fmt.Sprintf("Uploads may add up to at most %d MB", allowed>>20)}
+					ErrHandler(w, r, &err)
+					return
+				}
+			}
+			if sent == "" {
+				sent = r.FormValue(csrfField)
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				log.Printf("Rejected %s %s: missing or invalid CSRF token", r.Method, r.URL.Path)
+				if isAPIRequest(r) {
+					writeAPIError(w, http.StatusForbidden, "missing or invalid CSRF token")
+					return
+				}
+				err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+				ErrHandler(w, r, &err)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfTokenKey, token)))
+	})
+}
+
+func safeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// csrfToken is the token pages must put in every form that changes state. It
+// is empty for visitors without a session.
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey).(string)
+	return token
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"01connecthub/src/permission"
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// CurrentUser is the logged in user behind a request, resolved from the
+// session or API token by AuthMiddleware. Handlers must act as this user and never as an ID
+// taken from the form or query string.
+type CurrentUser struct {
+	ID       int
+	Username string
+	RoleID   int
+	// SessionID is the database ID of the session the request was made with,
+	// and TokenID that of the API token. Only one of them is set.
+	SessionID   int
+	TokenID     int
+	Permissions permission.Set
+}
+
+func (u CurrentUser) Can(p permission.Permission) bool {
+	return u.Permissions.Has(p)
+}
+
+type contextKey int
+
+const (
+	currentUserKey contextKey = iota
+	csrfTokenKey
+)
+
+func withCurrentUser(r *http.Request, user CurrentUser) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), currentUserKey, user))
+}
+
+// userPermissions is what the current user may do, or nothing for routes that
+// are not behind AuthMiddleware.
+func userPermissions(r *http.Request) permission.Set {
+	user, _ := CurrentUserFrom(r)
+	return user.Permissions
+}
+
+// CurrentUserFrom returns the user stored by AuthMiddleware. ok is false for
+// routes that are not behind it.
+func CurrentUserFrom(r *http.Request) (CurrentUser, bool) {
+	user, ok := r.Context().Value(currentUserKey).(CurrentUser)
+	return user, ok
+}
+
+// actingUser returns the current user, rejecting the request if a
+// client-supplied user ID in any of fields names somebody else. Forms still
+// send the field, so an empty value is allowed. When it returns false the
+// error response has already been written.
+func actingUser(w http.ResponseWriter, r *http.Request, fields ...string) (CurrentUser, bool) {
+	user, ok := CurrentUserFrom(r)
+	if !ok {
+		log.Println("No current user in request context")
+		err := ErrorPageData{Code: "401", ErrorMsg: "UNAUTHORIZED"}
+		ErrHandler(w, r, &err)
+		return CurrentUser{}, false
+	}
+
+	for _, field := range fields {
+		claimed := r.FormValue(field)
+		if claimed == "" {
+			continue
+		}
+		if id, err := strconv.Atoi(claimed); err != nil || id != user.ID {
+			log.Printf("User %d tried to act as %q", user.ID, claimed)
+			err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+			ErrHandler(w, r, &err)
+			return CurrentUser{}, false
+		}
+	}
+	return user, true
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+)
+
+// diffPart is a run of text two versions share, or that only the older
+// (Removed) or newer (Added) one has.
+type diffPart struct {
+	Text    string `json:"text"`
+	Added   bool   `json:"added,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+}
+
+// maxDiffCells caps the comparison table. Texts that differ over a larger
+// stretch are shown as one removal followed by one addition.
+const maxDiffCells = 1 << 22
+
+// diffTokens splits text into words and the whitespace between them.
+var diffTokens = regexp.MustCompile(`\S+|\s+`)
+
+// diffWords compares two versions of a text word by word.
+func diffWords(before, after string) []diffPart {
+	a := diffTokens.FindAllString(before, -1)
+	b := diffTokens.FindAllString(after, -1)
+
+	var head, tail []diffPart
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		head = appendDiff(head, diffPart{Text: a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		tail = appendDiff([]diffPart{{Text: a[len(a)-1]}}, tail...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	parts := head
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, t := range a {
+			parts = appendDiff(parts, diffPart{Text: t, Removed: true})
+		}
+		for _, t := range b {
+			parts = appendDiff(parts, diffPart{Text: t, Added: true})
+		}
+		return appendDiff(parts, tail...)
+	}
+
+	// lcs[i][j] is the weight of the heaviest common subsequence of a[i:]
+	// and b[j:]. Words weigh more than the whitespace between them, so that
+	// the diff keeps words in common rather than the spaces around them.
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			if a[i] == b[j] {
+				lcs[i][j] = max(lcs[i][j], lcs[i+1][j+1]+tokenWeight(a[i]))
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j] && lcs[i][j] == lcs[i+1][j+1]+tokenWeight(a[i]):
+			parts = appendDiff(parts, diffPart{Text: a[i]})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			parts = appendDiff(parts, diffPart{Text: a[i], Removed: true})
+			i++
+		default:
+			parts = appendDiff(parts, diffPart{Text: b[j], Added: true})
+			j++
+		}
+	}
+	return appendDiff(parts, tail...)
+}
+
+// tokenWeight is how much keeping token in common counts for.
+func tokenWeight(token string) int {
+	if strings.TrimSpace(token) == "" {
+		return 1
+	}
+	return 2
+}
+
+// appendDiff appends parts, merging each into the last one when they are of
+// the same kind.
+func appendDiff(list []diffPart, parts ...diffPart) []diffPart {
+	for _, p := range parts {
+		if n := len(list); n > 0 && list[n-1].Added == p.Added && list[n-1].Removed == p.Removed {
+			list[n-1].Text += p.Text
+			continue
+		}
+		list = append(list, p)
+	}
+	return list
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          []diffPart
+	}{
+		{"", "", nil},
+		{"same text", "same text", []diffPart{{Text: "same text"}}},
+		{"", "new", []diffPart{{Text: "new", Added: true}}},
+		{"old", "", []diffPart{{Text: "old", Removed: true}}},
+		{"a c", "a b c", []diffPart{{Text: "a "}, {Text: "b ", Added: true}, {Text: "c"}}},
+		{"a b c", "a c", []diffPart{{Text: "a "}, {Text: "b ", Removed: true}, {Text: "c"}}},
+		{"the cat sat", "the dog sat", []diffPart{
+			{Text: "the "}, {Text: "cat", Removed: true}, {Text: "dog", Added: true}, {Text: " sat"},
+		}},
+		{"one two three", "zero one three four", []diffPart{
+			{Text: "zero ", Added: true}, {Text: "one "}, {Text: "two ", Removed: true},
+			{Text: "three"}, {Text: " four", Added: true},
+		}},
+		{"a  b", "a b", []diffPart{{Text: "a"}, {Text: "  ", Removed: true}, {Text: " ", Added: true}, {Text: "b"}}},
+		{"line one\nline two", "line one\nline 2", []diffPart{
+			{Text: "line one\nline "}, {Text: "two", Removed: true}, {Text: "2", Added: true},
+		}},
+	}
+	for _, tt := range tests {
+		if got := diffWords(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("diffWords(%q, %q) = %+v, want %+v", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestDiffWordsFallback(t *testing.T) {
+	words := func(prefix string, n int) string {
+		w := make([]string, n)
+		for i := range w {
+			w[i] = fmt.Sprintf("%s%d", prefix, i)
+		}
+		return strings.Join(w, " ")
+	}
+
+	// Past the cap the changed stretch is one removal and one addition, even
+	// though the spaces in it are the same.
+	before, after := words("old", 2000), words("new", 2000)
+	want := []diffPart{
+		{Text: "start "},
+		{Text: before, Removed: true},
+		{Text: after, Added: true},
+		{Text: " end"},
+	}
+	if got := diffWords("start "+before+" end", "start "+after+" end"); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffWords past the cap gave %d parts, want %d", len(got), len(want))
+	}
+
+	// The same edit in a short text keeps the spaces in common.
+	got := diffWords(words("old", 2), words("new", 2))
+	want = []diffPart{
+		{Text: "old0", Removed: true}, {Text: "new0", Added: true},
+		{Text: " "},
+		{Text: "old1", Removed: true}, {Text: "new1", Added: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffWords under the cap = %+v, want %+v", got, want)
+	}
+
+	// Long texts with a small change around the middle stay word by word,
+	// as the common start and end are skipped before counting.
+	long := words("w", 5000)
+	got = diffWords(long+" x "+long, long+" y "+long)
+	want = []diffPart{{Text: long + " "}, {Text: "x", Removed: true}, {Text: "y", Added: true}, {Text: " " + long}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffWords of long texts with one change gave %d parts, want %d", len(got), len(want))
+	}
+}
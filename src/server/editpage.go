@@ -0,0 +1,244 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/permission"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EditPost saves a new title and content for a post. Authors can edit their
+// own posts and moderators anyone's; the replaced version is kept for the
+// revision history.
+func (app *App) EditPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		log.Println("Method not allowed")
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		log.Println("Invalid post ID")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	title := strings.TrimSpace(r.FormValue("title"))
+	content := strings.TrimSpace(r.FormValue("content"))
+	if content == "" {
+		log.Println("Missing post content")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	user, ok := actingUser(w, r)
+	if !ok {
+		return
+	}
+	post, err := app.Repo.GetPostByID(postID)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "POST NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Error fetching post:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if !user.Permissions.HasOn(permission.PostEdit, user.ID, post.UserUserID) {
+		log.Printf("User %d may not edit post %d", user.ID, postID)
+		err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	categories, err := app.Repo.GetCategoriesForPost(postID)
+	if err != nil {
+		log.Println("Error fetching post categories:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if postLength(content) > app.maxPostLength(categories) {
+		log.Println("Post content exceeds the character limit")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	if err := app.Repo.UpdatePost(postID, user.ID, title, content); err != nil {
+		log.Println("Error editing post:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, "/post?id="+strconv.Itoa(postID), http.StatusSeeOther)
+}
+
+// EditComment is EditPost for comments.
+func (app *App) EditComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		log.Println("Method not allowed")
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	commentID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		log.Println("Invalid comment ID")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	content := strings.TrimSpace(r.FormValue("content"))
+	if content == "" {
+		log.Println("Missing comment content")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	user, ok := actingUser(w, r)
+	if !ok {
+		return
+	}
+	comment, err := app.Repo.GetCommentByID(commentID)
+	if err == sql.ErrNoRows || (err == nil && comment.Deleted) {
+		err := ErrorPageData{Code: "404", ErrorMsg: "COMMENT NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Error fetching comment:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if !user.Permissions.HasOn(permission.CommentEdit, user.ID, comment.UserID) {
+		log.Printf("User %d may not edit comment %d", user.ID, commentID)
+		err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	if err := app.Repo.UpdateComment(commentID, user.ID, content); err != nil {
+		log.Println("Error editing comment:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	back := r.Header.Get("Referer")
+	if back == "" {
+		back = "/post?id=" + strconv.Itoa(comment.PostID)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// revisionView is one version on the history page, compared with the
+// version before it.
+type revisionView struct {
+	database.Version
+	Number      int
+	TitleDiff   []diffPart
+	ContentDiff []diffPart
+}
+
+// newRevisionViews compares each version with the one before it and returns
+// them newest first.
+func newRevisionViews(versions []database.Version) []revisionView {
+	views := make([]revisionView, len(versions))
+	for i, v := range versions {
+		view := revisionView{Version: v, Number: i + 1}
+		if i == 0 {
+			view.TitleDiff = []diffPart{{Text: v.Title}}
+			view.ContentDiff = []diffPart{{Text: v.Content}}
+		} else {
+			view.TitleDiff = diffWords(versions[i-1].Title, v.Title)
+			view.ContentDiff = diffWords(versions[i-1].Content, v.Content)
+		}
+		views[len(versions)-1-i] = view
+	}
+	return views
+}
+
+// RevisionsPage shows every version of a post (?post=ID) or comment
+// (?comment=ID), each with what changed since the one before.
+func (app *App) RevisionsPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not allowed")
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	var versions []database.Version
+	var what, back string
+	query := r.URL.Query()
+	if id := query.Get("comment"); id != "" {
+		commentID, err := strconv.Atoi(id)
+		if err != nil {
+			log.Println("Invalid comment ID")
+			err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		comment, err := app.Repo.GetCommentByID(commentID)
+		if err == nil {
+			versions, err = app.Repo.GetCommentHistory(commentID)
+		}
+		if err == sql.ErrNoRows {
+			err := ErrorPageData{Code: "404", ErrorMsg: "COMMENT NOT FOUND"}
+			ErrHandler(w, r, &err)
+			return
+		} else if err != nil {
+			log.Println("Error fetching comment history:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		what = "comment"
+		back = "/post?id=" + strconv.Itoa(comment.PostID) + "&thread=" + strconv.Itoa(commentID)
+	} else {
+		postID, err := strconv.Atoi(query.Get("post"))
+		if err != nil {
+			log.Println("Invalid post ID")
+			err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		versions, err = app.Repo.GetPostHistory(postID)
+		if err == sql.ErrNoRows {
+			err := ErrorPageData{Code: "404", ErrorMsg: "POST NOT FOUND"}
+			ErrHandler(w, r, &err)
+			return
+		} else if err != nil {
+			log.Println("Error fetching post history:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		what = "post"
+		back = "/post?id=" + strconv.Itoa(postID)
+	}
+
+	data := struct {
+		What      string
+		Back      string
+		Revisions []revisionView
+	}{
+		What:      what,
+		Back:      back,
+		Revisions: newRevisionViews(versions),
+	}
+	if err := templates.ExecuteTemplate(w, "revisions.html", data); err != nil {
+		log.Println("Error executing template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"01connecthub/src/events"
+	"01connecthub/src/markdown"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const eventsKeepAlive = 25 * time.Second
+
+// Events streams live updates to the logged in user as Server-Sent Events:
+// their own notifications always, new comments and reaction counts for
+// ?post=ID, and reaction counts for every post with ?feed=1.
+func (app *App) Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Authenticated here rather than by AuthMiddleware, since a browser's
+	// EventSource gives up on an error status but would follow the redirect.
+	user, err := app.authenticate(w, r)
+	if err == errNotSignedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	} else if err == errTokenScope {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	} else if err != nil {
+		log.Println("Error authenticating event stream:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	topics := []string{events.UserTopic(user.ID)}
+	if post := r.URL.Query().Get("post"); post != "" {
+		postID, err := strconv.Atoi(post)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		topics = append(topics, events.PostTopic(postID))
+	}
+	if r.URL.Query().Get("feed") != "" {
+		topics = append(topics, events.FeedTopic)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("Streaming not supported by response writer")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	sub := app.Hub.Subscribe(topics...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped by the hub for falling behind; the browser reconnects.
+				return
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				log.Println("Error encoding event:", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// publishPostCounts pushes a post's current like, dislike and comment counts to
+// anyone viewing it or a feed.
+func (app *App) publishPostCounts(postID int) {
+	counts, err := app.Repo.GetPostCounts(postID)
+	if err != nil {
+		log.Println("Failed to fetch post counts:", err)
+		return
+	}
+	app.Hub.Publish(events.Event{Name: "post_counts", Data: counts}, events.PostTopic(postID), events.FeedTopic)
+}
+
+func (app *App) publishCommentCounts(commentID int) {
+	counts, err := app.Repo.GetCommentCounts(commentID)
+	if err != nil {
+		log.Println("Failed to fetch comment counts:", err)
+		return
+	}
+	app.Hub.Publish(events.Event{Name: "comment_counts", Data: counts}, events.PostTopic(counts.PostID))
+}
+
+type pushedComment struct {
+	ID        int    `json:"id"`
+	PostID    int    `json:"post_id"`
+	ParentID  int    `json:"parent_id,omitempty"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+	Avatar    string `json:"avatar"`
+	Content   string `json:"content"`
+	// ContentHTML is Content rendered from Markdown and sanitized.
+	ContentHTML string `json:"content_html"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// publishComment pushes a new comment to everyone viewing its post.
+func (app *App) publishComment(commentID int) {
+	comment, err := app.Repo.GetCommentByID(commentID)
+	if err != nil {
+		log.Println("Failed to fetch comment:", err)
+		return
+	}
+	app.Hub.Publish(events.Event{Name: "comment", Data: pushedComment{
+		ID:          comment.ID,
+		PostID:      comment.PostID,
+		ParentID:    comment.ParentID,
+		FirstName:   comment.FirstName,
+		LastName:    comment.LastName,
+		Username:    comment.Username,
+		Avatar:      comment.Avatar.String,
+		Content:     comment.Content,
+		ContentHTML: string(markdown.Render(comment.Content)),
+		CreatedAt:   comment.CreatedAt.Format("02/01/2006 - 15:04"),
+	}}, events.PostTopic(comment.PostID))
+	app.publishPostCounts(comment.PostID)
+}
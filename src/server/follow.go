@@ -0,0 +1,182 @@
+package server
+
+import (
+	"01connecthub/database"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func (app *App) Follow(w http.ResponseWriter, r *http.Request) {
+	viewerID, targetID, ok := app.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Repo.FollowUser(viewerID, targetID)
+	if err != nil {
+		log.Println("Failed to follow user:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if err := app.Notify.Followed(viewerID, targetID); err != nil {
+		log.Println("Failed to create notification:", err)
+	}
+
+	redirectBack(w, r, targetID)
+}
+
+func (app *App) Unfollow(w http.ResponseWriter, r *http.Request) {
+	viewerID, targetID, ok := app.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Repo.UnfollowUser(viewerID, targetID)
+	if err != nil {
+		log.Println("Failed to unfollow user:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	redirectBack(w, r, targetID)
+}
+
+func (app *App) FriendRequest(w http.ResponseWriter, r *http.Request) {
+	viewerID, targetID, ok := app.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := app.Repo.GetFriendStatus(viewerID, targetID)
+	if err != nil {
+		log.Println("Failed to fetch friend status:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.SendFriendRequest(viewerID, targetID)
+	if err != nil {
+		log.Println("Failed to send friend request:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	// A request to someone who already asked us is an acceptance.
+	switch status {
+	case database.FriendStatusNone:
+		err = app.Notify.FriendRequested(viewerID, targetID)
+	case database.FriendStatusRequestReceived:
+		err = app.Notify.FriendAccepted(viewerID, targetID)
+	}
+	if err != nil {
+		log.Println("Failed to create notification:", err)
+	}
+
+	redirectBack(w, r, targetID)
+}
+
+func (app *App) FriendAccept(w http.ResponseWriter, r *http.Request) {
+	viewerID, senderID, ok := app.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Repo.AcceptFriendRequest(viewerID, senderID)
+	if err == sql.ErrNoRows {
+		log.Println("No pending friend request from user:", senderID)
+		err := ErrorPageData{Code: "404", ErrorMsg: "FRIEND REQUEST NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to accept friend request:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if err := app.Notify.FriendAccepted(viewerID, senderID); err != nil {
+		log.Println("Failed to create notification:", err)
+	}
+
+	redirectBack(w, r, senderID)
+}
+
+func (app *App) FriendDecline(w http.ResponseWriter, r *http.Request) {
+	viewerID, senderID, ok := app.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Repo.DeclineFriendRequest(viewerID, senderID)
+	if err == sql.ErrNoRows {
+		log.Println("No pending friend request from user:", senderID)
+		err := ErrorPageData{Code: "404", ErrorMsg: "FRIEND REQUEST NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to decline friend request:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	redirectBack(w, r, senderID)
+}
+
+// relationshipRequest validates a follow or friend request: it must be a POST
+// from a logged in user naming another existing user in the "user" field.
+// When it returns false the error response has already been written.
+func (app *App) relationshipRequest(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	if r.Method != "POST" {
+		log.Println("Method not allowed")
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return 0, 0, false
+	}
+
+	viewer, ok := actingUser(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+
+	targetID, err := strconv.Atoi(r.FormValue("user"))
+	if err != nil || targetID == viewer.ID {
+		log.Println("Invalid target user:", r.FormValue("user"))
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return 0, 0, false
+	}
+
+	_, err = app.Repo.GetUserByID(targetID)
+	if err == sql.ErrNoRows {
+		log.Println("No user found with the given ID:", targetID)
+		err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return 0, 0, false
+	} else if err != nil {
+		log.Println("Failed to fetch user data:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return 0, 0, false
+	}
+
+	return viewer.ID, targetID, true
+}
+
+// redirectBack returns the user to the page the form was posted from, falling
+// back to the other user's profile. Only the path of the referer is kept so the
+// redirect never leaves the site.
+func redirectBack(w http.ResponseWriter, r *http.Request, userID int) {
+	target := fmt.Sprintf("/profile?user=%d", userID)
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Path != "" {
+		target = ref.RequestURI()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
@@ -2,7 +2,10 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/markdown"
+	"01connecthub/src/permission"
 	"database/sql"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
@@ -17,8 +20,61 @@ var (
 	templates *template.Template
 )
 
-func init() {
-	templates = template.Must(template.ParseGlob(filepath.Join("templates", "*.html")))
+// templateFuncs lets pages show controls only to users allowed to use them:
+// {{if can .Perms "category.manage"}} or, for things users own,
+// {{if canOn $.Perms "post.delete" $.UserID .UserUserID}}. markdown and
+// plaintext show post and comment content, highlight search snippets, and
+// feedPreview and fileSize their attachments.
+var templateFuncs = template.FuncMap{
+	"can": func(perms permission.Set, name string) bool {
+		return perms.Has(permission.Permission(name))
+	},
+	"canOn": func(perms permission.Set, action string, userID, ownerID int) bool {
+		return perms.HasOn(permission.Action(action), userID, ownerID)
+	},
+	"markdown":    markdown.Render,
+	"plaintext":   markdown.PlainText,
+	"highlight":   highlight,
+	"feedPreview": newFeedPreview,
+	"fileSize":    fileSize,
+}
+
+// feedPreviewImages is how many of a post's images a feed shows.
+const feedPreviewImages = 4
+
+// feedPreview is the images a feed shows for a post and how many more it has.
+type feedPreview struct {
+	Images []database.Attachment
+	More   int
+}
+
+func newFeedPreview(images []database.Attachment) feedPreview {
+	if len(images) <= feedPreviewImages {
+		return feedPreview{Images: images}
+	}
+	return feedPreview{Images: images[:feedPreviewImages], More: len(images) - feedPreviewImages}
+}
+
+// fileSize writes a size in bytes the way people read it, such as "1.4 MB".
+func fileSize(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%d KB", size>>10)
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
+
+// LoadTemplates parses the page templates in dir. It must be called before
+// the server handles any request.
+func LoadTemplates(dir string) error {
+	t, err := template.New("").Funcs(templateFuncs).ParseGlob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return fmt.Errorf("LoadTemplates: %v", err)
+	}
+	templates = t
+	return nil
 }
 
 type ErrorPageData struct {
@@ -35,6 +91,10 @@ type SearchResult struct {
 	Content    string `json:"content,omitempty"`
 	Username   string `json:"username,omitempty"`
 	CategoryID int    `json:"category_id,omitempty"`
+	// PostID is the post a comment is on. Snippet is the matching text as
+	// HTML, escaped, with the matched words in <mark>.
+	PostID  int    `json:"post_id,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
 }
 type PageData struct {
 	HasSession      bool
@@ -52,16 +112,38 @@ type PageData struct {
 	TotalUsers      int
 	TotalCategories int
 	UserLogs        []database.UserLog
-	UserSessions    []database.UserSession
+	UserSessions    []SessionView
+	APITokens       []TokenView
+	ReactionTypes   []database.ReactionType
 	Notifications   []database.Notification
+	UnreadCount     int
+	CSRFToken       string
+	Perms           permission.Set
 	RoleID          int
 	Post            database.Post
 	Comments        []database.Comment
 	SelectedTab     string
 	SelectedFilter  string
-	ImageBase64     string
 	UserRoleName    string
 	SearchQuery     string
+	// Next*Page link to the next page of a list, or are empty on the last.
+	NextPostsPage    string
+	NextUsersPage    string
+	NextCommentsPage string
+	// ThreadID is the comment a thread view of a post starts from, or zero
+	// when showing all of its comments.
+	ThreadID int
+	// MaxLength is the most characters the post being shown may have, or on
+	// the admin dashboard the default for categories without a limit.
+	MaxLength int
+	// MailEnabled is whether saved searches can send email digests.
+	MailEnabled bool
+	// Collections are the user's bookmark collections and Collection the
+	// one being shown, if any. ShareLink opens it for others, and is only
+	// set for its owner.
+	Collections []database.Collection
+	Collection  database.Collection
+	ShareLink   string
 }
 
 func HashPassword(password string) (string, error) {
@@ -74,59 +156,23 @@ func VerifyPassword(password, hash string) bool {
 	return err == nil
 }
 
-func LikePost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
+func (app *App) DeletePost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "DELETE" {
 		log.Println("Method not allowed")
 		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	postID, err := strconv.Atoi(r.FormValue("post_id"))
-	if err != nil {
-		log.Println("Invalid post ID")
+	postID := r.FormValue("id")
+	if postID == "" {
+		log.Println("Post ID is missing")
 		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	userID, err := strconv.Atoi(r.FormValue("user"))
-	if err != nil {
-		log.Println("Error converting userID to int:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
-	err = database.ToggleLike(db, postID, userID)
-	if err != nil {
-		log.Println("Error toggling like:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	log.Println("Like toggled")
-	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
-}
-
-func DislikePost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		log.Println("Method not allowed")
-		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
-		ErrHandler(w, r, &err)
-		return
-	}
-
-	postID, err := strconv.Atoi(r.FormValue("post_id"))
+	postIDInt, err := strconv.Atoi(postID)
 	if err != nil {
 		log.Println("Invalid post ID")
 		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
@@ -134,79 +180,40 @@ func DislikePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := strconv.Atoi(r.FormValue("user"))
-	if err != nil {
-		log.Println("Error converting userID to int:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
+	user, ok := actingUser(w, r)
+	if !ok {
 		return
 	}
-
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+	post, err := app.Repo.GetPostByID(postIDInt)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "POST NOT FOUND"}
 		ErrHandler(w, r, &err)
 		return
-	}
-	defer db.Close()
-
-	err = database.ToggleDislike(db, postID, userID)
-	if err != nil {
-		log.Println("Error toggling dislike:", err)
+	} else if err != nil {
+		log.Println("Error fetching post:", err)
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
-	log.Println("Dislike toggled")
-	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
-}
-
-func LikeComment(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		log.Println("Method not allowed")
-		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+	if !user.Permissions.HasOn(permission.PostDelete, user.ID, post.UserUserID) {
+		log.Printf("User %d may not delete post %d", user.ID, postIDInt)
+		err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	commentID, err := strconv.Atoi(r.FormValue("comment_id"))
+	err = app.Repo.DeletePost(postIDInt, user.ID)
 	if err != nil {
-		log.Println("Invalid comment ID")
-		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
-		ErrHandler(w, r, &err)
-		return
-	}
-
-	userID, err := strconv.Atoi(r.FormValue("user"))
-	if err != nil {
-		log.Println("Error converting userID to int:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
+		log.Println("Error deleting post:", err)
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
-	defer db.Close()
 
-	err = database.ToggleCommentLike(db, commentID, userID)
-	if err != nil {
-		log.Println("Error toggling like:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	log.Println("Like toggled")
-	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+	http.Redirect(w, r, "/home", http.StatusSeeOther)
 }
 
-func DislikeComment(w http.ResponseWriter, r *http.Request) {
+func (app *App) ReportPost(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		log.Println("Method not allowed")
 		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
@@ -214,112 +221,87 @@ func DislikeComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	commentID, err := strconv.Atoi(r.FormValue("comment_id"))
-	if err != nil {
-		log.Println("Invalid comment ID")
+	postID := r.FormValue("id")
+	if postID == "" {
+		log.Println("Post ID is missing")
 		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	userID, err := strconv.Atoi(r.FormValue("user"))
+	postIDInt, err := strconv.Atoi(postID)
 	if err != nil {
-		log.Println("Error converting userID to int:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		log.Println("Invalid post ID")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
+	user, ok := actingUser(w, r, "user")
+	if !ok {
 		return
 	}
-	defer db.Close()
-
-	err = database.ToggleCommentDislike(db, commentID, userID)
+	reportedBy := user.ID
+	err = app.Repo.InsertReport(postIDInt, reportedBy, "Reported by moderator")
 	if err != nil {
-		log.Println("Error toggling dislike:", err)
+		log.Println("Error reporting post:", err)
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
-	log.Println("Dislike toggled")
-	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+	if err := app.Notify.PostReported(reportedBy, postIDInt); err != nil {
+		log.Println("Failed to create notification:", err)
+	}
+
+	http.Redirect(w, r, "/home", http.StatusSeeOther)
 }
 
-func DeletePost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
+func (app *App) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "DELETE" {
 		log.Println("Method not allowed")
 		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	postID := r.URL.Query().Get("id")
-	if postID == "" {
-		log.Println("Post ID is missing")
-		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
-		ErrHandler(w, r, &err)
-		return
-	}
-
-	db, err := sql.Open("sqlite3", "./database/main.db")
+	commentID, err := strconv.Atoi(r.FormValue("id"))
 	if err != nil {
-		log.Println("Error opening database:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		log.Println("Invalid comment ID")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 		ErrHandler(w, r, &err)
 		return
 	}
-	defer db.Close()
 
-	_, err = db.Exec("DELETE FROM post WHERE postid = ?", postID)
-	if err != nil {
-		log.Println("Error deleting post:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
+	user, ok := actingUser(w, r)
+	if !ok {
 		return
 	}
-
-	http.Redirect(w, r, "/home", http.StatusSeeOther)
-}
-
-func ReportPost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		log.Println("Method not allowed")
-		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+	comment, err := app.Repo.GetCommentByID(commentID)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "COMMENT NOT FOUND"}
 		ErrHandler(w, r, &err)
 		return
-	}
-
-	postID := r.URL.Query().Get("id")
-	if postID == "" {
-		log.Println("Post ID is missing")
-		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+	} else if err != nil {
+		log.Println("Error fetching comment:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
-
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+	if !user.Permissions.HasOn(permission.CommentDelete, user.ID, comment.UserID) {
+		log.Printf("User %d may not delete comment %d", user.ID, commentID)
+		err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
 		ErrHandler(w, r, &err)
 		return
 	}
-	defer db.Close()
 
-	_, err = db.Exec("INSERT INTO reports (post_id, reported_by, report_reason) VALUES (?, ?, ?)", postID, r.URL.Query().Get("user"), "Reported by moderator")
-	if err != nil {
-		log.Println("Error reporting post:", err)
+	if err := app.Repo.DeleteComment(commentID, user.ID); err != nil {
+		log.Println("Error deleting comment:", err)
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	http.Redirect(w, r, "/home", http.StatusSeeOther)
+	http.Redirect(w, r, "/post?id="+strconv.Itoa(comment.PostID), http.StatusSeeOther)
 }
 
 func CheckFilter(filter string, categoryNames []string) bool {
@@ -2,15 +2,14 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/permission"
 	"database/sql"
-	"encoding/base64"
-	"fmt"
 	"log"
 	"net/http"
-	"time"
+	"slices"
 )
 
-func HomePage(w http.ResponseWriter, r *http.Request) {
+func (app *App) HomePage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/home" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -25,56 +24,10 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
+	user, hasSession := CurrentUserFrom(r)
+	userID, userName := user.ID, user.Username
 
-	var hasSession bool
-	var userID int
-	var userName string
-	seshCok, err := r.Cookie("session_token")
-	if err != nil {
-		fmt.Println("No cookie found, treated as guest")
-	} else if seshCok.Value == "" {
-		hasSession = false
-	} else {
-		hasSession = true
-
-		seshVal := seshCok.Value
-
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
-		if err == sql.ErrNoRows {
-			http.SetCookie(w, &http.Cookie{
-				Name:     "session_token",
-				Value:    "",
-				Expires:  time.Now().Add(-time.Hour),
-				HttpOnly: true,
-			})
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-		} else if err != nil {
-			log.Println("Error fetching userid ID from user table:", err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
-		} else {
-			log.Println("User is logged in:", userName)
-		}
-	}
-
-	users, err := database.GetAllUsers(db)
-	if err != nil {
-		log.Println("Failed to fetch users:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-
-	categories, err := database.GetAllCategories(db)
+	categories, err := app.Repo.GetAllCategories()
 	if err != nil {
 		log.Println("Failed to fetch categories:", err)
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -88,22 +41,10 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var posts []database.Post
-
-	allPosts, err := database.GetAllPosts(db)
-	if err != nil {
-		log.Println("Failed to fetch posts:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-
-	for i, post := range allPosts {
-		if post.Image.Valid {
-			allPosts[i].ImageBase64 = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
-		}
-	}
-
-	posts = allPosts
+	var nextPosts string
+	var collections []database.Collection
+	var collection database.Collection
+	page := pageFrom(r, "after")
 
 	filter := r.URL.Query().Get("filter")
 	selectedTab := r.URL.Query().Get("tab")
@@ -128,17 +69,19 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 	case "posts":
 		switch filter {
 		case "all":
-			posts = allPosts
+			posts, nextPosts, err = app.Repo.GetAllPosts(page)
+			if err != nil {
+				listError(w, r, "Failed to fetch posts:", err)
+				return
+			}
 		case "following":
 			if !hasSession {
 				http.Redirect(w, r, "/", http.StatusSeeOther)
 				return
 			}
-			posts, err = database.GetFollowingPosts(db, userID)
+			posts, nextPosts, err = app.Repo.GetFollowingPosts(userID, page)
 			if err != nil {
-				log.Println("Failed to fetch following posts:", err)
-				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &err)
+				listError(w, r, "Failed to fetch following posts:", err)
 				return
 			}
 		case "friends":
@@ -146,27 +89,15 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 				http.Redirect(w, r, "/", http.StatusSeeOther)
 				return
 			}
-			posts, err = database.GetFriendsPosts(db, userID)
+			posts, nextPosts, err = app.Repo.GetFriendsPosts(userID, page)
 			if err != nil {
-				log.Println("Failed to fetch friends' posts:", err)
-				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &err)
+				listError(w, r, "Failed to fetch friends' posts:", err)
 				return
 			}
-		case "top-rated":
-			posts, err = database.GetFilteredPosts(db, filter)
+		case "top-rated", "oldest":
+			posts, nextPosts, err = app.Repo.GetFilteredPosts(filter, page)
 			if err != nil {
-				log.Println("Failed to fetch posts:", err)
-				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &err)
-				return
-			}
-		case "oldest":
-			posts, err = database.GetFilteredPosts(db, filter)
-			if err != nil {
-				log.Println("Failed to fetch posts:", err)
-				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &err)
+				listError(w, r, "Failed to fetch posts:", err)
 				return
 			}
 		default:
@@ -178,13 +109,15 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 	case "tags":
 
 		if filter == "all" {
-			posts = allPosts
+			posts, nextPosts, err = app.Repo.GetAllPosts(page)
+			if err != nil {
+				listError(w, r, "Failed to fetch posts:", err)
+				return
+			}
 		} else if CheckFilter(filter, categoryNames) {
-			posts, err = database.GetPostsByCategory(db, filter)
+			posts, nextPosts, err = app.Repo.GetPostsByCategory(filter, page)
 			if err != nil {
-				log.Println("Failed to fetch posts:", err)
-				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &err)
+				listError(w, r, "Failed to fetch posts:", err)
 				return
 			}
 		} else {
@@ -198,20 +131,10 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 
 		switch filter {
 
-		case "newest":
-			posts, err = database.GetUserPosts(db, userID, filter)
-			if err != nil {
-				log.Println("Failed to fetch posts:", err)
-				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &err)
-				return
-			}
-		case "oldest":
-			posts, err = database.GetUserPosts(db, userID, filter)
+		case "newest", "oldest":
+			posts, nextPosts, err = app.Repo.GetUserPosts(userID, filter, page)
 			if err != nil {
-				log.Println("Failed to fetch posts:", err)
-				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &err)
+				listError(w, r, "Failed to fetch posts:", err)
 				return
 			}
 		default:
@@ -223,7 +146,7 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 
 	case "your+replies":
 
-		posts, err = database.GetUserCommentedPosts(db, userID, filter)
+		posts, err = app.Repo.GetUserCommentedPosts(userID, filter)
 		if err != nil {
 			log.Println("Failed to fetch posts:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -235,7 +158,7 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 		switch filter {
 
 		case "likes":
-			posts, err = database.GetUserReaction(db, userID, filter)
+			posts, err = app.Repo.GetUserReaction(userID, filter)
 			if err != nil {
 				log.Println("Failed to fetch posts:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -243,7 +166,7 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		case "dislikes":
-			posts, err = database.GetUserReaction(db, userID, filter)
+			posts, err = app.Repo.GetUserReaction(userID, filter)
 			if err != nil {
 				log.Println("Failed to fetch posts:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -257,6 +180,59 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+	case "saved":
+		collections, err = app.Repo.GetUserCollections(userID)
+		if err != nil {
+			log.Println("Failed to fetch collections:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		if filter == "all" {
+			posts, nextPosts, err = app.Repo.GetBookmarkedPosts(userID, page)
+			if err != nil {
+				listError(w, r, "Failed to fetch bookmarked posts:", err)
+				return
+			}
+		} else if i := slices.IndexFunc(collections, func(c database.Collection) bool { return c.Name == filter }); i >= 0 {
+			collection = collections[i]
+			posts, nextPosts, err = app.Repo.GetCollectionPosts(collection.ID, page)
+			if err != nil {
+				listError(w, r, "Failed to fetch collection posts:", err)
+				return
+			}
+		} else {
+			log.Println("Invalid filter selected", err)
+			err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+	case "collection":
+		// A shared collection, where the filter is its share token.
+		if !hasSession {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		collection, err = app.Repo.GetCollectionByToken(filter)
+		if err == sql.ErrNoRows || (err == nil && !collection.Public && collection.UserID != userID) {
+			log.Println("No shared collection found for the given link")
+			err := ErrorPageData{Code: "404", ErrorMsg: "COLLECTION NOT FOUND"}
+			ErrHandler(w, r, &err)
+			return
+		} else if err != nil {
+			log.Println("Failed to fetch collection:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		posts, nextPosts, err = app.Repo.GetCollectionPosts(collection.ID, page)
+		if err != nil {
+			listError(w, r, "Failed to fetch collection posts:", err)
+			return
+		}
+
 	default:
 		log.Println("Invalid tab selected", err)
 		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
@@ -267,7 +243,7 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 	if hasSession {
 		var avatar sql.NullString
 		var roleID int
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		avatar, roleID, err = app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -280,17 +256,26 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var roleName string
-		if roleID == 1 {
-			roleName = "Admin"
-		} else if roleID == 2 {
-			roleName = "Moderator"
-		} else {
-			roleName = "User"
+		roleName, err := app.Repo.GetRoleNameByID(roleID)
+		if err != nil {
+			log.Println("Failed to fetch role name:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		// /home is open to guests, so it is not behind AuthMiddleware and has to
+		// look the permissions up itself.
+		permissions, err := app.Repo.GetRolePermissions(roleID)
+		if err != nil {
+			log.Println("Failed to fetch permissions:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
 		}
 
 		var totalLikes, totalPosts int
-		err = db.QueryRow("SELECT COUNT(*) FROM likes WHERE user_userID = ?", userID).Scan(&totalLikes)
+		totalLikes, err = app.Repo.GetTotalLikes(userID)
 		if err != nil {
 			log.Println("Failed to fetch total likes:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -298,7 +283,7 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userID = ?", userID).Scan(&totalPosts)
+		totalPosts, err = app.Repo.GetTotalPosts(userID)
 		if err != nil {
 			log.Println("Failed to fetch total posts:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -306,7 +291,7 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		notifications, err := database.GetLastNotifications(db, userID)
+		notifications, err := app.Repo.GetLastNotifications(userID)
 		if err != nil {
 			log.Println("Failed to fetch notifications:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -314,6 +299,26 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		unreadCount, err := app.Repo.CountUnreadNotifications(userID)
+		if err != nil {
+			log.Println("Failed to count unread notifications:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		if err := app.Repo.AttachPostBookmarks(posts, userID); err != nil {
+			log.Println("Failed to fetch bookmarks:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		var shareLink string
+		if collection.ID != 0 && collection.UserID == userID {
+			shareLink = app.shareLink(collection)
+		}
+
 		data := PageData{
 			HasSession:     hasSession,
 			UserID:         userID,
@@ -323,12 +328,18 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 			TotalLikes:     totalLikes,
 			TotalPosts:     totalPosts,
 			Categories:     categories,
-			Users:          users,
 			Posts:          posts,
+			NextPostsPage:  nextPageURL(r, "after", nextPosts),
 			SelectedTab:    selectedTab,
 			SelectedFilter: filter,
 			Notifications:  notifications,
+			UnreadCount:    unreadCount,
+			CSRFToken:      csrfToken(r),
+			Perms:          permission.NewSet(permissions),
 			RoleID:         roleID,
+			Collections:    collections,
+			Collection:     collection,
+			ShareLink:      shareLink,
 		}
 
 		err = templates.ExecuteTemplate(w, "home.html", data)
@@ -354,8 +365,8 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 			UserID:         userID,
 			UserName:       userName,
 			Categories:     categories,
-			Users:          users,
 			Posts:          posts,
+			NextPostsPage:  nextPageURL(r, "after", nextPosts),
 			SelectedTab:    selectedTab,
 			SelectedFilter: filter,
 		}
@@ -1,14 +1,12 @@
 package server
 
 import (
-	UUID "01connecthub/src/security"
 	"database/sql"
 	"log"
 	"net/http"
-	"time"
 )
 
-func LoginPage(w http.ResponseWriter, r *http.Request) {
+func (app *App) LoginPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -16,27 +14,17 @@ func LoginPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
 	if r.Method == "POST" {
-		var userID int
-		var dbPassword, userName string
-		err = db.QueryRow("SELECT userid, password, username FROM user WHERE email = ?", email).Scan(&userID, &dbPassword, &userName)
+		credentials, err := app.Repo.GetUserCredentials(email)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				// No credentials found with the given email
 				err = templates.ExecuteTemplate(w, "index.html", map[string]interface{}{
-					"ErrorMsg": "Invalid email or password",
+					"ErrorMsg":  "Invalid email or password",
+					"CSRFToken": csrfToken(r),
 				})
 				if err != nil {
 					log.Println("Error rendering login page:", err)
@@ -51,9 +39,12 @@ func LoginPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if !VerifyPassword(password, dbPassword) {
+		userID := credentials.ID
+
+		if !VerifyPassword(password, credentials.Password) {
 			err := templates.ExecuteTemplate(w, "index.html", map[string]interface{}{
-				"ErrorMsg": "Invalid email or password",
+				"ErrorMsg":  "Invalid email or password",
+				"CSRFToken": csrfToken(r),
 			})
 			if err != nil {
 				log.Println("Error rendering login page:", err)
@@ -63,42 +54,8 @@ func LoginPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		sessionToken, err := UUID.GenerateToken()
-		if err != nil {
-			log.Println("Error generating UUID:", err)
-			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &errData)
-		}
-
-		stringToken := sessionToken.String()
-
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_token",
-			Value:    stringToken,
-			Expires:  time.Now().Add(1 * time.Hour),
-			HttpOnly: true,
-		})
-
-		result, err := db.Exec("UPDATE session SET sessionid = ? WHERE userid = ?", stringToken, userID)
-		if err != nil {
-			log.Println("Error updating session ID in session table:", err)
-			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &errData)
-			return
-		} else if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 { //only insert a new row if no record is updated (i.e., no session is found)
-			_, err := db.Exec("INSERT INTO session (sessionid, userid, endtime) VALUES (?, ?, ?) RETURNING sessionid",
-				stringToken, userID, time.Now().Add(1*time.Hour))
-			if err != nil {
-				log.Println("Error creating new session:", err)
-				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &errData)
-				return
-			}
-		}
-
-		_, err = db.Exec("UPDATE user SET current_session = ? WHERE userid = ?", stringToken, userID)
-		if err != nil {
-			log.Println("Error updating session ID in user table:", err)
+		if err := app.CreateSession(w, r, userID); err != nil {
+			log.Println("Error creating session:", err)
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &errData)
 			return
@@ -110,7 +67,7 @@ func LoginPage(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/home?tab=posts&filter=all", http.StatusSeeOther)
 	}
 
-	err = templates.ExecuteTemplate(w, "index.html", nil)
+	err := templates.ExecuteTemplate(w, "index.html", map[string]interface{}{"CSRFToken": csrfToken(r)})
 	if err != nil {
 		log.Println("Error rendering login page:", err)
 		errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -1,36 +1,33 @@
 package server
 
 import (
+	"01connecthub/src/security"
 	"database/sql"
 	"log"
 	"net/http"
-	"time"
 )
 
-func Logout(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("userID")
-
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Fatal(err)
+// Logout ends the session on this device only; see RevokeAllSessions for
+// signing out everywhere.
+func (app *App) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return
 	}
-	defer db.Close()
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: true,
-	})
-
-	_, err = db.Exec("DELETE FROM session WHERE userid = ?", userID)
-	if err != nil {
-		log.Fatal(err)
+	user, ok := actingUser(w, r, "userID")
+	if !ok {
+		return
 	}
 
-	_, err = db.Exec("UPDATE user SET current_session = NULL WHERE userid = ?", userID)
-	if err != nil {
-		log.Fatal(err)
+	security.ClearSessionCookie(w)
+
+	err := app.Repo.RevokeSession(user.ID, user.SessionID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("Failed to delete session:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
 	}
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
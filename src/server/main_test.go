@@ -0,0 +1,16 @@
+package server
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Tests run in this package's directory, two levels below the templates.
+	if err := LoadTemplates(filepath.Join("..", "..", "templates")); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
@@ -2,12 +2,11 @@ package server
 
 import (
 	"01connecthub/database"
-	"database/sql"
 	"log"
 	"net/http"
 )
 
-func MainPage(w http.ResponseWriter, r *http.Request) {
+func (app *App) MainPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -28,16 +27,7 @@ func MainPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
-	categories, err := database.GetAllCategories(db)
+	categories, err := app.Repo.GetAllCategories()
 	if err != nil {
 		log.Println("Failed to fetch categories")
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -56,11 +46,11 @@ func MainPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if selectedTab == "tags" && filter != "all" {
-		posts, err = database.GetPostsByCategory(db, filter)
+		posts, _, err = app.Repo.GetPostsByCategory(filter, database.Page{})
 	} else if filter == "all" {
-		posts, err = database.GetAllPosts(db)
+		posts, _, err = app.Repo.GetAllPosts(database.Page{})
 	} else {
-		posts, err = database.GetFilteredPosts(db, filter)
+		posts, _, err = app.Repo.GetFilteredPosts(filter, database.Page{})
 	}
 	if err != nil {
 		log.Println("Failed to fetch posts")
@@ -69,7 +59,7 @@ func MainPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := database.GetAllUsers(db)
+	users, _, err := app.Repo.GetAllUsers(database.Page{})
 	if err != nil {
 		log.Println("Failed to fetch users")
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/media"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// saveImage stores an uploaded image and returns its hash. It fails with
+// media.ErrUnsupported or media.ErrTooLarge for images that are refused.
+func (app *App) saveImage(data []byte) (string, error) {
+	upload, err := app.Media.Save(data)
+	if err != nil {
+		return "", err
+	}
+	variants := make(map[string]database.Media, len(upload.Variants))
+	for name, v := range upload.Variants {
+		variants[name] = database.Media(v)
+	}
+	if err := app.Repo.SaveMedia(database.Media(upload.File), variants); err != nil {
+		return "", err
+	}
+	return upload.Hash, nil
+}
+
+// saveFile stores an uploaded file that isn't an image and returns its hash.
+// It fails with media.ErrUnsupportedFile for files that are refused.
+func (app *App) saveFile(data []byte) (string, error) {
+	file, err := app.Media.SaveFile(data)
+	if err != nil {
+		return "", err
+	}
+	if err := app.Repo.SaveMedia(database.Media(file), nil); err != nil {
+		return "", err
+	}
+	return file.Hash, nil
+}
+
+// ServeMedia serves /media/{hash}, or with ?size=thumb or ?size=medium the
+// image scaled down to that size. Stored files never change, so browsers may
+// cache them for good and revalidate by ETag.
+func (app *App) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hash := r.PathValue("hash")
+	if !media.ValidHash(hash) {
+		http.NotFound(w, r)
+		return
+	}
+
+	m, err := app.Repo.GetMedia(hash, r.URL.Query().Get("size"))
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Println("Error fetching media:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	f, err := app.Media.Open(m.Hash)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Println("Media file missing:", m.Hash)
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Println("Error opening media:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", m.ContentType)
+	h.Set("ETag", `"`+m.Hash+`"`)
+	h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "default-src 'none'")
+	if !strings.HasPrefix(m.ContentType, "image/") {
+		// Other files are downloaded, under the name the page's link gives.
+		h.Set("Content-Disposition", "attachment")
+	}
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// MigrateImageBlobs moves post images still kept as BLOBs in the database
+// into the media store. Ones that aren't valid images are dropped.
+func (app *App) MigrateImageBlobs() error {
+	moved, dropped := 0, 0
+	for {
+		postID, data, err := app.Repo.NextPostImageBlob()
+		if err == sql.ErrNoRows {
+			break
+		} else if err != nil {
+			return err
+		}
+		hash, err := app.saveImage(data)
+		if errors.Is(err, media.ErrUnsupported) || errors.Is(err, media.ErrTooLarge) {
+			log.Printf("Dropping the image of post %d: %v", postID, err)
+			hash = ""
+			dropped++
+		} else if err != nil {
+			return err
+		} else {
+			moved++
+		}
+		if err := app.Repo.SetPostImage(postID, hash); err != nil {
+			return err
+		}
+	}
+	if moved+dropped > 0 {
+		log.Printf("Moved %d post images into the media store and dropped %d invalid ones", moved, dropped)
+	}
+	return nil
+}
@@ -1,63 +1,204 @@
 package server
 
 import (
+	"01connecthub/database"
+	"01connecthub/src/permission"
+	"01connecthub/src/security"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 )
 
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		db, err := sql.Open("sqlite3", "./database/main.db")
-		if err != nil {
-			log.Println("Database connection failed")
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
+const sessionRenewInterval = time.Minute
+
+var (
+	// errNotSignedIn means a request carries no valid session or API token.
+	errNotSignedIn = errors.New("not signed in")
+	// errTokenScope means the request's API token lacks the scope it needs.
+	errTokenScope = errors.New("token scope does not allow this request")
+)
+
+// authenticate resolves the user behind r. An "Authorization: Bearer" header
+// takes precedence and is checked as a personal access token; otherwise the
+// session cookie is used and the session's expiry slides forward. A missing,
+// empty or expired session gives errNotSignedIn, clearing the cookie if there
+// was one.
+func (app *App) authenticate(w http.ResponseWriter, r *http.Request) (CurrentUser, error) {
+	if token, ok := security.BearerToken(r); ok {
+		return app.authenticateToken(r, token)
+	}
+
+	seshCok, err := r.Cookie(security.SessionCookie)
+	if err != nil {
+		return CurrentUser{}, errNotSignedIn
+	}
+
+	seshVal := seshCok.Value
+
+	if seshVal == "" {
+		security.ClearSessionCookie(w)
+		return CurrentUser{}, errNotSignedIn
+	}
+
+	session, err := app.Repo.GetSession(seshVal)
+	if err == sql.ErrNoRows {
+		security.ClearSessionCookie(w)
+		return CurrentUser{}, errNotSignedIn
+	} else if err != nil {
+		return CurrentUser{}, fmt.Errorf("authenticate: %v", err)
+	}
+
+	user, err := app.loadCurrentUser(session.UserID)
+	if err != nil {
+		return CurrentUser{}, fmt.Errorf("authenticate: %v", err)
+	}
+	user.SessionID = session.ID
+
+	// Slide the expiry forward, but at most once per sessionRenewInterval so
+	// a burst of requests doesn't write on every one of them.
+	if time.Since(session.LastSeen) > sessionRenewInterval {
+		expiresAt := time.Now().Add(security.SessionLifetime)
+		if err := app.Repo.RenewSession(seshVal, expiresAt); err != nil {
+			log.Println("Failed to renew session:", err)
+		} else {
+			security.SetSessionCookie(w, seshVal, expiresAt)
 		}
-		defer db.Close()
+	}
 
-		seshCok, err := r.Cookie("session_token")
-		if err != nil {
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-			fmt.Println("Error fetching session cookie")
-			return
+	return user, nil
+}
+
+// authenticateToken resolves the user behind a personal access token. Safe
+// requests need the read scope and all others the write scope; the owner's
+// moderation permissions only come with the moderate scope.
+func (app *App) authenticateToken(r *http.Request, plaintext string) (CurrentUser, error) {
+	if plaintext == "" {
+		return CurrentUser{}, errNotSignedIn
+	}
+
+	token, err := app.Repo.GetAPITokenByHash(security.HashAPIToken(plaintext))
+	if err == sql.ErrNoRows {
+		return CurrentUser{}, errNotSignedIn
+	} else if err != nil {
+		return CurrentUser{}, fmt.Errorf("authenticateToken: %v", err)
+	}
+
+	needed := database.ScopeWrite
+	if safeMethod(r.Method) {
+		needed = database.ScopeRead
+	}
+	if !token.HasScope(needed) {
+		log.Printf("Token %d denied %s %s: missing scope %s", token.ID, r.Method, r.URL.Path, needed)
+		return CurrentUser{}, errTokenScope
+	}
+
+	user, err := app.loadCurrentUser(token.UserID)
+	if err != nil {
+		return CurrentUser{}, fmt.Errorf("authenticateToken: %v", err)
+	}
+	user.TokenID = token.ID
+	if !token.HasScope(database.ScopeModerate) {
+		user.Permissions = user.Permissions.Without(permission.Moderation...)
+	}
+
+	// Like sessions, record use at most once per sessionRenewInterval.
+	if !token.LastUsed.Valid || time.Since(token.LastUsed.Time) > sessionRenewInterval {
+		if err := app.Repo.TouchAPIToken(token.ID); err != nil {
+			log.Println("Failed to record token use:", err)
 		}
+	}
 
-		seshVal := seshCok.Value
+	return user, nil
+}
 
-		if seshVal == "" {
-			fmt.Println("Invalid session")
-			http.SetCookie(w, &http.Cookie{
-				Name:     "session_token",
-				Value:    "",
-				Expires:  time.Now().Add(-time.Hour),
-				HttpOnly: true,
-			})
+func (app *App) loadCurrentUser(userID int) (CurrentUser, error) {
+	user, err := app.Repo.GetUserByID(userID)
+	if err != nil {
+		return CurrentUser{}, fmt.Errorf("fetching user %d: %v", userID, err)
+	}
+	permissions, err := app.Repo.GetRolePermissions(user.RoleID)
+	if err != nil {
+		return CurrentUser{}, err
+	}
+	return CurrentUser{ID: user.ID, Username: user.Username, RoleID: user.RoleID, Permissions: permission.NewSet(permissions)}, nil
+}
 
+func (app *App) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.authenticate(w, r)
+		if err == errNotSignedIn {
+			log.Println("No valid session for", r.URL.Path)
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 			return
+		} else if err == errTokenScope {
+			err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+			ErrHandler(w, r, &err)
+			return
+		} else if err != nil {
+			log.Println("Error :", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
 		}
 
-		var exists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE current_session = ?)", seshVal).Scan(&exists)
-		if err != nil {
+		next.ServeHTTP(w, withCurrentUser(r, user))
+	})
+}
+
+// GuestMiddleware is AuthMiddleware for pages guests may see too: without a
+// valid session or API token the request goes through with no current user.
+func (app *App) GuestMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := app.authenticate(w, r)
+		if err == errNotSignedIn {
+			next.ServeHTTP(w, r)
+			return
+		} else if err == errTokenScope {
+			err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+			ErrHandler(w, r, &err)
+			return
+		} else if err != nil {
 			log.Println("Error :", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &err)
 			return
-		} else if !exists {
-			log.Println("Inavlid Session")
-			http.SetCookie(w, &http.Cookie{
-				Name:     "session_token",
-				Value:    "",
-				Expires:  time.Now().Add(-time.Hour),
-				HttpOnly: true,
-			})
-			http.Redirect(w, r, "/", http.StatusSeeOther)
+		}
+
+		next.ServeHTTP(w, withCurrentUser(r, user))
+	})
+}
+
+// RequirePermission lets the request through only if the current user holds
+// perm. It must sit behind AuthMiddleware.
+func (app *App) RequirePermission(perm permission.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := CurrentUserFrom(r)
+		if !ok || !user.Can(perm) {
+			log.Printf("User %d denied %s: missing permission %s", user.ID, r.URL.Path, perm)
+			err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+			ErrHandler(w, r, &err)
+			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireFormPermissions rejects a form that asks for any action in actions
+// the current user lacks the permission for. actions maps a form field to the
+// permission needed when the field is set. When it returns false the error
+// response has already been written.
+func requireFormPermissions(w http.ResponseWriter, r *http.Request, actions map[string]permission.Permission) bool {
+	user, _ := CurrentUserFrom(r)
+	for field, perm := range actions {
+		if r.FormValue(field) != "" && !user.Can(perm) {
+			log.Printf("User %d denied %s %s: missing permission %s", user.ID, r.URL.Path, field, perm)
+			err := ErrorPageData{Code: "403", ErrorMsg: "FORBIDDEN"}
+			ErrHandler(w, r, &err)
+			return false
+		}
+	}
+	return true
+}
@@ -2,14 +2,22 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/permission"
 	"database/sql"
-	"fmt"
 	"log"
 	"net/http"
-	"time"
+	"strconv"
 )
 
-func ModeratorPage(w http.ResponseWriter, r *http.Request) {
+// moderatorActions is the permission each moderator panel form needs.
+var moderatorActions = map[string]permission.Permission{
+	"delete_post":    permission.PostDeleteAny,
+	"report_post":    permission.PostReport,
+	"delete_comment": permission.CommentDeleteAny,
+	"report_comment": permission.CommentReport,
+}
+
+func (app *App) ModeratorPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/moderator" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -17,67 +25,20 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
+	user, hasSession := CurrentUserFrom(r)
+	userID, userName := user.ID, user.Username
 
-	var hasSession bool
-	var userID int
-	var userName string
-	seshCok, err := r.Cookie("session_token")
-	if err != nil {
-		fmt.Println("No cookie found, treated as guest")
-	} else if seshCok.Value == "" {
-		hasSession = false
-	} else {
-		hasSession = true
-
-		seshVal := seshCok.Value
-
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
-		if err == sql.ErrNoRows {
-			http.SetCookie(w, &http.Cookie{
-				Name:     "session_token",
-				Value:    "",
-				Expires:  time.Now().Add(-time.Hour),
-				HttpOnly: true,
-			})
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-		} else if err != nil {
-			log.Println("Error fetching userid ID from user table:", err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
-		} else {
-			log.Println("User is logged in:", userName)
-		}
-	}
-
-	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	roleID := user.RoleID
+	roleName, err := app.Repo.GetRoleNameByID(roleID)
 	if err != nil {
+		log.Println("Failed to fetch role name:", err)
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	var roleName string
-	if roleID == 1 {
-		roleName = "Admin"
-	} else if roleID == 2 {
-		roleName = "Moderator"
-	} else {
-		roleName = "User"
-	}
-
 	if hasSession {
-		var avatar sql.NullString
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		_, roleID, err = app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -93,15 +54,13 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "GET":
 
-			posts, err := database.GetAllPosts(db)
+			posts, nextPosts, err := app.Repo.GetAllPosts(pageFrom(r, "after"))
 			if err != nil {
-				log.Println("Failed to fetch posts:", err)
-				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-				ErrHandler(w, r, &err)
+				listError(w, r, "Failed to fetch posts:", err)
 				return
 			}
 
-			comments, err := database.GetComments(db)
+			comments, err := app.Repo.GetComments()
 			if err != nil {
 				log.Println("Failed to fetch comments:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -110,7 +69,7 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 			}
 
 			var totalLikes, totalPosts int
-			err = db.QueryRow("SELECT COUNT(*) FROM likes WHERE user_userID = ?", userID).Scan(&totalLikes)
+			totalLikes, err = app.Repo.GetTotalLikes(userID)
 			if err != nil {
 				log.Println("Failed to fetch total likes:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -118,7 +77,7 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			err = db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userID = ?", userID).Scan(&totalPosts)
+			totalPosts, err = app.Repo.GetTotalPosts(userID)
 			if err != nil {
 				log.Println("Failed to fetch total posts:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -126,7 +85,7 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			user, err := database.GetUserByID(db, userID)
+			user, err := app.Repo.GetUserByID(userID)
 			if err != nil {
 				log.Println("Failed to fetch user data")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -134,6 +93,22 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			notifications, err := app.Repo.GetLastNotifications(userID)
+			if err != nil {
+				log.Println("Failed to fetch notifications:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
+			unreadCount, err := app.Repo.CountUnreadNotifications(userID)
+			if err != nil {
+				log.Println("Failed to count unread notifications:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
 			userAvatar := user.Avatar.String
 
 			data := PageData{
@@ -143,11 +118,15 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 				RoleName:      roleName,
 				RoleID:        roleID,
 				Posts:         posts,
+				NextPostsPage: nextPageURL(r, "after", nextPosts),
 				Comments:      comments,
 				TotalPosts:    totalPosts,
 				TotalLikes:    totalLikes,
 				SelectedTab:   "moderator",
-				Notifications: []database.Notification{},
+				Notifications: notifications,
+				UnreadCount:   unreadCount,
+				CSRFToken:     csrfToken(r),
+				Perms:         userPermissions(r),
 				Categories:    []database.Category{},
 				Avatar:        userAvatar,
 			}
@@ -161,9 +140,19 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 			}
 		case "POST":
 			r.ParseForm()
+			if !requireFormPermissions(w, r, moderatorActions) {
+				return
+			}
 			if r.FormValue("delete_post") != "" {
 				postID := r.FormValue("delete_post")
-				_, err := db.Exec("DELETE FROM post WHERE postid = ?", postID)
+				id, err := strconv.Atoi(postID)
+				if err != nil {
+					log.Println("Invalid post ID")
+					err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				err = app.Repo.DeletePost(id, userID)
 				if err != nil {
 					log.Println("Failed to delete post")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -173,16 +162,33 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 			} else if r.FormValue("report_post") != "" {
 				postID := r.FormValue("report_post")
 				reportReason := r.FormValue("report_reason")
-				_, err := db.Exec("INSERT INTO reports (post_id, reported_by, report_reason) VALUES (?, ?, ?)", postID, userID, reportReason)
+				id, err := strconv.Atoi(postID)
+				if err != nil {
+					log.Println("Invalid post ID")
+					err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				err = app.Repo.InsertReport(id, userID, reportReason)
 				if err != nil {
 					log.Println("Failed to report post")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 					ErrHandler(w, r, &err)
 					return
 				}
+				if err := app.Notify.PostReported(userID, id); err != nil {
+					log.Println("Failed to create notification:", err)
+				}
 			} else if r.FormValue("delete_comment") != "" {
 				commentID := r.FormValue("delete_comment")
-				_, err := db.Exec("DELETE FROM comment WHERE commentid = ?", commentID)
+				id, err := strconv.Atoi(commentID)
+				if err != nil {
+					log.Println("Invalid comment ID")
+					err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				err = app.Repo.DeleteComment(id, userID)
 				if err != nil {
 					log.Println("Failed to delete comment")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -191,7 +197,14 @@ func ModeratorPage(w http.ResponseWriter, r *http.Request) {
 				}
 			} else if r.FormValue("report_comment") != "" {
 				commentID := r.FormValue("report_comment")
-				_, err := db.Exec("INSERT INTO reports (comment_id, reported_by, report_reason) VALUES (?, ?, ?)", commentID, userID, "Reported by moderator")
+				id, err := strconv.Atoi(commentID)
+				if err != nil {
+					log.Println("Invalid comment ID")
+					err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				err = app.Repo.InsertCommentReport(id, userID, "Reported by moderator")
 				if err != nil {
 					log.Println("Failed to report comment")
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/permission"
 	"database/sql"
 	"fmt"
 	"log"
@@ -9,7 +10,7 @@ import (
 	"time"
 )
 
-func MyProfilePage(w http.ResponseWriter, r *http.Request) {
+func (app *App) MyProfilePage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/myprofile" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -17,15 +18,6 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
 	var hasSession bool
 	var userID int
 	var userName string
@@ -39,7 +31,8 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 
 		seshVal := seshCok.Value
 
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
+		sessionUser, err := app.Repo.GetUserBySession(seshVal)
+		userID, userName = sessionUser.ID, sessionUser.Username
 		if err == sql.ErrNoRows {
 			http.SetCookie(w, &http.Cookie{
 				Name:     "session_token",
@@ -58,8 +51,7 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	_, err = app.Repo.GetUserRoleID(userID)
 	if err != nil {
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
@@ -67,8 +59,7 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if hasSession {
-		var avatar sql.NullString
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		user, err := app.Repo.GetUserByID(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -81,59 +72,53 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var user database.User
-		err = db.QueryRow("SELECT userid, F_name, L_name, Username, Avatar, role_id FROM user WHERE userid = ?", userID).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Username, &user.Avatar, &user.RoleID)
-		if err == sql.ErrNoRows {
-			http.NotFound(w, r)
-			return
-		} else if err != nil {
-			log.Println("Error fetching user details:", err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
+		posts, nextPosts, err := app.Repo.GetUserPosts(userID, "newest", pageFrom(r, "after"))
+		if err != nil {
+			listError(w, r, "Error fetching user posts:", err)
 			return
 		}
 
-		posts, err := database.GetUserPosts(db, userID, "newest")
+		followersCount, err := app.Repo.GetFollowersCount(userID)
 		if err != nil {
-			log.Println("Error fetching user posts:", err)
+			log.Println("Error fetching followers count:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &err)
 			return
 		}
 
-		followersCount, err := database.GetFollowersCount(db, userID)
+		followingCount, err := app.Repo.GetFollowingCount(userID)
 		if err != nil {
-			log.Println("Error fetching followers count:", err)
+			log.Println("Error fetching following count:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &err)
 			return
 		}
 
-		followingCount, err := database.GetFollowingCount(db, userID)
+		friendsCount, err := app.Repo.GetFriendsCount(userID)
 		if err != nil {
-			log.Println("Error fetching following count:", err)
+			log.Println("Error fetching friends count:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &err)
 			return
 		}
 
-		friendsCount, err := database.GetFriendsCount(db, userID)
+		notifications, err := app.Repo.GetLastNotifications(userID)
 		if err != nil {
-			log.Println("Error fetching friends count:", err)
+			log.Println("Error fetching notifications:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &err)
 			return
 		}
 
-		notifications, err := database.GetLastNotifications(db, userID)
+		unreadCount, err := app.Repo.CountUnreadNotifications(userID)
 		if err != nil {
-			log.Println("Error fetching notifications:", err)
+			log.Println("Failed to count unread notifications:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &err)
 			return
 		}
 
-		totalLikes, err := database.GetTotalLikes(db, userID)
+		totalLikes, err := app.Repo.GetTotalLikes(userID)
 		if err != nil {
 			log.Println("Error fetching total likes:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -141,7 +126,7 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		totalPosts, err := database.GetTotalPosts(db, userID)
+		totalPosts, err := app.Repo.GetTotalPosts(userID)
 		if err != nil {
 			log.Println("Error fetching total posts:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -149,20 +134,19 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var roleName string
-		if user.RoleID == 1 {
-			roleName = "Admin"
-		} else if user.RoleID == 2 {
-			roleName = "Moderator"
-		} else {
-			roleName = "User"
+		roleName, err := app.Repo.GetRoleNameByID(user.RoleID)
+		if err != nil {
+			log.Println("Failed to fetch role name:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
 		}
 
 		view := r.URL.Query().Get("view")
 		var followers, following, friends []database.User
 
 		if view == "followers" {
-			followers, err = database.GetFollowers(db, userID)
+			followers, err = app.Repo.GetFollowers(userID)
 			if err != nil {
 				log.Println("Error fetching followers:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -170,7 +154,7 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		} else if view == "following" {
-			following, err = database.GetFollowing(db, userID)
+			following, err = app.Repo.GetFollowing(userID)
 			if err != nil {
 				log.Println("Error fetching following:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -178,7 +162,7 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		} else if view == "friends" {
-			friends, err = database.GetFriends(db, userID)
+			friends, err = app.Repo.GetFriends(userID)
 			if err != nil {
 				log.Println("Error fetching friends:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -187,7 +171,7 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if view == "followers" {
-			followers, err = database.GetFollowers(db, userID)
+			followers, err = app.Repo.GetFollowers(userID)
 			if err != nil {
 				log.Println("Error fetching followers:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -195,7 +179,7 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		} else if view == "following" {
-			following, err = database.GetFollowing(db, userID)
+			following, err = app.Repo.GetFollowing(userID)
 			if err != nil {
 				log.Println("Error fetching following:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -203,7 +187,7 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		} else if view == "friends" {
-			friends, err = database.GetFriends(db, userID)
+			friends, err = app.Repo.GetFriends(userID)
 			if err != nil {
 				log.Println("Error fetching friends:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -212,6 +196,14 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		friendRequests, err := app.Repo.GetFriendRequests(userID)
+		if err != nil {
+			log.Println("Error fetching friend requests:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
 		data := struct {
 			UserID         int
 			FirstName      string
@@ -223,11 +215,16 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 			FollowingCount int
 			FriendsCount   int
 			Posts          []database.Post
+			NextPostsPage  string
 			View           string
 			Followers      []database.User
 			Following      []database.User
 			Friends        []database.User
+			FriendRequests []database.User
 			Notifications  []database.Notification
+			UnreadCount    int
+			CSRFToken      string
+			Perms          permission.Set
 			RoleName       string
 			TotalLikes     int
 			TotalPosts     int
@@ -241,16 +238,21 @@ func MyProfilePage(w http.ResponseWriter, r *http.Request) {
 			LastName:       user.LastName,
 			Username:       user.Username,
 			Avatar:         user.Avatar.String,
-			PostsCount:     len(posts),
+			PostsCount:     totalPosts,
 			FollowersCount: followersCount,
 			FollowingCount: followingCount,
 			FriendsCount:   friendsCount,
 			Posts:          posts,
+			NextPostsPage:  nextPageURL(r, "after", nextPosts),
 			View:           view,
 			Followers:      followers,
 			Following:      following,
 			Friends:        friends,
+			FriendRequests: friendRequests,
 			Notifications:  notifications,
+			UnreadCount:    unreadCount,
+			CSRFToken:      csrfToken(r),
+			Perms:          userPermissions(r),
 			RoleName:       roleName,
 			TotalLikes:     totalLikes,
 			TotalPosts:     totalPosts,
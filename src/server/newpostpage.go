@@ -2,35 +2,158 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/media"
+	"01connecthub/src/permission"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
-const maxPostLength = 500
 const maxFileSize = 20 << 20
 
-func NewPostPage(w http.ResponseWriter, r *http.Request) {
+// maxAttachments is how many files a post may carry and maxUploadSize how
+// large they may be altogether.
+const (
+	maxAttachments = 10
+	maxUploadSize  = 50 << 20
+)
+
+const maxCaptionLength = 200
+
+// attachmentLimits is the largest file of each kind a post may carry, none
+// of them above maxFileSize, along with what to call that kind when a file is
+// too large.
+var attachmentLimits = map[string]struct {
+	name string
+	size int
+}{
+	media.KindImage: {"Images", maxFileSize},
+	media.KindPDF:   {"PDFs", 10 << 20},
+	media.KindZip:   {"Zip archives", maxFileSize},
+	media.KindText:  {"Text files", 1 << 20},
+}
+
+// attachmentError is why an upload can't be attached to a post, worded for
+// the user.
+type attachmentError string
+
+func (e attachmentError) Error() string { return string(e) }
+
+// saveAttachment checks an upload against the limits for its kind and stores
+// it. Uploads that are refused get an attachmentError.
+func (app *App) saveAttachment(data []byte, filename, caption string) (database.Attachment, error) {
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(filename))
+	caption = strings.TrimSpace(caption)
+	if utf8.RuneCountInString(caption) > maxCaptionLength {
+		return database.Attachment{}, attachmentError(fmt.Sprintf("Captions may be at most %d characters", maxCaptionLength))
+	}
+	label := filename
+	if label == "" {
+		label = "attachment"
+	}
+	if len(data) == 0 {
+		return database.Attachment{}, attachmentError(label + ": the file is empty")
+	}
+	kind := media.Kind(data)
+	limit, ok := attachmentLimits[kind]
+	if !ok {
+		return database.Attachment{}, attachmentError(label + ": only images, PDFs, plain text files and zip archives can be attached")
+	}
+	if len(data) > limit.size {
+		return database.Attachment{}, attachmentError(fmt.Sprintf("%s: %s may be at most %d MB", label, limit.name, limit.size>>20))
+	}
+
+	var hash string
+	var err error
+	if kind == media.KindImage {
+		hash, err = app.saveImage(data)
+	} else {
+		hash, err = app.saveFile(data)
+	}
+	if errors.Is(err, media.ErrUnsupported) || errors.Is(err, media.ErrTooLarge) {
+		return database.Attachment{}, attachmentError(label + ": images must be JPEG, PNG, GIF or WebP of at most 40 megapixels")
+	} else if errors.Is(err, media.ErrUnsupportedFile) {
+		return database.Attachment{}, attachmentError(label + ": text files must be UTF-8")
+	} else if err != nil {
+		return database.Attachment{}, err
+	}
+	return database.Attachment{Hash: hash, Filename: filename, Caption: caption}, nil
+}
+
+// readUpload reads the whole of an uploaded file.
+func readUpload(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// postLength counts characters the way the new post form does, with each
+// line break as one.
+func postLength(content string) int {
+	return utf8.RuneCountInString(strings.ReplaceAll(content, "\r\n", "\n"))
+}
+
+// maxPostLength is the longest post allowed in all of categories: the
+// smallest of their limits, with categories that have none, or a post in no
+// category, held to the configured default.
+func (app *App) maxPostLength(categories []database.Category) int {
+	if len(categories) == 0 {
+		return app.Config.MaxPostLength
+	}
+	limit := 0
+	for _, c := range categories {
+		n := c.MaxLength
+		if n == 0 {
+			n = app.Config.MaxPostLength
+		}
+		if limit == 0 || n < limit {
+			limit = n
+		}
+	}
+	return limit
+}
+
+// selectCategories picks the categories with the given IDs out of all of
+// them. ok is false if an ID matches no category.
+func selectCategories(all []database.Category, ids []int) (selected []database.Category, ok bool) {
+	byID := make(map[int]database.Category, len(all))
+	for _, c := range all {
+		byID[c.ID] = c
+	}
+	for _, id := range ids {
+		c, found := byID[id]
+		if !found {
+			return nil, false
+		}
+		selected = append(selected, c)
+	}
+	return selected, true
+}
+
+func (app *App) NewPostPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/newpost" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
 		ErrHandler(w, r, &err)
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
 	var hasSession bool
 	var userID int
 	var userName string
@@ -44,7 +167,8 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 
 		seshVal := seshCok.Value
 
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
+		sessionUser, err := app.Repo.GetUserBySession(seshVal)
+		userID, userName = sessionUser.ID, sessionUser.Username
 		if err == sql.ErrNoRows {
 			http.SetCookie(w, &http.Cookie{
 				Name:     "session_token",
@@ -63,8 +187,7 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	_, err = app.Repo.GetUserRoleID(userID)
 	if err == sql.ErrNoRows {
 		log.Println("No user found with the given user ID:", userID)
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -76,8 +199,7 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if hasSession {
-		var avatar sql.NullString
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userid = ?", userID).Scan(&avatar, &roleID)
+		_, _, err = app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -92,7 +214,7 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 
 		switch r.Method {
 		case "GET":
-			categories, err := database.GetAllCategories(db)
+			categories, err := app.Repo.GetAllCategories()
 			if err != nil {
 				log.Println("Failed to fetch categories")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -100,7 +222,7 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			user, err := database.GetUserByID(db, userID)
+			user, err := app.Repo.GetUserByID(userID)
 			if err != nil {
 				log.Println("Failed to fetch user data")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -108,7 +230,7 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			notifications, err := database.GetLastNotifications(db, userID)
+			notifications, err := app.Repo.GetLastNotifications(userID)
 			if err != nil {
 				log.Println("Failed to fetch notifications")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -116,6 +238,14 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			unreadCount, err := app.Repo.CountUnreadNotifications(userID)
+			if err != nil {
+				log.Println("Failed to count unread notifications:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
 			userAvatar := user.Avatar.String // Assuming Avatar is of type sql.NullString
 
 			log.Printf("Fetched user data: %+v\n", user)
@@ -124,7 +254,7 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 				user.RoleID = 3
 			}
 
-			roleName, err := database.GetRoleNameByID(db, user.RoleID)
+			roleName, err := app.Repo.GetRoleNameByID(user.RoleID)
 			if err != nil {
 				log.Println("Failed to fetch role name")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -134,7 +264,7 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 
 			log.Printf("Fetched role name: %s\n", roleName)
 
-			totalLikes, err := database.GetTotalLikes(db, userID)
+			totalLikes, err := app.Repo.GetTotalLikes(userID)
 			if err != nil {
 				log.Println("Failed to fetch total likes")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -142,7 +272,7 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			totalPosts, err := database.GetTotalPosts(db, userID)
+			totalPosts, err := app.Repo.GetTotalPosts(userID)
 			if err != nil {
 				log.Println("Failed to fetch total posts")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -153,7 +283,11 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 			data := struct {
 				UserID        int
 				Categories    []database.Category
+				MaxLength     int
 				Notifications []database.Notification
+				UnreadCount   int
+				CSRFToken     string
+				Perms         permission.Set
 				Avatar        string
 				RoleName      string
 				UserName      string
@@ -165,7 +299,11 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 			}{
 				UserID:        userID,
 				Categories:    categories,
+				MaxLength:     app.Config.MaxPostLength,
 				Notifications: notifications,
+				UnreadCount:   unreadCount,
+				CSRFToken:     csrfToken(r),
+				Perms:         userPermissions(r),
 				Avatar:        userAvatar,
 				RoleName:      roleName,
 				UserName:      userName,
@@ -186,63 +324,109 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 
 		case "POST":
 
+			r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
 			err := r.ParseMultipartForm(10 << 20)
-			if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				log.Println("Post uploads too large")
+				err := ErrorPageData{Code: "400", ErrorMsg: fmt.Sprintf("Attachments may add up to at most %d MB", maxUploadSize>>20)}
+				ErrHandler(w, r, &err)
+				return
+			} else if err != nil {
 				log.Println("Failed to parse form data")
 				err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 				ErrHandler(w, r, &err)
 				return
 			}
 
-			userID := r.FormValue("user")
+			author, ok := actingUser(w, r, "user")
+			if !ok {
+				return
+			}
+
 			content := strings.TrimSpace(r.FormValue("content"))
 			title := strings.TrimSpace(r.FormValue("title"))
-			fmt.Println(userID, content)
-			if userID == "" || content == "" {
+			if content == "" {
 				log.Println("Invalid form data")
 				err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 				ErrHandler(w, r, &err)
 				return
 			}
 
-			if len(content) > maxPostLength {
-				http.Error(w, "Post content exceeds the character limit", http.StatusBadRequest)
+			var categoryIDs []int
+			for _, categoryID := range r.Form["categories"] {
+				categoryIDInt, err := strconv.Atoi(categoryID)
+				if err != nil {
+					log.Println("Invalid category ID")
+					continue
+				}
+				categoryIDs = append(categoryIDs, categoryIDInt)
+			}
+			allCategories, err := app.Repo.GetAllCategories()
+			if err != nil {
+				log.Println("Failed to fetch categories:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+			categories, ok := selectCategories(allCategories, categoryIDs)
+			if !ok {
+				log.Println("Unknown category ID")
+				err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+				ErrHandler(w, r, &err)
 				return
 			}
 
-			if len(content) > maxPostLength {
+			if postLength(content) > app.maxPostLength(categories) {
 				http.Error(w, "Post content exceeds the character limit", http.StatusBadRequest)
 				return
 			}
 
-			// Handle file upload
-			file, fileHeader, err := r.FormFile("image")
-			if err != nil && err != http.ErrMissingFile {
-				log.Println("Error getting uploaded file")
-				err := ErrorPageData{Code: "500", ErrorMsg: "Error getting uploaded file"}
+			// Handle file uploads, in the order the form lists them, each with
+			// the caption in the same place.
+			files := r.MultipartForm.File["attachments"]
+			captions := r.MultipartForm.Value["attachment_caption"]
+			if len(files) > maxAttachments {
+				err := ErrorPageData{Code: "400", ErrorMsg: fmt.Sprintf("A post may have at most %d attachments", maxAttachments)}
 				ErrHandler(w, r, &err)
 				return
 			}
 
-			var imageData []byte
-			if file != nil {
-				defer file.Close()
-
+			var attachments []database.Attachment
+			for i, fileHeader := range files {
 				if fileHeader.Size > maxFileSize {
-					err := ErrorPageData{Code: "500", ErrorMsg: "Image size exceeds 20 MB limit"}
+					err := ErrorPageData{Code: "400", ErrorMsg: fmt.Sprintf("%s exceeds the %d MB limit", fileHeader.Filename, maxFileSize>>20)}
 					ErrHandler(w, r, &err)
 					return
 				}
 
-				imageData, err = io.ReadAll(file)
+				data, err := readUpload(fileHeader)
 				if err != nil {
 					err := ErrorPageData{Code: "500", ErrorMsg: "Error reading uploaded file"}
 					ErrHandler(w, r, &err)
 					return
 				}
+				var caption string
+				if i < len(captions) {
+					caption = captions[i]
+				}
+				attachment, err := app.saveAttachment(data, fileHeader.Filename, caption)
+				var refused attachmentError
+				if errors.As(err, &refused) {
+					log.Println("Refused attachment:", err)
+					err := ErrorPageData{Code: "400", ErrorMsg: string(refused)}
+					ErrHandler(w, r, &err)
+					return
+				} else if err != nil {
+					log.Println("Error saving attachment:", err)
+					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				attachments = append(attachments, attachment)
 			}
 
-			postID, err := database.InsertPost(db, content, title, imageData, userID)
+			postID, err := app.Repo.InsertPost(content, title, author.ID, attachments)
 			if err != nil {
 				log.Println("Failed to insert new post")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -250,14 +434,8 @@ func NewPostPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			categories := r.Form["categories"]
-			for _, categoryID := range categories {
-				categoryIDInt, err := strconv.Atoi(categoryID)
-				if err != nil {
-					log.Println("Invalid category ID")
-					continue
-				}
-				err = database.InsertPostCategory(db, postID, categoryIDInt)
+			for _, category := range categories {
+				err = app.Repo.InsertPostCategory(postID, category.ID)
 				if err != nil {
 					log.Println("Failed to insert post category")
 				}
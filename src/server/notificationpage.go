@@ -2,14 +2,16 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/permission"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
-func NotificationsPage(w http.ResponseWriter, r *http.Request) {
+func (app *App) NotificationsPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/notifications" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -17,15 +19,6 @@ func NotificationsPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
 	var hasSession bool
 	var userID int
 	var userName string
@@ -39,7 +32,8 @@ func NotificationsPage(w http.ResponseWriter, r *http.Request) {
 
 		seshVal := seshCok.Value
 
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
+		sessionUser, err := app.Repo.GetUserBySession(seshVal)
+		userID, userName = sessionUser.ID, sessionUser.Username
 		if err == sql.ErrNoRows {
 			http.SetCookie(w, &http.Cookie{
 				Name:     "session_token",
@@ -59,25 +53,24 @@ func NotificationsPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	roleID, err = app.Repo.GetUserRoleID(userID)
 	if err != nil {
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	var roleName string
-	if roleID == 1 {
-		roleName = "Admin"
-	} else if roleID == 2 {
-		roleName = "Moderator"
-	} else {
-		roleName = "User"
+	roleName, err := app.Repo.GetRoleNameByID(roleID)
+	if err != nil {
+		log.Println("Failed to fetch role name:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
 	}
 
 	if hasSession {
 		var avatar sql.NullString
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		avatar, roleID, err = app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -90,16 +83,22 @@ func NotificationsPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		notifications, err := database.GetLastNotifications(db, userID)
+		notifications, nextNotifications, err := app.Repo.GetNotifications(userID, pageFrom(r, "after"))
+		if err != nil {
+			listError(w, r, "Failed to fetch notifications:", err)
+			return
+		}
+
+		unreadCount, err := app.Repo.CountUnreadNotifications(userID)
 		if err != nil {
-			log.Println("Failed to fetch notifications")
-			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &errData)
+			log.Println("Failed to count unread notifications:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
 			return
 		}
 
 		var totalLikes, totalPosts int
-		err = db.QueryRow("SELECT COUNT(*) FROM likes WHERE user_userID = ?", userID).Scan(&totalLikes)
+		totalLikes, err = app.Repo.GetTotalLikes(userID)
 		if err != nil {
 			log.Println("Failed to fetch total likes:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -107,7 +106,7 @@ func NotificationsPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userID = ?", userID).Scan(&totalPosts)
+		totalPosts, err = app.Repo.GetTotalPosts(userID)
 		if err != nil {
 			log.Println("Failed to fetch total posts:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -121,6 +120,10 @@ func NotificationsPage(w http.ResponseWriter, r *http.Request) {
 			UserName      string
 			Avatar        string
 			Notifications []database.Notification
+			NextPage      string
+			UnreadCount   int
+			CSRFToken     string
+			Perms         permission.Set
 			RoleName      string
 			TotalLikes    int
 			TotalPosts    int
@@ -133,6 +136,10 @@ func NotificationsPage(w http.ResponseWriter, r *http.Request) {
 			Avatar:        avatar.String,
 			RoleName:      roleName,
 			Notifications: notifications,
+			NextPage:      nextPageURL(r, "after", nextNotifications),
+			UnreadCount:   unreadCount,
+			CSRFToken:     csrfToken(r),
+			Perms:         userPermissions(r),
 			RoleID:        roleID,
 			TotalLikes:    totalLikes,
 			TotalPosts:    totalPosts,
@@ -148,3 +155,82 @@ func NotificationsPage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// ReadNotification marks one notification as read and opens what it is about.
+func (app *App) ReadNotification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.notificationUser(w, r)
+	if !ok {
+		return
+	}
+
+	notificationID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		log.Println("Invalid notification ID:", r.FormValue("id"))
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	notification, err := app.Repo.GetNotification(userID, notificationID)
+	if err == sql.ErrNoRows {
+		log.Println("No notification found with the given ID:", notificationID)
+		err := ErrorPageData{Code: "404", ErrorMsg: "NOTIFICATION NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to fetch notification:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.MarkNotificationRead(userID, notificationID)
+	if err != nil {
+		log.Println("Failed to mark notification as read:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if err := app.Notify.ReadChanged(userID); err != nil {
+		log.Println("Failed to publish unread count:", err)
+	}
+
+	http.Redirect(w, r, notification.Link(), http.StatusSeeOther)
+}
+
+func (app *App) ReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.notificationUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Repo.MarkAllNotificationsRead(userID)
+	if err != nil {
+		log.Println("Failed to mark notifications as read:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if err := app.Notify.ReadChanged(userID); err != nil {
+		log.Println("Failed to publish unread count:", err)
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// notificationUser checks that the request is a POST and returns the current
+// user's ID. When it returns false the response has already been written.
+func (app *App) notificationUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	if r.Method != "POST" {
+		log.Println("Method not allowed")
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return 0, false
+	}
+
+	user, ok := actingUser(w, r)
+	if !ok {
+		return 0, false
+	}
+	return user.ID, true
+}
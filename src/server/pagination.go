@@ -0,0 +1,41 @@
+package server
+
+import (
+	"01connecthub/database"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// pageFrom reads the cursor in the query parameter param, and the page size
+// in ?limit=, which may be left out.
+func pageFrom(r *http.Request, param string) database.Page {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	return database.Page{After: r.URL.Query().Get(param), Limit: limit}
+}
+
+// nextPageURL is the address of the page after this one: r's URL with param
+// set to the next cursor. It is empty on the last page, so templates can
+// show a "load more" link only when there is more.
+func nextPageURL(r *http.Request, param, next string) string {
+	if next == "" {
+		return ""
+	}
+	q := r.URL.Query()
+	q.Set(param, next)
+	return r.URL.Path + "?" + q.Encode()
+}
+
+// listError answers a failed page fetch: 400 for a cursor that was tampered
+// with or has gone stale, 500 for anything else.
+func listError(w http.ResponseWriter, r *http.Request, context string, err error) {
+	log.Println(context, err)
+	if errors.Is(err, database.ErrBadCursor) {
+		errData := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &errData)
+		return
+	}
+	errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+	ErrHandler(w, r, &errData)
+}
@@ -3,7 +3,6 @@ package server
 import (
 	"01connecthub/database"
 	"database/sql"
-	"encoding/base64"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,7 +10,7 @@ import (
 	"time"
 )
 
-func PostPage(w http.ResponseWriter, r *http.Request) {
+func (app *App) PostPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/post" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -25,14 +24,6 @@ func PostPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Error opening database:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	var hasSession bool
 	var userID int
 	var userName string
@@ -46,7 +37,8 @@ func PostPage(w http.ResponseWriter, r *http.Request) {
 
 		seshVal := seshCok.Value
 
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
+		sessionUser, err := app.Repo.GetUserBySession(seshVal)
+		userID, userName = sessionUser.ID, sessionUser.Username
 		if err == sql.ErrNoRows {
 			http.SetCookie(w, &http.Cookie{
 				Name:     "session_token",
@@ -66,25 +58,23 @@ func PostPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	roleID, err = app.Repo.GetUserRoleID(userID)
 	if err != nil {
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	var roleName string
-	if roleID == 1 {
-		roleName = "Admin"
-	} else if roleID == 2 {
-		roleName = "Moderator"
-	} else {
-		roleName = "User"
+	roleName, err := app.Repo.GetRoleNameByID(roleID)
+	if err != nil {
+		log.Println("Failed to fetch role name:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
 	}
 
 	if hasSession {
-		var avatar sql.NullString
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		_, _, err = app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -97,16 +87,6 @@ func PostPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var userName string
-		seshVal := seshCok.Value
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
-		if err != nil {
-			log.Println("Error fetching userid and username from user table:", err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
-		}
-
 		postID := r.URL.Query().Get("id")
 		if postID == "" {
 			log.Println("Post ID not found in query parameters")
@@ -114,38 +94,91 @@ func PostPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var post database.Post
-		err = db.QueryRow(`
-        SELECT post.postid, post.image, post.title, post.content, post.post_at, post.user_userid, user.Username, user.F_name, user.L_name, user.Avatar,
-               (SELECT COUNT(*) FROM likes WHERE likes.post_postid = post.postid) AS Likes,
-               (SELECT COUNT(*) FROM dislikes WHERE dislikes.post_postid = post.postid) AS Dislikes,
-               (SELECT COUNT(*) FROM comment WHERE comment.post_postid = post.postid) AS Comments
-        FROM post
-        JOIN user ON post.user_userid = user.userid
-        WHERE post.postid = ?
-		`, postID).Scan(&post.PostID, &post.Image, &post.Title, &post.Content, &post.PostAt, &post.UserUserID, &post.Username, &post.FirstName, &post.LastName, &post.Avatar, &post.Likes, &post.Dislikes, &post.Comments)
+		postIDInt, err := strconv.Atoi(postID)
 		if err != nil {
-			log.Println("Failed to fetch posts")
-			errData := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
-			ErrHandler(w, r, &errData)
+			log.Println("Error converting post ID to integer:", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		post, err := app.Repo.GetPostByID(postIDInt)
+		if err == sql.ErrNoRows {
+			log.Println("No post found with the given ID:", postIDInt)
+			err := ErrorPageData{Code: "404", ErrorMsg: "POST NOT FOUND"}
+			ErrHandler(w, r, &err)
+			return
+		} else if err != nil {
+			log.Println("Failed to fetch post:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
 			return
 		}
 
-		var base64Image string
-		if post.Image.Valid {
-			base64Image = base64.StdEncoding.EncodeToString([]byte(post.Image.String))
+		var comments []database.Comment
+		var nextComments string
+		threadID := 0
+		if thread := r.URL.Query().Get("thread"); thread != "" {
+			threadID, err = strconv.Atoi(thread)
+			if err != nil {
+				log.Println("Error converting thread ID to integer:", err)
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+			comments, err = app.Repo.GetCommentThread(postIDInt, threadID)
+			if err == sql.ErrNoRows {
+				log.Println("No comment found for thread:", threadID)
+				err := ErrorPageData{Code: "404", ErrorMsg: "COMMENT NOT FOUND"}
+				ErrHandler(w, r, &err)
+				return
+			} else if err != nil {
+				log.Println("Error getting comment thread:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+		} else {
+			comments, nextComments, err = app.Repo.GetCommentsForPost(postIDInt, pageFrom(r, "after"))
+			if err != nil {
+				listError(w, r, "Error getting comments for post:", err)
+				return
+			}
 		}
 
-		postIDInt, err := strconv.Atoi(postID)
+		posts := []database.Post{post}
+		if err := app.Repo.AttachPostReactions(posts, userID); err != nil {
+			log.Println("Error fetching post reactions:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		if err := app.Repo.AttachPostBookmarks(posts, userID); err != nil {
+			log.Println("Error fetching bookmarks:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		post = posts[0]
+
+		collections, err := app.Repo.GetPostCollections(userID, post.PostID)
 		if err != nil {
-			log.Println("Error converting post ID to integer:", err)
-			http.Error(w, "Bad request", http.StatusBadRequest)
+			log.Println("Error fetching collections:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		if err := app.Repo.AttachCommentReactions(comments, userID); err != nil {
+			log.Println("Error fetching comment reactions:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
 			return
 		}
-		comments, err := database.GetCommentsForPost(db, postIDInt)
+
+		reactionTypes, err := app.Repo.GetReactionTypes()
 		if err != nil {
-			log.Println("Error getting comments for post:", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Println("Error fetching reaction types:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
 			return
 		}
 
@@ -155,14 +188,14 @@ func PostPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		categories, err := database.GetCategoriesForPost(db, post.PostID)
+		categories, err := app.Repo.GetCategoriesForPost(post.PostID)
 		if err != nil {
 			log.Println("Error fetching categories for post:", err)
 			return
 		}
 
 		log.Println("User ID:", userID)
-		user, err := database.GetUserByID(db, userID)
+		user, err := app.Repo.GetUserByID(userID)
 		if err != nil {
 			log.Println("Failed to fetch user data")
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -170,18 +203,42 @@ func PostPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		notifications, err := app.Repo.GetLastNotifications(userID)
+		if err != nil {
+			log.Println("Failed to fetch notifications:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		unreadCount, err := app.Repo.CountUnreadNotifications(userID)
+		if err != nil {
+			log.Println("Failed to count unread notifications:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
 		userAvatar := user.Avatar.String
 
 		data := PageData{
-			RoleName:    roleName,
-			HasSession:  hasSession,
-			Post:        post,
-			Comments:    comments,
-			UserID:      userID,
-			UserName:    userName,
-			Categories:  categories,
-			ImageBase64: base64Image,
-			Avatar:      userAvatar,
+			RoleName:         roleName,
+			HasSession:       hasSession,
+			Post:             post,
+			Comments:         comments,
+			NextCommentsPage: nextPageURL(r, "after", nextComments),
+			ThreadID:         threadID,
+			MaxLength:        app.maxPostLength(categories),
+			ReactionTypes:    reactionTypes,
+			UserID:           userID,
+			UserName:         userName,
+			Categories:       categories,
+			Avatar:           userAvatar,
+			Notifications:    notifications,
+			UnreadCount:      unreadCount,
+			CSRFToken:        csrfToken(r),
+			Perms:            userPermissions(r),
+			Collections:      collections,
 		}
 
 		err = templates.ExecuteTemplate(w, "post.html", data)
@@ -2,6 +2,7 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/permission"
 	"database/sql"
 	"fmt"
 	"log"
@@ -10,7 +11,7 @@ import (
 	"time"
 )
 
-func ProfilePage(w http.ResponseWriter, r *http.Request) {
+func (app *App) ProfilePage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/profile" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -18,15 +19,6 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
-
 	var hasSession bool
 	var userID int
 	var userName string
@@ -40,7 +32,8 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 
 		seshVal := seshCok.Value
 
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
+		sessionUser, err := app.Repo.GetUserBySession(seshVal)
+		userID, userName = sessionUser.ID, sessionUser.Username
 		if err == sql.ErrNoRows {
 			http.SetCookie(w, &http.Cookie{
 				Name:     "session_token",
@@ -60,25 +53,23 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	roleID, err = app.Repo.GetUserRoleID(userID)
 	if err != nil {
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	var roleName string
-	if roleID == 1 {
-		roleName = "Admin"
-	} else if roleID == 2 {
-		roleName = "Moderator"
-	} else {
-		roleName = "User"
+	roleName, err := app.Repo.GetRoleNameByID(roleID)
+	if err != nil {
+		log.Println("Failed to fetch role name:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
 	}
 
 	if hasSession {
-		var avatar sql.NullString
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		avatar, _, err := app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -91,31 +82,41 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		userID, err := strconv.Atoi(r.FormValue("user"))
+		profileUserID, err := strconv.Atoi(r.FormValue("user"))
 		if err != nil {
 			log.Println("Error converting userID to int:", err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 			ErrHandler(w, r, &err)
 			return
 		}
-		var user database.User
-		err = db.QueryRow("SELECT userid, F_name, L_name, Username, Avatar FROM user WHERE userid = ?", userID).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Username, &user.Avatar)
-		if err != nil {
+		user, err := app.Repo.GetUserByID(profileUserID)
+		if err == sql.ErrNoRows {
+			log.Println("No user found with the given ID:", profileUserID)
+			errData := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
+			ErrHandler(w, r, &errData)
+			return
+		} else if err != nil {
 			log.Println("Failed to fetch user data")
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &errData)
 			return
 		}
 
-		posts, err := database.GetUserPosts(db, userID, "newest")
+		posts, nextPosts, err := app.Repo.GetUserPosts(profileUserID, "newest", pageFrom(r, "after"))
 		if err != nil {
-			log.Println("Failed to fetch user posts")
+			listError(w, r, "Failed to fetch user posts:", err)
+			return
+		}
+
+		profilePostsCount, err := app.Repo.GetTotalPosts(profileUserID)
+		if err != nil {
+			log.Println("Failed to fetch profile post count:", err)
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &errData)
 			return
 		}
 
-		followersCount, err := database.GetFollowersCount(db, userID)
+		followersCount, err := app.Repo.GetFollowersCount(profileUserID)
 		if err != nil {
 			log.Println("Failed to fetch followers count")
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -123,7 +124,7 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		followingCount, err := database.GetFollowingCount(db, userID)
+		followingCount, err := app.Repo.GetFollowingCount(profileUserID)
 		if err != nil {
 			log.Println("Failed to fetch following count")
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -131,7 +132,7 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		friendsCount, err := database.GetFriendsCount(db, userID)
+		friendsCount, err := app.Repo.GetFriendsCount(profileUserID)
 		if err != nil {
 			log.Println("Failed to fetch friends count")
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -139,7 +140,7 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		isFollowing, err := database.IsFollowing(db, userID, userID)
+		isFollowing, err := app.Repo.IsFollowing(userID, profileUserID)
 		if err != nil {
 			log.Println("Failed to check if user is following")
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -147,17 +148,57 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		friendStatus, err := app.Repo.GetFriendStatus(userID, profileUserID)
+		if err != nil {
+			log.Println("Failed to fetch friend status:", err)
+			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &errData)
+			return
+		}
+
+		totalLikes, err := app.Repo.GetTotalLikes(userID)
+		if err != nil {
+			log.Println("Failed to fetch total likes:", err)
+			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &errData)
+			return
+		}
+
+		totalPosts, err := app.Repo.GetTotalPosts(userID)
+		if err != nil {
+			log.Println("Failed to fetch total posts:", err)
+			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &errData)
+			return
+		}
+
+		notifications, err := app.Repo.GetLastNotifications(userID)
+		if err != nil {
+			log.Println("Failed to fetch notifications:", err)
+			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &errData)
+			return
+		}
+
+		unreadCount, err := app.Repo.CountUnreadNotifications(userID)
+		if err != nil {
+			log.Println("Failed to count unread notifications:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
 		view := r.URL.Query().Get("view")
 		var followers, following []database.User
 
 		if view == "followers" {
-			followers, err = database.GetFollowers(db, userID)
+			followers, err = app.Repo.GetFollowers(profileUserID)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		} else if view == "following" {
-			following, err = database.GetFollowing(db, userID)
+			following, err = app.Repo.GetFollowing(profileUserID)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -166,8 +207,19 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 
 		data := struct {
 			UserID                int
-			Hassession            bool
+			UserName              string
+			HasSession            bool
 			RoleName              string
+			RoleID                int
+			Avatar                string
+			TotalLikes            int
+			TotalPosts            int
+			Notifications         []database.Notification
+			UnreadCount           int
+			CSRFToken             string
+			Perms                 permission.Set
+			SelectedTab           string
+			ProfileUserID         int
 			ProfileFirstName      string
 			ProfileLastName       string
 			ProfileUsername       string
@@ -177,24 +229,40 @@ func ProfilePage(w http.ResponseWriter, r *http.Request) {
 			ProfileFollowingCount int
 			ProfileFriendsCount   int
 			ProfilePosts          []database.Post
+			NextPostsPage         string
+			IsOwnProfile          bool
 			IsFollowing           bool
+			FriendStatus          string
 			View                  string
 			Followers             []database.User
 			Following             []database.User
 		}{
 			UserID:                userID,
-			Hassession:            hasSession,
+			UserName:              userName,
+			HasSession:            hasSession,
 			RoleName:              roleName,
+			RoleID:                roleID,
+			Avatar:                avatar.String,
+			TotalLikes:            totalLikes,
+			TotalPosts:            totalPosts,
+			Notifications:         notifications,
+			UnreadCount:           unreadCount,
+			CSRFToken:             csrfToken(r),
+			Perms:                 userPermissions(r),
+			ProfileUserID:         profileUserID,
 			ProfileFirstName:      user.FirstName,
 			ProfileLastName:       user.LastName,
 			ProfileUsername:       user.Username,
 			ProfileAvatar:         user.Avatar.String,
-			ProfilePostsCount:     len(posts),
+			ProfilePostsCount:     profilePostsCount,
 			ProfileFollowersCount: followersCount,
 			ProfileFollowingCount: followingCount,
 			ProfileFriendsCount:   friendsCount,
 			ProfilePosts:          posts,
+			NextPostsPage:         nextPageURL(r, "after", nextPosts),
+			IsOwnProfile:          profileUserID == userID,
 			IsFollowing:           isFollowing,
+			FriendStatus:          friendStatus,
 			View:                  view,
 			Followers:             followers,
 			Following:             following,
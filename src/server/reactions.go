@@ -0,0 +1,133 @@
+package server
+
+import (
+	"01connecthub/database"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (app *App) LikePost(w http.ResponseWriter, r *http.Request) {
+	app.reactionForm(w, r, database.TargetPost, "post_id", database.ReactionLike)
+}
+
+func (app *App) DislikePost(w http.ResponseWriter, r *http.Request) {
+	app.reactionForm(w, r, database.TargetPost, "post_id", database.ReactionDislike)
+}
+
+func (app *App) LikeComment(w http.ResponseWriter, r *http.Request) {
+	app.reactionForm(w, r, database.TargetComment, "comment_id", database.ReactionLike)
+}
+
+func (app *App) DislikeComment(w http.ResponseWriter, r *http.Request) {
+	app.reactionForm(w, r, database.TargetComment, "comment_id", database.ReactionDislike)
+}
+
+// React handles the reaction picker, which can set any reaction type on a
+// post or comment.
+func (app *App) React(w http.ResponseWriter, r *http.Request) {
+	target := r.FormValue("target")
+	if target != database.TargetPost && target != database.TargetComment {
+		log.Println("Invalid reaction target:", target)
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	app.reactionForm(w, r, target, "id", r.FormValue("kind"))
+}
+
+// reactionForm handles the reaction buttons. A state field of "on" or "off"
+// sets the reaction outright, so a retried request is harmless; without one
+// the reaction is toggled. Requests that accept JSON get the resulting
+// ReactionState back instead of a redirect.
+func (app *App) reactionForm(w http.ResponseWriter, r *http.Request, target, idField, kind string) {
+	asJSON := strings.Contains(r.Header.Get("Accept"), "application/json")
+	fail := func(status int, message string) {
+		if asJSON {
+			writeAPIError(w, status, message)
+			return
+		}
+		err := ErrorPageData{Code: strconv.Itoa(status), ErrorMsg: strings.ToUpper(http.StatusText(status))}
+		ErrHandler(w, r, &err)
+	}
+
+	if r.Method != "POST" {
+		log.Println("Method not allowed")
+		fail(http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	targetID, err := strconv.Atoi(r.FormValue(idField))
+	if err != nil {
+		log.Printf("Invalid %s ID", target)
+		fail(http.StatusBadRequest, "invalid "+target+" id")
+		return
+	}
+
+	user, ok := actingUser(w, r, "user")
+	if !ok {
+		return
+	}
+
+	state, err := app.applyReaction(target, targetID, user.ID, kind, r.FormValue("state"))
+	switch {
+	case errors.Is(err, errBadReactionState), errors.Is(err, database.ErrUnknownReaction):
+		fail(http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, sql.ErrNoRows):
+		fail(http.StatusNotFound, target+" not found")
+		return
+	case err != nil:
+		log.Println("Error setting reaction:", err)
+		fail(http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, state)
+		return
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
+
+var errBadReactionState = errors.New(`state must be "on" or "off"`)
+
+// applyReaction sets or toggles userID's reaction, as state asks, then
+// notifies the author of a new like and pushes the new counts to viewers.
+func (app *App) applyReaction(target string, targetID, userID int, kind, state string) (database.ReactionState, error) {
+	var result database.ReactionState
+	var err error
+	switch state {
+	case "":
+		result, err = app.Repo.ToggleReaction(target, targetID, userID, kind)
+	case "on", "off":
+		result, err = app.Repo.SetReaction(target, targetID, userID, kind, state == "on")
+	default:
+		return result, errBadReactionState
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if result.Reaction == database.ReactionLike && result.Previous != database.ReactionLike {
+		var err error
+		if target == database.TargetPost {
+			err = app.Notify.PostLiked(userID, targetID)
+		} else {
+			err = app.Notify.CommentLiked(userID, targetID)
+		}
+		if err != nil {
+			log.Println("Failed to create notification:", err)
+		}
+	}
+
+	if target == database.TargetPost {
+		app.publishPostCounts(targetID)
+	} else {
+		app.publishCommentCounts(targetID)
+	}
+	return result, nil
+}
@@ -1,23 +1,14 @@
 package server
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 )
 
-func ReverseMiddleware(next http.HandlerFunc) http.HandlerFunc {
+func (app *App) ReverseMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		db, err := sql.Open("sqlite3", "./database/main.db")
-		if err != nil {
-			log.Println("Database connection failed")
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
-		}
-		defer db.Close()
 
 		seshCok, err := r.Cookie("session_toekn")
 		if err != nil {
@@ -25,8 +16,7 @@ func ReverseMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		} else {
 			seshVal := seshCok.Value
 
-			var exists bool
-			err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE current_session = ?)", seshVal).Scan(&exists)
+			exists, err := app.Repo.SessionExists(seshVal)
 			if err != nil {
 				log.Println("Error :", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/config"
+	"log"
+)
+
+// SignupRoleID is the role a new account registered with email starts with.
+func SignupRoleID(cfg config.Config, email string) int {
+	switch {
+	case cfg.IsAdmin(email):
+		return database.RoleAdmin
+	case cfg.IsModerator(email):
+		return database.RoleModerator
+	default:
+		return database.RoleUser
+	}
+}
+
+// BootstrapRoles promotes existing accounts whose emails the config lists as
+// admins or moderators. Accounts created later get their role at signup.
+func (app *App) BootstrapRoles() error {
+	for _, group := range []struct {
+		emails []string
+		roleID int
+	}{
+		{app.Config.Admins, database.RoleAdmin},
+		{app.Config.Moderators, database.RoleModerator},
+	} {
+		for _, email := range group.emails {
+			promoted, err := app.Repo.PromoteUserByEmail(email, group.roleID)
+			if err != nil {
+				return err
+			}
+			if promoted {
+				log.Printf("Promoted %s to role %d from config", email, group.roleID)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"01connecthub/database"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxSavedSearches      = 20
+	maxSavedSearchLength  = 200
+	searchDigestFrequency = 24 * time.Hour
+)
+
+// SavedSearchView is a saved search as listed on the settings page.
+type SavedSearchView struct {
+	ID          int
+	Query       string
+	Category    string
+	EmailDigest bool
+	Matches     int
+	CreatedAt   string
+	// Link runs the search.
+	Link string
+}
+
+func savedSearchViews(searches []database.SavedSearch) []SavedSearchView {
+	views := make([]SavedSearchView, 0, len(searches))
+	for _, s := range searches {
+		views = append(views, SavedSearchView{
+			ID:          s.ID,
+			Query:       s.Query,
+			Category:    s.CategoryName,
+			EmailDigest: s.EmailDigest,
+			Matches:     s.Matches,
+			CreatedAt:   s.CreatedAt.Local().Format("02/01/2006 - 15:04"),
+			Link:        "/searchpage?q=" + url.QueryEscape(s.Query),
+		})
+	}
+	return views
+}
+
+// searchOwner returns the current user for the saved search forms, which
+// only take POST. When it returns false the error response has already been
+// written.
+func searchOwner(w http.ResponseWriter, r *http.Request) (CurrentUser, bool) {
+	if r.Method != "POST" {
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return CurrentUser{}, false
+	}
+	return actingUser(w, r)
+}
+
+// SaveSearch saves the search in ?q= for the current user, limited to the
+// category picked, if any, and with an email digest if asked for.
+func (app *App) SaveSearch(w http.ResponseWriter, r *http.Request) {
+	user, ok := searchOwner(w, r)
+	if !ok {
+		return
+	}
+
+	query := strings.TrimSpace(r.FormValue("q"))
+	search := database.ParseSearch(query)
+	if msg := search.DateError(); msg != "" {
+		err := ErrorPageData{Code: "400", ErrorMsg: msg}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if search.IsEmpty() || len(query) > maxSavedSearchLength {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	var categoryID int
+	if category := r.FormValue("category"); category != "" {
+		id, err := strconv.Atoi(category)
+		if err != nil {
+			err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		categories, err := app.Repo.GetAllCategories()
+		if err != nil {
+			log.Println("Failed to fetch categories:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		if !slices.ContainsFunc(categories, func(c database.Category) bool { return c.ID == id }) {
+			err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+			ErrHandler(w, r, &err)
+			return
+		}
+		categoryID = id
+	}
+
+	searches, err := app.Repo.GetSavedSearches(user.ID)
+	if err != nil {
+		log.Println("Failed to fetch saved searches:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if len(searches) >= maxSavedSearches {
+		err := ErrorPageData{Code: "400", ErrorMsg: fmt.Sprintf("You can save at most %d searches", maxSavedSearches)}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	_, err = app.Repo.SaveSearch(database.SavedSearch{
+		UserID:      user.ID,
+		Query:       query,
+		CategoryID:  categoryID,
+		EmailDigest: r.FormValue("email_digest") == "on" && app.Config.SMTP.Enabled(),
+	})
+	if err != nil {
+		log.Println("Failed to save search:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, "/settings#saved-searches", http.StatusSeeOther)
+}
+
+// SetSavedSearchDigest turns the email digest of one of the current user's
+// saved searches on or off.
+func (app *App) SetSavedSearchDigest(w http.ResponseWriter, r *http.Request) {
+	user, ok := searchOwner(w, r)
+	if !ok {
+		return
+	}
+
+	searchID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	on := r.FormValue("email_digest") == "on" && app.Config.SMTP.Enabled()
+
+	err = app.Repo.SetSavedSearchDigest(user.ID, searchID, on)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "SAVED SEARCH NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to update saved search:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, "/settings#saved-searches", http.StatusSeeOther)
+}
+
+// DeleteSavedSearch removes one of the current user's saved searches.
+func (app *App) DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	user, ok := searchOwner(w, r)
+	if !ok {
+		return
+	}
+
+	searchID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.DeleteSavedSearch(user.ID, searchID)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "SAVED SEARCH NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to delete saved search:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, "/settings#saved-searches", http.StatusSeeOther)
+}
+
+// MatchSavedSearches checks the posts and comments added since the last run
+// against every saved search, every interval, and notifies their owners of
+// what matched. It never returns and is meant to run in its own goroutine.
+func (app *App) MatchSavedSearches(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		matches, err := app.Repo.MatchSavedSearches()
+		if err != nil {
+			log.Println("Failed to match saved searches:", err)
+		}
+		for _, match := range matches {
+			if err := app.Notify.SearchMatched(match); err != nil {
+				log.Println("Failed to notify saved search match:", err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// SendSearchDigests emails each user who asked for digests the matches of
+// their saved searches, at most once a day, checking every interval. It
+// never returns and is meant to run in its own goroutine, and only when SMTP
+// is configured.
+func (app *App) SendSearchDigests(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		digests, err := app.Repo.GetPendingDigests(time.Now().Add(-searchDigestFrequency))
+		if err != nil {
+			log.Println("Failed to fetch search digests:", err)
+		}
+		for _, digest := range digests {
+			if err := app.sendDigest(digest); err != nil {
+				log.Printf("Failed to send search digest to user %d: %v", digest.UserID, err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func (app *App) sendDigest(digest database.SearchDigest) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %s,\n\nHere is what matched your saved searches since the last digest:\n", digest.Username)
+	ids := make([]int, 0, len(digest.Matches))
+	query := ""
+	for _, item := range digest.Matches {
+		if item.Query != query {
+			query = item.Query
+			fmt.Fprintf(&body, "\n%q\n", query)
+		}
+		kind := "Post"
+		if item.CommentID != 0 {
+			kind = "Comment on"
+		}
+		fmt.Fprintf(&body, "- %s %q: %s%s\n", kind, item.Title, app.Config.BaseURL, item.Link())
+		ids = append(ids, item.MatchID)
+	}
+	fmt.Fprintf(&body, "\nYou can change which searches send digests at %s/settings#saved-searches\n", app.Config.BaseURL)
+
+	subject := fmt.Sprintf("%d new matches for your saved searches", len(digest.Matches))
+	if len(digest.Matches) == 1 {
+		subject = "1 new match for your saved searches"
+	}
+	if err := app.Mail.Send(digest.Email, subject, body.String()); err != nil {
+		return err
+	}
+	return app.Repo.MarkDigestSent(ids)
+}
@@ -2,132 +2,164 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/markdown"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
-func SearchHandler(w http.ResponseWriter, r *http.Request) {
+// suggestionLimit is how many of each kind of result the search box suggests.
+const suggestionLimit = 5
+
+// SearchHandler answers the search box's suggestions as it is typed, as JSON:
+// the best matching users, categories, posts and comments, with the last word
+// matched as a prefix since it may not be finished.
+func (app *App) SearchHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	query := r.URL.Query().Get("q")
-	if query == "" {
+	w.Header().Set("Content-Type", "application/json")
+	query := database.ParseSearch(r.URL.Query().Get("q")).AsYouType()
+	if query.IsEmpty() || query.DateError() != "" {
 		json.NewEncoder(w).Encode([]SearchResult{})
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
-	var results []SearchResult
+	results := []SearchResult{}
+	page := database.Page{Limit: suggestionLimit}
 
-	userRows, err := db.Query(`
-        SELECT userid, Username, F_name, L_name, Avatar 
-        FROM user 
-        WHERE Username LIKE ? OR F_name LIKE ? OR L_name LIKE ? 
-        ORDER BY Username
-    `, "%"+query+"%", "%"+query+"%", "%"+query+"%")
-	if err != nil {
-		log.Println("Error searching users:", err)
-	} else {
-		defer userRows.Close()
-		for userRows.Next() {
-			var result SearchResult
-			var avatar sql.NullString
-			var fname, lname string
-			err := userRows.Scan(&result.ID, &result.Username, &fname, &lname, &avatar)
-			if err != nil {
-				continue
+	// Filters only make sense for posts and comments.
+	if !query.HasFilters() {
+		users, _, err := app.Repo.SearchUsers(query, page)
+		if err != nil {
+			log.Println("Error searching users:", err)
+		}
+		for _, user := range users {
+			result := SearchResult{
+				Type:     "user",
+				ID:       user.ID,
+				Username: user.Username,
+				Name:     user.FirstName + " " + user.LastName,
 			}
-			result.Type = "user"
-			result.Name = fname + " " + lname
-			if avatar.Valid {
-				result.Avatar = avatar.String
+			if user.Avatar.Valid {
+				result.Avatar = user.Avatar.String
 			}
 			results = append(results, result)
 		}
-	}
 
-	categoryRows, err := db.Query(`
-        SELECT idcategories, name 
-        FROM categories 
-        WHERE name LIKE ? 
-        ORDER BY name
-    `, "%"+query+"%")
-	if err != nil {
-		log.Println("Error searching categories:", err)
-	} else {
-		defer categoryRows.Close()
-		for categoryRows.Next() {
-			var result SearchResult
-			err := categoryRows.Scan(&result.CategoryID, &result.Name)
-			if err != nil {
-				continue
-			}
-			result.Type = "category"
-			results = append(results, result)
+		categories, err := app.Repo.SearchCategories(query)
+		if err != nil {
+			log.Println("Error searching categories:", err)
+		}
+		for _, category := range categories {
+			results = append(results, SearchResult{
+				Type:       "category",
+				CategoryID: category.ID,
+				Name:       category.Name,
+			})
 		}
 	}
 
-	postRows, err := db.Query(`
-        SELECT postid, title, content 
-        FROM post 
-        WHERE title LIKE ? OR content LIKE ? 
-        ORDER BY post_at DESC
-    `, "%"+query+"%", "%"+query+"%")
+	posts, _, err := app.Repo.SearchPosts(query, page)
 	if err != nil {
 		log.Println("Error searching posts:", err)
-	} else {
-		defer postRows.Close()
-		for postRows.Next() {
-			var result SearchResult
-			err := postRows.Scan(&result.ID, &result.Title, &result.Content)
-			if err != nil {
-				continue
+	}
+	for _, post := range posts {
+		results = append(results, SearchResult{
+			Type:    "post",
+			ID:      post.PostID,
+			Title:   post.Title,
+			Snippet: string(highlight(post.Snippet)),
+		})
+	}
+
+	comments, _, err := app.Repo.SearchComments(query, page)
+	if err != nil {
+		log.Println("Error searching comments:", err)
+	}
+	for _, comment := range comments {
+		results = append(results, SearchResult{
+			Type:     "comment",
+			ID:       comment.ID,
+			PostID:   comment.PostID,
+			Title:    comment.PostTitle,
+			Username: comment.Username,
+			Snippet:  string(highlight(comment.Snippet)),
+		})
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
+
+// searchTypes are the kinds of result the search page lists, one at a time.
+var searchTypes = []string{"posts", "comments", "users"}
+
+// highlight shows a search snippet as plain text with the matched words
+// marked. The text is escaped before the marks go in.
+func highlight(snippet string) template.HTML {
+	text := template.HTMLEscapeString(markdown.PlainText(snippet))
+	var b strings.Builder
+	open := false
+	for _, r := range text {
+		switch string(r) {
+		case database.SnippetOpen:
+			if !open {
+				b.WriteString("<mark>")
 			}
-			result.Type = "post"
-			if len(result.Content) > 100 {
-				result.Content = result.Content[:100] + "..."
+			open = true
+		case database.SnippetClose:
+			if open {
+				b.WriteString("</mark>")
 			}
-			results = append(results, result)
+			open = false
+		default:
+			b.WriteRune(r)
 		}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(results)
+	if open {
+		b.WriteString("</mark>")
+	}
+	return template.HTML(b.String())
 }
 
-func SearchPageHandler(w http.ResponseWriter, r *http.Request) {
+// SearchPageHandler lists the results of a search a page at a time, on the
+// home page under a search tab. ?type= picks posts, comments or users.
+func (app *App) SearchPageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	query := r.URL.Query().Get("q")
-	if query == "" {
+	search := database.ParseSearch(query)
+	if msg := search.DateError(); msg != "" {
+		err := ErrorPageData{Code: "400", ErrorMsg: msg}
+		ErrHandler(w, r, &err)
+		return
+	}
+	if search.IsEmpty() {
 		http.Redirect(w, r, "/home", http.StatusSeeOther)
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed:", err)
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+	resultType := r.URL.Query().Get("type")
+	if resultType == "" {
+		resultType = "posts"
+	}
+	if !slices.Contains(searchTypes, resultType) {
+		log.Println("Invalid search type selected")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 		ErrHandler(w, r, &err)
 		return
 	}
-	defer db.Close()
 
 	var hasSession bool
 	var userID int
@@ -141,7 +173,8 @@ func SearchPageHandler(w http.ResponseWriter, r *http.Request) {
 		hasSession = true
 		seshVal := seshCok.Value
 
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
+		sessionUser, err := app.Repo.GetUserBySession(seshVal)
+		userID, userName = sessionUser.ID, sessionUser.Username
 		if err == sql.ErrNoRows {
 			http.SetCookie(w, &http.Cookie{
 				Name:     "session_token",
@@ -160,16 +193,38 @@ func SearchPageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := PageData{
-		HasSession:  hasSession,
-		SearchQuery: query,
-		UserID:      userID,
-		UserName:    userName,
+		HasSession:     hasSession,
+		SearchQuery:    query,
+		UserID:         userID,
+		UserName:       userName,
+		CSRFToken:      csrfToken(r),
+		Perms:          userPermissions(r),
+		SelectedTab:    "search",
+		SelectedFilter: resultType,
+	}
+
+	page := pageFrom(r, "after")
+	var next string
+	switch resultType {
+	case "posts":
+		data.Posts, next, err = app.Repo.SearchPosts(search, page)
+		data.NextPostsPage = nextPageURL(r, "after", next)
+	case "comments":
+		data.Comments, next, err = app.Repo.SearchComments(search, page)
+		data.NextCommentsPage = nextPageURL(r, "after", next)
+	case "users":
+		data.Users, next, err = app.Repo.SearchUsers(search, page)
+		data.NextUsersPage = nextPageURL(r, "after", next)
+	}
+	if err != nil {
+		listError(w, r, "Failed to search "+resultType+":", err)
+		return
 	}
 
 	if hasSession {
 		var avatar sql.NullString
 		var roleID int
-		err = db.QueryRow("SELECT avatar, role_id FROM user WHERE userID = ?", userID).Scan(&avatar, &roleID)
+		avatar, roleID, err = app.Repo.GetUserAvatarAndRole(userID)
 		if err == sql.ErrNoRows {
 			log.Println("No user found with the given ID:", userID)
 			err := ErrorPageData{Code: "404", ErrorMsg: "USER NOT FOUND"}
@@ -182,17 +237,16 @@ func SearchPageHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var roleName string
-		if roleID == 1 {
-			roleName = "Admin"
-		} else if roleID == 2 {
-			roleName = "Moderator"
-		} else {
-			roleName = "User"
+		roleName, err := app.Repo.GetRoleNameByID(roleID)
+		if err != nil {
+			log.Println("Failed to fetch role name:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
 		}
 
 		var totalLikes, totalPosts int
-		err = db.QueryRow("SELECT COUNT(*) FROM likes WHERE user_userID = ?", userID).Scan(&totalLikes)
+		totalLikes, err = app.Repo.GetTotalLikes(userID)
 		if err != nil {
 			log.Println("Failed to fetch total likes:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -200,7 +254,7 @@ func SearchPageHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userID = ?", userID).Scan(&totalPosts)
+		totalPosts, err = app.Repo.GetTotalPosts(userID)
 		if err != nil {
 			log.Println("Failed to fetch total posts:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -208,7 +262,7 @@ func SearchPageHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		notifications, err := database.GetLastNotifications(db, userID)
+		notifications, err := app.Repo.GetLastNotifications(userID)
 		if err != nil {
 			log.Println("Failed to fetch notifications:", err)
 			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -216,15 +270,41 @@ func SearchPageHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		unreadCount, err := app.Repo.CountUnreadNotifications(userID)
+		if err != nil {
+			log.Println("Failed to count unread notifications:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		categories, err := app.Repo.GetAllCategories()
+		if err != nil {
+			log.Println("Failed to fetch categories:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		if err := app.Repo.AttachPostBookmarks(data.Posts, userID); err != nil {
+			log.Println("Failed to fetch bookmarks:", err)
+			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+			ErrHandler(w, r, &err)
+			return
+		}
+
+		data.Categories = categories
+		data.MailEnabled = app.Mail != nil
 		data.Avatar = avatar.String
 		data.RoleName = roleName
 		data.TotalLikes = totalLikes
 		data.TotalPosts = totalPosts
 		data.RoleID = roleID
 		data.Notifications = notifications
+		data.UnreadCount = unreadCount
 	}
 
-	err = templates.ExecuteTemplate(w, "search.html", data)
+	err = templates.ExecuteTemplate(w, "home.html", data)
 	if err != nil {
 		log.Println("Error rendering search page:", err)
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
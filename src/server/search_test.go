@@ -0,0 +1,33 @@
+package server
+
+import (
+	"01connecthub/database"
+	"html/template"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	const o, c = database.SnippetOpen, database.SnippetClose
+	tests := []struct {
+		snippet string
+		want    template.HTML
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{"a " + o + "match" + c + " here", "a <mark>match</mark> here"},
+		{o + "one" + c + " and " + o + "two" + c, "<mark>one</mark> and <mark>two</mark>"},
+		{"x < y & " + o + "z" + c, "x &lt; y &amp; <mark>z</mark>"},
+		{o + "<b>bold</b>" + c + "<script>x()</script>", "<mark>bold</mark>x()"},
+		{"**" + o + "strong" + c + "** words", "<mark>strong</mark> words"},
+		{"[" + o + "link" + c + "](https://example.com)", "<mark>link</mark>"},
+		{"open " + o + "to the end", "open <mark>to the end</mark>"},
+		{"stray" + c + " close", "stray close"},
+		{o + o + "twice" + c + c, "<mark>twice</mark>"},
+		{"…" + o + "cut" + c + "…", "…<mark>cut</mark>…"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.snippet); got != tt.want {
+			t.Errorf("highlight(%q) = %q, want %q", tt.snippet, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"01connecthub/database"
+	"01connecthub/src/security"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionView is a session as listed on the settings and admin pages.
+type SessionView struct {
+	ID        int
+	Device    string
+	IP        string
+	CreatedAt string
+	LastSeen  string
+	ExpiresAt string
+	Current   bool
+}
+
+func sessionViews(sessions []database.UserSession, currentID int) []SessionView {
+	views := make([]SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, SessionView{
+			ID:        s.ID,
+			Device:    deviceName(s.UserAgent),
+			IP:        s.IP,
+			CreatedAt: s.CreatedAt.Local().Format("02/01/2006 - 15:04"),
+			LastSeen:  s.LastSeen.Local().Format("02/01/2006 - 15:04"),
+			ExpiresAt: s.ExpiresAt.Local().Format("02/01/2006 - 15:04"),
+			Current:   s.ID == currentID,
+		})
+	}
+	return views
+}
+
+// deviceName turns a User-Agent header into something like "Firefox on
+// Windows". It only needs to be good enough for people to recognise their own
+// devices.
+func deviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	system := ""
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			system = o.name
+			break
+		}
+	}
+
+	if system == "" {
+		return browser
+	}
+	return browser + " on " + system
+}
+
+// CreateSession signs userID in on the device making r and sets the session
+// cookie. Sessions on the user's other devices stay valid.
+func (app *App) CreateSession(w http.ResponseWriter, r *http.Request, userID int) error {
+	session, err := security.NewSession(r)
+	if err != nil {
+		return err
+	}
+	session.UserID = userID
+	if err := app.Repo.CreateSession(session); err != nil {
+		return err
+	}
+	security.SetSessionCookie(w, session.Token, session.ExpiresAt)
+	return nil
+}
+
+// RevokeSession signs the current user out of one of their devices.
+func (app *App) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	user, ok := actingUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	err = app.Repo.RevokeSession(user.ID, sessionID)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "SESSION NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to revoke session:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	if sessionID == user.SessionID {
+		security.ClearSessionCookie(w)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/settings#sessions", http.StatusSeeOther)
+}
+
+// RevokeAllSessions signs the current user out everywhere, this device
+// included.
+func (app *App) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	user, ok := actingUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.Repo.DeleteSession(user.ID); err != nil {
+		log.Println("Failed to revoke sessions:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	security.ClearSessionCookie(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// SweepSessions deletes expired sessions every interval. Expired sessions are
+// already rejected when used; this only keeps the table from growing.
+func (app *App) SweepSessions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := app.Repo.DeleteExpiredSessions()
+		if err != nil {
+			log.Println("Failed to sweep expired sessions:", err)
+		} else if n > 0 {
+			log.Printf("Removed %d expired sessions", n)
+		}
+		<-ticker.C
+	}
+}
@@ -2,16 +2,18 @@ package server
 
 import (
 	"01connecthub/database"
+	"01connecthub/src/media"
+	"01connecthub/src/permission"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 )
 
-func SettingsPage(w http.ResponseWriter, r *http.Request) {
+func (app *App) SettingsPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/settings" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -19,14 +21,6 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := sql.Open("sqlite3", "./database/main.db")
-	if err != nil {
-		log.Println("Database connection failed")
-		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-		ErrHandler(w, r, &err)
-		return
-	}
-	defer db.Close()
 	var hasSession bool
 	var userID int
 	var userName string
@@ -40,7 +34,8 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 
 		seshVal := seshCok.Value
 
-		err = db.QueryRow("SELECT userid, Username FROM user WHERE current_session = ?", seshVal).Scan(&userID, &userName)
+		sessionUser, err := app.Repo.GetUserBySession(seshVal)
+		userID, userName = sessionUser.ID, sessionUser.Username
 		if err == sql.ErrNoRows {
 			http.SetCookie(w, &http.Cookie{
 				Name:     "session_token",
@@ -60,53 +55,26 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var roleID int
-	err = db.QueryRow("SELECT role_id FROM user WHERE userid = ?", userID).Scan(&roleID)
+	roleID, err = app.Repo.GetUserRoleID(userID)
 	if err != nil {
 		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 		ErrHandler(w, r, &err)
 		return
 	}
 
-	var roleName string
-	if roleID == 1 {
-		roleName = "Admin"
-	} else if roleID == 2 {
-		roleName = "Moderator"
-	} else {
-		roleName = "User"
+	roleName, err := app.Repo.GetRoleNameByID(roleID)
+	if err != nil {
+		log.Println("Failed to fetch role name:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
 	}
 
 	if hasSession {
-		var user database.User
-		err := db.QueryRow("SELECT F_name, L_name, username, email, avatar FROM user WHERE userid = ?", userID).Scan(&user.FirstName, &user.LastName, &user.Username, &user.Email, &user.Avatar)
-		if err != nil {
-			log.Println(err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
-		}
-		var totalLikes, totalPosts int
-		err = db.QueryRow("SELECT COUNT(*) FROM likes WHERE user_userID = ?", userID).Scan(&totalLikes)
-		if err != nil {
-			log.Println(err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
-		}
-
-		err = db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userID = ?", userID).Scan(&totalPosts)
-		if err != nil {
-			log.Println(err)
-			err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &err)
-			return
-		}
-
 		switch r.Method {
 		case "GET":
 
-			var user database.User
-			err := db.QueryRow("SELECT F_name, L_name, username, email, avatar FROM user WHERE userid = ?", userID).Scan(&user.FirstName, &user.LastName, &user.Username, &user.Email, &user.Avatar)
+			user, err := app.Repo.GetUserByID(userID)
 			if err != nil {
 				log.Println("Failed to fetch user data:", err)
 				errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -114,7 +82,7 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			var totalLikes, totalPosts int
-			err = db.QueryRow("SELECT COUNT(*) FROM likes WHERE user_userID = ?", userID).Scan(&totalLikes)
+			totalLikes, err = app.Repo.GetTotalLikes(userID)
 			if err != nil {
 				log.Println("Failed to fetch total likes:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -122,13 +90,62 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			err = db.QueryRow("SELECT COUNT(*) FROM post WHERE user_userID = ?", userID).Scan(&totalPosts)
+			totalPosts, err = app.Repo.GetTotalPosts(userID)
 			if err != nil {
 				log.Println("Failed to fetch total posts:", err)
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 				ErrHandler(w, r, &err)
 				return
 			}
+			notifications, err := app.Repo.GetLastNotifications(userID)
+			if err != nil {
+				log.Println("Failed to fetch notifications:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
+			unreadCount, err := app.Repo.CountUnreadNotifications(userID)
+			if err != nil {
+				log.Println("Failed to count unread notifications:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
+			sessions, err := app.Repo.GetUserSessions(userID)
+			if err != nil {
+				log.Println("Failed to fetch sessions:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+			current, _ := CurrentUserFrom(r)
+
+			tokens, err := app.Repo.GetUserAPITokens(userID)
+			if err != nil {
+				log.Println("Failed to fetch API tokens:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
+			searches, err := app.Repo.GetSavedSearches(userID)
+			if err != nil {
+				log.Println("Failed to fetch saved searches:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
+			categories, err := app.Repo.GetAllCategories()
+			if err != nil {
+				log.Println("Failed to fetch categories:", err)
+				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+				ErrHandler(w, r, &err)
+				return
+			}
+
 			data := struct {
 				HasSession    bool
 				RoleName      string
@@ -141,10 +158,20 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 				Password      string
 				PasswordShown bool
 				Notifications []database.Notification
+				UnreadCount   int
+				CSRFToken     string
+				Perms         permission.Set
 				TotalLikes    int
 				TotalPosts    int
 				SelectedTab   string
 				RoleID        int
+				Sessions      []SessionView
+				APITokens     []TokenView
+				NewToken      string
+				Scopes        []string
+				SavedSearches []SavedSearchView
+				Categories    []database.Category
+				MailEnabled   bool
 			}{
 				HasSession:    hasSession,
 				RoleName:      roleName,
@@ -155,11 +182,21 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 				Email:         user.Email,
 				Avatar:        user.Avatar.String,
 				Password:      "",
-				Notifications: []database.Notification{},
+				Notifications: notifications,
+				UnreadCount:   unreadCount,
+				CSRFToken:     csrfToken(r),
+				Perms:         userPermissions(r),
 				TotalLikes:    totalLikes,
 				TotalPosts:    totalPosts,
 				SelectedTab:   "settings",
 				RoleID:        roleID,
+				Sessions:      sessionViews(sessions, current.SessionID),
+				APITokens:     tokenViews(tokens),
+				NewToken:      app.takeNewToken(current.SessionID),
+				Scopes:        database.Scopes,
+				SavedSearches: savedSearchViews(searches),
+				Categories:    categories,
+				MailEnabled:   app.Mail != nil,
 			}
 
 			err = templates.ExecuteTemplate(w, "settings.html", data)
@@ -170,8 +207,15 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		case "POST":
+			r.Body = http.MaxBytesReader(w, r.Body, maxFileSize+1<<20)
 			err := r.ParseMultipartForm(10 << 20)
-			if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				log.Println("Avatar upload too large")
+				err := ErrorPageData{Code: "400", ErrorMsg: "Image size exceeds 20 MB limit"}
+				ErrHandler(w, r, &err)
+				return
+			} else if err != nil {
 				log.Println("Failed to parse form data")
 				err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
 				ErrHandler(w, r, &err)
@@ -188,20 +232,32 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 			file, handler, err := r.FormFile("avatar")
 			if err == nil {
 				defer file.Close()
-				avatarPath.String = fmt.Sprintf("static/uploads/%s", handler.Filename)
-				avatarPath.Valid = true
-
-				os.MkdirAll("static/uploads", os.ModePerm)
-
-				f, err := os.OpenFile(avatarPath.String, os.O_WRONLY|os.O_CREATE, 0666)
+				if handler.Size > maxFileSize {
+					err := ErrorPageData{Code: "400", ErrorMsg: "Image size exceeds 20 MB limit"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				data, err := io.ReadAll(file)
 				if err != nil {
-					log.Println("Failed to open file for writing")
+					log.Println("Failed to read avatar:", err)
 					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 					ErrHandler(w, r, &err)
 					return
 				}
-				defer f.Close()
-				io.Copy(f, file)
+				hash, err := app.saveImage(data)
+				if errors.Is(err, media.ErrUnsupported) || errors.Is(err, media.ErrTooLarge) {
+					log.Println("Refused avatar upload:", err)
+					err := ErrorPageData{Code: "400", ErrorMsg: "Images must be JPEG, PNG, GIF or WebP of at most 40 megapixels"}
+					ErrHandler(w, r, &err)
+					return
+				} else if err != nil {
+					log.Println("Failed to save avatar:", err)
+					err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+					ErrHandler(w, r, &err)
+					return
+				}
+				avatarPath.String = "/media/" + hash + "?size=thumb"
+				avatarPath.Valid = true
 			} else if err != http.ErrMissingFile {
 				log.Println("Failed to upload avatar")
 				err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -209,10 +265,20 @@ func SettingsPage(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			if password != "" {
-				_, err = db.Exec("UPDATE user SET first_name = ?, last_name = ?, username = ?, email = ?, password = ?, avatar = ? WHERE id = ?", firstName, lastName, username, email, password, avatarPath, userID)
-			} else {
-				_, err = db.Exec("UPDATE user SET first_name = ?, last_name = ?, username = ?, email = ?, avatar = ? WHERE id = ?", firstName, lastName, username, email, avatarPath, userID)
+			err = app.Repo.UpdateUserProfile(database.User{
+				ID:        userID,
+				FirstName: firstName,
+				LastName:  lastName,
+				Username:  username,
+				Email:     email,
+				Avatar:    avatarPath,
+			})
+			if err == nil && password != "" {
+				var hashedPassword string
+				hashedPassword, err = HashPassword(password)
+				if err == nil {
+					err = app.Repo.UpdateUserPassword(userID, hashedPassword)
+				}
 			}
 
 			if err != nil {
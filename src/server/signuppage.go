@@ -1,15 +1,15 @@
 package server
 
 import (
+	"01connecthub/database"
 	"01connecthub/src/security"
 	"database/sql"
 	"log"
 	"net/http"
 	"regexp"
-	"time"
 )
 
-func SignupPage(w http.ResponseWriter, r *http.Request) {
+func (app *App) SignupPage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/signup" {
 		log.Println("Invalid URL path")
 		err := ErrorPageData{Code: "404", ErrorMsg: "PAGE NOT FOUND"}
@@ -29,6 +29,7 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 		if !emailRegex.MatchString(email) {
 			err := templates.ExecuteTemplate(w, "signup.html", map[string]string{
 				"ErrorMessage": "Invalid email format",
+				"CSRFToken":    csrfToken(r),
 			})
 			if err != nil {
 				log.Println("Error rendering signup page:", err)
@@ -41,6 +42,7 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 		if password != confirmPassword {
 			err := templates.ExecuteTemplate(w, "signup.html", map[string]string{
 				"ErrorMessage": "Passwords do not match",
+				"CSRFToken":    csrfToken(r),
 			})
 			if err != nil {
 				log.Println("Error rendering signup page:", err)
@@ -50,17 +52,7 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		db, err := sql.Open("sqlite3", "./database/main.db")
-		if err != nil {
-			log.Println("Database connection failed")
-			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &errData)
-			return
-		}
-		defer db.Close()
-
-		var usernameExists, emailExists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE username = ?)", username).Scan(&usernameExists)
+		usernameExists, err := app.Repo.UsernameExists(username)
 		if err != nil {
 			log.Println("Failed to check if username exists")
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -68,7 +60,7 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE email = ?)", email).Scan(&emailExists)
+		emailExists, err := app.Repo.EmailExists(email)
 		if err != nil {
 			log.Println("Failed to check if email exists")
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
@@ -79,6 +71,7 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 		if usernameExists {
 			err := templates.ExecuteTemplate(w, "signup.html", map[string]string{
 				"ErrorMessage": "Username already exists",
+				"CSRFToken":    csrfToken(r),
 			})
 			if err != nil {
 				log.Println("Error rendering signup page:", err)
@@ -91,6 +84,7 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 		if emailExists {
 			err := templates.ExecuteTemplate(w, "signup.html", map[string]string{
 				"ErrorMessage": "Email already exists",
+				"CSRFToken":    csrfToken(r),
 			})
 			if err != nil {
 				log.Println("Error rendering signup page:", err)
@@ -100,20 +94,10 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Begin transaction for atomic operations
-		tx, err := db.Begin()
-		if err != nil {
-			log.Println("Failed to begin transaction:", err)
-			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &errData)
-			return
-		}
-
 		// Generate session token
-		sessionToken, err := security.GenerateToken()
+		session, err := security.NewSession(r)
 		if err != nil {
 			log.Println("Failed to generate session token:", err)
-			tx.Rollback()
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &errData)
 			return
@@ -122,71 +106,33 @@ func SignupPage(w http.ResponseWriter, r *http.Request) {
 		hashedPassword, _ := HashPassword(password)
 		defaultAvatar := "static/assets/default-avatar.png"
 
-		var roleID int
-		if email == "sayedahmed97.sad@gmail.com" {
-			roleID = 1
-		} else if email == "qassimhassan9@gmail.com" {
-			roleID = 2
-		} else {
-			roleID = 3
+		// Insert user together with its session
+		user := database.User{
+			FirstName: F_name,
+			LastName:  L_name,
+			Username:  username,
+			Email:     email,
+			Password:  hashedPassword,
+			RoleID:    SignupRoleID(app.Config, email),
+			Avatar:    sql.NullString{String: defaultAvatar, Valid: true},
 		}
-
-		// Insert user with session token
-		result, err := tx.Exec("INSERT INTO user (F_name, L_name, Username, Email, password, current_session, role_id, Avatar, provider) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-			F_name, L_name, username, email, hashedPassword, sessionToken.String(), roleID, defaultAvatar, "normal")
+		_, err = app.Repo.InsertUser(user, "normal", session)
 		if err != nil {
 			log.Println("Failed to insert user data:", err)
-			tx.Rollback()
-			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &errData)
-			return
-		}
-
-		// Get user ID for session creation
-		lastID, err := result.LastInsertId()
-		if err != nil {
-			log.Println("Failed to get last insert ID:", err)
-			tx.Rollback()
-			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &errData)
-			return
-		}
-
-		userID := int(lastID)
-
-		// Create session record
-		_, err = tx.Exec("INSERT INTO session (sessionid, userid, endtime) VALUES (?, ?, ?)",
-			sessionToken.String(), userID, time.Now().Add(1*time.Hour))
-		if err != nil {
-			log.Println("Error creating session:", err)
-			tx.Rollback()
-			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
-			ErrHandler(w, r, &errData)
-			return
-		}
-
-		// Commit transaction
-		if err = tx.Commit(); err != nil {
-			log.Println("Failed to commit transaction:", err)
 			errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
 			ErrHandler(w, r, &errData)
 			return
 		}
 
 		// Create session cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_token",
-			Value:    sessionToken.String(),
-			Expires:  time.Now().Add(1 * time.Hour),
-			HttpOnly: true,
-		})
+		security.SetSessionCookie(w, session.Token, session.ExpiresAt)
 
 		// Redirect to home page
 		http.Redirect(w, r, "/home?tab=posts&filter=all", http.StatusSeeOther)
 		return
 	}
 
-	err := templates.ExecuteTemplate(w, "signup.html", nil)
+	err := templates.ExecuteTemplate(w, "signup.html", map[string]string{"CSRFToken": csrfToken(r)})
 	if err != nil {
 		log.Println("Error rendering signup page:", err)
 		errData := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
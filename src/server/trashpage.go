@@ -0,0 +1,112 @@
+package server
+
+import (
+	"01connecthub/database"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TrashPage lists deleted posts, comments and users, most recently deleted
+// first, each with a button to restore it.
+func (app *App) TrashPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not allowed")
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	items, next, err := app.Repo.GetTrash(pageFrom(r, "after"))
+	if err != nil {
+		listError(w, r, "Failed to fetch trash:", err)
+		return
+	}
+
+	data := struct {
+		Items         []database.TrashItem
+		NextPage      string
+		RetentionDays int
+		CSRFToken     string
+	}{
+		Items:         items,
+		NextPage:      nextPageURL(r, "after", next),
+		RetentionDays: app.Config.TrashRetentionDays,
+		CSRFToken:     csrfToken(r),
+	}
+	if err := templates.ExecuteTemplate(w, "trash.html", data); err != nil {
+		log.Println("Error executing template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// RestoreFromTrash takes the post, comment or user named by the kind and id
+// fields back out of the trash.
+func (app *App) RestoreFromTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		log.Println("Method not allowed")
+		err := ErrorPageData{Code: "405", ErrorMsg: "METHOD NOT ALLOWED"}
+		ErrHandler(w, r, &err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		log.Println("Invalid trash item ID")
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	err = app.restore(r.FormValue("kind"), id)
+	if err == sql.ErrNoRows {
+		err := ErrorPageData{Code: "404", ErrorMsg: "NOT FOUND"}
+		ErrHandler(w, r, &err)
+		return
+	} else if errors.Is(err, database.ErrAuthorDeleted) || errors.Is(err, errUnknownTrashKind) {
+		log.Println("Refused to restore:", err)
+		err := ErrorPageData{Code: "400", ErrorMsg: "BAD REQUEST"}
+		ErrHandler(w, r, &err)
+		return
+	} else if err != nil {
+		log.Println("Failed to restore:", err)
+		err := ErrorPageData{Code: "500", ErrorMsg: "INTERNAL SERVER ERROR"}
+		ErrHandler(w, r, &err)
+		return
+	}
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+var errUnknownTrashKind = errors.New("unknown kind of trash")
+
+// restore is RestoreFromTrash for both the page and the API.
+func (app *App) restore(kind string, id int) error {
+	switch kind {
+	case database.TrashPost:
+		return app.Repo.RestorePost(id)
+	case database.TrashComment:
+		return app.Repo.RestoreComment(id)
+	case database.TrashUser:
+		return app.Repo.RestoreUser(id)
+	}
+	return errUnknownTrashKind
+}
+
+// PurgeTrash removes what has been in the trash longer than the retention
+// period, checking every interval until the server stops.
+func (app *App) PurgeTrash(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().AddDate(0, 0, -app.Config.TrashRetentionDays)
+		purged, err := app.Repo.PurgeTrash(cutoff)
+		if err != nil {
+			log.Println("Failed to purge trash:", err)
+		} else if purged != (database.TrashPurge{}) {
+			log.Printf("Purged %d posts, %d comments and %d users from the trash", purged.Posts, purged.Comments, purged.Users)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,127 @@
+// Lists the files picked for a new post, each with a caption box and buttons
+// to move or remove it. The file input is kept in the listed order, so the
+// server pairs the nth file with the nth caption.
+document.addEventListener('DOMContentLoaded', function () {
+    const input = document.getElementById('attachments');
+    const list = document.getElementById('attachment-list');
+    const error = document.getElementById('attachment-error');
+    if (!input || !window.DataTransfer) {
+        return;
+    }
+
+    const maxAttachments = 10;
+    const maxUploadSize = 50 * 1024 * 1024;
+    const limits = [
+        { types: ['image/jpeg', 'image/png', 'image/gif', 'image/webp'], size: 20, name: 'Images' },
+        { types: ['application/pdf'], size: 10, name: 'PDFs' },
+        { types: ['text/plain'], size: 1, name: 'Text files' },
+        { types: ['application/zip', 'application/x-zip-compressed'], size: 20, name: 'Zip archives' },
+    ];
+
+    // Each entry is a file with the caption typed for it so far.
+    let entries = [];
+
+    function check(file) {
+        const limit = limits.find(l => l.types.includes(file.type));
+        if (!limit && !file.type) {
+            // The browser couldn't tell; the server will.
+            return '';
+        }
+        if (!limit) {
+            return file.name + ': only images, PDFs, plain text files and zip archives can be attached.';
+        }
+        if (file.size > limit.size * 1024 * 1024) {
+            return file.name + ': ' + limit.name + ' may be at most ' + limit.size + ' MB.';
+        }
+        return '';
+    }
+
+    function sync() {
+        const transfer = new DataTransfer();
+        entries.forEach(entry => transfer.items.add(entry.file));
+        input.files = transfer.files;
+    }
+
+    function button(icon, label, onClick) {
+        const b = document.createElement('button');
+        b.type = 'button';
+        b.title = label;
+        b.setAttribute('aria-label', label);
+        b.innerHTML = '<i class="fas ' + icon + '"></i>';
+        b.addEventListener('click', onClick);
+        return b;
+    }
+
+    function move(i, by) {
+        const j = i + by;
+        if (j < 0 || j >= entries.length) {
+            return;
+        }
+        [entries[i], entries[j]] = [entries[j], entries[i]];
+        render();
+    }
+
+    function render() {
+        list.replaceChildren();
+        entries.forEach((entry, i) => {
+            const item = document.createElement('li');
+
+            if (entry.file.type.startsWith('image/')) {
+                const img = document.createElement('img');
+                img.src = entry.url;
+                img.alt = '';
+                item.appendChild(img);
+            } else {
+                const icon = document.createElement('span');
+                icon.className = 'attachment-icon';
+                icon.innerHTML = '<i class="fas fa-file"></i>';
+                item.appendChild(icon);
+            }
+
+            const name = document.createElement('span');
+            name.className = 'attachment-name';
+            name.textContent = entry.file.name;
+            item.appendChild(name);
+
+            const caption = document.createElement('input');
+            caption.type = 'text';
+            caption.name = 'attachment_caption';
+            caption.maxLength = 200;
+            caption.placeholder = 'Caption (optional)';
+            caption.value = entry.caption;
+            caption.addEventListener('input', () => { entry.caption = caption.value; });
+            item.appendChild(caption);
+
+            item.appendChild(button('fa-arrow-up', 'Move up', () => move(i, -1)));
+            item.appendChild(button('fa-arrow-down', 'Move down', () => move(i, 1)));
+            item.appendChild(button('fa-times', 'Remove', () => {
+                URL.revokeObjectURL(entry.url);
+                entries.splice(i, 1);
+                render();
+            }));
+            list.appendChild(item);
+        });
+        sync();
+    }
+
+    input.addEventListener('change', function () {
+        // Picking more files adds to the list rather than replacing it.
+        const problems = [];
+        Array.from(input.files).forEach(file => {
+            const problem = check(file);
+            if (problem) {
+                problems.push(problem);
+            } else if (entries.length >= maxAttachments) {
+                problems.push('A post may have at most ' + maxAttachments + ' attachments.');
+            } else {
+                entries.push({ file: file, caption: '', url: URL.createObjectURL(file) });
+            }
+        });
+        const total = entries.reduce((sum, entry) => sum + entry.file.size, 0);
+        if (total > maxUploadSize) {
+            problems.push('Attachments may add up to at most 50 MB.');
+        }
+        error.textContent = Array.from(new Set(problems)).join(' ');
+        render();
+    });
+});
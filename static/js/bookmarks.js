@@ -0,0 +1,43 @@
+// Sends the bookmark buttons in the background and flips them in place,
+// instead of reloading the page.
+(function () {
+    function render(postID, bookmarked) {
+        document.querySelectorAll('form[action="/bookmark"]').forEach(f => {
+            if (f.elements.post_id.value !== postID) {
+                return;
+            }
+            f.elements.state.value = bookmarked ? 'off' : 'on';
+            const button = f.querySelector('button');
+            button.classList.toggle('bookmarked', bookmarked);
+            button.title = bookmarked ? 'Remove from saved' : 'Save for later';
+            // Pages that load Font Awesome's script have swapped the <i> for
+            // an <svg>, so put a fresh icon in rather than editing it.
+            const icon = document.createElement('i');
+            icon.className = (bookmarked ? 'fa-solid' : 'fa-regular') + ' fa-bookmark';
+            button.replaceChildren(icon);
+        });
+    }
+
+    document.addEventListener('submit', function (event) {
+        const form = event.target;
+        if (new URL(form.action, window.location.href).pathname !== '/bookmark' || !window.fetch) {
+            return;
+        }
+        event.preventDefault();
+
+        fetch(form.action, {
+            method: 'POST',
+            body: new FormData(form),
+            headers: { 'Accept': 'application/json' },
+            credentials: 'same-origin',
+        })
+            .then(response => {
+                if (!response.ok) {
+                    throw new Error(response.statusText);
+                }
+                return response.json();
+            })
+            .then(result => render(form.elements.post_id.value, result.data.bookmarked))
+            .catch(() => form.submit());
+    });
+})();
@@ -1,37 +1,33 @@
 document.addEventListener('DOMContentLoaded', function () {
-    const dropdownButtons = document.querySelectorAll('.dropbtn');
-
-    dropdownButtons.forEach(button => {
-        button.addEventListener('click', function (event) {
-            event.stopPropagation();
-            const dropdownContent = this.nextElementSibling;
-
-            const isVisible = dropdownContent.classList.contains('show');
-            document.querySelectorAll('.dropdown-content').forEach(content => {
-                content.classList.remove('show');
-                content.previousElementSibling.setAttribute('aria-expanded', 'false');
-            });
-
-            if (!isVisible) {
-                dropdownContent.classList.add('show');
-                this.setAttribute('aria-expanded', 'true');
-            }
-        });
-    });
-
-    window.addEventListener('click', function () {
+    function closeAll() {
         document.querySelectorAll('.dropdown-content').forEach(content => {
             content.classList.remove('show');
             content.previousElementSibling.setAttribute('aria-expanded', 'false');
         });
+    }
+
+    // Delegated so dropdowns in items added later, such as by load more,
+    // open too.
+    document.addEventListener('click', function (event) {
+        const button = event.target.closest('.dropbtn');
+        if (!button) {
+            closeAll();
+            return;
+        }
+        const dropdownContent = button.nextElementSibling;
+
+        const isVisible = dropdownContent.classList.contains('show');
+        closeAll();
+
+        if (!isVisible) {
+            dropdownContent.classList.add('show');
+            button.setAttribute('aria-expanded', 'true');
+        }
     });
 
     window.addEventListener('keydown', function (e) {
         if (e.key === 'Escape') {
-            document.querySelectorAll('.dropdown-content').forEach(content => {
-                content.classList.remove('show');
-                content.previousElementSibling.setAttribute('aria-expanded', 'false');
-            });
+            closeAll();
         }
     });
-});
\ No newline at end of file
+});
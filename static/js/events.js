@@ -0,0 +1,225 @@
+(function () {
+    if (!window.EventSource) {
+        return;
+    }
+
+    const script = document.currentScript;
+    const postID = script.dataset.post;
+    const viewerID = script.dataset.user;
+    const csrfToken = script.dataset.csrf;
+
+    const params = new URLSearchParams();
+    if (postID) {
+        params.set('post', postID);
+    }
+    if (script.dataset.feed) {
+        params.set('feed', '1');
+    }
+    const query = params.toString();
+    const source = new EventSource('/events' + (query ? '?' + query : ''));
+
+    function setText(selector, value) {
+        document.querySelectorAll(selector).forEach(el => {
+            el.textContent = value;
+        });
+    }
+
+    function setUnread(count) {
+        document.querySelectorAll('.dropdown-toggle').forEach(toggle => {
+            let badge = toggle.querySelector('.notification-badge');
+            if (count > 0) {
+                if (!badge) {
+                    badge = document.createElement('span');
+                    badge.className = 'notification-badge';
+                    toggle.appendChild(badge);
+                }
+                badge.textContent = count;
+            } else if (badge) {
+                badge.remove();
+            }
+        });
+        const markAll = document.querySelector('.mark-all-read');
+        if (markAll) {
+            markAll.closest('form').style.display = count > 0 ? '' : 'none';
+        }
+    }
+
+    function hiddenInput(name, value) {
+        const input = document.createElement('input');
+        input.type = 'hidden';
+        input.name = name;
+        input.value = value;
+        return input;
+    }
+
+    function notificationItem(n) {
+        const item = document.createElement('div');
+        item.className = 'notification-item';
+        item.dataset.notification = n.id;
+
+        const form = document.createElement('form');
+        form.method = 'POST';
+        form.action = '/notifications/read';
+        form.append(hiddenInput('csrf_token', csrfToken), hiddenInput('id', n.id));
+
+        const button = document.createElement('button');
+        button.type = 'submit';
+        button.className = 'dropdown-item unread';
+
+        const img = document.createElement('img');
+        img.src = n.user_image || '/static/assets/default-avatar.png';
+        img.alt = 'User Image';
+        img.className = 'notification-user-image';
+
+        const text = document.createElement('div');
+        text.className = 'notification-user-name';
+        const who = document.createElement('span');
+        who.textContent = '@' + n.user_name;
+        const what = document.createElement('span');
+        let others = '';
+        if (n.others > 0) {
+            others = ' and ' + n.others + ' other' + (n.others > 1 ? 's' : '');
+        }
+        what.textContent = others + ' ' + n.message;
+        text.append(who, what);
+
+        button.append(img, text);
+        form.appendChild(button);
+        item.appendChild(form);
+        return item;
+    }
+
+    source.addEventListener('notification', function (e) {
+        const n = JSON.parse(e.data);
+        document.querySelectorAll('[data-notification="' + n.id + '"]').forEach(el => el.remove());
+
+        document.querySelectorAll('.dropdown-toggle').forEach(toggle => {
+            const heading = toggle.nextElementSibling.querySelector('h2');
+            if (heading) {
+                heading.after(notificationItem(n));
+            }
+        });
+        const list = document.getElementById('notification-list');
+        if (list) {
+            list.prepend(notificationItem(n));
+        }
+        document.querySelectorAll('.no-notifications').forEach(el => el.remove());
+        setUnread(n.unread);
+    });
+
+    source.addEventListener('unread', function (e) {
+        setUnread(JSON.parse(e.data).unread);
+    });
+
+    source.addEventListener('post_counts', function (e) {
+        const c = JSON.parse(e.data);
+        setText('[data-post-likes="' + c.post_id + '"]', c.likes);
+        setText('[data-post-dislikes="' + c.post_id + '"]', c.dislikes);
+        setText('[data-post-comments="' + c.post_id + '"]', c.comments);
+    });
+
+    source.addEventListener('comment_counts', function (e) {
+        const c = JSON.parse(e.data);
+        setText('[data-comment-likes="' + c.comment_id + '"]', c.likes);
+        setText('[data-comment-dislikes="' + c.comment_id + '"]', c.dislikes);
+    });
+
+    function reactionForm(action, comment, rotate, count, attr) {
+        const form = document.createElement('form');
+        form.action = action;
+        form.method = 'POST';
+        form.style.display = 'inline';
+        form.append(hiddenInput('csrf_token', csrfToken), hiddenInput('comment_id', comment.id), hiddenInput('user', viewerID));
+
+        const button = document.createElement('button');
+        button.type = 'submit';
+        const icon = document.createElement('i');
+        icon.className = 'fa-solid fa-arrow-up';
+        if (rotate) {
+            icon.style.rotate = '180deg';
+        }
+        const value = document.createElement('span');
+        value.setAttribute(attr, comment.id);
+        value.textContent = count;
+        button.append(icon, ' ', value);
+        form.appendChild(button);
+        return form;
+    }
+
+    source.addEventListener('comment', function (e) {
+        const c = JSON.parse(e.data);
+        const list = document.getElementById('comment-list');
+        if (!list || String(c.post_id) !== postID || document.querySelector('[data-comment="' + c.id + '"]')) {
+            return;
+        }
+
+        let depth = 0;
+        let before = null;
+        if (c.parent_id) {
+            // Replies go after the last comment nested under their parent,
+            // unless the parent is not shown or its replies are behind a
+            // "continue thread" link.
+            const parent = list.querySelector('.comment[data-comment="' + c.parent_id + '"]');
+            if (!parent || parent.hasAttribute('data-depth-limit')) {
+                return;
+            }
+            depth = Number(parent.dataset.depth) + 1;
+            for (let el = parent.nextElementSibling; el; el = el.nextElementSibling) {
+                if (el.matches('.comment') && Number(el.dataset.depth) < depth) {
+                    before = el;
+                    break;
+                }
+            }
+            // Keep a comment's delete menu, which comes just before it, with it.
+            if (before && before.previousElementSibling && before.previousElementSibling.matches('.post-actions')) {
+                before = before.previousElementSibling;
+            }
+        } else if (list.dataset.thread || document.querySelector('.load-more[data-list="#comment-list"]')) {
+            // Comments run oldest first; a new one belongs after pages not yet
+            // loaded, and not in a single thread at all.
+            return;
+        }
+
+        const comment = document.createElement('div');
+        comment.className = depth ? 'comment reply depth-' + depth : 'comment';
+        comment.dataset.comment = c.id;
+        comment.dataset.depth = depth;
+
+        const header = document.createElement('div');
+        header.className = 'comment-header';
+        const avatar = document.createElement('img');
+        avatar.src = c.avatar || '/static/assets/default-avatar.png';
+        avatar.alt = 'User Avatar';
+        const info = document.createElement('div');
+        info.className = 'comment-info';
+        const name = document.createElement('h1');
+        name.style.cssText = 'margin: 0; font-size: 15px; font-weight: 700; color: var(--secondary-color); font-family: var(--font-family);';
+        name.textContent = c.first_name + ' ' + c.last_name;
+        const username = document.createElement('span');
+        username.style.cssText = 'margin: 5px 0 0; font-size: 12px; color: var(--text-muted-color);';
+        username.textContent = '@' + c.username;
+        info.append(name, username);
+        header.append(avatar, info);
+
+        const content = document.createElement('div');
+        content.className = 'comment-content markdown';
+        // content_html is sanitized on the server.
+        content.innerHTML = c.content_html;
+
+        const actions = document.createElement('div');
+        actions.className = 'comment-actions';
+        const time = document.createElement('time');
+        const clock = document.createElement('i');
+        clock.className = 'fa fa-clock';
+        time.append(clock, ' ' + c.created_at);
+        actions.append(
+            reactionForm('/commentlike', c, false, 0, 'data-comment-likes'),
+            reactionForm('/commentdislike', c, true, 0, 'data-comment-dislikes'),
+            time
+        );
+
+        comment.append(header, content, actions);
+        list.querySelectorAll('.no-comments').forEach(el => el.remove());
+        list.insertBefore(comment, before);
+    });
+})();
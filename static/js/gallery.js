@@ -0,0 +1,67 @@
+// Opens the images of a post's gallery in a lightbox, where the arrow keys or
+// buttons go through them. Without it each image links to its full size file.
+document.addEventListener('DOMContentLoaded', function () {
+    const gallery = document.querySelector('[data-gallery]');
+    if (!gallery) {
+        return;
+    }
+    const links = Array.from(gallery.querySelectorAll('a'));
+
+    const box = document.createElement('div');
+    box.className = 'lightbox';
+    box.hidden = true;
+    box.innerHTML =
+        '<button type="button" class="lightbox-close" aria-label="Close">&times;</button>' +
+        '<button type="button" class="lightbox-prev" aria-label="Previous image">&#8249;</button>' +
+        '<figure><img alt=""><figcaption></figcaption></figure>' +
+        '<button type="button" class="lightbox-next" aria-label="Next image">&#8250;</button>';
+    document.body.appendChild(box);
+
+    const image = box.querySelector('img');
+    const caption = box.querySelector('figcaption');
+    const prev = box.querySelector('.lightbox-prev');
+    const next = box.querySelector('.lightbox-next');
+    prev.hidden = next.hidden = links.length < 2;
+    let current = 0;
+
+    function show(i) {
+        current = (i + links.length) % links.length;
+        const link = links[current];
+        image.src = link.href;
+        image.alt = link.querySelector('img').alt;
+        caption.textContent = link.dataset.caption || '';
+        box.hidden = false;
+    }
+
+    function close() {
+        box.hidden = true;
+        image.removeAttribute('src');
+    }
+
+    links.forEach((link, i) => {
+        link.addEventListener('click', event => {
+            event.preventDefault();
+            show(i);
+        });
+    });
+    prev.addEventListener('click', () => show(current - 1));
+    next.addEventListener('click', () => show(current + 1));
+    box.querySelector('.lightbox-close').addEventListener('click', close);
+    box.addEventListener('click', event => {
+        if (event.target === box) {
+            close();
+        }
+    });
+    document.addEventListener('keydown', event => {
+        if (box.hidden) {
+            return;
+        }
+        if (event.key === 'Escape') {
+            close();
+        } else if (event.key === 'ArrowLeft') {
+            show(current - 1);
+        } else if (event.key === 'ArrowRight') {
+            show(current + 1);
+        }
+    });
+});
@@ -0,0 +1,42 @@
+document.addEventListener('click', function (event) {
+    const link = event.target.closest('a.load-more[data-list]');
+    if (!link) {
+        return;
+    }
+    const list = document.querySelector(link.dataset.list);
+    if (!list) {
+        return;
+    }
+    event.preventDefault();
+    if (link.classList.contains('loading')) {
+        return;
+    }
+    link.classList.add('loading');
+
+    fetch(link.href, { credentials: 'same-origin' })
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(response.statusText);
+            }
+            return response.text();
+        })
+        .then(html => {
+            const page = new DOMParser().parseFromString(html, 'text/html');
+            const items = page.querySelector(link.dataset.list);
+            if (!items) {
+                throw new Error('list missing from next page');
+            }
+            list.append(...items.children);
+
+            const next = page.querySelector('a.load-more[data-list="' + link.dataset.list + '"]');
+            if (next) {
+                link.setAttribute('href', next.getAttribute('href'));
+                link.classList.remove('loading');
+            } else {
+                link.remove();
+            }
+        })
+        .catch(() => {
+            window.location.href = link.href;
+        });
+});
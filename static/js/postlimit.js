@@ -1,22 +1,41 @@
-
 document.addEventListener('DOMContentLoaded', function () {
     const textarea = document.getElementById('content');
     const charCounter = document.getElementById('char-counter');
     const errorMessage = document.getElementById('error-message');
     const submitButton = document.getElementById('submit-button');
-    const charLimit = 500;
+    const categories = document.querySelectorAll('input[name="categories"]');
+
+    // A post may be as long as the strictest of its categories allows, or
+    // the site default when it has none.
+    function charLimit() {
+        let limit = 0;
+        categories.forEach(function (box) {
+            const n = Number(box.dataset.maxLength);
+            if (box.checked && n && (limit === 0 || n < limit)) {
+                limit = n;
+            }
+        });
+        return limit || Number(textarea.dataset.maxLength);
+    }
 
-    textarea.addEventListener('input', function () {
+    function update() {
         const textLength = textarea.value.length;
-        charCounter.textContent = `${textLength}/${charLimit}`;
+        const limit = charLimit();
+        charCounter.textContent = `${textLength}/${limit}`;
 
-        if (textLength > charLimit) {
+        if (textLength > limit) {
+            errorMessage.textContent = "Character limit exceeded!";
             errorMessage.style.display = 'block';
             submitButton.disabled = true;
         } else {
             errorMessage.style.display = 'none';
             submitButton.disabled = false;
         }
+    }
+
+    textarea.addEventListener('input', update);
+    categories.forEach(function (box) {
+        box.addEventListener('change', update);
     });
 
     submitButton.addEventListener('click', function (event) {
@@ -29,4 +48,4 @@ document.addEventListener('DOMContentLoaded', function () {
             textarea.value = trimmedContent;
         }
     });
-});
\ No newline at end of file
+});
@@ -0,0 +1,53 @@
+// Shows how the post being written will look, rendered by the server the same
+// way it will be once posted. Editing the text goes back to the editor.
+document.addEventListener('DOMContentLoaded', function () {
+    const button = document.getElementById('preview-button');
+    const textarea = document.getElementById('content');
+    const preview = document.getElementById('preview');
+    if (!button || !window.fetch) {
+        return;
+    }
+    const form = button.form;
+    const label = button.innerHTML;
+
+    function showEditor() {
+        preview.hidden = true;
+        textarea.hidden = false;
+        button.innerHTML = label;
+    }
+
+    button.addEventListener('click', function () {
+        if (!preview.hidden) {
+            showEditor();
+            return;
+        }
+        const categoryIDs = Array.from(form.querySelectorAll('input[name="categories"]:checked'))
+            .map(box => Number(box.value));
+        fetch('/api/v1/preview', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'X-CSRF-Token': form.elements['csrf_token'].value,
+            },
+            credentials: 'same-origin',
+            body: JSON.stringify({ content: textarea.value, category_ids: categoryIDs }),
+        })
+            .then(response => {
+                if (!response.ok) {
+                    throw new Error(response.statusText);
+                }
+                return response.json();
+            })
+            .then(result => {
+                // The server sanitizes the HTML it renders.
+                preview.innerHTML = result.data.html || '<p class="preview-empty">Nothing to preview yet.</p>';
+                preview.hidden = false;
+                textarea.hidden = true;
+                button.innerHTML = '<i class="fa-regular fa-pen-to-square"></i> Edit';
+            })
+            .catch(() => {
+                preview.textContent = 'The preview could not be loaded.';
+                preview.hidden = false;
+            });
+    });
+});
@@ -0,0 +1,98 @@
+// Sends the reaction forms in the background and updates the counts in
+// place, instead of reloading the page.
+(function () {
+    const forms = {
+        '/like': { id: 'post_id', kind: 'like', attr: 'post' },
+        '/dislike': { id: 'post_id', kind: 'dislike', attr: 'post' },
+        '/commentlike': { id: 'comment_id', kind: 'like', attr: 'comment' },
+        '/commentdislike': { id: 'comment_id', kind: 'dislike', attr: 'comment' },
+    };
+
+    function render(attr, id, state) {
+        document.querySelectorAll('[data-' + attr + '-likes="' + id + '"]').forEach(el => {
+            el.textContent = state.likes;
+        });
+        document.querySelectorAll('[data-' + attr + '-dislikes="' + id + '"]').forEach(el => {
+            el.textContent = state.dislikes;
+        });
+        Object.keys(forms).forEach(action => {
+            const other = forms[action];
+            if (other.attr !== attr) {
+                return;
+            }
+            document.querySelectorAll('form[action="' + action + '"]').forEach(f => {
+                if (f.elements[other.id].value === id) {
+                    f.querySelector('button').classList.toggle('reacted', state.reaction === other.kind);
+                }
+            });
+        });
+        document.querySelectorAll('form.reaction-bar').forEach(f => {
+            if (f.elements.target.value !== attr || f.elements.id.value !== id) {
+                return;
+            }
+            f.querySelectorAll('[data-reaction]').forEach(button => {
+                const kind = button.dataset.reaction;
+                const count = (state.counts && state.counts[kind]) || 0;
+                button.classList.toggle('reacted', state.reaction === kind);
+                if (button.classList.contains('reaction-chip')) {
+                    button.querySelector('.reaction-count').textContent = count;
+                    button.hidden = count === 0;
+                }
+            });
+            const picker = f.querySelector('.reaction-picker');
+            if (picker) {
+                picker.open = false;
+            }
+        });
+    }
+
+    document.addEventListener('submit', function (event) {
+        const form = event.target;
+        const path = new URL(form.action, window.location.href).pathname;
+        let attr, id;
+        if (path === '/react') {
+            attr = form.elements.target.value;
+            id = form.elements.id.value;
+        } else if (forms[path]) {
+            attr = forms[path].attr;
+            id = form.elements[forms[path].id].value;
+        } else {
+            return;
+        }
+        if (!window.fetch) {
+            return;
+        }
+        event.preventDefault();
+
+        // The picker's buttons carry the kind, so the submitter has to be sent
+        // along with the form.
+        const body = new FormData(form);
+        if (event.submitter && event.submitter.name) {
+            body.set(event.submitter.name, event.submitter.value);
+        }
+        fetch(form.action, {
+            method: 'POST',
+            body: body,
+            headers: { 'Accept': 'application/json' },
+            credentials: 'same-origin',
+        })
+            .then(response => {
+                if (!response.ok) {
+                    throw new Error(response.statusText);
+                }
+                return response.json();
+            })
+            .then(result => render(attr, id, result.data))
+            .catch(() => {
+                // form.submit() skips the submitter, so carry its value over.
+                if (event.submitter && event.submitter.name) {
+                    const input = document.createElement('input');
+                    input.type = 'hidden';
+                    input.name = event.submitter.name;
+                    input.value = event.submitter.value;
+                    form.appendChild(input);
+                }
+                form.submit();
+            });
+    });
+})();
@@ -37,7 +37,8 @@ document.addEventListener('DOMContentLoaded', function () {
                     const groupedResults = {
                         user: results.filter(r => r.type === 'user'),
                         category: results.filter(r => r.type === 'category'),
-                        post: results.filter(r => r.type === 'post')
+                        post: results.filter(r => r.type === 'post'),
+                        comment: results.filter(r => r.type === 'comment')
                     };
 
                     Object.entries(groupedResults).forEach(([type, items]) => {
@@ -54,6 +55,12 @@ document.addEventListener('DOMContentLoaded', function () {
                         }
                     });
 
+                    const all = document.createElement('div');
+                    all.className = 'search-suggestion-item search-suggestion-all';
+                    all.textContent = 'See all results';
+                    all.addEventListener('click', () => openResults(query));
+                    suggestionsDiv.appendChild(all);
+
                     suggestionsDiv.style.display = 'block';
                 })
                 .catch(error => {
@@ -72,36 +79,64 @@ document.addEventListener('DOMContentLoaded', function () {
         }
     });
 
+    // Builds a suggestion from text nodes so names and titles can't inject
+    // markup. Only the snippet is HTML, escaped by the server apart from the
+    // <mark> around matched words.
     function createSuggestionItem(result) {
         const div = document.createElement('div');
         div.className = 'search-suggestion-item';
 
-        let icon, content;
+        let icon;
+        if (result.type === 'user' && result.avatar) {
+            icon = document.createElement('img');
+            icon.src = result.avatar;
+            icon.alt = '';
+            icon.className = 'suggestion-avatar';
+        } else {
+            icon = document.createElement('div');
+            icon.className = 'suggestion-icon';
+            const i = document.createElement('i');
+            i.className = {
+                user: 'fas fa-user',
+                category: 'fas fa-tag',
+                post: 'fas fa-file-alt',
+                comment: 'fa-regular fa-message'
+            }[result.type];
+            icon.appendChild(i);
+        }
+
+        const content = document.createElement('div');
+        content.className = 'suggestion-content';
+        const heading = document.createElement('strong');
         switch (result.type) {
             case 'user':
-                icon = result.avatar ?
-                    `<img src="${result.avatar}" alt="" class="suggestion-avatar">` :
-                    `<div class="suggestion-icon"><i class="fas fa-user"></i></div>`;
-                content = `<strong>@${result.username}</strong><br>${result.name}`;
+                heading.textContent = '@' + result.username;
+                content.append(heading, document.createElement('br'), result.name);
                 break;
             case 'category':
-                icon = `<div class="suggestion-icon"><i class="fas fa-tag"></i></div>`;
-                content = result.name;
+                content.append(result.name);
                 break;
             case 'post':
-                icon = `<div class="suggestion-icon"><i class="fas fa-file-alt"></i></div>`;
-                content = `<strong>${result.title}</strong><br>${result.content}`;
+                heading.textContent = result.title;
+                content.appendChild(heading);
+                break;
+            case 'comment':
+                heading.textContent = '@' + result.username + ' on ' + result.title;
+                content.appendChild(heading);
                 break;
         }
+        if (result.snippet) {
+            const snippet = document.createElement('div');
+            snippet.className = 'suggestion-snippet';
+            snippet.innerHTML = result.snippet;
+            content.appendChild(snippet);
+        }
+        const type = document.createElement('div');
+        type.className = 'suggestion-type';
+        type.textContent = result.type;
+        content.appendChild(type);
 
-        div.innerHTML = `
-            ${icon}
-            <div class="suggestion-content">
-                ${content}
-                <div class="suggestion-type">${result.type}</div>
-            </div>
-        `;
-
+        div.append(icon, content);
         div.addEventListener('click', () => handleSuggestionClick(result));
         return div;
     }
@@ -109,7 +144,7 @@ document.addEventListener('DOMContentLoaded', function () {
     function handleSuggestionClick(result) {
         switch (result.type) {
             case 'user':
-                window.location.href = `/profile?id=${result.id}`;
+                window.location.href = `/profile?user=${result.id}`;
                 break;
             case 'category':
                 window.location.href = `/home?tab=tags&filter=${encodeURIComponent(result.name)}`;
@@ -117,9 +152,16 @@ document.addEventListener('DOMContentLoaded', function () {
             case 'post':
                 window.location.href = `/post?id=${result.id}`;
                 break;
+            case 'comment':
+                window.location.href = `/post?id=${result.post_id}&thread=${result.id}`;
+                break;
         }
     }
 
+    function openResults(query) {
+        window.location.href = `/searchpage?q=${encodeURIComponent(query)}`;
+    }
+
     document.addEventListener('click', function (e) {
         if (!searchContainer.contains(e.target)) {
             suggestionsDiv.style.display = 'none';
@@ -131,7 +173,7 @@ document.addEventListener('DOMContentLoaded', function () {
             e.preventDefault();
             const query = searchInput.value.trim();
             if (query) {
-                window.location.href = `/search?q=${encodeURIComponent(query)}`;
+                openResults(query);
             }
         }
     });